@@ -0,0 +1,69 @@
+package zipfs
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptEncoding holds the q-values of the content-codings listed in the
+// Accept-Encoding header of a request, keyed by their lower case names.
+// The key "*" holds the q-value of every coding that is not listed.
+type acceptEncoding map[string]float64
+
+// parseAcceptEncoding parses the Accept-Encoding header of r. Codings
+// with an invalid q-value are left out, as if they were not listed.
+func parseAcceptEncoding(r *http.Request) acceptEncoding {
+	accept := acceptEncoding{}
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, item := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(item, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(param, "=")
+				if !strings.EqualFold(strings.TrimSpace(key), "q") {
+					continue
+				}
+				var err error
+				q, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = -1
+				}
+			}
+			if q >= 0 {
+				accept[name] = q
+			}
+		}
+	}
+	return accept
+}
+
+// quality returns the q-value of encoding, which is 0 if it is not
+// acceptable.
+func (a acceptEncoding) quality(encoding string) float64 {
+	if q, ok := a[encoding]; ok {
+		return q
+	}
+	return a["*"]
+}
+
+// accepts reports whether the client accepts content in encoding.
+func (a acceptEncoding) accepts(encoding string) bool {
+	return a.quality(encoding) > 0
+}
+
+// preferred returns the acceptable encoding with the highest q-value,
+// or "" if none of them is acceptable. Ties go to the earliest one.
+func (a acceptEncoding) preferred(encodings ...string) string {
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		if q := a.quality(encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,44 @@
+package zipfs
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		AcceptEncoding string
+		Accepts        []string
+		Refuses        []string
+		Deflate        string
+	}{
+		{AcceptEncoding: "", Refuses: []string{"gzip", "deflate"}, Deflate: ""},
+		{AcceptEncoding: "gzip, deflate", Accepts: []string{"gzip", "deflate"}, Refuses: []string{"br"}, Deflate: "deflate"},
+		{AcceptEncoding: "GZip;Q=0.5, deflate;q=0", Accepts: []string{"gzip"}, Refuses: []string{"deflate"}, Deflate: "gzip"},
+		{AcceptEncoding: "deflate;q=0.5, gzip", Accepts: []string{"gzip", "deflate"}, Deflate: "gzip"},
+		{AcceptEncoding: "*", Accepts: []string{"gzip", "zstd"}, Deflate: "deflate"},
+		{AcceptEncoding: "*;q=0.1, gzip;q=0", Accepts: []string{"zstd"}, Refuses: []string{"gzip"}, Deflate: "deflate"},
+		{AcceptEncoding: "xgzip, gzip;q=x", Refuses: []string{"gzip"}, Deflate: ""},
+	}
+	for _, tc := range testCases {
+		r := newTestRequest("GET", "/", "", "Accept-Encoding", tc.AcceptEncoding)
+		accept := parseAcceptEncoding(r)
+		for _, encoding := range tc.Accepts {
+			assert.True(accept.accepts(encoding), "%s: %s", tc.AcceptEncoding, encoding)
+		}
+		for _, encoding := range tc.Refuses {
+			assert.False(accept.accepts(encoding), "%s: %s", tc.AcceptEncoding, encoding)
+		}
+		assert.Equal(tc.Deflate, deflateEncoding(r), tc.AcceptEncoding)
+	}
+
+	// Every header line counts
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Add("Accept-Encoding", "br")
+	r.Header.Add("Accept-Encoding", "gzip")
+	assert.True(parseAcceptEncoding(r).accepts("gzip"))
+}
@@ -0,0 +1,110 @@
+package zipfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AccessLogFormat selects the format of the lines of WithAccessLog.
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon is the Common Log Format:
+	// host ident authuser [date] "request" status bytes
+	AccessLogCommon AccessLogFormat = iota
+
+	// AccessLogCombined is the Combined Log Format, which adds the
+	// Referer and User-Agent headers to the Common Log Format.
+	AccessLogCombined
+)
+
+// WithAccessLog writes a line to w for every request once it has been
+// served, in the Common or Combined Log Format used by Apache and nginx,
+// so that the logs can be processed by the usual tools. The host is
+// the RemoteAddr of the request without the port, the user is the one
+// of basic authentication, if any, and the time is the time the request
+// was received. Lines are written whole, one at a time.
+func WithAccessLog(w io.Writer, format AccessLogFormat) Option {
+	return func(h *fileHandler) {
+		h.accessLog = &accessLog{w: w, format: format}
+	}
+}
+
+type accessLog struct {
+	w      io.Writer
+	format AccessLogFormat
+	mutex  sync.Mutex
+}
+
+// accessLogTime is the layout of the time of the Common Log Format.
+const accessLogTime = "02/Jan/2006:15:04:05 -0700"
+
+// withAccessLog serves the request and writes it to the access log.
+func (h *fileHandler) withAccessLog(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := h.now()
+		uri := r.RequestURI
+		if uri == "" {
+			uri = r.URL.RequestURI()
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+
+		user := "-"
+		if name, _, ok := r.BasicAuth(); ok && name != "" {
+			user = escapeAccessLog(name)
+		}
+		host := clientIP(r)
+		if host == "" {
+			host = "-"
+		}
+		size := "-"
+		if sw.written > 0 {
+			size = strconv.FormatInt(sw.written, 10)
+		}
+		line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+			host, user, start.Format(accessLogTime),
+			escapeAccessLog(r.Method), escapeAccessLog(uri), escapeAccessLog(r.Proto),
+			sw.Status(), size)
+		if h.accessLog.format == AccessLogCombined {
+			line += fmt.Sprintf(" \"%s\" \"%s\"", accessLogField(r.Referer()), accessLogField(r.UserAgent()))
+		}
+
+		log := h.accessLog
+		log.mutex.Lock()
+		defer log.mutex.Unlock()
+		io.WriteString(log.w, line+"\n")
+	}
+}
+
+// accessLogField returns a quoted field of the access log, which is "-"
+// if it is empty.
+func accessLogField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return escapeAccessLog(s)
+}
+
+// escapeAccessLog escapes the quotes, backslashes and control characters
+// of s like Apache does, so that a field cannot end early or forge a line.
+func escapeAccessLog(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package zipfs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "test.txt", "hello")
+	clock := &testClock{now: time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("", -7*60*60))}
+	var buf bytes.Buffer
+	h := FileServer(fs, "api/", "", false, nil, nil, WithClock(clock), WithAccessLog(&buf, AccessLogCommon))
+
+	serveTest(fromRemoteAddr(h, "192.0.2.1:51234"), "GET", "/test.txt?v=1", "",
+		"Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("frank:secret")))
+	assert.Equal("192.0.2.1 - frank [05/Mar/2024:14:07:09 -0700] \"GET /test.txt?v=1 HTTP/1.1\" 200 5\n", buf.String())
+
+	buf.Reset()
+	h = FileServer(fs, "api/", "", false, nil, nil, WithClock(clock), WithAccessLog(&buf, AccessLogCombined))
+	serveTest(fromRemoteAddr(h, "[2001:db8::1]:443"), "HEAD", "/missing.txt", "",
+		"Referer", "http://example.com/", "User-Agent", "Agent \"quoted\"\n")
+	assert.Equal("2001:db8::1 - - [05/Mar/2024:14:07:09 -0700] \"HEAD /missing.txt HTTP/1.1\" 404 - "+
+		"\"http://example.com/\" \"Agent \\\"quoted\\\"\\x0a\"\n", buf.String())
+}
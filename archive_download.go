@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+// WithArchiveDownload enables the downloadzip API endpoint, which
+// serves the mounted ZIP files themselves so that mirrors can fetch a
+// whole archive from the server that serves its contents. The archive
+// is selected with the zip query parameter, which may be omitted when
+// only one archive is mounted. Range requests and conditional requests
+// are supported.
+//
+// This exposes every entry of the archive, including any that the file
+// server would not serve itself, so it is disabled by default.
+func WithArchiveDownload() Option {
+	return func(h *fileHandler) {
+		h.archiveDownload = true
+	}
+}
+
+// archiveEtag returns a strong validator for the archive, derived from
+// its size and the names, sizes and CRCs of its entries. It is computed
+// the first time it is asked for, as the archive cannot change.
+func (fs *FileSystem) archiveEtag() string {
+	fs.etagOnce.Do(func() {
+		fs.etag = fs.computeArchiveEtag()
+	})
+	return fs.etag
+}
+
+func (fs *FileSystem) computeArchiveEtag() string {
+	hash := sha256.New()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(fs.size))
+	hash.Write(buf[:])
+	for _, f := range fs.reader.File {
+		io.WriteString(hash, f.Name)
+		binary.LittleEndian.PutUint64(buf[:], f.UncompressedSize64)
+		hash.Write(buf[:])
+		binary.LittleEndian.PutUint32(buf[:4], f.CRC32)
+		hash.Write(buf[:4])
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)[:12])
+}
+
+// Serve a mounted ZIP file for download.
+func (h *fileHandler) DownloadFs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		h.logErrorf("DownloadFs", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+	if !h.archiveDownload {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+
+	mounts, release := h.acquireMounts()
+	defer release()
+
+	var fs *FileSystem
+	zip := r.URL.Query().Get("zip")
+	for _, fse := range mounts {
+		if fse.origin().givenPath == zip || (zip == "" && len(mounts) == 1) {
+			fs = fse
+			break
+		}
+	}
+	if fs == nil || fs.readerAt == nil {
+		http.Error(w, "Zip file not mounted.", http.StatusNotFound)
+		return
+	}
+	if fs.subDir != "" {
+		http.Error(w, "Only part of the zip file is mounted.", http.StatusNotFound)
+		return
+	}
+	if fs.madeUp {
+		http.Error(w, "Only zip files can be downloaded.", http.StatusNotFound)
+		return
+	}
+
+	name := path.Base(fs.givenPath)
+	w.Header().Set("Content-Type", "application/zip")
+	setAttachment(w, name)
+	w.Header().Set("Etag", fs.archiveEtag())
+	http.ServeContent(w, r, name, fs.modTime, io.NewSectionReader(fs.readerAt, 0, fs.size))
+}
@@ -0,0 +1,73 @@
+package zipfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data, err := os.ReadFile("testdata/testdata.zip")
+	require.NoError(err)
+	fs, err := New("testdata/testdata.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	assert.Equal(404, serveTest(handler, "GET", "/api/downloadzip", "").status)
+
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithArchiveDownload())
+	w := serveTest(handler, "GET", "/api/downloadzip", "")
+	assert.Equal(200, w.status)
+	assert.Equal("application/zip", w.Header().Get("Content-Type"))
+	assert.Equal(`attachment; filename=testdata.zip`, w.Header().Get("Content-Disposition"))
+	assert.Equal(data, w.buf.Bytes())
+
+	etag := w.Header().Get("Etag")
+	assert.NotEmpty(etag)
+	assert.Equal(304, serveTest(handler, "GET", "/api/downloadzip", "", "If-None-Match", etag).status)
+
+	w = serveTest(handler, "GET", "/api/downloadzip?zip=testdata/testdata.zip", "", "Range", "bytes=0-3")
+	assert.Equal(206, w.status)
+	assert.Equal(data[:4], w.buf.Bytes())
+
+	assert.Equal(404, serveTest(handler, "GET", "/api/downloadzip?zip=other.zip", "").status)
+
+	// The archive is not closed by serving it
+	f, err := fs.Open("test.html")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	assert.NoError(err)
+}
+
+func TestDownloadFsReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	zipPath := filepath.Join(t.TempDir(), "a.zip")
+	writeTestZip(t, zipPath, "a.txt", "a")
+	fs, err := New(zipPath)
+	require.NoError(err)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithArchiveDownload())
+
+	etag := func() string {
+		w := serveTest(handler, "GET", "/api/downloadzip", "")
+		require.Equal(200, w.status)
+		return w.Header().Get("Etag")
+	}
+	before := etag()
+	assert.Equal(before, etag())
+
+	// A reloaded archive gets a validator of its own
+	writeTestZip(t, zipPath, "a.txt", "b")
+	require.NoError(fs.Reload())
+	assert.NotEqual(before, etag())
+}
@@ -0,0 +1,419 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachePolicy selects which entries are evicted when a cache is full.
+type CachePolicy int
+
+const (
+	// CacheLRU evicts the least recently used entries first.
+	CacheLRU CachePolicy = iota
+
+	// CacheLFU evicts the least frequently used entries first,
+	// and the least recently used of those.
+	CacheLFU
+
+	// CacheTTL expires entries a fixed time after they were added,
+	// and evicts the entries closest to expiry first.
+	CacheTTL
+)
+
+// CacheConfig configures the caches of the file server.
+//
+// The memory cache holds generated content, such as the pages rendered
+// by WithRenderer, and the decompressed contents of compressed entries
+// no larger than DecompressedLimit, so that small files that are
+// requested often are not decompressed for every request. The disk cache holds
+// decompressed copies of entries, which are needed to serve range
+// requests, and compressed copies made for WithZstd. A limit of zero
+// disables the cache.
+// The per-mount limits cap how much of each cache a single archive may
+// use, so that one huge archive cannot evict everything else.
+type CacheConfig struct {
+	Policy            CachePolicy
+	TTL               time.Duration // Lifetime of entries for CacheTTL
+	MemoryLimit       int64         // Size of the memory cache in bytes
+	MountMemoryLimit  int64         // Memory cache bytes per archive, 0 for no limit
+	DecompressedLimit int64         // Largest entry decompressed into the memory cache, 0 for none
+	DiskLimit         int64         // Size of the disk cache in bytes
+	MountDiskLimit    int64         // Disk cache bytes per archive, 0 for no limit
+	DiskDir           string        // Disk cache directory, a temporary directory if empty
+}
+
+// WithCache configures the caches used by the file server.
+func WithCache(cfg CacheConfig) Option {
+	return func(h *fileHandler) {
+		h.memCache = nil
+		h.diskCache = nil
+		h.decompressedMax = cfg.DecompressedLimit
+		if cfg.MemoryLimit > 0 {
+			h.memCache = newCacheStore(cfg.Policy, cfg.TTL, cfg.MemoryLimit, cfg.MountMemoryLimit, "")
+		}
+		if cfg.DiskLimit > 0 {
+			h.diskCache = newCacheStore(cfg.Policy, cfg.TTL, cfg.DiskLimit, cfg.MountDiskLimit, cfg.DiskDir)
+		}
+	}
+}
+
+// cacheKey identifies a cached variant of an entry in an archive.
+type cacheKey struct {
+	fs      *FileSystem
+	name    string
+	variant string
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	data     []byte // Contents, for memory caches
+	path     string // File holding the contents, for disk caches
+	meta     any    // Kept and evicted with the contents, see putWithMeta
+	size     int64
+	added    time.Time
+	lastUsed time.Time
+	hits     int64
+
+	// Positions of the entry in the eviction order of the whole cache
+	// and of its archive, see cacheOrder
+	elements  [2]*list.Element
+	positions [2]int
+}
+
+// cacheStore is a size-bounded cache of entry variants, held either in
+// memory or in files in a directory.
+type cacheStore struct {
+	policy     CachePolicy
+	ttl        time.Duration
+	limit      int64
+	mountLimit int64
+	dir        string
+
+	mutex       sync.Mutex
+	entries     map[cacheKey]*cacheEntry
+	size        int64
+	mountSize   map[*FileSystem]int64
+	order       cacheOrder                 // Every entry
+	mountOrders map[*FileSystem]cacheOrder // The entries of each archive, if mountLimit is set
+
+	hits   atomic.Int64 // Lookups that found an entry, see Stats
+	misses atomic.Int64
+}
+
+func newCacheStore(policy CachePolicy, ttl time.Duration, limit int64, mountLimit int64, dir string) *cacheStore {
+	return &cacheStore{
+		policy:      policy,
+		ttl:         ttl,
+		limit:       limit,
+		mountLimit:  mountLimit,
+		dir:         dir,
+		entries:     map[cacheKey]*cacheEntry{},
+		mountSize:   map[*FileSystem]int64{},
+		order:       newCacheOrder(policy, orderAll),
+		mountOrders: map[*FileSystem]cacheOrder{},
+	}
+}
+
+func (c *cacheStore) expired(e *cacheEntry, now time.Time) bool {
+	return c.policy == CacheTTL && c.ttl > 0 && now.Sub(e.added) >= c.ttl
+}
+
+// get returns the cached data (or file path, for disk caches).
+func (c *cacheStore) get(key cacheKey, now time.Time) ([]byte, string, bool) {
+	e := c.lookup(key, now)
+	if e == nil {
+		return nil, "", false
+	}
+	return e.data, e.path, true
+}
+
+// getWithMeta returns the cached data, and the value kept with it by
+// putWithMeta.
+func (c *cacheStore) getWithMeta(key cacheKey, now time.Time) ([]byte, any, bool) {
+	e := c.lookup(key, now)
+	if e == nil {
+		return nil, nil, false
+	}
+	return e.data, e.meta, true
+}
+
+// lookup returns the entry of key, or nil if it is not cached.
+func (c *cacheStore) lookup(key cacheKey, now time.Time) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e := c.entries[key]
+	if e == nil {
+		c.misses.Add(1)
+		return nil
+	}
+	if c.expired(e, now) {
+		c.removeLocked(e)
+		c.misses.Add(1)
+		return nil
+	}
+	c.hits.Add(1)
+	e.lastUsed = now
+	e.hits++
+	c.order.used(e)
+	if order := c.mountOrders[key.fs]; order != nil {
+		order.used(e)
+	}
+	return e
+}
+
+// put adds an entry to the cache, evicting other entries as needed.
+// It reports whether the entry was added, which it is not if it is
+// already cached or cannot fit.
+func (c *cacheStore) put(key cacheKey, data []byte, path string, size int64, now time.Time) bool {
+	return c.add(&cacheEntry{key: key, data: data, path: path, size: size}, now)
+}
+
+// putWithMeta is put for memory caches, which keeps meta with the data
+// until the entry is evicted.
+func (c *cacheStore) putWithMeta(key cacheKey, data []byte, meta any, now time.Time) bool {
+	return c.add(&cacheEntry{key: key, data: data, meta: meta, size: int64(len(data))}, now)
+}
+
+// add adds the entry e, see put.
+func (c *cacheStore) add(e *cacheEntry, now time.Time) bool {
+	key, size := e.key, e.size
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	tooBig := size > c.limit || (c.mountLimit > 0 && size > c.mountLimit)
+	if _, exists := c.entries[key]; exists || tooBig {
+		return false
+	}
+
+	if c.mountLimit > 0 {
+		for c.mountSize[key.fs]+size > c.mountLimit && c.evictLocked(key.fs) {
+		}
+	}
+	for c.size+size > c.limit && c.evictLocked(nil) {
+	}
+
+	e.added = now
+	e.lastUsed = now
+	c.entries[key] = e
+	c.size += size
+	c.mountSize[key.fs] += size
+	c.order.add(e)
+	if c.mountLimit > 0 {
+		order := c.mountOrders[key.fs]
+		if order == nil {
+			order = newCacheOrder(c.policy, orderMount)
+			c.mountOrders[key.fs] = order
+		}
+		order.add(e)
+	}
+	return true
+}
+
+// evictLocked removes one entry, belonging to fs if fs is not nil,
+// according to the eviction policy. For CacheTTL the entries are
+// evicted in the order they were added, so expired entries are always
+// removed first. It reports whether an entry was removed.
+func (c *cacheStore) evictLocked(fs *FileSystem) bool {
+	order := c.order
+	if fs != nil {
+		order = c.mountOrders[fs]
+		if order == nil {
+			return false
+		}
+	}
+	victim := order.next()
+	if victim == nil {
+		return false
+	}
+	c.removeLocked(victim)
+	return true
+}
+
+func (c *cacheStore) removeLocked(e *cacheEntry) {
+	delete(c.entries, e.key)
+	c.size -= e.size
+	c.mountSize[e.key.fs] -= e.size
+	if c.mountSize[e.key.fs] <= 0 {
+		delete(c.mountSize, e.key.fs)
+	}
+	c.order.remove(e)
+	if order := c.mountOrders[e.key.fs]; order != nil {
+		order.remove(e)
+		if order.next() == nil {
+			delete(c.mountOrders, e.key.fs)
+		}
+	}
+	if e.path != "" {
+		// On Windows this fails while the file is still being served,
+		// in which case the file is left behind in the cache directory.
+		os.Remove(e.path)
+	}
+}
+
+// drop removes all of the entries of an archive that is no longer mounted,
+// including those of the archives it was reloaded from.
+func (c *cacheStore) drop(fs *FileSystem) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, e := range c.entries {
+		if e.key.fs.origin() == fs {
+			c.removeLocked(e)
+		}
+	}
+}
+
+// directory returns the directory holding the files of a disk cache,
+// creating it if necessary.
+func (c *cacheStore) directory() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "zipfs-cache-")
+		if err != nil {
+			return "", err
+		}
+		c.dir = dir
+		return dir, nil
+	}
+	return c.dir, os.MkdirAll(c.dir, 0755)
+}
+
+// openFile opens the cached file for key, calling fill to create it if
+// it is not in the cache. If the new file could not be added to the
+// cache, temporary is true and the caller must remove the file after
+// closing it.
+func (c *cacheStore) openFile(key cacheKey, now time.Time, fill func(io.Writer) error) (file *os.File, temporary bool, err error) {
+	if _, p, ok := c.get(key, now); ok {
+		file, err := os.Open(p)
+		if err == nil {
+			return file, false, nil
+		}
+		// The file was evicted before it could be opened
+	}
+
+	dir, err := c.directory()
+	if err != nil {
+		return nil, false, err
+	}
+	file, err = os.CreateTemp(dir, "entry-")
+	if err != nil {
+		return nil, false, err
+	}
+	size, err := countedFill(file, fill)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, false, err
+	}
+
+	temporary = !c.put(key, nil, file.Name(), size, now)
+	return file, temporary, nil
+}
+
+func countedFill(w io.Writer, fill func(io.Writer) error) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := fill(cw)
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+// serveCachedFile serves the decompressed contents of fi from the disk
+// cache, extracting them first if necessary.
+func (h *fileHandler) serveCachedFile(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, modtime time.Time) {
+	key := cacheKey{fs: fs, name: fi.fullName(), variant: w.Header().Get("Etag")}
+	file, temporary, err := h.diskCache.openFile(key, h.now(), func(dst io.Writer) error {
+		return copyEntry(dst, fi)
+	})
+	if err != nil {
+		recordError(r, fi.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return
+	}
+	defer func() {
+		file.Close()
+		if temporary {
+			os.Remove(file.Name())
+		}
+	}()
+
+	serveRanges(w, r, fi.Name(), modtime, fi.Size(), file)
+}
+
+// copyEntry writes the decompressed contents of fi to dst.
+func copyEntry(dst io.Writer, fi *fileInfo) error {
+	reader, err := fi.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(dst, reader)
+	return err
+}
+
+// serveDecompressed serves the decompressed contents of fi from the
+// memory cache, decompressing them into it first if necessary, and
+// reports whether it did. Entries stored without compression, larger
+// than the DecompressedLimit of the cache, and PHP scripts are not
+// cached.
+func (h *fileHandler) serveDecompressed(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, modtime time.Time) bool {
+	if h.memCache == nil || fi.zipFile.Method == zip.Store || fi.Size() > h.decompressedMax || (h.phpPath != "" && checkForPhp(fi.name)) {
+		return false
+	}
+	key := cacheKey{fs: fs, name: fi.fullName(), variant: "decompressed"}
+	now := h.now()
+	data, _, ok := h.memCache.get(key, now)
+	if !ok {
+		reader, err := fi.open()
+		if err == nil {
+			data, err = io.ReadAll(reader)
+			reader.Close()
+		}
+		if err != nil {
+			recordError(r, fi.name, err)
+			msg, code := toHTTPError(err)
+			httpError(w, r, msg, code)
+			return true
+		}
+		h.memCache.put(key, data, "", int64(len(data)), now)
+	}
+
+	w.Header().Del("Content-Encoding")
+	serveRanges(w, r, fi.Name(), modtime, int64(len(data)), bytes.NewReader(data))
+	return true
+}
+
+// dropCached removes the cached content of an archive that is no
+// longer mounted.
+func (h *fileHandler) dropCached(fs *FileSystem) {
+	if h.memCache != nil {
+		h.memCache.drop(fs)
+	}
+	if h.diskCache != nil {
+		h.diskCache.drop(fs)
+	}
+}
@@ -0,0 +1,52 @@
+package zipfs
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WithCacheControl sets the Cache-Control header of served entries from
+// policy, which maps file extensions such as ".png", content types such
+// as "text/html", or content type wildcards such as "image/*" to header
+// values. Extensions take precedence over content types, and exact
+// content types over wildcards. Headers set by per-directory
+// configuration files or header rules are left alone.
+func WithCacheControl(policy map[string]string) Option {
+	return func(h *fileHandler) {
+		h.cacheControl = map[string]string{}
+		for key, value := range policy {
+			key = strings.ToLower(key)
+			if !strings.Contains(key, "/") && !strings.HasPrefix(key, ".") {
+				key = "." + key
+			}
+			h.cacheControl[key] = value
+		}
+	}
+}
+
+// applyCacheControl sets the Cache-Control header for the entry name
+// from the policy, unless the header has been set already.
+func (h *fileHandler) applyCacheControl(w http.ResponseWriter, name string) {
+	if h.cacheControl == nil || w.Header().Get("Cache-Control") != "" {
+		return
+	}
+	if value, ok := h.cacheControl[strings.ToLower(path.Ext(name))]; ok {
+		w.Header().Set("Cache-Control", value)
+		return
+	}
+	ctype, _, err := mime.ParseMediaType(contentType(w.Header(), name, h.defaultMimeType()))
+	if err != nil {
+		return
+	}
+	if value, ok := h.cacheControl[ctype]; ok {
+		w.Header().Set("Cache-Control", value)
+		return
+	}
+	if major, _, ok := strings.Cut(ctype, "/"); ok {
+		if value, ok := h.cacheControl[major+"/*"]; ok {
+			w.Header().Set("Cache-Control", value)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheControl(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"page.html", "<p>hi</p>",
+		"logo.png", "png",
+		"photo.jpg", "jpg",
+		"app.js", "js",
+		"data.bin", "bin",
+		"fixed/page.html", "<p>fixed</p>",
+		"_headers", "/fixed/*\n  Cache-Control: max-age=60\n",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithHeadersFile(),
+		WithCacheControl(map[string]string{
+			"text/html": "no-cache",
+			"image/*":   "max-age=86400",
+			"PNG":       "max-age=31536000, immutable",
+		}))
+
+	cacheControl := func(target string) string {
+		w := serveTest(handler, "GET", target, "")
+		return w.Header().Get("Cache-Control")
+	}
+
+	assert.Equal("no-cache", cacheControl("/page.html"))
+	assert.Equal("max-age=31536000, immutable", cacheControl("/logo.png"))
+	assert.Equal("max-age=86400", cacheControl("/photo.jpg"))
+	assert.Equal("", cacheControl("/app.js"))
+	assert.Equal("", cacheControl("/data.bin"))
+	assert.Equal("max-age=60", cacheControl("/fixed/page.html"))
+}
@@ -0,0 +1,109 @@
+package zipfs
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// Which of the positions of a cacheEntry a cacheOrder keeps.
+const (
+	orderAll   = 0
+	orderMount = 1
+)
+
+// cacheOrder keeps the entries of a cache, or those of one archive in
+// it, in the order in which they are evicted.
+type cacheOrder interface {
+	add(e *cacheEntry)
+	used(e *cacheEntry) // Called when the entry has been looked up
+	remove(e *cacheEntry)
+	next() *cacheEntry // The entry to evict next, or nil if there are none
+}
+
+func newCacheOrder(policy CachePolicy, slot int) cacheOrder {
+	switch policy {
+	case CacheLFU:
+		return &lfuOrder{slot: slot}
+	case CacheTTL:
+		return &listOrder{slot: slot}
+	default:
+		return &listOrder{slot: slot, moveOnUse: true}
+	}
+}
+
+// listOrder evicts the entries in the order they were added, or, if
+// moveOnUse is set, in the order they were last used.
+type listOrder struct {
+	slot      int
+	moveOnUse bool
+	entries   list.List
+}
+
+func (o *listOrder) add(e *cacheEntry) {
+	e.elements[o.slot] = o.entries.PushBack(e)
+}
+
+func (o *listOrder) used(e *cacheEntry) {
+	if o.moveOnUse {
+		o.entries.MoveToBack(e.elements[o.slot])
+	}
+}
+
+func (o *listOrder) remove(e *cacheEntry) {
+	o.entries.Remove(e.elements[o.slot])
+	e.elements[o.slot] = nil
+}
+
+func (o *listOrder) next() *cacheEntry {
+	if front := o.entries.Front(); front != nil {
+		return front.Value.(*cacheEntry)
+	}
+	return nil
+}
+
+// lfuOrder evicts the least frequently used entries first, and the
+// least recently used of those. It is a min-heap.
+type lfuOrder struct {
+	slot    int
+	entries []*cacheEntry
+}
+
+func (o *lfuOrder) add(e *cacheEntry)    { heap.Push(o, e) }
+func (o *lfuOrder) used(e *cacheEntry)   { heap.Fix(o, e.positions[o.slot]) }
+func (o *lfuOrder) remove(e *cacheEntry) { heap.Remove(o, e.positions[o.slot]) }
+
+func (o *lfuOrder) next() *cacheEntry {
+	if len(o.entries) == 0 {
+		return nil
+	}
+	return o.entries[0]
+}
+
+func (o *lfuOrder) Len() int { return len(o.entries) }
+
+func (o *lfuOrder) Less(i, j int) bool {
+	a, b := o.entries[i], o.entries[j]
+	if a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastUsed.Before(b.lastUsed)
+}
+
+func (o *lfuOrder) Swap(i, j int) {
+	o.entries[i], o.entries[j] = o.entries[j], o.entries[i]
+	o.entries[i].positions[o.slot] = i
+	o.entries[j].positions[o.slot] = j
+}
+
+func (o *lfuOrder) Push(x any) {
+	e := x.(*cacheEntry)
+	e.positions[o.slot] = len(o.entries)
+	o.entries = append(o.entries, e)
+}
+
+func (o *lfuOrder) Pop() any {
+	e := o.entries[len(o.entries)-1]
+	o.entries[len(o.entries)-1] = nil
+	o.entries = o.entries[:len(o.entries)-1]
+	return e
+}
@@ -0,0 +1,185 @@
+package zipfs
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheStoreMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newCacheStore(CacheLRU, 0, 20, 0, "")
+	assert.True(c.putWithMeta(cacheKey{name: "a"}, []byte("aaaaaaaaaa"), "meta of a", now))
+	data, meta, ok := c.getWithMeta(cacheKey{name: "a"}, now)
+	assert.True(ok)
+	assert.Equal("aaaaaaaaaa", string(data))
+	assert.Equal("meta of a", meta)
+
+	// The value is evicted with the data
+	assert.True(c.putWithMeta(cacheKey{name: "b"}, make([]byte, 20), nil, now))
+	_, meta, ok = c.getWithMeta(cacheKey{name: "a"}, now)
+	assert.False(ok)
+	assert.Nil(meta)
+	assert.Len(c.entries, 1)
+}
+
+func TestCacheStorePolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := &FileSystem{}
+	key := func(name string) cacheKey {
+		return cacheKey{fs: fs, name: name}
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	has := func(c *cacheStore, name string) bool {
+		_, _, ok := c.get(key(name), now)
+		return ok
+	}
+
+	// LRU: b is the least recently used when c arrives
+	c := newCacheStore(CacheLRU, 0, 20, 0, "")
+	assert.True(c.put(key("a"), []byte("aaaaaaaaaa"), "", 10, tick()))
+	assert.True(c.put(key("b"), []byte("bbbbbbbbbb"), "", 10, tick()))
+	c.get(key("a"), tick())
+	assert.True(c.put(key("c"), []byte("cccccccccc"), "", 10, tick()))
+	assert.True(has(c, "a"))
+	assert.False(has(c, "b"))
+	assert.True(has(c, "c"))
+
+	// Entries larger than the cache are not added
+	assert.False(c.put(key("d"), make([]byte, 21), "", 21, tick()))
+
+	// LFU: a has been used twice, b never
+	c = newCacheStore(CacheLFU, 0, 20, 0, "")
+	c.put(key("a"), nil, "", 10, tick())
+	c.put(key("b"), nil, "", 10, tick())
+	c.get(key("a"), tick())
+	c.get(key("a"), tick())
+	c.put(key("c"), nil, "", 10, tick())
+	assert.True(has(c, "a"))
+	assert.False(has(c, "b"))
+
+	// LFU: of the entries used as often, the least recently used goes
+	c = newCacheStore(CacheLFU, 0, 30, 0, "")
+	c.put(key("a"), nil, "", 10, tick())
+	c.put(key("b"), nil, "", 10, tick())
+	c.put(key("c"), nil, "", 10, tick())
+	c.get(key("b"), tick())
+	c.get(key("a"), tick())
+	c.get(key("c"), tick())
+	c.get(key("c"), tick())
+	c.put(key("d"), nil, "", 10, tick())
+	assert.False(has(c, "b"))
+	assert.True(has(c, "a"))
+	assert.True(has(c, "c"))
+
+	// TTL: entries expire regardless of use
+	c = newCacheStore(CacheTTL, 10*time.Second, 100, 0, "")
+	c.put(key("a"), nil, "", 10, tick())
+	now = now.Add(5 * time.Second)
+	assert.True(has(c, "a"))
+	now = now.Add(5 * time.Second)
+	assert.False(has(c, "a"))
+	assert.Equal(int64(0), c.size)
+
+	// TTL: the entries closest to expiry are evicted first
+	c = newCacheStore(CacheTTL, time.Hour, 20, 0, "")
+	c.put(key("a"), nil, "", 10, tick())
+	c.put(key("b"), nil, "", 10, tick())
+	c.get(key("a"), tick())
+	c.put(key("c"), nil, "", 10, tick())
+	assert.False(has(c, "a"))
+	assert.True(has(c, "b"))
+	assert.True(has(c, "c"))
+}
+
+func TestCacheStoreMountLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	big := &FileSystem{}
+	small := &FileSystem{}
+	now := time.Now()
+
+	c := newCacheStore(CacheLRU, 0, 100, 30, "")
+	assert.True(c.put(cacheKey{fs: small, name: "s"}, nil, "", 10, now))
+	for _, name := range []string{"1", "2", "3", "4", "5"} {
+		now = now.Add(time.Second)
+		assert.True(c.put(cacheKey{fs: big, name: name}, nil, "", 10, now))
+	}
+
+	// The big archive only evicted its own entries
+	_, _, ok := c.get(cacheKey{fs: small, name: "s"}, now)
+	assert.True(ok)
+	assert.Equal(int64(30), c.mountSize[big])
+	assert.Equal(int64(40), c.size)
+
+	c.drop(big)
+	assert.Equal(int64(10), c.size)
+	assert.Len(c.mountOrders, 1)
+	assert.Equal(1, c.order.(*listOrder).entries.Len())
+}
+
+func TestDiskCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	fs := newTestFileSystem(t, "data.txt", strings.Repeat("0123456789", 10))
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithCache(CacheConfig{
+		DiskLimit: 1024,
+		DiskDir:   dir,
+	}))
+
+	w := serveTest(handler, "GET", "/data.txt", "", "Range", "bytes=10-14")
+	assert.Equal(206, w.status)
+	assert.Equal("01234", w.buf.String())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(err)
+	assert.Len(files, 1)
+
+	w = serveTest(handler, "GET", "/data.txt", "", "Range", "bytes=10-14")
+	assert.Equal(206, w.status)
+	assert.Equal("01234", w.buf.String())
+	files, err = os.ReadDir(dir)
+	require.NoError(err)
+	assert.Len(files, 1)
+}
+
+func TestDecompressedCache(t *testing.T) {
+	assert := assert.New(t)
+
+	small := strings.Repeat("small ", 100)
+	fs := newTestFileSystem(t, "small.css", small, "large.js", strings.Repeat("large ", 1000))
+	h := FileServer(fs, "api/", "", false, nil, nil, WithCache(CacheConfig{
+		MemoryLimit:       1 << 20,
+		DecompressedLimit: 1000,
+	})).(*fileHandler)
+	cached := func(name string) bool {
+		_, _, ok := h.memCache.get(cacheKey{fs: fs, name: name, variant: "decompressed"}, h.now())
+		return ok
+	}
+
+	assert.False(cached("small.css"))
+	assert.Equal(small, serveTest(h, "GET", "/small.css", "").buf.String())
+	assert.True(cached("small.css"))
+	assert.Equal(small, serveTest(h, "GET", "/small.css", "").buf.String())
+	w := serveTest(h, "GET", "/small.css", "", "Range", "bytes=0-4")
+	assert.Equal(http.StatusPartialContent, w.status)
+	assert.Equal("small", w.buf.String())
+
+	// Entries larger than the limit are decompressed for every request
+	assert.Equal(strings.Repeat("large ", 1000), serveTest(h, "GET", "/large.js", "").buf.String())
+	assert.False(cached("large.js"))
+}
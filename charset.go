@@ -0,0 +1,37 @@
+package zipfs
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WithCharsets sets the charset parameter of the Content-Type of files
+// by extension, for archives of sites that are not encoded in UTF-8.
+// For example, {".html": "shift_jis"} serves .html files as
+// "text/html; charset=shift_jis". The keys are extensions including the
+// dot, and are matched ignoring case.
+func WithCharsets(charsets map[string]string) Option {
+	return func(h *fileHandler) {
+		h.charsets = map[string]string{}
+		for ext, charset := range charsets {
+			h.charsets[strings.ToLower(ext)] = charset
+		}
+	}
+}
+
+// applyCharset sets the Content-Type of the entry name with the charset
+// configured for its extension, if there is one.
+func (h *fileHandler) applyCharset(w http.ResponseWriter, name string) {
+	charset, ok := h.charsets[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType(w.Header(), name, h.defaultMimeType()))
+	if err != nil {
+		return
+	}
+	params["charset"] = charset
+	w.Header().Set("Content-Type", mime.FormatMediaType(mediaType, params))
+}
@@ -0,0 +1,31 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharsets(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.HTML", "<p>\x82\xa0</p>",
+		"notes.txt", "text",
+		"game.sjs", "script",
+		"logo.png", "png",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, map[string]string{".sjs": "text/javascript"},
+		WithCharsets(map[string]string{".html": "shift_jis", ".SJS": "shift_jis", ".txt": "euc-jp"}))
+
+	contentType := func(target string) string {
+		w := serveTest(handler, "GET", target, "")
+		return w.Header().Get("Content-Type")
+	}
+
+	assert.Equal("text/html; charset=shift_jis", contentType("/index.HTML"))
+	assert.Equal("text/plain; charset=euc-jp", contentType("/notes.txt"))
+	assert.Equal("text/javascript; charset=shift_jis", contentType("/game.sjs"))
+	assert.Equal("image/png", contentType("/logo.png"))
+}
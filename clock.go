@@ -0,0 +1,50 @@
+package zipfs
+
+import "time"
+
+// Clock is a source of the current time. The file server uses it for
+// everything that depends on the time of day, which allows tests to
+// control time deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock used by default. It returns time.Now.
+var SystemClock Clock = systemClock{}
+
+// WithClock sets the clock used by the file server.
+func WithClock(clock Clock) Option {
+	return func(h *fileHandler) {
+		h.clock = clock
+	}
+}
+
+// timeSource returns the clock used by the file server.
+func (h *fileHandler) timeSource() Clock {
+	if h.clock == nil {
+		return SystemClock
+	}
+	return h.clock
+}
+
+func (h *fileHandler) now() time.Time {
+	return h.timeSource().Now()
+}
+
+// lastModified returns the modification time to report for fi. An
+// origin server must not send a Last-Modified date later than the time
+// of the response (RFC 9110, section 8.8.2.1), so entries with a
+// modification time in the future are reported as modified now.
+func (h *fileHandler) lastModified(fi *fileInfo) time.Time {
+	modtime := fi.ModTime()
+	if now := h.now(); modtime.After(now) {
+		return now
+	}
+	return modtime
+}
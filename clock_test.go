@@ -0,0 +1,39 @@
+package zipfs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestLastModifiedClamp(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "hello.txt", "hello")
+	fi, err := fs.openFileInfo("hello.txt")
+	assert.NoError(err)
+	modtime := fi.ModTime()
+
+	h := &fileHandler{}
+	assert.Equal(modtime, h.lastModified(fi))
+
+	before := modtime.Add(-time.Hour)
+	h = &fileHandler{clock: fixedClock(before)}
+	assert.Equal(before, h.lastModified(fi))
+
+	after := modtime.Add(time.Hour)
+	h = &fileHandler{clock: fixedClock(after)}
+	assert.Equal(modtime, h.lastModified(fi))
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithClock(fixedClock(before)))
+	w := serveTest(handler, "GET", "/hello.txt", "")
+	assert.Equal(before.UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+}
@@ -0,0 +1,64 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"compress/bzip2"
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// Compression methods of Zip entries that archive/zip does not support
+// itself.
+const (
+	methodBzip2 = 12
+	methodZstd  = 93
+)
+
+// decompressor returns a reader of the contents of an entry, which
+// decompress to size bytes, from its compressed data r.
+type decompressor func(r io.Reader, size uint64) (io.ReadCloser, error)
+
+var (
+	decompressors = map[uint16]decompressor{
+		zip.Deflate: func(r io.Reader, size uint64) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+		methodBzip2: func(r io.Reader, size uint64) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+		methodZstd: func(r io.Reader, size uint64) (io.ReadCloser, error) {
+			return decompressZstd(r), nil
+		},
+	}
+	decompressorsMutex sync.RWMutex
+)
+
+// RegisterDecompressor registers dcomp for the entries of all file
+// systems that are compressed with method, in addition to the methods
+// supported by default: store, deflate, bzip2 and zstd. A decompressor
+// for a method that is already supported replaces it. Package zipfs7z
+// registers LZMA and XZ.
+func RegisterDecompressor(method uint16, dcomp zip.Decompressor) {
+	RegisterSizedDecompressor(method, func(r io.Reader, size uint64) (io.ReadCloser, error) {
+		return dcomp(r), nil
+	})
+}
+
+// RegisterSizedDecompressor is like RegisterDecompressor, for methods
+// that need the size of the contents to decompress them, such as LZMA.
+// dcomp returns a reader of the contents, which decompress to size
+// bytes, from their compressed data r.
+func RegisterSizedDecompressor(method uint16, dcomp func(r io.Reader, size uint64) (io.ReadCloser, error)) {
+	decompressorsMutex.Lock()
+	defer decompressorsMutex.Unlock()
+	decompressors[method] = dcomp
+}
+
+// decompressorFor returns the decompressor of entries compressed with
+// method, or nil if the method is not supported.
+func decompressorFor(method uint16) decompressor {
+	decompressorsMutex.RLock()
+	defer decompressorsMutex.RUnlock()
+	return decompressors[method]
+}
@@ -0,0 +1,95 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMethods(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	bz2Content := strings.Repeat("bzip2 compressed entry", 20)
+	bz2Data, _ := hex.DecodeString("425a68393141592653591247d2040000279980400010001e23dc30200070530004d029550c8cd1a6a7a26c4c13c26c4fc4c93d9324d13c26e4e89d9324c1344c13e9344c1344fe2ee48a70a120248fa408")
+
+	// Entries of a method registered with RegisterDecompressor
+	const methodReversed = 0xbeef
+	RegisterDecompressor(methodReversed, func(r io.Reader) io.ReadCloser {
+		data, _ := io.ReadAll(r)
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return io.NopCloser(bytes.NewReader(data))
+	})
+
+	// Entries whose compressed data is followed by padding, which is
+	// cut by the size of the contents
+	const methodPadded = 0xbef0
+	RegisterSizedDecompressor(methodPadded, func(r io.Reader, size uint64) (io.ReadCloser, error) {
+		return io.NopCloser(io.LimitReader(r, int64(size))), nil
+	})
+	defer func() {
+		decompressorsMutex.Lock()
+		delete(decompressors, methodReversed)
+		delete(decompressors, methodPadded)
+		decompressorsMutex.Unlock()
+	}()
+
+	entries := []struct {
+		name    string
+		method  uint16
+		content string
+		data    []byte
+	}{
+		{"bzip2.txt", methodBzip2, bz2Content, bz2Data},
+		{"reversed.txt", methodReversed, "reversed", []byte("desrever")},
+		{"padded.txt", methodPadded, "padded", []byte("padded----")},
+		{"unknown.txt", 0xbeee, "unknown", []byte("unknown")},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               e.name,
+			Method:             e.method,
+			CRC32:              crc32.ChecksumIEEE([]byte(e.content)),
+			CompressedSize64:   uint64(len(e.data)),
+			UncompressedSize64: uint64(len(e.content)),
+		})
+		require.NoError(err)
+		w.Write(e.data)
+	}
+	require.NoError(zw.Close())
+
+	fs, err := NewFromBytes(buf.Bytes(), "methods.zip")
+	require.NoError(err)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	for _, e := range entries {
+		w := serveTest(handler, "GET", "/"+e.name, "")
+		if e.name == "unknown.txt" {
+			assert.Equal(500, w.status)
+			continue
+		}
+		assert.Equal(200, w.status, e.name)
+		assert.Equal(e.content, w.buf.String(), e.name)
+	}
+
+	// The contents are checked against their CRC
+	data := bytes.Replace(buf.Bytes(), []byte("desrever"), []byte("DESREVER"), 1)
+	damaged, err := NewFromBytes(data, "damaged.zip")
+	require.NoError(err)
+	defer damaged.Close()
+	f, err := damaged.Open("reversed.txt")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	assert.Equal(errChecksum, err)
+}
@@ -0,0 +1,95 @@
+package zipfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// WithContentHashEtags computes the SHA-256 hash of the contents of every
+// entry of the served archives in the background, using up to workers
+// goroutines, or one per CPU if workers is not positive, and serves the
+// entries with ETags derived from it instead of from their CRC-32 and
+// size. The hashes are computed for the archives given to the
+// constructor, and for the ones mounted or reloaded later. Entries are
+// served with the CRC-derived ETag until their hash is known, so their
+// ETag changes once, and encrypted entries keep it unless a password is
+// set.
+func WithContentHashEtags(workers int) Option {
+	return func(h *fileHandler) {
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		h.hashWorkers = workers
+		h.etagAlgorithm = EtagSHA256
+	}
+}
+
+// hashContents computes the content hashes of the entries of the
+// mounted archive fs, see WithContentHashEtags. It stops when fs is
+// unmounted or reloaded.
+func (h *fileHandler) hashContents(fs *FileSystem) {
+	if h.etagAlgorithm != EtagSHA256 || h.etagFunc != nil {
+		return
+	}
+	gen := fs.current()
+	var entries []*fileInfo
+	seen := map[*fileInfo]bool{}
+	for _, fi := range gen.fileInfos {
+		if fi.zipFile != nil && !fi.IsDir() && !seen[fi] && fi.checkPassword() == nil {
+			seen[fi] = true
+			entries = append(entries, fi)
+		}
+	}
+
+	jobs := make(chan *fileInfo)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < h.hashWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fi := range jobs {
+				if !h.holdMounted(fs, gen) {
+					stopOnce.Do(func() { close(stop) })
+					continue
+				}
+				err := fi.hashContents()
+				gen.inFlight.Done()
+				if err != nil {
+					h.logErrorf("hashContents", "%s: %w", fi.name, err)
+				}
+			}
+		}()
+	}
+feed:
+	for _, fi := range entries {
+		select {
+		case jobs <- fi:
+		case <-stop:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// hashContents computes the SHA-256 hash of the contents of fi and
+// keeps it for entryEtag.
+func (fi *fileInfo) hashContents() error {
+	reader, err := fi.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return err
+	}
+	var sum [sha256.Size]byte
+	hash.Sum(sum[:0])
+	fi.contentHash.Store(&sum)
+	return nil
+}
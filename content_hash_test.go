@@ -0,0 +1,32 @@
+package zipfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentHashEtags(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.txt", "hashed", "other.txt", "other")
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithContentHashEtags(2))
+
+	want := fmt.Sprintf(`"sha256-%x"`, sha256.Sum256([]byte("hashed")))
+	assert.Eventually(func() bool {
+		return serveTest(handler, "GET", "/page.txt", "").Header().Get("Etag") == want
+	}, 5*time.Second, 10*time.Millisecond)
+	w := serveTest(handler, "GET", "/page.txt", "", "If-None-Match", want)
+	assert.Equal(http.StatusNotModified, w.status)
+	w = serveTest(handler, "GET", "/page.txt", "", "If-None-Match", calcEtag(fs.fileInfos["page.txt"].zipFile))
+	assert.Equal(http.StatusOK, w.status)
+	assert.Equal("hashed", w.buf.String())
+
+	// Without the option, the ETag is derived from the CRC
+	w = serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/page.txt", "")
+	assert.Equal(calcEtag(fs.fileInfos["page.txt"].zipFile), w.Header().Get("Etag"))
+}
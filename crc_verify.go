@@ -0,0 +1,124 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+)
+
+// WithCRCVerification checks the CRC-32 of entries that are served in
+// full against the one recorded in the archive while they are sent. The
+// last chunk of an entry is only sent once its CRC has been checked, so
+// if the entry is damaged, the error is logged and the connection is
+// aborted, and the client sees an incomplete response rather than
+// corrupted data. Range requests and entries without a CRC, such as
+// AE-2 encrypted entries, are not checked.
+func WithCRCVerification(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.verifyCRC = enabled
+	}
+}
+
+// verifyingReader checks the CRC-32 and size of the data read from r.
+// The read that completes the data fails instead of returning the last
+// bytes if they do not match.
+type verifyingReader struct {
+	r    io.Reader
+	hash hash.Hash32
+	crc  uint32
+	size uint64
+	read uint64
+}
+
+func newVerifyingReader(r io.Reader, crc uint32, size uint64) *verifyingReader {
+	return &verifyingReader{r: r, hash: crc32.NewIEEE(), crc: crc, size: size}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	v.read += uint64(n)
+	if v.read > v.size || v.read == v.size && v.hash.Sum32() != v.crc {
+		return 0, errChecksum
+	}
+	if err == io.EOF && v.read < v.size {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// verifies reports whether the contents of fi are checked while it is
+// served.
+func (h *fileHandler) verifies(fi *fileInfo) bool {
+	return h.verifyCRC && fi.zipFile.CRC32 != 0
+}
+
+// verified returns reader, which reads the contents of fi, wrapped in a
+// verifyingReader if the contents are checked.
+func (h *fileHandler) verified(fi *fileInfo, reader io.Reader) io.Reader {
+	if !h.verifies(fi) {
+		return reader
+	}
+	return newVerifyingReader(reader, fi.zipFile.CRC32, fi.zipFile.UncompressedSize64)
+}
+
+// isCorrupt reports whether err was caused by damaged contents of an
+// entry.
+func isCorrupt(err error) bool {
+	return errors.Is(err, errChecksum) || errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat)
+}
+
+// abortCorrupt logs that fi failed verification while it was being
+// served and aborts the response, so that the client does not mistake
+// what it was sent for the whole entry.
+func (h *fileHandler) abortCorrupt(r *http.Request, fi *fileInfo, err error) {
+	h.logErrorf("verifyCRC", "%s: %w", fi.name, err)
+	recordError(r, fi.name, err)
+	panic(http.ErrAbortHandler)
+}
+
+// deflateVerifier checks the CRC-32 of the deflated data written to it
+// by decompressing it in the background.
+type deflateVerifier struct {
+	pw     *io.PipeWriter
+	done   chan error
+	closed bool
+	err    error
+}
+
+func newDeflateVerifier(crc uint32, size uint64) *deflateVerifier {
+	pr, pw := io.Pipe()
+	v := &deflateVerifier{pw: pw, done: make(chan error, 1)}
+	go func() {
+		fr := flate.NewReader(pr)
+		_, err := io.Copy(io.Discard, newVerifyingReader(fr, crc, size))
+		fr.Close()
+		if err != nil {
+			// Writes of the rest of a damaged stream fail
+			pr.CloseWithError(err)
+		} else {
+			io.Copy(io.Discard, pr)
+		}
+		v.done <- err
+	}()
+	return v
+}
+
+func (v *deflateVerifier) Write(b []byte) (int, error) {
+	return v.pw.Write(b)
+}
+
+// Close waits for all of the data to be decompressed and returns the
+// error, if any, found in it.
+func (v *deflateVerifier) Close() error {
+	if !v.closed {
+		v.closed = true
+		v.pw.Close()
+		v.err = <-v.done
+	}
+	return v.err
+}
@@ -0,0 +1,40 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRCVerification(t *testing.T) {
+	assert := assert.New(t)
+
+	long := strings.Repeat("the quick brown fox jumps over the lazy dog ", 5000)
+	fs := newTestFileSystem(t, "deflated.txt", long, "good.txt", long)
+	defer fs.Close()
+
+	// Damage the recorded CRC, as if the contents were corrupted
+	fs.fileInfos["deflated.txt"].zipFile.CRC32 ^= 1
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithCompression(true))
+	w, aborted := serveAborted(handler, "GET", "/deflated.txt", "")
+	assert.False(aborted)
+	assert.Equal(len(long), w.buf.Len())
+
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithCompression(true), WithCRCVerification(true))
+	for _, encoding := range []string{"", "deflate", "gzip"} {
+		w, aborted = serveAborted(handler, "GET", "/deflated.txt", "", "Accept-Encoding", encoding)
+		assert.True(aborted, encoding)
+		assert.True(w.buf.Len() < len(long), encoding)
+
+		w, aborted = serveAborted(handler, "GET", "/good.txt", "", "Accept-Encoding", encoding)
+		assert.False(aborted, encoding)
+		assert.Equal(200, w.status, encoding)
+	}
+
+	w, aborted = serveAborted(handler, "GET", "/good.txt", "")
+	assert.Equal(long, w.buf.String())
+	assert.Equal(uint16(zip.Deflate), fs.fileInfos["good.txt"].zipFile.Method)
+}
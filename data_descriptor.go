@@ -0,0 +1,96 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const dataDescriptorSig = 0x08074b50
+
+// dataDescriptor holds the CRC and sizes of an entry that were written
+// after its data, by tools that stream Zip files and so only know them
+// once the data is written.
+type dataDescriptor struct {
+	crc              uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+	end              int64 // Offset of the end of the descriptor
+}
+
+// readDataDescriptor reads the data descriptor at pos of an entry whose
+// data is compressed to compressed bytes. The signature of the
+// descriptor is optional, and its sizes can take 4 or 8 bytes, so it is
+// only ok if the compressed size it holds is the expected one.
+func readDataDescriptor(r io.ReaderAt, pos int64, compressed uint64) (dataDescriptor, bool) {
+	buf := make([]byte, 24)
+	n, _ := r.ReadAt(buf, pos)
+	buf = buf[:n]
+	if len(buf) >= 4 && binary.LittleEndian.Uint32(buf) == dataDescriptorSig {
+		if d, ok := parseDataDescriptor(buf[4:], compressed); ok {
+			d.end += pos + 4
+			return d, true
+		}
+	}
+	d, ok := parseDataDescriptor(buf, compressed)
+	d.end += pos
+	return d, ok
+}
+
+// parseDataDescriptor parses the fields of a data descriptor after its
+// signature, with 4 byte sizes if they match compressed or 8 byte ones
+// otherwise. The end is relative to the start of buf.
+func parseDataDescriptor(buf []byte, compressed uint64) (dataDescriptor, bool) {
+	le := binary.LittleEndian
+	switch {
+	case len(buf) >= 12 && uint64(le.Uint32(buf[4:])) == compressed:
+		return dataDescriptor{le.Uint32(buf), compressed, uint64(le.Uint32(buf[8:])), 12}, true
+	case len(buf) >= 20 && le.Uint64(buf[4:]) == compressed:
+		return dataDescriptor{le.Uint32(buf), compressed, le.Uint64(buf[12:]), 20}, true
+	}
+	return dataDescriptor{}, false
+}
+
+// findDataDescriptor looks for the data descriptor of an entry whose
+// data starts at dataStart but whose size is not known, by looking for
+// a descriptor signature followed by the distance to dataStart.
+func findDataDescriptor(r io.ReaderAt, size int64, dataStart int64) (dataDescriptor, bool) {
+	for pos := findSignature(r, size, dataStart, dataDescriptorSig); pos < size; pos = findSignature(r, size, pos+1, dataDescriptorSig) {
+		if d, ok := readDataDescriptor(r, pos, uint64(pos-dataStart)); ok {
+			return d, true
+		}
+	}
+	return dataDescriptor{}, false
+}
+
+// reconcileDataDescriptor makes the CRC and sizes of zf, which archive/zip
+// takes from the central directory, agree with those of its data
+// descriptor. Some streaming tools write a central directory that
+// leaves them zero or gets them wrong, in which case the descriptor,
+// written right after the data, is the one to trust. Otherwise the
+// Content-Length and ETag of the entry would be wrong, and reading it
+// would fail its checksum.
+func reconcileDataDescriptor(r io.ReaderAt, size int64, zf *zip.File) {
+	if zf.Flags&0x8 == 0 || strings.HasSuffix(zf.Name, "/") {
+		return
+	}
+	dataStart, err := zf.DataOffset()
+	if err != nil {
+		return
+	}
+	d, ok := readDataDescriptor(r, dataStart+int64(zf.CompressedSize64), zf.CompressedSize64)
+	if !ok {
+		if d, ok = findDataDescriptor(r, size, dataStart); !ok {
+			return
+		}
+	}
+	if d.crc == zf.CRC32 && d.compressedSize == zf.CompressedSize64 && d.uncompressedSize == zf.UncompressedSize64 {
+		return
+	}
+	zf.CRC32 = d.crc
+	zf.CompressedSize64 = d.compressedSize
+	zf.UncompressedSize64 = d.uncompressedSize
+	zf.CompressedSize = uint32(min(d.compressedSize, uint32Max))
+	zf.UncompressedSize = uint32(min(d.uncompressedSize, uint32Max))
+}
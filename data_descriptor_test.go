@@ -0,0 +1,96 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataDescriptors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// archive/zip writes the CRC and sizes of entries in data
+	// descriptors, and copies them to the central directory
+	long := strings.Repeat("streamed ", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"crc.txt", "sizes.txt", "intact.txt"} {
+		w, err := zw.Create(name)
+		require.NoError(err)
+		w.Write([]byte(long))
+	}
+	require.NoError(zw.Close())
+	intact, err := NewFromBytes(buf.Bytes(), "intact.zip")
+	require.NoError(err)
+	want := intact.fileInfos["intact.txt"].zipFile.FileHeader
+	assert.NotZero(want.Flags & 0x8)
+
+	// Make the central directory disagree with the descriptors
+	data := buf.Bytes()
+	le := binary.LittleEndian
+	for pos := 0; ; pos++ {
+		i := bytes.Index(data[pos:], []byte("PK\x01\x02"))
+		if i < 0 {
+			break
+		}
+		pos += i
+		switch string(data[pos+46 : pos+46+int(le.Uint16(data[pos+28:]))]) {
+		case "crc.txt":
+			le.PutUint32(data[pos+16:], 0)
+		case "sizes.txt":
+			le.PutUint32(data[pos+16:], 0)
+			le.PutUint32(data[pos+20:], 0)
+			le.PutUint32(data[pos+24:], 12)
+		}
+	}
+	fs, err := NewFromBytes(data, "streamed.zip")
+	require.NoError(err)
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	for _, name := range []string{"crc.txt", "sizes.txt", "intact.txt"} {
+		zf := fs.fileInfos[name].zipFile
+		assert.Equal(want.CRC32, zf.CRC32, name)
+		assert.Equal(want.CompressedSize64, zf.CompressedSize64, name)
+		assert.Equal(want.UncompressedSize64, zf.UncompressedSize64, name)
+
+		f, err := fs.Open(name)
+		require.NoError(err)
+		got, err := io.ReadAll(f)
+		assert.NoError(err, name)
+		assert.Equal(long, string(got), name)
+		f.Close()
+
+		w := serveTest(handler, "GET", "/"+name, "")
+		assert.Equal(strconv.Itoa(len(long)), w.Header().Get("Content-Length"), name)
+		assert.Equal(calcEtag(intact.fileInfos["intact.txt"].zipFile), w.Header().Get("Etag"), name)
+	}
+}
+
+func TestReadDataDescriptor(t *testing.T) {
+	assert := assert.New(t)
+
+	le := binary.LittleEndian
+	withSig := le.AppendUint32(nil, dataDescriptorSig)
+	withSig = le.AppendUint32(withSig, 0xabcd)
+	withSig = le.AppendUint32(withSig, 10)
+	withSig = le.AppendUint32(withSig, 20)
+	d, ok := readDataDescriptor(bytes.NewReader(withSig), 0, 10)
+	assert.True(ok)
+	assert.Equal(dataDescriptor{0xabcd, 10, 20, 16}, d)
+	_, ok = readDataDescriptor(bytes.NewReader(withSig), 0, 11)
+	assert.False(ok)
+
+	zip64 := le.AppendUint32(nil, 0xabcd)
+	zip64 = le.AppendUint64(zip64, 5<<30)
+	zip64 = le.AppendUint64(zip64, 6<<30)
+	d, ok = readDataDescriptor(bytes.NewReader(zip64), 0, 5<<30)
+	assert.True(ok)
+	assert.Equal(dataDescriptor{0xabcd, 5 << 30, 6 << 30, 20}, d)
+}
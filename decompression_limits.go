@@ -0,0 +1,115 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DecompressionLimits protects against hostile archives, such as zip
+// bombs, whose entries decompress to far more data than they take up in
+// the archive. Zero values mean no limit.
+type DecompressionLimits struct {
+	// MaxRatio is the largest ratio of the decompressed size of an
+	// entry to its compressed size. Entries over it are answered with
+	// 403 Forbidden.
+	MaxRatio float64
+
+	// MaxEntrySize is the largest decompressed size of an entry that is
+	// served. Entries over it are answered with 413 Content Too Large.
+	MaxEntrySize int64
+
+	// MaxRequestBytes is the most bytes that serving a request may
+	// decompress. Range requests for compressed entries decompress the
+	// entry from the start for every range, so a request with many
+	// ranges can decompress many times the size of the entry. Requests
+	// over it are answered with 413 Content Too Large.
+	MaxRequestBytes int64
+}
+
+var (
+	errCompressionRatio = errors.New("compression ratio exceeds limit")
+	errEntryTooLarge    = errors.New("decompressed size exceeds limit")
+)
+
+// WithDecompressionLimits rejects requests for entries that exceed the
+// limits. The decompressed size of an entry is the size recorded for it
+// in the archive, which reading the entry never goes past.
+func WithDecompressionLimits(limits DecompressionLimits) Option {
+	return func(h *fileHandler) {
+		h.limits = &limits
+	}
+}
+
+// checkLimits responds with an error if serving fi to r would exceed the
+// decompression limits, and reports whether the request may proceed.
+func (h *fileHandler) checkLimits(w http.ResponseWriter, r *http.Request, fi *fileInfo) bool {
+	if h.limits == nil || fi.zipFile == nil {
+		return true
+	}
+	size := fi.Size()
+	compressed := int64(fi.zipFile.CompressedSize64)
+
+	var err error
+	var code int
+	switch {
+	case h.limits.MaxRatio > 0 && size > 0 && (compressed == 0 || float64(size)/float64(compressed) > h.limits.MaxRatio):
+		err = fmt.Errorf("%w: %d bytes compressed to %d", errCompressionRatio, size, compressed)
+		code = http.StatusForbidden
+	case h.limits.MaxEntrySize > 0 && size > h.limits.MaxEntrySize:
+		err = fmt.Errorf("%w: %d bytes", errEntryTooLarge, size)
+		code = http.StatusRequestEntityTooLarge
+	case h.limits.MaxRequestBytes > 0 && decompressionCost(r, fi) > h.limits.MaxRequestBytes:
+		err = fmt.Errorf("%w: request decompresses %d bytes", errEntryTooLarge, decompressionCost(r, fi))
+		code = http.StatusRequestEntityTooLarge
+	default:
+		return true
+	}
+	h.logErrorf("checkLimits", "%s: %w", fi.name, err)
+	recordError(r, fi.name, err)
+	httpError(w, r, http.StatusText(code), code)
+	return false
+}
+
+// decompressionCost returns the number of bytes that serving fi to r
+// decompresses at most. Entries stored without compression are read in
+// place, so only the requested ranges count.
+func decompressionCost(r *http.Request, fi *fileInfo) int64 {
+	size := fi.Size()
+	rangeReq := r.Header.Get("Range")
+	if !strings.HasPrefix(rangeReq, "bytes=") {
+		return size
+	}
+	stored := fi.zipFile.Method == zip.Store && !fi.encrypted()
+
+	var cost int64
+	for _, spec := range strings.Split(rangeReq[len("bytes="):], ",") {
+		start, end, ok := strings.Cut(strings.TrimSpace(spec), "-")
+		if !ok {
+			continue
+		}
+		first, last := int64(0), size-1
+		if start == "" {
+			// The last bytes of the entry
+			if n, err := strconv.ParseInt(end, 10, 64); err == nil && n < size {
+				first = size - n
+			}
+		} else {
+			if n, err := strconv.ParseInt(start, 10, 64); err == nil && n < size {
+				first = n
+			}
+			if n, err := strconv.ParseInt(end, 10, 64); err == nil && n < last {
+				last = n
+			}
+		}
+		if stored {
+			cost += last - first + 1
+		} else {
+			cost += last + 1
+		}
+	}
+	return cost
+}
@@ -0,0 +1,70 @@
+package zipfs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompressionLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	var big strings.Builder
+	for i := 0; big.Len() < 160000; i++ {
+		fmt.Fprintf(&big, "%d,", i*7919)
+	}
+	fs := newTestFileSystem(t,
+		"bomb.txt", strings.Repeat("0", 1000000),
+		"big.txt", big.String(),
+		"small.txt", "small",
+	)
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithDecompressionLimits(DecompressionLimits{
+		MaxRatio:        100,
+		MaxEntrySize:    150000,
+		MaxRequestBytes: 500000,
+	}))
+
+	assert.Equal(403, serveTest(handler, "GET", "/bomb.txt", "").status)
+	assert.Equal(413, serveTest(handler, "GET", "/big.txt", "").status)
+	assert.Equal("small", serveTest(handler, "GET", "/small.txt", "").buf.String())
+
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithDecompressionLimits(DecompressionLimits{
+		MaxRequestBytes: 500000,
+	}))
+	assert.Equal(200, serveTest(handler, "GET", "/big.txt", "").status)
+	assert.Equal(206, serveTest(handler, "GET", "/big.txt", "", "Range", "bytes=150000-159999").status)
+	assert.Equal(413, serveTest(handler, "GET", "/big.txt", "", "Range", "bytes=150000-150001,150002-150003,150004-150005,150006-150007").status)
+}
+
+func TestDecompressionCost(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "file.txt", strings.Repeat("x", 1000))
+	defer fs.Close()
+	fi := fs.fileInfos["file.txt"]
+
+	testCases := []struct {
+		Range string
+		Want  int64
+	}{
+		{Range: "", Want: 1000},
+		{Range: "bytes=0-99", Want: 100},
+		{Range: "bytes=900-", Want: 1000},
+		{Range: "bytes=-100", Want: 1000},
+		{Range: "bytes=0-9, 500-509", Want: 520},
+		{Range: "items=0-9", Want: 1000},
+	}
+	for _, tc := range testCases {
+		r := newTestRequest("GET", "/file.txt", "", "Range", tc.Range)
+		assert.Equal(tc.Want, decompressionCost(r, fi), tc.Range)
+	}
+
+	// Stored entries are read in place
+	fi.zipFile.Method = 0
+	r := newTestRequest("GET", "/file.txt", "", "Range", "bytes=900-,-50")
+	assert.Equal(int64(150), decompressionCost(r, fi))
+}
@@ -0,0 +1,196 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// dirConfigName is the name of the per-directory configuration file
+// that may be placed in any directory inside the archive.
+const dirConfigName = ".zipfsrc"
+
+// maxDirConfigSize limits how much of a configuration file is read.
+// Anything larger is treated as invalid and ignored.
+const maxDirConfigSize = 64 * 1024
+
+// dirConfigHeaderDenyList contains the response headers that a
+// configuration file is not allowed to set, because the file server
+// computes them itself or because they could be used to hijack the
+// response.
+var dirConfigHeaderDenyList = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Content-Range":     true,
+	"Content-Type":      true,
+	"Etag":              true,
+	"Last-Modified":     true,
+	"Location":          true,
+	"Set-Cookie":        true,
+	"Transfer-Encoding": true,
+	"Zipsvr_filename":   true,
+}
+
+// WithDirConfig enables per-directory configuration files. When enabled,
+// a .zipfsrc file in a directory of the archive controls the behaviour of
+// that directory and everything below it. Files are evaluated from the
+// root of the archive down, with settings in deeper directories
+// overriding those of their parents. The files themselves are never
+// served.
+//
+// A configuration file is a JSON object with the following optional keys:
+//
+//	{
+//	    "indexExtensions": ["html", "htm"],
+//	    "autoIndex": true,
+//	    "headers": {"Cache-Control": "max-age=3600"}
+//	}
+//
+// Configuration files can only affect the directory they are in, and
+// cannot set headers that the file server manages itself, such as
+// Content-Length or Content-Encoding.
+func WithDirConfig() Option {
+	return func(h *fileHandler) {
+		h.dirConfig = true
+	}
+}
+
+// dirConfig is the contents of a single .zipfsrc file, or the result
+// of merging all of the files that apply to a directory.
+type dirConfig struct {
+	IndexExtensions []string          `json:"indexExtensions"`
+	AutoIndex       *bool             `json:"autoIndex"`
+	Headers         map[string]string `json:"headers"`
+}
+
+// merge applies the settings in child on top of c.
+func (c *dirConfig) merge(child *dirConfig) {
+	if child == nil {
+		return
+	}
+	if child.IndexExtensions != nil {
+		c.IndexExtensions = child.IndexExtensions
+	}
+	if child.AutoIndex != nil {
+		c.AutoIndex = child.AutoIndex
+	}
+	for key, value := range child.Headers {
+		if c.Headers == nil {
+			c.Headers = map[string]string{}
+		}
+		c.Headers[key] = value
+	}
+}
+
+// indexExts returns the index extensions to use, falling back to
+// defaultExts when the configuration does not specify any.
+func (c *dirConfig) indexExts(defaultExts []string) []string {
+	if c == nil || c.IndexExtensions == nil {
+		return defaultExts
+	}
+	return c.IndexExtensions
+}
+
+func (c *dirConfig) autoIndex() bool {
+	return c != nil && c.AutoIndex != nil && *c.AutoIndex
+}
+
+func (c *dirConfig) applyHeaders(w http.ResponseWriter) {
+	if c == nil {
+		return
+	}
+	for key, value := range c.Headers {
+		w.Header().Set(key, value)
+	}
+}
+
+// isDirConfigFile reports whether the request path refers to a
+// per-directory configuration file.
+func isDirConfigFile(name string) bool {
+	return strings.ToLower(path.Base(name)) == dirConfigName
+}
+
+// dirConfigFor returns the merged configuration that applies to fi.
+// For a file this is the configuration of its parent directory. Files
+// that cannot be read are reported to logError.
+func (fs *FileSystem) dirConfigFor(fi *fileInfo, logError func(op string, err error)) *dirConfig {
+	dir := strings.Trim(fi.name, "/")
+	if !fi.IsDir() {
+		dir = path.Dir(dir)
+		if dir == "." {
+			dir = ""
+		}
+	}
+
+	merged := &dirConfig{}
+	merged.merge(fs.loadDirConfig("", logError))
+	if dir != "" {
+		prefix := ""
+		for _, part := range strings.Split(dir, "/") {
+			prefix += part + "/"
+			merged.merge(fs.loadDirConfig(prefix, logError))
+		}
+	}
+	return merged
+}
+
+// loadDirConfig returns the configuration file in the directory
+// identified by prefix (either empty or ending in a slash), or nil if
+// there is no valid configuration file. Results are cached because the
+// archive contents cannot change, so files that cannot be read are only
+// reported to logError the first time.
+func (fs *FileSystem) loadDirConfig(prefix string, logError func(op string, err error)) *dirConfig {
+	fs.dirConfigMutex.Lock()
+	defer fs.dirConfigMutex.Unlock()
+
+	if cfg, ok := fs.dirConfigs[prefix]; ok {
+		return cfg
+	}
+	if fs.dirConfigs == nil {
+		fs.dirConfigs = map[string]*dirConfig{}
+	}
+
+	cfg, err := fs.readDirConfig(prefix + dirConfigName)
+	if err != nil {
+		logError("DirConfig", fmt.Errorf("%s%s: %w", prefix, dirConfigName, err))
+		cfg = nil
+	}
+	fs.dirConfigs[prefix] = cfg
+	return cfg
+}
+
+func (fs *FileSystem) readDirConfig(name string) (*dirConfig, error) {
+	fi := fs.fileInfos[name]
+	if fi == nil || fi.IsDir() {
+		return nil, nil
+	}
+
+	reader, err := fi.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxDirConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDirConfigSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxDirConfigSize)
+	}
+
+	var cfg dirConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	for key := range cfg.Headers {
+		if dirConfigHeaderDenyList[http.CanonicalHeaderKey(key)] {
+			delete(cfg.Headers, key)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,95 @@
+package zipfs
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		".zipfsrc", `{"headers": {"X-Root": "root", "X-Level": "root", "Content-Length": "1"}}`,
+		"index.html", "root index",
+		"docs/.zipfsrc", `{"autoIndex": true, "headers": {"X-Level": "docs"}}`,
+		"docs/a.txt", "a",
+		"docs/sub/", "",
+		"docs/sub/b.txt", "b",
+		"site/.zipfsrc", `{"indexExtensions": ["htm"]}`,
+		"site/index.html", "html index",
+		"site/index.htm", "htm index",
+		"broken/.zipfsrc", `{not json`,
+		"broken/c.txt", "c",
+	)
+	var logged bytes.Buffer
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil, WithDirConfig(), WithLogger(log.New(&logged, "", 0)))
+
+	w := serveTest(handler, "GET", "/", "")
+	assert.Equal(200, w.status)
+	assert.Equal("root index", w.buf.String())
+	assert.Equal("root", w.Header().Get("X-Root"))
+	assert.Equal("root", w.Header().Get("X-Level"))
+	assert.Equal("10", w.Header().Get("Content-Length"))
+
+	w = serveTest(handler, "GET", "/docs/a.txt", "")
+	assert.Equal(200, w.status)
+	assert.Equal("root", w.Header().Get("X-Root"))
+	assert.Equal("docs", w.Header().Get("X-Level"))
+
+	w = serveTest(handler, "GET", "/docs/", "")
+	assert.Equal(200, w.status)
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(w.buf.String(), `<a href="a.txt">a.txt</a>`)
+	assert.Contains(w.buf.String(), `<a href="sub/">sub/</a>`)
+	assert.NotContains(w.buf.String(), dirConfigName)
+
+	// autoIndex is inherited by subdirectories
+	w = serveTest(handler, "GET", "/docs/sub/", "")
+	assert.Equal(200, w.status)
+	assert.Contains(w.buf.String(), `<a href="b.txt">b.txt</a>`)
+
+	w = serveTest(handler, "GET", "/site/", "")
+	assert.Equal(200, w.status)
+	assert.Equal("htm index", w.buf.String())
+
+	w = serveTest(handler, "GET", "/broken/c.txt", "")
+	assert.Equal(200, w.status)
+	assert.Equal("root", w.Header().Get("X-Root"))
+	assert.Contains(logged.String(), "Error (DirConfig): broken/.zipfsrc: ")
+
+	// Invalid files are only reported once
+	logged.Reset()
+	serveTest(handler, "GET", "/broken/c.txt", "")
+	assert.Empty(logged.String())
+
+	w = serveTest(handler, "GET", "/docs/.zipfsrc", "")
+	assert.Equal(404, w.status)
+
+	w = serveTest(handler, "GET", "/.ZIPFSRC", "")
+	assert.Equal(404, w.status)
+}
+
+func TestDirConfigDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		".zipfsrc", `{"autoIndex": true, "headers": {"X-Root": "root"}}`,
+		"docs/a.txt", "a",
+	)
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil)
+
+	r := &http.Request{
+		URL:    &url.URL{Path: "/docs/"},
+		Header: make(http.Header),
+		Method: "GET",
+	}
+	w := NewTestResponseWriter()
+	handler.ServeHTTP(w, r)
+	assert.Equal(403, w.status)
+	assert.Equal("", w.Header().Get("X-Root"))
+}
@@ -0,0 +1,194 @@
+package zipfs
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	iofs "io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DirEntry describes an entry of a directory in a JSON directory listing.
+type DirEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// DirListing is the JSON directory listing of a directory.
+type DirListing struct {
+	Path    string     `json:"path"`
+	Entries []DirEntry `json:"entries"`
+}
+
+// wantsJSONListing reports whether the client asked for a JSON directory
+// listing, with the "format=json" query parameter or by accepting
+// application/json.
+func wantsJSONListing(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accept)
+		if err == nil && mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the link to the entry relative to the listing, ending in
+// a slash for directories.
+func (e DirEntry) URL() string {
+	name := e.Name
+	if e.IsDir {
+		name += "/"
+	}
+	// name may contain '?' or '#', which must be escaped to remain
+	// part of the URL path, and not indicate the start of a query
+	// string or fragment.
+	link := url.URL{Path: name}
+	return link.String()
+}
+
+// DirListAssetsPath is the URL path under which the assets given to
+// WithDirListTemplate are served.
+const DirListAssetsPath = "/.zipfs-dirlist/"
+
+// DirListPage is the data passed to the directory listing template.
+type DirListPage struct {
+	DirListing
+	AssetsPath string // DirListAssetsPath, for links to the assets
+}
+
+// WithDirListTemplate renders HTML directory listings, which are enabled
+// by .zipfsrc files (see WithDirConfig), with tmpl. The template is
+// passed a DirListPage. If assets is not nil, its files are served
+// under DirListAssetsPath, so that listings can have their own style
+// sheets, scripts and images.
+func WithDirListTemplate(tmpl *template.Template, assets iofs.FS) Option {
+	return func(h *fileHandler) {
+		h.dirListTmpl = tmpl
+		h.dirListAssets = nil
+		if assets != nil {
+			h.dirListAssets = http.StripPrefix(strings.TrimSuffix(DirListAssetsPath, "/"), http.FileServer(http.FS(assets)))
+		}
+	}
+}
+
+// serveDirListAssets serves the request if it is for a directory
+// listing asset, and reports whether it was.
+func (h *fileHandler) serveDirListAssets(w http.ResponseWriter, r *http.Request) bool {
+	if h.dirListAssets == nil || !strings.HasPrefix(r.URL.Path, DirListAssetsPath) {
+		return false
+	}
+	h.dirListAssets.ServeHTTP(w, r)
+	return true
+}
+
+// dirListing returns the listing of the directory fi, which is served
+// at urlPath. Configuration files, hidden entries and entries blocked by
+// the path rules are left out.
+func (h *fileHandler) dirListing(fi *fileInfo, urlPath string) DirListing {
+	listing := DirListing{
+		Path:    "/" + fi.name,
+		Entries: []DirEntry{},
+	}
+	for _, child := range fi.fileInfos {
+		name := child.Name()
+		if !h.listable(path.Join(fi.name, name), path.Join(urlPath, name)) {
+			continue
+		}
+		listing.Entries = append(listing.Entries, DirEntry{
+			Name:    name,
+			Size:    child.Size(),
+			ModTime: child.ModTime(),
+			IsDir:   child.IsDir(),
+		})
+	}
+	return listing
+}
+
+// listable reports whether the entry name of an archive, which is served
+// at urlPath, may be listed. Configuration files, hidden entries and
+// entries blocked by the path rules are not.
+func (h *fileHandler) listable(name string, urlPath string) bool {
+	if strings.ToLower(path.Base(name)) == dirConfigName || h.isHidden(name) {
+		return false
+	}
+	if h.headersFile && isHeadersFile(name) || h.redirectsFile && isRedirectsFile(name) {
+		return false
+	}
+	return h.pathAllowed(urlPath)
+}
+
+// serveDirList writes a listing of the entries in the directory fi,
+// either as JSON (see wantsJSONListing) or as HTML.
+func (h *fileHandler) serveDirList(w http.ResponseWriter, r *http.Request, fi *fileInfo) {
+	addVary(w.Header(), "Accept")
+	listing := h.dirListing(fi, r.URL.Path)
+	switch {
+	case wantsJSONListing(r):
+		serveJSONDirList(w, r, listing)
+	case h.dirListTmpl != nil:
+		h.serveTemplateDirList(w, r, fi, listing)
+	default:
+		serveHTMLDirList(w, r, listing)
+	}
+}
+
+// serveTemplateDirList writes the listing of the directory fi rendered
+// with the directory listing template.
+func (h *fileHandler) serveTemplateDirList(w http.ResponseWriter, r *http.Request, fi *fileInfo, listing DirListing) {
+	var buf bytes.Buffer
+	err := h.dirListTmpl.Execute(&buf, DirListPage{
+		DirListing: listing,
+		AssetsPath: DirListAssetsPath,
+	})
+	if err != nil {
+		h.logError("serveDirList", err)
+		recordError(r, fi.name, err)
+		httpError(w, r, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+	if r.Method != "HEAD" {
+		w.Write(buf.Bytes())
+	}
+}
+
+// serveJSONDirList writes listing as JSON.
+func serveJSONDirList(w http.ResponseWriter, r *http.Request, listing DirListing) {
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Type", "application/json")
+		return
+	}
+	makeJsonResponse(w, listing, http.StatusOK)
+}
+
+// serveHTMLDirList writes listing as simple HTML. It is adapted from the
+// dirList function in the standard library net/http package.
+func serveHTMLDirList(w http.ResponseWriter, r *http.Request, listing DirListing) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == "HEAD" {
+		return
+	}
+
+	fmt.Fprintf(w, "<pre>\n")
+	for _, entry := range listing.Entries {
+		name := entry.Name
+		if entry.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", entry.URL(), html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
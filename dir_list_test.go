@@ -0,0 +1,95 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"html/template"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONDirList(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"docs/.zipfsrc", `{"autoIndex": true}`,
+		"docs/a.txt", "aaa",
+		"docs/sub/", "",
+		"docs/sub/b.txt", "b",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithDirConfig())
+
+	for _, w := range []*TestResponseWriter{
+		serveTest(handler, "GET", "/docs/?format=json", ""),
+		serveTest(handler, "GET", "/docs/", "", "Accept", "application/json, text/plain;q=0.5"),
+	} {
+		require.Equal(200, w.status)
+		assert.Equal("application/json", w.Header().Get("Content-Type"))
+		assert.Equal("Accept", w.Header().Get("Vary"))
+
+		var listing DirListing
+		require.NoError(json.Unmarshal(w.buf.Bytes(), &listing))
+		assert.Equal("/docs/", listing.Path)
+		require.Len(listing.Entries, 2)
+		entries := map[string]DirEntry{}
+		for _, entry := range listing.Entries {
+			entries[entry.Name] = entry
+		}
+		assert.Equal(int64(3), entries["a.txt"].Size)
+		assert.False(entries["a.txt"].IsDir)
+		assert.False(entries["a.txt"].ModTime.IsZero())
+		assert.True(entries["sub"].IsDir)
+	}
+
+	// Browsers still get HTML
+	w := serveTest(handler, "GET", "/docs/", "", "Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(w.buf.String(), `<a href="a.txt">a.txt</a>`)
+
+	// Directories without listings stay forbidden
+	fs2 := newTestFileSystem(t, "docs/a.txt", "a")
+	defer fs2.Close()
+	w = serveTest(FileServer(fs2, "api/", "", false, nil, nil, WithDirConfig()), "GET", "/docs/?format=json", "")
+	assert.Equal(403, w.status)
+}
+
+func TestDirListTemplate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"docs/.zipfsrc", `{"autoIndex": true}`,
+		"docs/a b.txt", "aaa",
+		"docs/sub/", "",
+	)
+	defer fs.Close()
+	tmpl := template.Must(template.New("list").Parse(
+		`<link rel="stylesheet" href="{{.AssetsPath}}style.css"><h1>{{.Path}}</h1>{{range .Entries}}<a href="{{.URL}}">{{.Name}}</a>{{end}}`))
+	assets := fstest.MapFS{"style.css": {Data: []byte("body{}")}}
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithDirConfig(), WithDirListTemplate(tmpl, assets))
+
+	w := serveTest(handler, "GET", "/docs/", "")
+	require.Equal(200, w.status)
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(`<link rel="stylesheet" href="/.zipfs-dirlist/style.css"><h1>/docs/</h1>`+
+		`<a href="a%20b.txt">a b.txt</a><a href="sub/">sub</a>`, w.buf.String())
+
+	w = serveTest(handler, "GET", DirListAssetsPath+"style.css", "")
+	require.Equal(200, w.status)
+	assert.Equal("body{}", w.buf.String())
+
+	// JSON listings are not affected by the template
+	w = serveTest(handler, "GET", "/docs/?format=json", "")
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+
+	// Template errors are reported before anything is written
+	tmpl = template.Must(template.New("list").Parse(`{{.Missing}}`))
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithDirConfig(), WithDirListTemplate(tmpl, nil))
+	w = serveTest(handler, "GET", "/docs/", "")
+	assert.Equal(500, w.status)
+	assert.Equal(404, serveTest(handler, "GET", DirListAssetsPath+"style.css", "").status)
+}
@@ -0,0 +1,248 @@
+package zipfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadAttempts is the number of times a download is attempted
+// before giving up. Every attempt resumes where the last one stopped.
+const downloadAttempts = 5
+
+// downloadRetryDelay is multiplied by the attempt number to give the
+// delay before retrying a download.
+var downloadRetryDelay = time.Second
+
+var errHashMismatch = errors.New("downloaded file does not match the expected SHA-256 hash")
+
+// WithMountDownloads allows the mountZIP API endpoint to download
+// archives that are not present yet. A mount request that includes a
+// "url" downloads the archive from that URL to "filePath" before
+// mounting it, and checks it against the optional "sha256" hash.
+// Interrupted downloads are resumed. A mount request whose "filePath"
+// is an http or https URL mounts the remote archive without downloading
+// it, see NewFromURL. If client is nil, http.DefaultClient is used.
+func WithMountDownloads(client *http.Client) Option {
+	return func(h *fileHandler) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		h.downloadClient = client
+	}
+}
+
+// partialDownload is stored next to an incomplete download, so that the
+// download is only resumed if the remote file has not changed.
+type partialDownload struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// DownloadArchive downloads url to the file dest. The file is first
+// written to dest + ".part", and requests use Range and If-Range headers
+// to resume an earlier download that was interrupted, for up to five
+// attempts. If sha256Hex is not empty, the completed file must have
+// that SHA-256 hash, or it is removed and an error returned.
+func DownloadArchive(ctx context.Context, client *http.Client, url string, dest string, sha256Hex string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	partPath := dest + ".part"
+	metaPath := dest + ".part.json"
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		var retry bool
+		retry, err = downloadPart(ctx, client, url, partPath, metaPath)
+		if err == nil || !retry {
+			break
+		}
+		if attempt < downloadAttempts {
+			select {
+			case <-time.After(time.Duration(attempt) * downloadRetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if sha256Hex != "" {
+		if err := verifySHA256(partPath, sha256Hex); err != nil {
+			os.Remove(partPath)
+			os.Remove(metaPath)
+			return err
+		}
+	}
+
+	os.Remove(metaPath)
+	return os.Rename(partPath, dest)
+}
+
+// downloadPart downloads the rest of the file into partPath. It reports
+// whether a failed download is worth retrying.
+func downloadPart(ctx context.Context, client *http.Client, url string, partPath string, metaPath string) (bool, error) {
+	var offset int64
+	var meta partialDownload
+	if stat, err := os.Stat(partPath); err == nil {
+		if data, err := os.ReadFile(metaPath); err == nil && json.Unmarshal(data, &meta) == nil && meta.URL == url {
+			offset = stat.Size()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" && !strings.HasPrefix(meta.ETag, "W/") {
+			req.Header.Set("If-Range", meta.ETag)
+		} else if meta.LastModified != "" {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset == 0 || !ok || start != offset || !meta.matches(resp) {
+			// A different range, or a different file, so start over
+			return true, restartDownload(partPath, metaPath, url, resp)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The server ignored the range or the file changed, so start over
+		flags |= os.O_TRUNC
+		meta = partialDownload{
+			URL:          url,
+			ETag:         resp.Header.Get("Etag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		data, _ := json.Marshal(meta)
+		if err := os.WriteFile(metaPath, data, 0644); err != nil {
+			return false, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The download is complete if offset is the size of the file
+		_, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset == 0 || !ok || size != offset || !meta.matches(resp) {
+			return true, restartDownload(partPath, metaPath, url, resp)
+		}
+		return false, nil
+	default:
+		return resp.StatusCode >= 500, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	return false, nil
+}
+
+// matches reports whether the validators of resp, if it has any, are
+// those of the file that is partially downloaded.
+func (meta partialDownload) matches(resp *http.Response) bool {
+	if etag := resp.Header.Get("Etag"); etag != "" && meta.ETag != "" && etag != meta.ETag {
+		return false
+	}
+	if modified := resp.Header.Get("Last-Modified"); modified != "" && meta.LastModified != "" && modified != meta.LastModified {
+		return false
+	}
+	return true
+}
+
+// restartDownload removes the partial download, so that the next attempt
+// starts from scratch, and returns the error that explains why.
+func restartDownload(partPath string, metaPath string, url string, resp *http.Response) error {
+	os.Remove(partPath)
+	os.Remove(metaPath)
+	return fmt.Errorf("download %s: %s with Content-Range %q does not continue the partial download",
+		url, resp.Status, resp.Header.Get("Content-Range"))
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes start-end/size" or "bytes */size". The start is -1 for the
+// latter, and the size is -1 if it is unknown.
+func parseContentRange(header string) (start int64, size int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, total, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if total != "*" {
+		var err error
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil || size < 0 {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return -1, size, size >= 0
+	}
+	first, last, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start || size >= 0 && end >= size {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+func verifySHA256(name string, sha256Hex string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), sha256Hex) {
+		return errHashMismatch
+	}
+	return nil
+}
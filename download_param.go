@@ -0,0 +1,49 @@
+package zipfs
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// defaultDownloadParam is the query parameter used by WithDownloadParam
+// when it is given an empty name.
+const defaultDownloadParam = "download"
+
+// WithDownloadParam makes entries requested with the query parameter
+// name, such as "?download=1", download instead of being displayed by
+// the browser, by serving them with Content-Disposition: attachment. An
+// empty name means "download". The parameter may be given without a
+// value, and values that parse as false with strconv.ParseBool, such as
+// "0", leave the response alone. Entries rendered by WithRenderer are
+// downloaded as they are stored.
+func WithDownloadParam(name string) Option {
+	return func(h *fileHandler) {
+		if name == "" {
+			name = defaultDownloadParam
+		}
+		h.downloadParam = name
+	}
+}
+
+// wantsDownload reports whether the request asks for the entry to be
+// downloaded.
+func (h *fileHandler) wantsDownload(r *http.Request) bool {
+	if h.downloadParam == "" {
+		return false
+	}
+	values, ok := r.URL.Query()[h.downloadParam]
+	if !ok {
+		return false
+	}
+	if len(values) == 0 || values[0] == "" {
+		return true
+	}
+	download, err := strconv.ParseBool(values[0])
+	return err != nil || download
+}
+
+// setAttachment makes the response download as a file called name.
+func setAttachment(w http.ResponseWriter, name string) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+}
@@ -0,0 +1,37 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadParam(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"docs/readme.md", "# Title",
+		"docs/ゲーム.txt", "game",
+	)
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithDownloadParam(""), WithRenderer(".md", &testRenderer{}))
+	w := serveTest(handler, "GET", "/docs/readme.md?download=1", "")
+	assert.Equal(200, w.status)
+	assert.Equal("attachment; filename=readme.md", w.Header().Get("Content-Disposition"))
+	assert.Equal("# Title", w.buf.String())
+
+	w = serveTest(handler, "GET", "/docs/%E3%82%B2%E3%83%BC%E3%83%A0.txt?download", "")
+	assert.Equal("attachment; filename*=utf-8''%E3%82%B2%E3%83%BC%E3%83%A0.txt", w.Header().Get("Content-Disposition"))
+
+	w = serveTest(handler, "GET", "/docs/readme.md?download=0", "")
+	assert.Empty(w.Header().Get("Content-Disposition"))
+	assert.Equal("<p># TITLE</p>", w.buf.String())
+
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithDownloadParam("dl"))
+	assert.Empty(serveTest(handler, "GET", "/docs/readme.md?download=1", "").Header().Get("Content-Disposition"))
+	assert.Equal("attachment; filename=readme.md", serveTest(handler, "GET", "/docs/readme.md?dl=true", "").Header().Get("Content-Disposition"))
+
+	handler = FileServer(fs, "api/", "", false, nil, nil)
+	assert.Empty(serveTest(handler, "GET", "/docs/readme.md?download=1", "").Header().Get("Content-Disposition"))
+}
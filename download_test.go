@@ -0,0 +1,153 @@
+package zipfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadArchive(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(d time.Duration) { downloadRetryDelay = d }(downloadRetryDelay)
+	downloadRetryDelay = time.Millisecond
+
+	data, err := os.ReadFile("testdata/testdata.zip")
+	require.NoError(err)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	// The first request is cut off half way through the body
+	var requests int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Etag", `"v1"`)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Content-Length", "100000")
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "testdata.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "packs", "testdata.zip")
+	err = DownloadArchive(context.Background(), nil, server.URL, dest, hash)
+	require.NoError(err)
+
+	got, err := os.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(data, got)
+	require.Len(ranges, 2)
+	assert.Equal("", ranges[0])
+	assert.True(strings.HasPrefix(ranges[1], "bytes="), ranges[1])
+	_, err = os.Stat(dest + ".part")
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(dest + ".part.json")
+	assert.True(os.IsNotExist(err))
+
+	// A file with the wrong hash is thrown away
+	dest = filepath.Join(t.TempDir(), "bad.zip")
+	err = DownloadArchive(context.Background(), nil, server.URL, dest, strings.Repeat("0", 64))
+	assert.Equal(errHashMismatch, err)
+	_, err = os.Stat(dest)
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(dest + ".part")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestDownloadArchiveResumeMismatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(d time.Duration) { downloadRetryDelay = d }(downloadRetryDelay)
+	downloadRetryDelay = time.Millisecond
+
+	data, err := os.ReadFile("testdata/testdata.zip")
+	require.NoError(err)
+	dir := t.TempDir()
+	// A range that does not start at the end of the partial download
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-9/%d", len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[:10])
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+	dest := filepath.Join(dir, "range.zip")
+	require.NoError(os.WriteFile(dest+".part", data[:100], 0644))
+	require.NoError(os.WriteFile(dest+".part.json", []byte(`{"url": "`+server.URL+`"}`), 0644))
+	require.NoError(DownloadArchive(context.Background(), nil, server.URL, dest, ""))
+	got, err := os.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(data, got)
+	assert.Equal([]string{"bytes=100-", ""}, ranges)
+
+	// A file that changed since the partial download, even though the
+	// server does not honour If-Range
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"v2"`)
+		r.Header.Del("If-Range")
+		http.ServeContent(w, r, "testdata.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	dest = filepath.Join(dir, "changed.zip")
+	require.NoError(os.WriteFile(dest+".part", make([]byte, 100), 0644))
+	require.NoError(os.WriteFile(dest+".part.json", []byte(`{"url": "`+server.URL+`", "etag": "\"v1\""}`), 0644))
+	require.NoError(DownloadArchive(context.Background(), nil, server.URL, dest, ""))
+	got, err = os.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(data, got)
+
+	// A partial download that is longer than the file is not complete
+	dest = filepath.Join(dir, "long.zip")
+	require.NoError(os.WriteFile(dest+".part", make([]byte, len(data)+10), 0644))
+	require.NoError(os.WriteFile(dest+".part.json", []byte(`{"url": "`+server.URL+`", "etag": "\"v2\""}`), 0644))
+	require.NoError(DownloadArchive(context.Background(), nil, server.URL, dest, ""))
+	got, err = os.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(data, got)
+}
+
+func TestMountFsDownload(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	mountDir := t.TempDir()
+	body := `{"filePath": "testdata.zip", "url": "` + server.URL + `/testdata.zip"}`
+
+	handler := EmptyFileServer("api/", "", false, nil, mountDir, "", nil, nil, t.TempDir())
+	assert.Equal(http.StatusBadRequest, serveTest(handler, "POST", "/api/mountzip", body).status)
+	_, err := os.Stat(filepath.Join(mountDir, "testdata.zip"))
+	assert.True(os.IsNotExist(err))
+
+	handler = EmptyFileServer("api/", "", false, nil, mountDir, "", nil, nil, t.TempDir(), WithMountDownloads(nil))
+	assert.Equal(http.StatusOK, serveTest(handler, "POST", "/api/mountzip", body).status)
+	assert.FileExists(filepath.Join(mountDir, "testdata.zip"))
+
+	w := serveTest(handler, "GET", "/test.html", "")
+	assert.Equal(http.StatusOK, w.status)
+}
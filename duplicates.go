@@ -0,0 +1,95 @@
+package zipfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy selects which of several entries with the same name
+// is served, see SetDuplicatePolicy.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLastWins serves the last of the entries in the archive,
+	// which is the one added last by tools that append updated files
+	// to an archive. It is the default.
+	DuplicateLastWins DuplicatePolicy = iota
+	// DuplicateFirstWins serves the first of the entries in the archive.
+	DuplicateFirstWins
+	// DuplicateError rejects archives with duplicate entries.
+	DuplicateError
+)
+
+var errDuplicateEntry = errors.New("zip: duplicate entry")
+
+// SetDuplicatePolicy sets which of several entries of fs with the same
+// name is served. Directories may be given several entries, which are
+// always the same directory. With DuplicateError, an error naming the
+// first duplicate entry is returned if there is one, and fs is left with
+// DuplicateLastWins. It must be called before fs is used.
+func (fs *FileSystem) SetDuplicatePolicy(policy DuplicatePolicy) error {
+	fs = fs.current()
+	fs.duplicates = policy
+	return fs.indexEntries()
+}
+
+// WithDuplicatePolicy sets which of several entries with the same name
+// is served from archives mounted through the API, and from the archives
+// given to the constructor, see FileSystem.SetDuplicatePolicy. With
+// DuplicateError, archives with duplicate entries are not mounted.
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(h *fileHandler) {
+		h.duplicates = policy
+	}
+}
+
+// duplicateEntry returns the entry indexed before with the same name as
+// the entry zf named name, or nil if there is none.
+func (fs *FileSystem) duplicateEntry(name string) *fileInfo {
+	if fi := fs.caseVariants[name]; fi != nil {
+		return fi
+	}
+	fi := fs.fileInfos[strings.ToLower(name)]
+	if fi == nil || fi.zipFile == nil || fi.IsDir() || fs.entryName(fi.zipFile) != name {
+		return nil
+	}
+	return fi
+}
+
+// inheritSettings gives fs, which replaces or is nested in from, the
+// settings of from: the password, and the settings of
+// SetSymlinkResolution, SetNameEncoding and SetDuplicatePolicy.
+func (fs *FileSystem) inheritSettings(from *FileSystem) error {
+	fs.password = from.password
+	fs.noSymlinks = from.noSymlinks
+	if from.nameEncoding == nil && from.duplicates == DuplicateLastWins {
+		return nil
+	}
+	fs.nameEncoding = from.nameEncoding
+	fs.duplicates = from.duplicates
+	return fs.indexEntries()
+}
+
+// configureArchive applies the settings of WithSymlinkResolution,
+// WithNameEncoding and WithDuplicatePolicy to fs, which is about to be
+// mounted. Settings that are not given are left alone.
+func (h *fileHandler) configureArchive(fs *FileSystem) error {
+	fs = fs.current()
+	if h.noSymlinks {
+		fs.noSymlinks = true
+	}
+	if h.nameEncoding == nil && h.duplicates == DuplicateLastWins {
+		return nil
+	}
+	if h.nameEncoding != nil {
+		fs.nameEncoding = h.nameEncoding
+	}
+	if h.duplicates != DuplicateLastWins {
+		fs.duplicates = h.duplicates
+	}
+	if err := fs.indexEntries(); err != nil {
+		return fmt.Errorf("%s: %w", fs.givenPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package zipfs
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "dir/a.txt", "old", "dir/b.txt", "b", "dir/A.txt", "variant", "dir/a.txt", "new")
+	assert.Equal("new", readTestFile(t, fs, "dir/a.txt"))
+	assert.Equal("variant", readTestFile(t, fs, "dir/A.txt"))
+	entries, err := fs.ReadDir("dir")
+	require.NoError(err)
+	assert.Len(entries, 3)
+
+	require.NoError(fs.SetDuplicatePolicy(DuplicateFirstWins))
+	assert.Equal("old", readTestFile(t, fs, "dir/a.txt"))
+	entries, err = fs.ReadDir("dir")
+	require.NoError(err)
+	assert.Len(entries, 3)
+
+	err = fs.SetDuplicatePolicy(DuplicateError)
+	assert.True(errors.Is(err, errDuplicateEntry), err)
+	assert.Contains(err.Error(), "dir/a.txt")
+	assert.Equal("new", readTestFile(t, fs, "dir/a.txt"))
+	assert.NoError(newTestFileSystem(t, "a.txt", "a", "A.txt", "b").SetDuplicatePolicy(DuplicateError))
+
+	// Archives with duplicate entries are not mounted
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "dup.zip"), "a.txt", "old", "a.txt", "new")
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithDuplicatePolicy(DuplicateError))
+	assert.Equal(http.StatusUnprocessableEntity, serveTest(h, "POST", "/api/mountzip", `{"filePath": "dup.zip"}`).status)
+	assert.Empty(h.(*fileHandler).mounted())
+}
@@ -0,0 +1,377 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// methodAES is the compression method of entries encrypted with the
+// WinZip AES scheme. The actual compression method is recorded in the
+// AES extra field of the entry.
+const methodAES = 99
+
+// aesExtraID is the ID of the extra field of AES encrypted entries.
+const aesExtraID = 0x9901
+
+// zipCryptoHeaderLen is the length of the header that precedes the data
+// of entries encrypted with the traditional PKWARE scheme.
+const zipCryptoHeaderLen = 12
+
+// aesAuthCodeLen is the length of the authentication code that follows
+// the data of AES encrypted entries.
+const aesAuthCodeLen = 10
+
+var (
+	errPasswordRequired = errors.New("entry is encrypted and no password is set")
+	errWrongPassword    = errors.New("wrong password")
+	errAuthentication   = errors.New("authentication code mismatch")
+	errChecksum         = errors.New("checksum error")
+)
+
+// NewWithPassword will open the Zip file specified by name, like New,
+// and decrypt its encrypted entries with password.
+func NewWithPassword(name string, password string) (*FileSystem, error) {
+	fs, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.SetPassword(password)
+	return fs, nil
+}
+
+// SetPassword sets the password that the encrypted entries of fs are
+// decrypted with. Both the traditional PKWARE encryption, also known as
+// ZipCrypto, and WinZip AES encryption are supported. Encrypted entries
+// cannot be read, and are answered with 403 Forbidden by the file
+// server, until a password is set. It must be called before fs is used.
+func (fs *FileSystem) SetPassword(password string) {
+	fs = fs.current()
+	fs.password = []byte(password)
+}
+
+// encrypted reports whether the entry of fi is encrypted.
+func (fi *fileInfo) encrypted() bool {
+	return fi.zipFile != nil && fi.zipFile.Flags&0x1 != 0
+}
+
+// checkPassword returns errPasswordRequired if the entry of fi is
+// encrypted but its file system has no password.
+func (fi *fileInfo) checkPassword() error {
+	if fi.encrypted() && (fi.fs == nil || fi.fs.password == nil) {
+		return errPasswordRequired
+	}
+	return nil
+}
+
+// open returns a reader of the decompressed contents of the entry of fi,
+// decrypting them if the entry is encrypted.
+func (fi *fileInfo) open() (io.ReadCloser, error) {
+	rc, err := fi.openContents()
+	if err != nil {
+		return nil, err
+	}
+	openReaders.Add(1)
+	return &countedReader{ReadCloser: rc}, nil
+}
+
+func (fi *fileInfo) openContents() (io.ReadCloser, error) {
+	if open := fi.fs.openers[fi.zipFile]; open != nil {
+		rc, err := open()
+		if err != nil {
+			return nil, err
+		}
+		zf := fi.zipFile
+		er, _ := decompressEntry(zip.Store, rc, zf.CRC32, zf.UncompressedSize64, zf.CRC32 != 0)
+		return struct {
+			io.Reader
+			io.Closer
+		}{er, rc}, nil
+	}
+	if !fi.encrypted() {
+		zf := fi.zipFile
+		if zf.Method == zip.Store || zf.Method == zip.Deflate || strings.HasSuffix(zf.Name, "/") {
+			return zf.Open()
+		}
+		// Other methods are decompressed by the registered
+		// decompressors, which are also given the size of the contents
+		raw, err := zf.OpenRaw()
+		if err != nil {
+			return nil, err
+		}
+		return decompressEntry(zf.Method, raw, zf.CRC32, zf.UncompressedSize64, true)
+	}
+	if err := fi.checkPassword(); err != nil {
+		return nil, err
+	}
+	zf := fi.zipFile
+	raw, err := zf.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	if zf.Method == methodAES {
+		return openAES(zf, raw, fi.fs.password)
+	}
+
+	header := make([]byte, zipCryptoHeaderLen)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	keys := newZipCryptoKeys(fi.fs.password)
+	keys.decrypt(header)
+	// The last byte of the header checks the password. It is the high
+	// byte of the CRC, or of the modification time if the CRC follows
+	// the data.
+	check := byte(zf.CRC32 >> 24)
+	if zf.Flags&0x8 != 0 {
+		check = byte(zf.ModifiedTime >> 8)
+	}
+	if header[zipCryptoHeaderLen-1] != check {
+		return nil, errWrongPassword
+	}
+	decrypted := &zipCryptoReader{r: raw, keys: keys}
+	return decompressEntry(zf.Method, decrypted, zf.CRC32, zf.UncompressedSize64, true)
+}
+
+// decompressEntry returns a reader of the data r of an entry compressed
+// with method. Once all of it has been read, the rest of r is read as
+// well, so that r can check its authentication code, and the CRC of the
+// contents is checked if checkCRC is true.
+func decompressEntry(method uint16, r io.Reader, crc uint32, size uint64, checkCRC bool) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	if method == zip.Store {
+		rc = io.NopCloser(r)
+	} else if dcomp := decompressorFor(method); dcomp != nil {
+		var err error
+		if rc, err = dcomp(r, size); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("unsupported zip method: %d", method)
+	}
+	return &entryReader{rc: rc, src: r, hash: crc32.NewIEEE(), crc: crc, size: size, checkCRC: checkCRC}, nil
+}
+
+// entryReader reads the decompressed contents of an encrypted entry.
+type entryReader struct {
+	rc       io.ReadCloser
+	src      io.Reader
+	hash     hash.Hash32
+	crc      uint32
+	size     uint64
+	read     uint64
+	checkCRC bool
+}
+
+func (r *entryReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.hash.Write(p[:n])
+	r.read += uint64(n)
+	if r.read > r.size {
+		return n, zip.ErrFormat
+	}
+	if err != io.EOF {
+		return n, err
+	}
+	if _, err := io.Copy(io.Discard, r.src); err != nil {
+		return n, err
+	}
+	if r.read != r.size || r.checkCRC && r.hash.Sum32() != r.crc {
+		return n, errChecksum
+	}
+	return n, io.EOF
+}
+
+func (r *entryReader) Close() error {
+	return r.rc.Close()
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE encryption.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		keys.update(b)
+	}
+	return keys
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) streamByte() byte {
+	temp := uint16(k[2] | 2)
+	return byte((uint32(temp) * uint32(temp^1)) >> 8)
+}
+
+// decrypt decrypts b in place.
+func (k *zipCryptoKeys) decrypt(b []byte) {
+	for i := range b {
+		b[i] ^= k.streamByte()
+		k.update(b[i])
+	}
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.keys.decrypt(p[:n])
+	return n, err
+}
+
+// aesExtra is the AES extra field of an entry.
+type aesExtra struct {
+	version  uint16 // 1 for AE-1, 2 for AE-2, which has no CRC
+	strength byte   // 1, 2 or 3 for 128, 192 or 256 bit keys
+	method   uint16 // Compression method of the entry
+}
+
+func parseAESExtra(extra []byte) (aesExtra, bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == aesExtraID && size >= 7 {
+			return aesExtra{
+				version:  binary.LittleEndian.Uint16(extra),
+				strength: extra[4],
+				method:   binary.LittleEndian.Uint16(extra[5:]),
+			}, true
+		}
+		extra = extra[size:]
+	}
+	return aesExtra{}, false
+}
+
+// openAES returns a reader of the decompressed contents of an AES
+// encrypted entry, whose raw data is read from raw.
+func openAES(zf *zip.File, raw io.Reader, password []byte) (io.ReadCloser, error) {
+	ae, ok := parseAESExtra(zf.Extra)
+	if !ok || ae.strength < 1 || ae.strength > 3 {
+		return nil, errors.New("invalid AES extra field")
+	}
+	keyLen := 8 + 8*int(ae.strength)
+	saltLen := keyLen / 2
+	dataLen := int64(zf.CompressedSize64) - int64(saltLen) - 2 - aesAuthCodeLen
+	if dataLen < 0 {
+		return nil, zip.ErrFormat
+	}
+
+	header := make([]byte, saltLen+2)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	key := pbkdf2SHA1(password, header[:saltLen], 1000, 2*keyLen+2)
+	if subtle.ConstantTimeCompare(key[2*keyLen:], header[saltLen:]) != 1 {
+		return nil, errWrongPassword
+	}
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	decrypted := &aesReader{
+		r:      io.LimitReader(raw, dataLen),
+		raw:    raw,
+		block:  block,
+		mac:    hmac.New(sha1.New, key[keyLen:2*keyLen]),
+		stream: make([]byte, aes.BlockSize),
+		used:   aes.BlockSize,
+	}
+	// AE-2 entries have no CRC, the authentication code checks them
+	return decompressEntry(ae.method, decrypted, zf.CRC32, zf.UncompressedSize64, ae.version != 2)
+}
+
+// aesReader decrypts the data of an AES encrypted entry, which uses AES
+// in counter mode with a little-endian counter starting at one, and
+// checks its authentication code at the end of the data.
+type aesReader struct {
+	r       io.Reader // The encrypted data
+	raw     io.Reader // Followed by the authentication code
+	block   cipher.Block
+	mac     hash.Hash
+	counter [aes.BlockSize]byte
+	stream  []byte
+	used    int  // Bytes of stream already used
+	checked bool // Set once the authentication code has been checked
+}
+
+func (r *aesReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.mac.Write(p[:n])
+	for i := 0; i < n; i++ {
+		if r.used == len(r.stream) {
+			for j := range r.counter {
+				r.counter[j]++
+				if r.counter[j] != 0 {
+					break
+				}
+			}
+			r.block.Encrypt(r.stream, r.counter[:])
+			r.used = 0
+		}
+		p[i] ^= r.stream[r.used]
+		r.used++
+	}
+	if err == io.EOF && !r.checked {
+		r.checked = true
+		code := make([]byte, aesAuthCodeLen)
+		if _, err := io.ReadFull(r.raw, code); err != nil {
+			return n, err
+		}
+		if !hmac.Equal(code, r.mac.Sum(nil)[:aesAuthCodeLen]) {
+			return n, errAuthentication
+		}
+	}
+	return n, err
+}
+
+// pbkdf2SHA1 derives a key of keyLen bytes from password and salt with
+// PBKDF2, as described in RFC 8018, using HMAC-SHA1.
+func pbkdf2SHA1(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,177 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encryptedEntry describes an entry of a ZIP file written by
+// writeEncryptedZip.
+type encryptedEntry struct {
+	name       string
+	content    string
+	method     uint16
+	aesVersion uint16 // Encrypted with AES if not zero, otherwise ZipCrypto
+}
+
+// writeEncryptedZip writes a ZIP file of entries encrypted with
+// password.
+func writeEncryptedZip(t *testing.T, name string, password string, entries ...encryptedEntry) {
+	t.Helper()
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		var compressed bytes.Buffer
+		if e.method == zip.Deflate {
+			fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+			fw.Write([]byte(e.content))
+			fw.Close()
+		} else {
+			compressed.WriteString(e.content)
+		}
+		fh := &zip.FileHeader{
+			Name:               e.name,
+			Method:             e.method,
+			Flags:              0x1,
+			CRC32:              crc32.ChecksumIEEE([]byte(e.content)),
+			UncompressedSize64: uint64(len(e.content)),
+		}
+
+		var raw []byte
+		if e.aesVersion == 0 {
+			keys := newZipCryptoKeys([]byte(password))
+			plain := append(make([]byte, zipCryptoHeaderLen-1), byte(fh.CRC32>>24))
+			plain = append(plain, compressed.Bytes()...)
+			raw = make([]byte, len(plain))
+			for i, b := range plain {
+				raw[i] = b ^ keys.streamByte()
+				keys.update(b)
+			}
+		} else {
+			salt := bytes.Repeat([]byte{7}, 16)
+			key := pbkdf2SHA1([]byte(password), salt, 1000, 66)
+			block, err := aes.NewCipher(key[:32])
+			require.NoError(err)
+			// Counter mode encryption is the same as decryption
+			enc := &aesReader{
+				r:       bytes.NewReader(compressed.Bytes()),
+				block:   block,
+				mac:     hmac.New(sha1.New, nil),
+				stream:  make([]byte, aes.BlockSize),
+				used:    aes.BlockSize,
+				checked: true,
+			}
+			ciphertext, err := io.ReadAll(enc)
+			require.NoError(err)
+			mac := hmac.New(sha1.New, key[32:64])
+			mac.Write(ciphertext)
+			raw = append(append(append(salt, key[64:]...), ciphertext...), mac.Sum(nil)[:aesAuthCodeLen]...)
+
+			fh.Extra = binary.LittleEndian.AppendUint16(nil, aesExtraID)
+			fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, 7)
+			fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, e.aesVersion)
+			fh.Extra = append(fh.Extra, 'A', 'E', 3)
+			fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, e.method)
+			fh.Method = methodAES
+			if e.aesVersion == 2 {
+				fh.CRC32 = 0
+			}
+		}
+		fh.CompressedSize64 = uint64(len(raw))
+		w, err := zw.CreateRaw(fh)
+		require.NoError(err)
+		_, err = w.Write(raw)
+		require.NoError(err)
+	}
+	w, err := zw.Create("plain.txt")
+	require.NoError(err)
+	w.Write([]byte("not encrypted"))
+	require.NoError(zw.Close())
+	require.NoError(os.WriteFile(name, buf.Bytes(), 0644))
+}
+
+func TestPBKDF2(t *testing.T) {
+	assert := assert.New(t)
+
+	// Test vectors of RFC 6070
+	key := pbkdf2SHA1([]byte("password"), []byte("salt"), 1, 20)
+	assert.Equal("0c60c80f961f0e71f3a9b524af6012062fe037a6", hex.EncodeToString(key))
+	key = pbkdf2SHA1([]byte("password"), []byte("salt"), 4096, 20)
+	assert.Equal("4b007901b765489abead49d926f721d065a429c1", hex.EncodeToString(key))
+	key = pbkdf2SHA1([]byte("passwordPASSWORDpassword"), []byte("saltSALTsaltSALTsaltSALTsaltSALTsalt"), 4096, 25)
+	assert.Equal("3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038", hex.EncodeToString(key))
+}
+
+func TestEncryptedEntries(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	long := strings.Repeat("encrypted and compressed ", 1000)
+	name := filepath.Join(t.TempDir(), "secret.zip")
+	writeEncryptedZip(t, name, "hunter2",
+		encryptedEntry{name: "stored.txt", content: "zipcrypto stored", method: zip.Store},
+		encryptedEntry{name: "deflated.txt", content: long, method: zip.Deflate},
+		encryptedEntry{name: "ae1.txt", content: "aes stored", method: zip.Store, aesVersion: 1},
+		encryptedEntry{name: "ae2.txt", content: long, method: zip.Deflate, aesVersion: 2},
+	)
+
+	fs, err := NewWithPassword(name, "hunter2")
+	require.NoError(err)
+	defer fs.Close()
+	for name, want := range map[string]string{
+		"stored.txt":   "zipcrypto stored",
+		"deflated.txt": long,
+		"ae1.txt":      "aes stored",
+		"ae2.txt":      long,
+		"plain.txt":    "not encrypted",
+	} {
+		assert.Equal(want, readTestFile(t, fs, name), name)
+	}
+
+	wrong, err := NewWithPassword(name, "wrong")
+	require.NoError(err)
+	defer wrong.Close()
+	_, err = iofs.ReadFile(wrong, "stored.txt")
+	assert.Equal(errWrongPassword, err)
+	_, err = iofs.ReadFile(wrong, "ae2.txt")
+	assert.Equal(errWrongPassword, err)
+
+	// Entries are decrypted when served, and forbidden without a password
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithCompression(true))
+	w := serveTest(handler, "GET", "/deflated.txt", "", "Accept-Encoding", "deflate")
+	assert.Equal(200, w.status)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(long, w.buf.String())
+	assert.Equal("aes stored", serveTest(handler, "GET", "/ae1.txt", "").buf.String())
+
+	locked, err := New(name)
+	require.NoError(err)
+	defer locked.Close()
+	handler = FileServer(locked, "api/", "", false, nil, nil)
+	assert.Equal(403, serveTest(handler, "GET", "/ae2.txt", "").status)
+	assert.Equal(403, serveTest(handler, "GET", "/stored.txt", "").status)
+	assert.Equal("not encrypted", serveTest(handler, "GET", "/plain.txt", "").buf.String())
+
+	// Archives mounted through the API can have a password
+	handler = EmptyFileServer("api/", "", false, nil, filepath.Dir(name), "", nil, nil, t.TempDir())
+	mountTestZip(t, handler, `{"filePath": "secret.zip", "password": "hunter2"}`)
+	assert.Equal("zipcrypto stored", serveTest(handler, "GET", "/stored.txt", "").buf.String())
+}
@@ -0,0 +1,54 @@
+package zipfs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrorHandler writes the response to a request for a file that could
+// not be served with the given status. The err is the underlying error,
+// such as an os.ErrNotExist or os.ErrPermission error.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// WithErrorHandler writes error responses with handler instead of a
+// plain text message, so that applications can render their own error
+// pages or translate statuses. It is not used for errors of the API
+// endpoints, or for 404 responses handled by WithNotFoundPage or
+// WithNotFoundHandler.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(h *fileHandler) {
+		h.errorHandler = handler
+	}
+}
+
+// withErrorHandler makes the error handler available to httpError.
+func (h *fileHandler) withErrorHandler(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), errorHandlerKey, h.errorHandler)
+		if _, ok := ctx.Value(errorInfoKey).(*errorInfo); !ok {
+			// The error handler is passed the error given to recordError
+			ctx = context.WithValue(ctx, errorInfoKey, &errorInfo{})
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// httpError responds to a request for a file with an error, using the
+// error handler if one is set, or http.Error otherwise. The error
+// passed to the handler is the one last given to recordError.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	handler, _ := r.Context().Value(errorHandlerKey).(ErrorHandler)
+	if handler == nil {
+		http.Error(w, msg, code)
+		return
+	}
+	var err error
+	if info, ok := r.Context().Value(errorInfoKey).(*errorInfo); ok {
+		err = info.err
+	}
+	if err == nil {
+		err = errors.New(msg)
+	}
+	handler(w, r, code, err)
+}
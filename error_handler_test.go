@@ -0,0 +1,61 @@
+package zipfs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.html", "hello", "dir/other.html", "other")
+	defer fs.Close()
+
+	var errs []error
+	var events []ErrorEvent
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithErrorHook(func(e ErrorEvent) { events = append(events, e) }, 1),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			errs = append(errs, err)
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "<h1>Error %d</h1>", status)
+		}),
+	)
+
+	w := serveTest(handler, "GET", "/missing.html", "")
+	assert.Equal(404, w.status)
+	assert.Equal("<h1>Error 404</h1>", w.buf.String())
+	assert.Equal("text/html", w.Header().Get("Content-Type"))
+
+	w = serveTest(handler, "GET", "/dir/", "")
+	assert.Equal(403, w.status)
+	assert.Equal("<h1>Error 403</h1>", w.buf.String())
+
+	w = serveTest(handler, "DELETE", "/page.html", "")
+	assert.Equal(405, w.status)
+	assert.Equal("<h1>Error 405</h1>", w.buf.String())
+	assert.Equal("GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	if assert.Len(errs, 3) {
+		assert.True(errors.Is(errs[0], os.ErrNotExist))
+		assert.Equal(errDirectory, errs[1])
+		assert.Equal(errMethodNotAllowed, errs[2])
+	}
+
+	// The error hook still sees every failure
+	if assert.Len(events, 3) {
+		assert.Equal(404, events[0].Status)
+		assert.True(errors.Is(events[0].Err, os.ErrNotExist))
+	}
+
+	// Files that exist are not affected
+	w = serveTest(handler, "GET", "/page.html", "")
+	assert.Equal(200, w.status)
+	assert.Equal("hello", w.buf.String())
+}
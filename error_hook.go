@@ -0,0 +1,75 @@
+package zipfs
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+)
+
+// ErrorEvent describes a request that resulted in a 4xx or 5xx response.
+type ErrorEvent struct {
+	Request *http.Request // The request that failed
+	Status  int           // Status code sent to the client
+	Path    string        // Resolved path of the entry, if known
+	Err     error         // Underlying error, if known
+}
+
+// WithErrorHook calls hook for every request that results in a 4xx or
+// 5xx response. If sampleRate is between 0 and 1, only that fraction of
+// failed requests is passed to hook; otherwise every failure is.
+func WithErrorHook(hook func(ErrorEvent), sampleRate float64) Option {
+	return func(h *fileHandler) {
+		h.errorHook = hook
+		h.errorSampleRate = sampleRate
+	}
+}
+
+// errorInfo holds the details of a failure while serving a request.
+type errorInfo struct {
+	path string
+	err  error
+}
+
+// recordError attaches the resolved path and underlying error of a
+// failed request so they can be reported to the error hook.
+func recordError(r *http.Request, path string, err error) {
+	if info, ok := r.Context().Value(errorInfoKey).(*errorInfo); ok {
+		info.path = path
+		info.err = err
+	}
+}
+
+// withErrorInfo attaches an errorInfo to the request for recordError,
+// unless an outer layer already has, and returns it.
+func withErrorInfo(r *http.Request) (*http.Request, *errorInfo) {
+	if info, ok := r.Context().Value(errorInfoKey).(*errorInfo); ok {
+		return r, info
+	}
+	info := &errorInfo{}
+	return r.WithContext(context.WithValue(r.Context(), errorInfoKey, info)), info
+}
+
+// withErrorHook serves the request and reports it to the error hook
+// if it failed.
+func (h *fileHandler) withErrorHook(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, info := withErrorInfo(r)
+		sw := &statusWriter{ResponseWriter: w}
+
+		next(sw, r)
+
+		status := sw.Status()
+		if status < 400 {
+			return
+		}
+		if h.errorSampleRate > 0 && h.errorSampleRate < 1 && rand.Float64() >= h.errorSampleRate {
+			return
+		}
+		h.errorHook(ErrorEvent{
+			Request: r,
+			Status:  status,
+			Path:    info.path,
+			Err:     info.err,
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package zipfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHook(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", "hello",
+		"dir/", "",
+	)
+
+	var events []ErrorEvent
+	hook := func(e ErrorEvent) {
+		events = append(events, e)
+	}
+
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil, WithErrorHook(hook, 0))
+
+	serveTest(handler, "GET", "/index.html", "")
+	serveTest(handler, "GET", "/", "")
+	assert.Len(events, 0)
+
+	w := serveTest(handler, "GET", "/missing.txt", "")
+	assert.Equal(404, w.status)
+	if assert.Len(events, 1) {
+		assert.Equal(404, events[0].Status)
+		assert.Equal("/missing.txt", events[0].Path)
+		assert.True(os.IsNotExist(events[0].Err))
+		assert.Equal("/missing.txt", events[0].Request.URL.Path)
+	}
+
+	w = serveTest(handler, "GET", "/dir/", "")
+	assert.Equal(403, w.status)
+	if assert.Len(events, 2) {
+		assert.Equal(403, events[1].Status)
+		assert.Equal("dir/", events[1].Path)
+		assert.Equal(errDirectory, events[1].Err)
+	}
+
+	// A tiny sample rate drops (practically) every event
+	events = nil
+	handler = FileServer(fs, "api/", "", false, []string{"html"}, nil, WithErrorHook(hook, 1e-12))
+	for i := 0; i < 10; i++ {
+		serveTest(handler, "GET", "/missing.txt", "")
+	}
+	assert.Len(events, 0)
+}
@@ -0,0 +1,87 @@
+package zipfs
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// EtagAlgorithm selects how the ETags of entries are derived, see
+// WithEtagAlgorithm.
+type EtagAlgorithm int
+
+const (
+	// EtagCRC derives ETags from the CRC-32 and size of entries. It is
+	// the default, as it needs nothing but the central directory.
+	EtagCRC EtagAlgorithm = iota
+	// EtagModTime derives ETags from the size and modification time of
+	// entries, like many web servers do for files.
+	EtagModTime
+	// EtagSHA256 derives ETags from the SHA-256 hash of the contents of
+	// entries, which is computed in the background as described for
+	// WithContentHashEtags.
+	EtagSHA256
+)
+
+// WithEtagAlgorithm serves entries with ETags derived by algorithm. With
+// EtagSHA256, the hashes are computed by one goroutine per CPU unless
+// WithContentHashEtags sets another number.
+func WithEtagAlgorithm(algorithm EtagAlgorithm) Option {
+	return func(h *fileHandler) {
+		h.etagAlgorithm = algorithm
+		if algorithm == EtagSHA256 && h.hashWorkers == 0 {
+			h.hashWorkers = runtime.NumCPU()
+		}
+	}
+}
+
+// WithEtagFunc serves entries with ETags returned by fn, which takes
+// precedence over WithEtagAlgorithm. fn returns the opaque part of the
+// ETag, without the double quotes, which must only contain characters
+// allowed in ETags. The ETag of WithEtagAlgorithm is used for entries
+// for which fn returns an empty string. The ETags of compressed variants
+// of the entries are derived from the ETags fn returns.
+func WithEtagFunc(fn func(info *FileInfo) string) Option {
+	return func(h *fileHandler) {
+		h.etagFunc = fn
+	}
+}
+
+// WithWeakEtags serves entries with weak ETags (W/"..."), which only
+// promise that the contents are equivalent rather than identical. Weak
+// ETags still make conditional GET requests with If-None-Match cheap,
+// but never match If-Match, and ranges are not requested with If-Range
+// for them, as RFC 9110 requires the strong comparison there. This suits
+// ETag algorithms that may give the same ETag to contents that differ,
+// such as EtagModTime.
+func WithWeakEtags() Option {
+	return func(h *fileHandler) {
+		h.weakEtags = true
+	}
+}
+
+// entryEtag returns the ETag that fi is served with.
+func (h *fileHandler) entryEtag(fi *fileInfo) string {
+	etag := h.strongEtag(fi)
+	if h.weakEtags {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// strongEtag returns the ETag of fi before WithWeakEtags is applied.
+func (h *fileHandler) strongEtag(fi *fileInfo) string {
+	if h.etagFunc != nil {
+		if tag := h.etagFunc(fi.info()); tag != "" {
+			return `"` + tag + `"`
+		}
+	}
+	switch h.etagAlgorithm {
+	case EtagModTime:
+		return fmt.Sprintf(`"%x-%x"`, fi.ModTime().Unix(), fi.Size())
+	case EtagSHA256:
+		if sum := fi.contentHash.Load(); sum != nil {
+			return fmt.Sprintf(`"sha256-%x"`, *sum)
+		}
+	}
+	return calcEtag(fi.zipFile)
+}
@@ -0,0 +1,99 @@
+package zipfs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.txt", "contents", "custom.txt", "custom")
+	page := fs.fileInfos["page.txt"]
+	etag := func(opts ...Option) string {
+		w := serveTest(FileServer(fs, "api/", "", false, nil, nil, opts...), "GET", "/page.txt", "")
+		assert.Equal(http.StatusOK, w.status)
+		return w.Header().Get("Etag")
+	}
+
+	assert.Equal(calcEtag(page.zipFile), etag())
+	assert.Equal(calcEtag(page.zipFile), etag(WithEtagAlgorithm(EtagCRC)))
+	assert.Equal(fmt.Sprintf(`"%x-%x"`, page.ModTime().Unix(), len("contents")), etag(WithEtagAlgorithm(EtagModTime)))
+
+	// Entries the function returns nothing for use the algorithm
+	custom := WithEtagFunc(func(info *FileInfo) string {
+		if !strings.HasPrefix(info.Path, "custom") {
+			return ""
+		}
+		return fmt.Sprintf("custom-%08x", info.CRC32)
+	})
+	assert.Equal(fmt.Sprintf(`"%x-%x"`, page.ModTime().Unix(), len("contents")), etag(WithEtagAlgorithm(EtagModTime), custom))
+	w := serveTest(FileServer(fs, "api/", "", false, nil, nil, custom), "GET", "/custom.txt", "")
+	assert.Equal(fmt.Sprintf(`"custom-%08x"`, fs.fileInfos["custom.txt"].zipFile.CRC32), w.Header().Get("Etag"))
+}
+
+func TestWeakEtags(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.txt", "contents")
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithWeakEtags())
+	strong := calcEtag(fs.fileInfos["page.txt"].zipFile)
+	weak := "W/" + strong
+
+	assert.Equal(weak, serveTest(handler, "GET", "/page.txt", "").Header().Get("Etag"))
+	// If-None-Match uses the weak comparison
+	assert.Equal(http.StatusNotModified, serveTest(handler, "GET", "/page.txt", "", "If-None-Match", weak).status)
+	assert.Equal(http.StatusNotModified, serveTest(handler, "GET", "/page.txt", "", "If-None-Match", strong).status)
+	assert.Equal(http.StatusNotModified, serveTest(handler, "GET", "/page.txt", "", "If-None-Match", `"a,b", `+weak).status)
+	assert.Equal(http.StatusOK, serveTest(handler, "GET", "/page.txt", "", "If-None-Match", `"other"`).status)
+	// If-Match and If-Range use the strong comparison
+	assert.Equal(http.StatusPreconditionFailed, serveTest(handler, "GET", "/page.txt", "", "If-Match", weak).status)
+	w := serveTest(handler, "GET", "/page.txt", "", "Range", "bytes=0-3", "If-Range", weak)
+	assert.Equal(http.StatusOK, w.status)
+	assert.Equal("contents", w.buf.String())
+}
+
+func TestEtagListMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(etagStrongMatch(`"x", "a,b"`, `"a,b"`))
+	assert.False(etagStrongMatch(`"a`, `"a,b"`))
+	assert.False(etagStrongMatch(`W/"a"`, `"a"`))
+	assert.True(etagWeakMatch(`W/"a"`, `"a"`))
+	assert.True(etagWeakMatch(`*`, `W/"a"`))
+	assert.False(etagWeakMatch(`a, "a"`, `"a"`))
+}
+
+func TestEncodingEtags(t *testing.T) {
+	assert := assert.New(t)
+
+	content := strings.Repeat("deflated ", 100)
+	fs := newTestFileSystem(t, "page.txt", content)
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithCompression(true))
+	etag := calcEtag(fs.fileInfos["page.txt"].zipFile)
+
+	w := serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "deflate")
+	assert.Equal("deflate", w.Header().Get("Content-Encoding"))
+	assert.Equal(deflateEtag(etag), w.Header().Get("Etag"))
+	w = serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "gzip")
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(gzipEtag(etag), w.Header().Get("Etag"))
+	w = serveTest(handler, "GET", "/page.txt", "")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(etag, w.Header().Get("Etag"))
+
+	// A range of the identity encoding is not taken for a range of the
+	// deflate encoding, and the whole contents are sent unencoded
+	w = serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "deflate", "Range", "bytes=0-7", "If-Range", deflateEtag(etag))
+	assert.Equal(http.StatusOK, w.status)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(etag, w.Header().Get("Etag"))
+	assert.Equal(content, w.buf.String())
+	w = serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "deflate", "Range", "bytes=0-7", "If-Range", etag)
+	assert.Equal(http.StatusPartialContent, w.status)
+	assert.Equal(content[:8], w.buf.String())
+}
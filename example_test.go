@@ -1,17 +1,32 @@
 package zipfs_test
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/FlashpointProject/zipfs"
 )
 
-func Example() error {
+func Example() {
 	fs, err := zipfs.New("testdata/testdata.zip")
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
 
 	extensions := []string{"html", "htm"}
-	return http.ListenAndServe(":8080", zipfs.FileServer(fs, "test/base/api/", "", true, extensions, nil))
+	log.Fatal(http.ListenAndServe(":8080", zipfs.FileServer(fs, "test/base/api/", "", true, extensions, nil)))
+}
+
+func ExampleFileServerWithOptions() {
+	fs, err := zipfs.New("testdata/testdata.zip")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := zipfs.FileServerWithOptions(fs,
+		zipfs.WithAPIPrefix("api/"),
+		zipfs.WithIndexExtensions("html", "htm"),
+		zipfs.WithNotFoundHandler(http.NotFoundHandler()),
+	)
+	log.Fatal(http.ListenAndServe(":8080", handler))
 }
@@ -0,0 +1,81 @@
+package zipfs
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// openReaders is the number of readers of entries that have not been
+// closed yet, across every archive.
+var openReaders atomic.Int64
+
+// countedReader is a reader of an entry that is counted in openReaders
+// until it is closed.
+type countedReader struct {
+	io.ReadCloser
+	closeOnce sync.Once
+}
+
+func (r *countedReader) Close() error {
+	r.closeOnce.Do(func() { openReaders.Add(-1) })
+	return r.ReadCloser.Close()
+}
+
+// WithExpvar publishes counters of the file server with the expvar
+// package, as a map named name holding the number of requests
+// ("requests"), of those that were answered with 404 Not Found
+// ("notFound"), the number of body bytes served ("bytesOut") and the
+// number of entries being read across every file server
+// ("openReaders"). They are served as JSON by expvar.Handler, which is
+// registered at /debug/vars of http.DefaultServeMux. As expvar names are
+// global, every file server must be given a name of its own.
+func WithExpvar(name string) Option {
+	return func(h *fileHandler) {
+		h.expvarName = name
+	}
+}
+
+// expvarCounters are the counters of WithExpvar.
+type expvarCounters struct {
+	requests expvar.Int
+	notFound expvar.Int
+	bytesOut expvar.Int
+}
+
+// publishExpvar publishes the counters of WithExpvar. It fails if the
+// name is already taken, which expvar.Publish would panic on.
+func (h *fileHandler) publishExpvar() error {
+	if expvar.Get(h.expvarName) != nil {
+		return fmt.Errorf("expvar: %s is already published", h.expvarName)
+	}
+	vars := &expvarCounters{}
+	m := &expvar.Map{}
+	m.Set("requests", &vars.requests)
+	m.Set("notFound", &vars.notFound)
+	m.Set("bytesOut", &vars.bytesOut)
+	m.Set("openReaders", expvar.Func(func() any {
+		return openReaders.Load()
+	}))
+	expvar.Publish(h.expvarName, m)
+	h.expvars = vars
+	return nil
+}
+
+// withExpvar serves the request and counts it in the counters of
+// WithExpvar.
+func (h *fileHandler) withExpvar(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+
+		h.expvars.requests.Add(1)
+		if sw.Status() == http.StatusNotFound {
+			h.expvars.notFound.Add(1)
+		}
+		h.expvars.bytesOut.Add(sw.written)
+	}
+}
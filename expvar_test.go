@@ -0,0 +1,50 @@
+package zipfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvar(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "test.txt", "hello")
+	h := FileServer(fs, "api/", "", false, nil, nil, WithExpvar("zipfs_test"))
+	for _, name := range []string{"/test.txt", "/test.txt", "/missing.txt"} {
+		serveTest(h, "GET", name, "")
+	}
+
+	var vars struct {
+		Requests    int64 `json:"requests"`
+		NotFound    int64 `json:"notFound"`
+		BytesOut    int64 `json:"bytesOut"`
+		OpenReaders int64 `json:"openReaders"`
+	}
+	v := expvar.Get("zipfs_test")
+	require.NotNil(v)
+	require.NoError(json.Unmarshal([]byte(v.String()), &vars))
+	assert.Equal(int64(3), vars.Requests)
+	assert.Equal(int64(1), vars.NotFound)
+	assert.Equal(int64(2*len("hello")+len("404 page not found\n")), vars.BytesOut)
+
+	// Readers are counted until they are closed
+	before := openReaders.Load()
+	reader, err := fs.fileInfos["test.txt"].open()
+	require.NoError(err)
+	assert.Equal(before+1, openReaders.Load())
+	reader.Close()
+	reader.Close()
+	assert.Equal(before, openReaders.Load())
+
+	// The name is taken
+	var buf bytes.Buffer
+	FileServer(fs, "api/", "", false, nil, nil, WithLogger(log.New(&buf, "", 0)), WithExpvar("zipfs_test"))
+	assert.Contains(buf.String(), "zipfs_test is already published")
+}
@@ -0,0 +1,31 @@
+package zipfs
+
+import "net/http"
+
+// WithNotFoundFallback delegates requests for paths that are not in any
+// mounted archive to handler, which may be another file server, a reverse
+// proxy or an API. Unlike a handler given to WithNotFoundHandler, the
+// fallback is not treated as an error response: it takes precedence over
+// the page of WithNotFoundPage, and misses it handles are not reported as
+// errors. Paths that are hidden or blocked by the path rules are never
+// passed to it.
+func WithNotFoundFallback(handler http.Handler) Option {
+	return func(h *fileHandler) {
+		h.notFound = handler
+		h.notFoundFallback = true
+	}
+}
+
+// serveFallback serves the request for a path that is not in any archive
+// with the handler of WithNotFoundFallback, and reports whether there is
+// one.
+func (h *fileHandler) serveFallback(w http.ResponseWriter, r *http.Request) bool {
+	if h.notFound == nil || !h.notFoundFallback {
+		return false
+	}
+	if h.isVerbose {
+		h.logf("[Zipfs] Falling back for: %s\n", r.URL.Path)
+	}
+	h.notFound.ServeHTTP(w, r)
+	return true
+}
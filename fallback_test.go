@@ -0,0 +1,53 @@
+package zipfs
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.txt", "from zip",
+		"404.html", "not found page",
+		".env", "secret",
+	)
+	defer fs.Close()
+
+	var events []ErrorEvent
+	var logged bytes.Buffer
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Fallback", "1")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(body))
+	})
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithNotFoundFallback(fallback),
+		WithNotFoundPage("404.html"),
+		WithHiddenPatterns(".*"),
+		WithLogger(log.New(&logged, "", 0)),
+		WithErrorHook(func(e ErrorEvent) { events = append(events, e) }, 1))
+
+	assert.Equal("from zip", serveTest(handler, "GET", "/index.txt", "").buf.String())
+
+	w := serveTest(handler, "POST", "/api/v1/items", "payload")
+	assert.Equal(201, w.status)
+	assert.Equal("POST /api/v1/items payload", w.buf.String())
+	assert.Empty(events)
+	assert.Empty(logged.String())
+
+	w = serveTest(handler, "GET", "/.env", "")
+	assert.Equal(404, w.status)
+	assert.Empty(w.Header().Get("X-Fallback"))
+
+	// Without archives, everything falls back
+	handler = FileServers(nil, "api/", "", false, nil, nil, WithNotFoundFallback(fallback))
+	assert.Equal("GET /index.txt ", serveTest(handler, "GET", "/index.txt", "").buf.String())
+}
@@ -0,0 +1,43 @@
+package zipfs
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultFavicon is a small built-in icon that can be passed to
+// WithFavicon.
+//
+//go:embed favicon.ico
+var DefaultFavicon []byte
+
+// WithFavicon serves icon in response to requests for /favicon.ico when
+// none of the mounted archives contain one, instead of logging and
+// returning a 404 on every page view. If icon is nil, the fallback
+// response is a 204 No Content.
+func WithFavicon(icon []byte) Option {
+	return func(h *fileHandler) {
+		h.favicon = &faviconFallback{icon: icon}
+	}
+}
+
+type faviconFallback struct {
+	icon []byte
+}
+
+func isFavicon(name string) bool {
+	return strings.ToLower(name) == "/favicon.ico"
+}
+
+func (f *faviconFallback) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if f.icon == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "image/x-icon")
+	http.ServeContent(w, r, "favicon.ico", time.Time{}, bytes.NewReader(f.icon))
+}
@@ -0,0 +1,34 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFavicon(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "index.html", "hello")
+
+	w := serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/favicon.ico", "")
+	assert.Equal(404, w.status)
+
+	w = serveTest(FileServer(fs, "api/", "", false, nil, nil, WithFavicon(DefaultFavicon)), "GET", "/favicon.ico", "")
+	assert.Equal(200, w.status)
+	assert.Equal("image/x-icon", w.Header().Get("Content-Type"))
+	assert.Equal(DefaultFavicon, w.buf.Bytes())
+
+	w = serveTest(FileServer(fs, "api/", "", false, nil, nil, WithFavicon(nil)), "GET", "/favicon.ico", "")
+	assert.Equal(204, w.status)
+	assert.Equal(0, w.buf.Len())
+
+	w = serveTest(EmptyFileServer("api/", "", false, nil, "", "", nil, nil, "", WithFavicon(nil)), "GET", "/favicon.ico", "")
+	assert.Equal(204, w.status)
+
+	// An icon inside the archive always wins
+	fs = newTestFileSystem(t, "favicon.ico", "zipped icon")
+	w = serveTest(FileServer(fs, "api/", "", false, nil, nil, WithFavicon(DefaultFavicon)), "GET", "/favicon.ico", "")
+	assert.Equal(200, w.status)
+	assert.Equal("zipped icon", w.buf.String())
+}
@@ -0,0 +1,78 @@
+package zipfs
+
+import (
+	"os"
+	"time"
+)
+
+// FileInfo describes an entry of a FileSystem, including the details
+// recorded for it in the ZIP file. It implements os.FileInfo, and is the
+// concrete type of the os.FileInfo values returned by the Stat and
+// Readdir methods of files opened from a FileSystem.
+//
+// Directories that have no entry of their own in the ZIP file only have
+// a Path.
+type FileInfo struct {
+	Path             string // Path of the entry within the ZIP file
+	CompressedSize   int64
+	UncompressedSize int64
+	CRC32            uint32
+	Method           uint16 // Compression method, such as zip.Deflate
+	ETag             string // ETag the entry is served with
+
+	fi *fileInfo
+}
+
+func (fi *fileInfo) info() *FileInfo {
+	info := &FileInfo{
+		Path: fi.name,
+		fi:   fi,
+	}
+	if zf := fi.zipFile; zf != nil {
+		info.Path = zf.Name
+		info.CompressedSize = int64(zf.CompressedSize64)
+		if info.CompressedSize == 0 {
+			info.CompressedSize = int64(zf.CompressedSize)
+		}
+		info.UncompressedSize = fi.Size()
+		info.CRC32 = zf.CRC32
+		info.Method = zf.Method
+		info.ETag = calcEtag(zf)
+	}
+	return info
+}
+
+func (info *FileInfo) Name() string {
+	return info.fi.Name()
+}
+
+func (info *FileInfo) Size() int64 {
+	return info.UncompressedSize
+}
+
+func (info *FileInfo) Mode() os.FileMode {
+	return info.fi.Mode()
+}
+
+func (info *FileInfo) ModTime() time.Time {
+	return info.fi.ModTime()
+}
+
+func (info *FileInfo) IsDir() bool {
+	return info.fi.IsDir()
+}
+
+// Sys returns the *zip.File of the entry, or nil if it has none.
+func (info *FileInfo) Sys() interface{} {
+	return info.fi.Sys()
+}
+
+// Stat returns the FileInfo of the named entry. The concrete type of
+// the result is *FileInfo.
+func (fs *FileSystem) Stat(name string) (os.FileInfo, error) {
+	fi, err := fs.current().openFileInfo(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "Stat", Path: name, Err: err}
+	}
+	return fi.info(), nil
+}
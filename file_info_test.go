@@ -0,0 +1,82 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileInfoZipFields(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs, err := New("testdata/testdata.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	stat, err := fs.Stat("/img/circle.png")
+	require.NoError(err)
+	info, ok := stat.(*FileInfo)
+	require.True(ok)
+	assert.Equal("img/circle.png", info.Path)
+	assert.Equal("circle.png", info.Name())
+	assert.Equal(int64(5973), info.UncompressedSize)
+	assert.Equal(info.UncompressedSize, info.Size())
+	assert.NotZero(info.CompressedSize)
+	assert.Equal(zip.Deflate, info.Method)
+	assert.Equal(`"1755529fb2ff"`, info.ETag)
+	assert.Equal(calcEtag(info.Sys().(*zip.File)), info.ETag)
+
+	// Readdir returns the same details
+	f, err := HTTPFileSystem(fs).Open("/img")
+	require.NoError(err)
+	entries, err := f.Readdir(0)
+	require.NoError(err)
+	var found *FileInfo
+	for _, entry := range entries {
+		if entry.Name() == "circle.png" {
+			found = entry.(*FileInfo)
+		}
+	}
+	require.NotNil(found)
+	assert.Equal(info.CRC32, found.CRC32)
+
+	_, err = fs.Stat("/does/not/exist")
+	assert.Error(err)
+}
+
+func TestExtendedTimestamp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// zip.Writer adds an extended timestamp extra field for Modified
+	modified := time.Date(2024, 3, 9, 17, 45, 31, 0, time.FixedZone("CET", 3600))
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.CreateHeader(&zip.FileHeader{Name: "extended.txt", Modified: modified})
+	require.NoError(err)
+	// Without Modified, only the MS-DOS time, of even seconds, is
+	// written
+	var dos zip.FileHeader
+	dos.SetModTime(modified) //nolint:staticcheck
+	_, err = zw.CreateHeader(&zip.FileHeader{Name: "dos.txt", ModifiedDate: dos.ModifiedDate, ModifiedTime: dos.ModifiedTime})
+	require.NoError(err)
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	info, err := fs.Stat("extended.txt")
+	require.NoError(err)
+	assert.True(modified.Equal(info.ModTime()), info.ModTime())
+	info, err = fs.Stat("dos.txt")
+	require.NoError(err)
+	assert.Equal(time.Date(2024, 3, 9, 16, 45, 30, 0, time.UTC), info.ModTime())
+
+	w := serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/extended.txt", "")
+	assert.Equal("Sat, 09 Mar 2024 16:45:31 GMT", w.Header().Get("Last-Modified"))
+}
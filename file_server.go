@@ -9,17 +9,25 @@ package zipfs
 
 import (
 	"archive/zip"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"io"
+	"log"
+	"log/slog"
 	"mime"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/text/encoding"
 )
 
 // FileServer returns a HTTP handler that serves
@@ -27,7 +35,7 @@ import (
 // It provides slightly better performance than the
 // http.FileServer implementation because it serves compressed content
 // to clients that can accept the "deflate" compression algorithm.
-func FileServer(fs *FileSystem, baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, mimeExts map[string]string) http.Handler {
+func FileServer(fs *FileSystem, baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, mimeExts map[string]string, opts ...Option) http.Handler {
 	fsVal := []*FileSystem{fs}
 	h := &fileHandler{
 		fs:          fsVal,
@@ -37,11 +45,12 @@ func FileServer(fs *FileSystem, baseAPIPath string, urlPrepend string, isVerbose
 		indexExts:   indexExts,
 		mimeExts:    mimeExts,
 	}
+	h.applyOptions(opts)
 
 	return h
 }
 
-func FileServers(fs []*FileSystem, baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, mimeExts map[string]string) http.Handler {
+func FileServers(fs []*FileSystem, baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, mimeExts map[string]string, opts ...Option) http.Handler {
 	h := &fileHandler{
 		fs:          fs,
 		baseAPIPath: baseAPIPath,
@@ -50,12 +59,13 @@ func FileServers(fs []*FileSystem, baseAPIPath string, urlPrepend string, isVerb
 		indexExts:   indexExts,
 		mimeExts:    mimeExts,
 	}
+	h.applyOptions(opts)
 
 	return h
 }
 
-func EmptyFileServer(baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, baseMountDir string, phpPath string, mimeExts map[string]string, overrideBases []string, htdocsPath string) http.Handler {
-	return &fileHandler{
+func EmptyFileServer(baseAPIPath string, urlPrepend string, isVerbose bool, indexExts []string, baseMountDir string, phpPath string, mimeExts map[string]string, overrideBases []string, htdocsPath string, opts ...Option) http.Handler {
+	h := &fileHandler{
 		baseAPIPath:   baseAPIPath,
 		isVerbose:     isVerbose,
 		urlPrepend:    urlPrepend,
@@ -66,23 +76,110 @@ func EmptyFileServer(baseAPIPath string, urlPrepend string, isVerbose bool, inde
 		overrideBases: overrideBases,
 		htdocsPath:    htdocsPath,
 	}
+	h.applyOptions(opts)
+
+	return h
 }
 
 type fileHandler struct {
-	fs            []*FileSystem
-	baseAPIPath   string
-	isVerbose     bool
-	urlPrepend    string
-	indexExts     []string
-	baseMountDir  string
-	phpPath       string
-	mimeExts      map[string]string
-	overrideBases []string
-	htdocsPath    string
+	fs               []*FileSystem
+	baseAPIPath      string
+	noAPI            bool // Set if the API endpoints are disabled
+	isVerbose        bool
+	urlPrepend       string
+	indexExts        []string
+	baseMountDir     string
+	phpPath          string
+	mimeExts         map[string]string
+	overrideBases    []string
+	htdocsPath       string
+	dirConfig        bool
+	renderers        map[string]Renderer // See WithRenderer
+	renderCache      *cacheStore
+	favicon          *faviconFallback
+	errorHook        func(ErrorEvent)
+	errorHandler     ErrorHandler
+	errorSampleRate  float64
+	logger           *log.Logger
+	slog             *slog.Logger
+	slogRequests     bool // See WithSlog
+	accessLog        *accessLog
+	mountEvents      func(MountEvent)
+	mountAudit       *mountAudit
+	slowThreshold    time.Duration
+	observers        []Observer
+	metricsHandler   http.Handler // See WithObserver
+	expvarName       string       // See WithExpvar
+	expvars          *expvarCounters
+	clock            Clock
+	quota            *byteQuota
+	hotFiles         *hotFileTracker
+	memCache         *cacheStore
+	diskCache        *cacheStore
+	decompressedMax  int64 // See CacheConfig.DecompressedLimit
+	transcodeCache   *transcodeCache
+	gzipPregen       *gzipPregen
+	hashWorkers      int // See WithContentHashEtags
+	etagAlgorithm    EtagAlgorithm
+	etagFunc         func(info *FileInfo) string
+	weakEtags        bool
+	noSymlinks       bool // See WithSymlinkResolution
+	nameEncoding     encoding.Encoding
+	duplicates       DuplicatePolicy
+	manifestEndpoint bool
+	searchEndpoint   bool
+	archiveDownload  bool
+	downloadClient   *http.Client
+	objectStores     map[string]ObjectStore // By lowercase scheme, see WithObjectStore
+	origin           *originCache
+	streaming        *streamingConfig
+	compression      bool
+	precompressed    bool
+	zstd             *zstdConfig
+	gzip             *gzipConfig
+	notFound         http.Handler
+	notFoundFallback bool // See WithNotFoundFallback
+	notFoundPage     string
+	dirListTmpl      *template.Template
+	dirListAssets    http.Handler
+	hidden           []string
+	pathRules        *pathRules
+	headerRules      []HeaderRule
+	headersFile      bool
+	redirectRules    []RedirectRule
+	redirectsFile    bool
+	cacheControl     map[string]string
+	securityHeaders  http.Header
+	sniff            bool
+	charsets         map[string]string
+	downloadParam    string
+	verifyCRC        bool
+	mountVerify      bool
+	repair           bool
+	inMemory         bool  // See WithInMemoryMounts
+	inMemoryMax      int64 // Largest archive read into memory, or 0
+	mmap             bool  // See WithMappedMounts
+	verifyWorkers    int
+	overlay          http.Dir
+	pathMappings     []pathMapping
+	limits           *DecompressionLimits
+	shadow           *shadowServer
+	autoReload       *autoReload
+
+	mountMutex *sync.RWMutex          // Guards fs, staged, mountGen, mountTimes and prefixes
+	staged     map[string]*FileSystem // Archives staged by stageZIP, by mount path
+	mountGen   uint64                 // Incremented whenever fs changes
+	mountTimes map[*FileSystem]time.Time
+	prefixes   map[*FileSystem]string // URL path prefixes of mounts, see stripMountPrefix
 }
 
 type Mount struct {
-	FilePath string `json:"filePath"`
+	FilePath  string `json:"filePath"`
+	URLPrefix string `json:"urlPrefix,omitempty"` // Only serve the zip under this URL path
+	URL       string `json:"url,omitempty"`       // Downloaded to FilePath if it does not exist
+	SHA256    string `json:"sha256,omitempty"`    // Expected hash of the downloaded file
+	Password  string `json:"password,omitempty"`  // Decrypts encrypted entries
+	Verify    bool   `json:"verify,omitempty"`    // Check every entry before mounting
 }
 
 type MountList struct {
@@ -90,36 +187,147 @@ type MountList struct {
 }
 
 func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve := serveFunc(h.serveHTTP)
+	if h.errorHandler != nil {
+		serve = h.withErrorHandler(serve)
+	}
+	if h.securityHeaders != nil {
+		serve = h.withSecurityHeaders(serve)
+	}
+	if h.shadow != nil {
+		serve = h.withShadow(serve)
+	}
+	if h.quota != nil {
+		serve = h.withByteQuota(serve)
+	}
+	if h.errorHook != nil {
+		serve = h.withErrorHook(serve)
+	}
+	if h.slowThreshold > 0 {
+		serve = h.withSlowRequestLog(serve)
+	}
+	if h.expvars != nil {
+		serve = h.withExpvar(serve)
+	}
+	if h.slog != nil {
+		serve = h.withSlog(serve)
+	}
+	if h.accessLog != nil {
+		serve = h.withAccessLog(serve)
+	}
+	if len(h.observers) > 0 {
+		serve = h.withObservers(serve)
+	}
+	serve(w, r)
+}
+
+func (h *fileHandler) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.noAPI && h.serveAPI(w, r) {
+		return
+	}
+
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+		r.URL.Path = upath
+	}
+	if r.Method == "HEAD" {
+		w = headWriter{w}
+	}
+	if h.serveDirListAssets(w, r) {
+		return
+	}
+	h.reloadChanged()
+	mounts, release := h.acquireMounts()
+	defer release()
+	name := path.Clean(upath)
+	if h.redirectRules != nil || h.redirectsFile {
+		rewrite, done := h.applyRedirects(w, r, mounts, name)
+		if done {
+			return
+		}
+		if rewrite != "" {
+			r = r.Clone(r.Context())
+			r.URL.Path, r.URL.RawQuery, _ = strings.Cut(rewrite, "?")
+			name = path.Clean(r.URL.Path)
+		}
+	}
+	serveFiles(w, r, h, mounts, name, true, h.phpPath)
+}
+
+// serveAPI serves the request if it is for an API endpoint, and reports
+// whether it was.
+func (h *fileHandler) serveAPI(w http.ResponseWriter, r *http.Request) bool {
 	var urlPath = path.Join("/", strings.ToLower(r.URL.Path))
 	var basePath = strings.ToLower(h.baseAPIPath)
 
 	if urlPath == path.Join("/", basePath, "/mountzip") {
-		h.MountFs(w, r)
-		return
+		h.serveMountEndpoint(MountEventMount, h.MountFs, w, r)
+		return true
 	}
 
 	if urlPath == path.Join("/", basePath, "/unmountzip") {
-		h.UnMountFs(w, r)
-		return
+		h.serveMountEndpoint(MountEventUnmount, h.UnMountFs, w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/stagezip") {
+		h.serveMountEndpoint(MountEventStage, h.StageFs, w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/swapzip") {
+		h.serveMountEndpoint(MountEventSwap, h.SwapFs, w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/mountstatus") {
+		h.MountStatus(w, r)
+		return true
 	}
 
 	if urlPath == path.Join("/", basePath, "/listmountzip") {
 		h.ListMountedFs(w, r)
-		return
+		return true
 	}
 
-	upath := r.URL.Path
-	if !strings.HasPrefix(upath, "/") {
-		upath = "/" + upath
-		r.URL.Path = upath
+	if urlPath == path.Join("/", basePath, "/downloadzip") {
+		h.DownloadFs(w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/hotfiles") {
+		h.HotFiles(w, r)
+		return true
 	}
-	serveFiles(w, r, h, path.Clean(upath), true, h.phpPath)
+
+	if urlPath == path.Join("/", basePath, "/manifest") {
+		h.Manifest(w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/search") {
+		h.Search(w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/metrics") {
+		h.Metrics(w, r)
+		return true
+	}
+
+	if urlPath == path.Join("/", basePath, "/mountevents") {
+		h.MountEvents(w, r)
+		return true
+	}
+
+	return false
 }
 
 // Add a ZIP file at runtime.
 func (h *fileHandler) MountFs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		fmt.Printf("Error (MountFs): Invalid request, not a POST")
+		h.logErrorf("MountFs", "Invalid request, not a POST")
 		http.Error(w, "POST request expected.", http.StatusBadRequest)
 		return
 	}
@@ -127,11 +335,18 @@ func (h *fileHandler) MountFs(w http.ResponseWriter, r *http.Request) {
 	var m Mount
 	err := json.NewDecoder(r.Body).Decode(&m)
 	if err != nil {
-		fmt.Printf("Error (MountFs): %s\n", err.Error())
+		h.logError("MountFs", err)
+		recordError(r, "", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Remote archives are read in place with range requests
+	if isRemoteArchive(m.FilePath) {
+		h.mountRemote(w, r, m)
+		return
+	}
+
 	// Ensure the zip is within the base directory
 	var zipPath string
 	if filepath.IsAbs(m.FilePath) {
@@ -141,94 +356,154 @@ func (h *fileHandler) MountFs(w http.ResponseWriter, r *http.Request) {
 		zipPath = path.Clean(zipPath)
 	}
 	if !strings.HasPrefix(zipPath, h.baseMountDir) {
-		fmt.Printf("Error (MountFs): Illegal path access (%s) %s", m.FilePath, zipPath)
+		h.logErrorf("MountFs", "Illegal path access (%s) %s", m.FilePath, zipPath)
 		http.Error(w, "Illegal path access", http.StatusBadRequest)
 		return
 	}
 
-	// Prevent duplicate mounts
-	for _, fse := range h.fs {
-		if fse.givenPath == zipPath {
-			fmt.Printf("Error (MountFs): Zip already mounted (%s) %s", m.FilePath, zipPath)
-			makeJsonResponse(w, SimpleResponseData{
-				Message: "Zip file already mounted!",
-			}, http.StatusOK)
+	if m.URL != "" {
+		if h.downloadClient == nil {
+			h.logErrorf("MountFs", "Mount downloads are not enabled")
+			http.Error(w, "Mount downloads are not enabled.", http.StatusBadRequest)
 			return
 		}
+		if _, err := os.Stat(zipPath); os.IsNotExist(err) {
+			h.logf("Downloading Zip: %s -> %s\n", m.URL, zipPath)
+			err := DownloadArchive(r.Context(), h.downloadClient, m.URL, zipPath, m.SHA256)
+			if err != nil {
+				h.logError("MountFs", err)
+				recordError(r, zipPath, err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
 	}
 
-	fmt.Printf("Mounting Zip: %s\n", zipPath)
-	newFS, fpErr := New(zipPath)
+	h.logf("Mounting Zip: %s\n", zipPath)
+	newFS, fpErr := h.openArchive(zipPath)
 	if fpErr != nil {
-		fmt.Printf("Error (MountFs): %s\n", fpErr.Error())
+		h.logError("MountFs", fpErr)
+		recordError(r, zipPath, fpErr)
 		http.Error(w, fpErr.Error(), http.StatusNotFound)
 		return
 	}
+	if newFS.Degraded() {
+		h.logWarningf("MountFs", "Recovered %d entries of damaged Zip: %s", len(newFS.reader.File), zipPath)
+	}
+	if m.Password != "" {
+		newFS.SetPassword(m.Password)
+	}
+	if !h.verifyMount(w, r, m, newFS) {
+		return
+	}
+	h.mount(w, zipPath, m.URLPrefix, newFS)
+}
 
-	// Find all files ending with a script extension and copy them to htdocs - Assists with file related PHP calls to other PHP files
-	count := 0
-	for _, f := range newFS.fileInfos {
-		if checkForPhp(f.name) {
-			extractPath := path.Clean(path.Join(h.htdocsPath, strings.TrimLeft(f.name, "content/")))
-			if h.isVerbose {
-				fmt.Printf("Extracting PHP file: %s\n", extractPath)
-			}
-
-			// Create the destination directory
-			err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm)
-			if err != nil {
-				fmt.Printf("Error (MountFs): %s\n", err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			// Open the file to write to
-			outFile, err := os.Create(extractPath)
-			if err != nil {
-				fmt.Printf("Error (MountFs) - Failed to make HTDOCS Folder: %s\n", err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer outFile.Close()
-
-			// Open PHP file from Zip and copy
-			reader, err := f.zipFile.Open()
-			if err != nil {
-				fmt.Printf("Error (MountFs) - Failed to open Zipped file content: %s\n", err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer reader.Close()
-
-			_, err = io.Copy(outFile, reader)
-			if err != nil {
-				fmt.Printf("Error (MountFs) - Failed to copy Zipped file content: %s\n", err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			count++
-		}
+// mount adds newFS to the mounted archives, or swaps it in for the
+// archive already mounted from zipPath, and writes the API response.
+func (h *fileHandler) mount(w http.ResponseWriter, zipPath string, urlPrefix string, newFS *FileSystem) {
+	if err := h.configureArchive(newFS); err != nil {
+		newFS.Close()
+		h.logError("MountFs", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
 	}
-	if count > 0 {
-		fmt.Printf("Extracted %d PHP files to %s\n", count, h.htdocsPath)
+	if err := h.extractPhpFiles(newFS); err != nil {
+		newFS.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	if h.isVerbose {
-		fmt.Printf("Zip Mounted: %s\n", zipPath)
+		h.logMount("Zip Mounted", zipPath)
 	}
 
-	h.fs = append(h.fs, newFS)
+	// Mounting a zip that is already mounted swaps in the new contents.
+	// Requests that are still reading from the old zip finish before it
+	// is closed.
+	h.mountMutex.Lock()
+	old := h.replaceMountLocked(zipPath, newFS)
+	if old == nil {
+		h.fs = append(h.fs, newFS)
+		h.setMountTimeLocked(newFS)
+		h.mountGen++
+	}
+	if urlPrefix != "" {
+		h.setMountPrefixLocked(newFS, urlPrefix)
+	}
+	h.mountMutex.Unlock()
+	go h.pregenerateGzip(newFS)
+	go h.hashContents(newFS)
+
+	if old != nil {
+		go h.retire(old)
+		h.logMount("Zip Remounted", zipPath)
+		makeJsonResponse(w, SimpleResponseData{
+			Message: "Zip file remounted!",
+		}, http.StatusOK)
+		return
+	}
 	makeJsonResponse(w, SimpleResponseData{
 		Message: "Zip file mounted!",
 	}, http.StatusOK)
 	return
 }
 
+// extractPhpFiles copies all files ending with a script extension to
+// htdocs. This assists with file related PHP calls to other PHP files.
+func (h *fileHandler) extractPhpFiles(fs *FileSystem) error {
+	count := 0
+	for _, f := range fs.fileInfos {
+		if !checkForPhp(f.name) {
+			continue
+		}
+		extractPath := path.Clean(path.Join(h.htdocsPath, strings.TrimLeft(f.name, "content/")))
+		if h.isVerbose {
+			h.logf("Extracting PHP file: %s\n", extractPath)
+		}
+
+		// Create the destination directory
+		err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm)
+		if err != nil {
+			h.logError("extractPhpFiles", err)
+			return err
+		}
+
+		// Open the file to write to
+		outFile, err := os.Create(extractPath)
+		if err != nil {
+			h.logErrorf("extractPhpFiles", "Failed to make HTDOCS Folder: %w", err)
+			return err
+		}
+
+		// Open PHP file from Zip and copy
+		reader, err := f.open()
+		if err != nil {
+			outFile.Close()
+			h.logErrorf("extractPhpFiles", "Failed to open Zipped file content: %w", err)
+			return err
+		}
+
+		_, err = io.Copy(outFile, reader)
+		reader.Close()
+		outFile.Close()
+		if err != nil {
+			h.logErrorf("extractPhpFiles", "Failed to copy Zipped file content: %w", err)
+			return err
+		}
+
+		count++
+	}
+	if count > 0 {
+		h.logf("Extracted %d PHP files to %s\n", count, h.htdocsPath)
+	}
+	return nil
+}
+
 // Remove a ZIP file at runtime.
 func (h *fileHandler) UnMountFs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		fmt.Printf("Error (UnMountFs): Invalid request, not a POST\n")
+		h.logErrorf("UnMountFs", "Invalid request, not a POST")
 		http.Error(w, "POST request expected.", http.StatusBadRequest)
 		return
 	}
@@ -236,41 +511,61 @@ func (h *fileHandler) UnMountFs(w http.ResponseWriter, r *http.Request) {
 	var m Mount
 	err := json.NewDecoder(r.Body).Decode(&m)
 	if err != nil {
-		fmt.Printf("Error (UnMountFs): %s\n", err.Error())
+		h.logError("UnMountFs", err)
+		recordError(r, "", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Ensure the zip is within the base directory
 	var zipPath string
-	if filepath.IsAbs(m.FilePath) {
+	if isRemoteArchive(m.FilePath) {
+		zipPath = m.FilePath
+	} else if filepath.IsAbs(m.FilePath) {
 		zipPath = path.Clean(m.FilePath)
 	} else {
 		zipPath = path.Join(h.baseMountDir, m.FilePath)
 		zipPath = path.Clean(zipPath)
 	}
-	if !strings.HasPrefix(zipPath, h.baseMountDir) {
-		fmt.Printf("Error (MountFs): Illegal path access (%s) %s", m.FilePath, zipPath)
+	if !isRemoteArchive(zipPath) && !strings.HasPrefix(zipPath, h.baseMountDir) {
+		h.logErrorf("UnMountFs", "Illegal path access (%s) %s", m.FilePath, zipPath)
 		http.Error(w, "Illegal path access", http.StatusBadRequest)
 		return
 	}
 
 	//Loop through and remove the zip requested
-	fmt.Printf("UnMounting Zip: %s\n", zipPath)
-	var found = false
+	h.logf("UnMounting Zip: %s\n", zipPath)
+	var removed []*FileSystem
+	h.mountMutex.Lock()
 	for i := len(h.fs) - 1; i >= 0; i-- {
 		if h.fs[i].givenPath == zipPath {
-			found = true
-			err := h.fs[i].Close()
-			if err != nil {
-				fmt.Printf("Failed to close zip file %s: %s\n", zipPath, err)
-			}
+			removed = append(removed, h.fs[i])
+			delete(h.mountTimes, h.fs[i])
 			h.fs = append(h.fs[:i], h.fs[i+1:]...)
+			h.mountGen++
 		}
 	}
+	if staged := h.staged[zipPath]; staged != nil {
+		staged.Close()
+		delete(h.staged, zipPath)
+	}
+	h.mountMutex.Unlock()
 
-	if found && h.isVerbose {
-		fmt.Printf("Zip UnMounted: %s\n", zipPath)
+	if len(removed) == 0 {
+		makeJsonResponse(w, SimpleResponseData{
+			Message: "Zip file not mounted!",
+		}, http.StatusOK)
+		return
+	}
+
+	// Wait for requests still being served from the zip, so that its file
+	// handle has been released by the time the caller is told it is
+	// unmounted, and the file can be replaced or deleted.
+	for _, fse := range removed {
+		h.retire(fse)
+	}
+	if h.isVerbose {
+		h.logMount("Zip UnMounted", zipPath)
 	}
 
 	makeJsonResponse(w, SimpleResponseData{
@@ -282,13 +577,19 @@ func (h *fileHandler) UnMountFs(w http.ResponseWriter, r *http.Request) {
 // Remove a ZIP file at runtime.
 func (h *fileHandler) ListMountedFs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		fmt.Printf("Error (ListMountedFs): Invalid request, not a GET\n")
+		h.logErrorf("ListMountedFs", "Invalid request, not a GET")
 		http.Error(w, "GET request expected.", http.StatusBadRequest)
 		return
 	}
 
+	mounts, gen := h.mountTable()
+	w.Header().Set("Etag", mountTableEtag(gen, mounts))
+	if _, done := checkPreconditions(w, r, time.Time{}); done {
+		return
+	}
+
 	var ml MountList
-	for _, fse := range h.fs {
+	for _, fse := range mounts {
 		ml.MountedZips = append(ml.MountedZips, fse.givenPath)
 	}
 
@@ -297,8 +598,38 @@ func (h *fileHandler) ListMountedFs(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// serveNotFound responds that name does not exist, using the not found
+// page or handler if one is set.
+func (h *fileHandler) serveNotFound(w http.ResponseWriter, r *http.Request, mounts []*FileSystem, name string, err error, msg string) {
+	recordError(r, name, err)
+	if h.notFoundPage != "" && h.serveNotFoundPage(w, r, mounts) {
+		return
+	}
+	if h.notFound != nil && !h.notFoundFallback {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+	httpError(w, r, msg, http.StatusNotFound)
+}
+
+// mountTableStart distinguishes the mount table ETags of different runs
+// of the server, which all start counting generations from zero.
+var mountTableStart = time.Now().UnixNano()
+
+// mountTableEtag returns the ETag of API responses that only depend on
+// the mount table of generation gen, which holds mounts. It changes as
+// well when one of mounts is replaced by FileSystem.ReplaceWith, as the
+// generation only counts the changes made by the file server.
+func mountTableEtag(gen uint64, mounts []*FileSystem) string {
+	var reloads uint64
+	for _, fse := range mounts {
+		reloads += fse.origin().reloads.Load()
+	}
+	return fmt.Sprintf(`"mounts-%x-%d-%d"`, mountTableStart, gen, reloads)
+}
+
 // name is '/'-separated, not filepath.Separator.
-func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name string, redirect bool, phpPath string) {
+func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, mounts []*FileSystem, name string, redirect bool, phpPath string) {
 	//If a file is attempting to be served, but no zips are available
 	//We want to fail gracefully.
 	const indexPage = "/index.html"
@@ -311,12 +642,19 @@ func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name str
 		return
 	}
 
+	// Hidden and blocked entries are treated as if they did not exist
+	if h.isHidden(name) || !h.pathAllowed(name) {
+		h.serveNotFound(w, r, mounts, name, os.ErrNotExist, "404 page not found")
+		return
+	}
+
 	var fi *fileInfo
 	var fsVal *FileSystem
 	var errVal error
 
 	var errMsg string
 	var errCode int
+	var errPath string
 	var errFlag = false
 
 	// Check for file overrides
@@ -370,22 +708,62 @@ func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name str
 			if err != nil {
 				continue
 			}
-			fmt.Printf("Serving override file: %s\n", foundFile.Name())
+			markLookupDone(r)
+			if !h.checkMethod(w, r, false) {
+				return
+			}
+			h.logf("Serving override file: %s\n", foundFile.Name())
 			http.ServeContent(w, r, foundFile.Name(), stats.ModTime(), foundFile)
 			return
 		}
 	}
 
-	if len(h.fs) == 0 {
-		http.Error(w, "File not found, no ZIP is added.", http.StatusNotFound)
+	if h.serveOverlay(w, r, name) {
+		return
+	}
+
+	if len(mounts) == 0 {
+		if h.origin != nil && h.origin.serve(w, r, h, name) {
+			return
+		}
+		if h.favicon != nil && isFavicon(name) {
+			h.favicon.serve(w, r)
+			return
+		}
+		if h.serveFallback(w, r) {
+			return
+		}
+		h.serveNotFound(w, r, mounts, name, os.ErrNotExist, "File not found, no ZIP is added.")
+		return
+	}
+
+	// Per-directory configuration files are never served
+	if h.dirConfig && isDirConfigFile(name) {
+		h.serveNotFound(w, r, mounts, name, os.ErrNotExist, "404 page not found")
 		return
 	}
 
+	// If no archive is mounted at the prefix of the path, the file does
+	// not exist.
+	errFlag = true
+	errVal = os.ErrNotExist
+	errMsg, errCode = toHTTPError(errVal)
+	errPath = name
+
 	// Loop through the files in order to find the first match
-	for _, fse := range h.fs {
+	for _, fse := range mounts {
+		fsName, ok := stripMountPrefix(h.mountPrefix(fse), name)
+		if !ok {
+			continue
+		}
+		fsName = h.mapPath(fsName)
+		// Header and redirect rules files are never served
+		if h.headersFile && isHeadersFile(fsName) || h.redirectsFile && isRedirectsFile(fsName) {
+			continue
+		}
 		errFlag = false
 		errVal = nil
-		fii, err := fse.openFileInfo(name)
+		fii, err := h.openServable(fse, fsName)
 		if err != nil {
 			errVal = err
 		}
@@ -397,6 +775,7 @@ func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name str
 		if errVal != nil {
 			errFlag = true
 			errMsg, errCode = toHTTPError(errVal)
+			errPath = name
 			continue
 		}
 
@@ -417,12 +796,17 @@ func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name str
 			}
 		}
 
+		var dirCfg *dirConfig
+		if h.dirConfig {
+			dirCfg = fi.fs.dirConfigFor(fi, h.logError)
+		}
+
 		//Loop through all available extensions and attempt to open them.
 		if fi.IsDir() {
-			for _, extension := range h.indexExts {
+			for _, extension := range dirCfg.indexExts(h.indexExts) {
 				// use contents of index.html for directory, if present
-				index := path.Join(strings.TrimPrefix(name, "/"), "/index."+extension)
-				fii, err := fsVal.openFileInfo(index)
+				index := path.Join(strings.TrimPrefix(fsName, "/"), "/index."+extension)
+				fii, err := h.openServable(fsVal, index)
 				if err == nil {
 					fi = fii
 				}
@@ -431,139 +815,341 @@ func serveFiles(w http.ResponseWriter, r *http.Request, h *fileHandler, name str
 
 		// Still a directory? (we didn't find an index.html file)
 		if fi.IsDir() {
+			// Directory listings can be enabled by a .zipfsrc file
+			if dirCfg.autoIndex() {
+				if !h.checkMethod(w, r, false) {
+					return
+				}
+				dirCfg.applyHeaders(w)
+				h.applyHeaderRules(w, fsVal, name, fsName)
+				h.serveDirList(w, r, fi)
+				return
+			}
+
 			// Unlike the standard library implementation, directory
 			// listing is prohibited.
 			errFlag = true
+			errVal = errDirectory
 			errMsg = "Forbidden"
 			errCode = http.StatusForbidden
+			errPath = fi.name
 			continue
 		}
 
+		markLookupDone(r)
+		if !h.checkMethod(w, r, h.phpPath != "" && checkForPhp(fi.name)) {
+			return
+		}
+		if h.hotFiles != nil {
+			h.hotFiles.record(fsVal.origin(), fi.fullName())
+		}
+
 		//Now that we have a file, override the mime-type if it on the list
 		mimeOverride, ok := h.mimeExts[strings.ToLower(filepath.Ext(path.Base(fi.Name())))]
 		if ok {
 			w.Header().Set("Content-Type", mimeOverride)
 		}
+		h.sniffContentType(w, fi)
+		h.applyCharset(w, fi.Name())
+
+		dirCfg.applyHeaders(w)
+		h.applyHeaderRules(w, fsVal, name, fsName)
+		h.applyCacheControl(w, fi.Name())
+		download := h.wantsDownload(r)
+		if download {
+			setAttachment(w, fi.Name())
+		}
 
 		// serveContent will check modification time and ETag
 		w.Header().Set("ZIPSVR_FILENAME", fi.name)
 
+		if !h.checkLimits(w, r, fi) {
+			return
+		}
+		if renderer := h.rendererFor(fi.name); renderer != nil && !download {
+			h.serveRendered(w, r, fsVal, fi, renderer)
+			return
+		}
+
 		//If the default value exists, send it over to be used, otherwise use default functionality.
 		mimeDefaultOverride, defExists := h.mimeExts["default"]
 		if defExists {
-			serveContent(w, r, fsVal, fi, phpPath, h.htdocsPath, &mimeDefaultOverride)
+			h.serveContent(w, r, fsVal, fi, &mimeDefaultOverride)
 		} else {
-			serveContent(w, r, fsVal, fi, phpPath, h.htdocsPath, nil)
+			h.serveContent(w, r, fsVal, fi, nil)
 		}
 		return
 	}
 
 	if errFlag {
-		http.Error(w, errMsg, errCode)
+		if errCode == http.StatusNotFound && h.origin != nil && h.origin.serve(w, r, h, name) {
+			return
+		}
+		if errCode == http.StatusNotFound && h.favicon != nil && isFavicon(name) {
+			h.favicon.serve(w, r)
+			return
+		}
+		if errCode == http.StatusNotFound && h.serveFallback(w, r) {
+			return
+		}
+		if errCode == http.StatusNotFound {
+			h.serveNotFound(w, r, mounts, errPath, errVal, errMsg)
+			return
+		}
+		recordError(r, errPath, errVal)
+		httpError(w, r, errMsg, errCode)
 		return
 	}
 }
 
-func serveContent(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, phpPath string, htdocsPath string, defaultMime *string) {
-	if checkLastModified(w, r, fi.ModTime()) {
+func (h *fileHandler) serveContent(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, defaultMime *string) {
+	if err := fi.checkPassword(); err != nil {
+		recordError(r, fi.name, err)
+		httpError(w, r, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	chargeEntry(r, fi)
+	if h.precompressed && h.servePrecompressed(w, r, fs, fi, defaultMime) {
 		return
 	}
 
-	// Set the Etag header in the response before calling checkETag.
-	// The checkETag function obtains the files ETag from the response header.
-	w.Header().Set("Etag", calcEtag(fi.zipFile))
-	rangeReq, done := checkETag(w, r, fi.ModTime())
+	modtime := h.lastModified(fi)
+
+	// Set the Etag header in the response before calling checkPreconditions.
+	// The checkPreconditions function obtains the files ETag from the response header.
+	etag := h.entryEtag(fi)
+	useZstd := h.useZstd(r, fi)
+	useGzip := !useZstd && h.useGzip(w, r, fi, defaultMime)
+	// Every content-encoding has its own ETag, so that caches and
+	// If-Range do not mix them up. Range requests are always served
+	// without one.
+	passDeflate := h.passesDeflate(fi) && r.Header.Get("Range") == ""
+	if useZstd {
+		etag = zstdEtag(etag)
+	} else if useGzip {
+		etag = gzipEtag(etag)
+	} else if passDeflate {
+		switch deflateEncoding(r) {
+		case "deflate":
+			etag = deflateEtag(etag)
+		case "gzip":
+			etag = gzipEtag(etag)
+		}
+	}
+	// Set Vary before the preconditions, as 304 responses must have it too
+	if h.negotiatesEncoding(w, fi, defaultMime) {
+		addVary(w.Header(), "Accept-Encoding")
+	}
+	w.Header().Set("Etag", etag)
+	rangeReq, done := checkPreconditions(w, r, modtime)
 	if done {
 		return
 	}
+	// Range responses have the same Content-Type as full responses,
+	// including each part of multipart/byteranges responses.
+	setContentType(w, fi.Name(), defaultMime)
+
+	if rangeReq != "" && fi.zipFile.Method == zip.Store && !fi.encrypted() && !(h.phpPath != "" && checkForPhp(fi.name)) {
+		serveStoredRange(w, r, fi, fi.fs.readerAt, modtime)
+		return
+	}
 	if rangeReq != "" {
-		// Range request requires seeking, so at this point create a temporary
-		// file and let the standard library serve it.
-		f := fi.openReader(r.URL.Path)
-		defer f.Close()
-		f.createTempFile()
-		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f.file)
+		// Range request requires seeking, so at this point use the copy
+		// of the entry in the disk cache, or skip through the
+		// decompressed contents, and let the standard library serve it.
+		if h.serveDecompressed(w, r, fs, fi, modtime) {
+			return
+		}
+		if h.serveTranscoded(w, r, fi, "identity", modtime, func(dst io.Writer) error {
+			return copyEntry(dst, fi)
+		}) {
+			return
+		}
+		if h.diskCache != nil {
+			h.serveCachedFile(w, r, fs, fi, modtime)
+			return
+		}
+		sr := newSkipReader(fi, fi.Size())
+		defer sr.Close()
+		serveRanges(w, r, fi.Name(), modtime, fi.Size(), sr)
 		return
 	}
 
-	setContentType(w, fi.Name(), defaultMime)
+	if useZstd {
+		h.serveZstd(w, r, fs, fi)
+		return
+	}
+	if useGzip {
+		h.serveGzip(w, r, fi)
+		return
+	}
 
 	switch fi.zipFile.Method {
 	case zip.Deflate:
+		if passDeflate {
+			if err := h.serveDeflate(w, r, fi, fi.fs.readerAt, h.verifies(fi)); err != nil {
+				h.abortCorrupt(r, fi, err)
+			}
+			return
+		}
 		fallthrough
-	case zip.Store:
-		serveIdentity(w, r, fi, phpPath, htdocsPath)
 	default:
-		http.Error(w, fmt.Sprintf("unsupported zip method: %d", fi.zipFile.Method), http.StatusInternalServerError)
+		if method := fi.zipFile.Method; method != zip.Store && method != methodAES && decompressorFor(method) == nil && fi.fs.openers[fi.zipFile] == nil {
+			err := fmt.Errorf("unsupported zip method: %d", method)
+			recordError(r, fi.name, err)
+			httpError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if h.serveDecompressed(w, r, fs, fi, modtime) {
+			return
+		}
+		sw, finish := h.streamWriter(w)
+		defer finish()
+		if err := h.serveIdentity(sw, r, fi, h.verifies(fi)); err != nil {
+			h.abortCorrupt(r, fi, err)
+		}
 	}
 }
 
-// serveIdentity serves a zip file in identity content encoding .
-func serveIdentity(w http.ResponseWriter, r *http.Request, fi *fileInfo, phpPath string, htdocsPath string) {
+// negotiatesEncoding reports whether the content-encoding fi is served
+// with depends on the Accept-Encoding header of the request, in which
+// case responses must have a "Vary: Accept-Encoding" header so that
+// shared caches do not send one encoding to clients that asked for
+// another. Precompressed siblings are handled by servePrecompressed.
+func (h *fileHandler) negotiatesEncoding(w http.ResponseWriter, fi *fileInfo, defaultMime *string) bool {
+	if h.phpPath != "" && checkForPhp(fi.name) {
+		return false
+	}
+	if h.passesDeflate(fi) {
+		return true
+	}
+	if h.zstd != nil {
+		return true
+	}
+	return h.gzip != nil && h.gzipEligible(w.Header(), fi, defaultMime)
+}
+
+// passesDeflate reports whether the deflated data of fi is served as is
+// to clients that accept it, see serveDeflate.
+func (h *fileHandler) passesDeflate(fi *fileInfo) bool {
+	return h.compression && fi.zipFile.Method == zip.Deflate && !fi.encrypted() && !(h.phpPath != "" && checkForPhp(fi.name))
+}
+
+// serveStoredRange serves a range request for an entry stored without
+// compression straight from the archive. Unlike compressed entries, this
+// does not need a temporary file, which matters for Zip64 entries that
+// can be many gigabytes in size.
+func serveStoredRange(w http.ResponseWriter, r *http.Request, fi *fileInfo, readerAt io.ReaderAt, modtime time.Time) {
+	offset, err := fi.zipFile.DataOffset()
+	if err != nil {
+		recordError(r, fi.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return
+	}
+	serveRanges(w, r, fi.Name(), modtime, fi.Size(), io.NewSectionReader(readerAt, offset, fi.Size()))
+}
+
+// serveIdentity serves a zip file in identity content encoding . If
+// verify is true, the CRC of the contents is checked before the last of
+// them are written, and the error is returned if they are damaged.
+func (h *fileHandler) serveIdentity(w http.ResponseWriter, r *http.Request, fi *fileInfo, verify bool) error {
 	// TODO: need to check if the client explicitly refuses to accept
 	// identity encoding (Accept-Encoding: identity;q=0), but this is
 	// going to be very rare.
 
 	// Divert php requests
-	if phpPath != "" && checkForPhp(fi.name) {
+	if h.phpPath != "" && checkForPhp(fi.name) {
 		fileName := strings.TrimLeft(fi.name, "content/")
 		// Run the file from the htdocs directory instead
-		htdocsFile := path.Clean(path.Join(htdocsPath, fileName))
-		fmt.Printf("Executing PHP Script: %s\n", fileName)
-		Cgi(w, r, phpPath, htdocsFile)
-		return
+		htdocsFile := path.Clean(path.Join(h.htdocsPath, fileName))
+		if h.isVerbose {
+			h.logf("Executing PHP Script: %s\n", fileName)
+		}
+		Cgi(w, r, h.phpPath, htdocsFile)
+		return nil
 	}
 
 	zf := fi.zipFile
-	reader, err := zf.Open()
+	rc, err := fi.open()
 	if err != nil {
+		recordError(r, fi.name, err)
 		msg, code := toHTTPError(err)
-		http.Error(w, msg, code)
-		return
+		httpError(w, r, msg, code)
+		return nil
+	}
+	defer rc.Close()
+	var reader io.Reader = rc
+	if verify {
+		reader = newVerifyingReader(rc, zf.CRC32, zf.UncompressedSize64)
 	}
-	defer reader.Close()
 
 	size := zf.FileInfo().Size()
 	w.Header().Del("Content-Encoding")
+	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
 	if r.Method != "HEAD" {
-		io.CopyN(w, reader, size)
+		if _, err := io.CopyN(w, reader, size); err == errChecksum {
+			return err
+		}
+	}
+	if h.isVerbose {
+		h.logf("[Zipfs] Serving Zipped File: %s\n", zf.Name)
 	}
-	fmt.Printf("[Zipfs] Serving Zipped File: %s\n", zf.Name)
+	return nil
 }
 
-// serveDeflat serves a zip file in deflate content-encoding if the
-// user agent can accept it. Otherwise it calls serveIdentity.
-func serveDeflate(w http.ResponseWriter, r *http.Request, fi *fileInfo, readerAt io.ReaderAt, phpPath string, htdocsPath string) {
-	acceptEncoding := r.Header.Get("Accept-Encoding")
+// deflateEncoding returns the content-encoding that serveDeflate sends
+// deflated data with for r, or "" if it must be decompressed.
+func deflateEncoding(r *http.Request) string {
+	return parseAcceptEncoding(r).preferred("deflate", "gzip")
+}
+
+// deflateEtag returns the ETag of the deflate encoded variant of an
+// entry with the given ETag.
+func deflateEtag(etag string) string {
+	return etag[:len(etag)-1] + `-deflate"`
+}
 
-	// TODO: need to parse the accept header to work out if the
-	// client is explicitly forbidding deflate (ie deflate;q=0)
-	acceptsDeflate := strings.Contains(acceptEncoding, "deflate")
-	if !acceptsDeflate {
+// serveDeflate serves a zip file in deflate content-encoding if the
+// user agent can accept it. User agents that only accept gzip are sent
+// the same deflate stream wrapped in a gzip header and trailer, which
+// needs neither decompression nor recompression. Otherwise it calls
+// serveIdentity. If verify is true, the deflated data is decompressed
+// alongside to check its CRC before the last of it is written, and the
+// error is returned if it is damaged.
+func (h *fileHandler) serveDeflate(w http.ResponseWriter, r *http.Request, fi *fileInfo, readerAt io.ReaderAt, verify bool) error {
+	encoding := deflateEncoding(r)
+	if encoding == "" {
 		// client will not accept deflate, so serve as identity
-		serveIdentity(w, r, fi, phpPath, htdocsPath)
-		return
+		return h.serveIdentity(w, r, fi, verify)
 	}
 
 	f := fi.zipFile
 	contentLength := int64(f.CompressedSize64)
-	if contentLength == 0 {
-		contentLength = int64(f.CompressedSize64)
+	if encoding == "gzip" {
+		contentLength += int64(len(gzipHeader) + gzipTrailerLen)
 	}
-	w.Header().Set("Content-Encoding", "deflate")
+	w.Header().Set("Content-Encoding", encoding)
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", contentLength))
 	if r.Method == "HEAD" {
-		return
+		return nil
 	}
 
 	var written int64
-	remaining := contentLength
+	remaining := int64(f.CompressedSize64)
 	offset, err := f.DataOffset()
 	if err != nil {
+		recordError(r, fi.name, err)
 		msg, code := toHTTPError(err)
-		http.Error(w, msg, code)
-		return
+		httpError(w, r, msg, code)
+		return nil
+	}
+
+	var verifier *deflateVerifier
+	if verify {
+		verifier = newDeflateVerifier(f.CRC32, f.UncompressedSize64)
+		defer verifier.Close()
 	}
 
 	// re-use buffers to reduce stress on GC
@@ -582,24 +1168,92 @@ func serveDeflate(w http.ResponseWriter, r *http.Request, fi *fileInfo, readerAt
 		if err != nil {
 			if written == 0 {
 				// have not written anything to the client yet, so we can send an error
+				recordError(r, fi.name, err)
 				msg, code := toHTTPError(err)
-				http.Error(w, msg, code)
+				httpError(w, r, msg, code)
+			}
+			return nil
+		}
+		if verifier != nil {
+			if _, err := verifier.Write(b); err != nil {
+				return err
+			}
+			if int64(size) == remaining {
+				if err := verifier.Close(); err != nil {
+					return err
+				}
+			}
+		}
+		if written == 0 && encoding == "gzip" {
+			if _, err := w.Write(gzipHeader); err != nil {
+				return nil
 			}
-			return
 		}
 		if _, err := w.Write(b); err != nil {
 			// Cannot write an error to the client because, er,  we just
 			// failed to write to the client.
-			return
+			return nil
 		}
 		written += int64(size)
 		remaining -= int64(size)
 		offset += int64(size)
 	}
+
+	if encoding == "gzip" {
+		if written == 0 {
+			w.Write(gzipHeader)
+		}
+		w.Write(gzipTrailer(f))
+	}
+	return nil
+}
+
+// gzipHeader is the header of a gzip stream of deflate data without a
+// file name or modification time, as described in RFC 1952.
+var gzipHeader = []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+
+const gzipTrailerLen = 8
+
+// gzipTrailer returns the trailer of a gzip stream with the contents of
+// f, which holds the CRC-32 and the size modulo 2^32 of the contents.
+func gzipTrailer(f *zip.File) []byte {
+	trailer := make([]byte, gzipTrailerLen)
+	binary.LittleEndian.PutUint32(trailer[:4], f.CRC32)
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(f.UncompressedSize64))
+	return trailer
+}
+
+// addVary adds field to the Vary header of h, unless it is already
+// listed.
+func addVary(h http.Header, field string) {
+	for _, value := range h.Values("Vary") {
+		for _, f := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// defaultMimeType returns the type set for the "default" key of the
+// MIME types, or nil if there is none.
+func (h *fileHandler) defaultMimeType() *string {
+	if mimeDefault, ok := h.mimeExts["default"]; ok {
+		return &mimeDefault
+	}
+	return nil
 }
 
 func setContentType(w http.ResponseWriter, filename string, defaultMime *string) {
-	ctypes, haveType := w.Header()["Content-Type"]
+	if ctype := contentType(w.Header(), filename, defaultMime); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+}
+
+// contentType returns the Content-Type that setContentType sets.
+func contentType(h http.Header, filename string, defaultMime *string) string {
+	ctypes, haveType := h["Content-Type"]
 	var ctype string
 
 	if !haveType {
@@ -618,20 +1272,17 @@ func setContentType(w http.ResponseWriter, filename string, defaultMime *string)
 	} else if len(ctypes) > 0 {
 		ctype = ctypes[0]
 	}
-
-	if ctype != "" {
-		w.Header().Set("Content-Type", ctype)
-	}
+	return ctype
 }
 
 // calcEtag calculates an ETag value for a given zip file based on
 // the file's CRC and its length.
 func calcEtag(f *zip.File) string {
+	// The high bits of Zip64 sizes are folded into the low bits, so
+	// that entries whose sizes differ by a multiple of 4 GB do not share
+	// an ETag. Entries smaller than 4 GB are unaffected.
 	size := f.UncompressedSize64
-	if size == 0 {
-		size = f.UncompressedSize64
-	}
-	etag := uint64(f.CRC32) ^ (size & 0xffffffff << 32)
+	etag := uint64(f.CRC32) ^ (size << 32) ^ (size >> 32)
 
 	// etag should always be in double quotes
 	return fmt.Sprintf(`"%x"`, etag)
@@ -639,88 +1290,185 @@ func calcEtag(f *zip.File) string {
 
 var unixEpochTime = time.Unix(0, 0)
 
-// modtime is the modification time of the resource to be served, or IsZero().
-// return value is whether this request is now complete.
-func checkLastModified(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
+// checkPreconditions evaluates the conditional request headers in the
+// order of RFC 9110, section 13.2.2: If-Match, If-Unmodified-Since,
+// If-None-Match, If-Modified-Since and finally If-Range.
+//
+// The ETag, if any, must have been previously set in the
+// ResponseWriter's headers. The modtime is the modification time of
+// the resource, or the zero value if it is unknown. Modification times
+// are only compared at second granularity.
+//
+// The return value is the effective request "Range" header to use and
+// whether this request is now considered done.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, modtime time.Time) (rangeReq string, done bool) {
 	if modtime.IsZero() || modtime.Equal(unixEpochTime) {
 		// If the file doesn't have a modtime (IsZero), or the modtime
 		// is obviously garbage (Unix time == 0), then ignore modtimes
-		// and don't process the If-Modified-Since header.
-		return false
+		// and don't process the date based headers.
+		modtime = time.Time{}
+	} else {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
 	}
+	etag := w.Header().Get("Etag")
+	isGetOrHead := r.Method == "GET" || r.Method == "HEAD"
 
-	// The Date-Modified header truncates sub-second precision, so
-	// use mtime < t+1s instead of mtime <= t to check for unmodified.
-	if t, err := time.Parse(http.TimeFormat, r.Header.Get("If-Modified-Since")); err == nil && modtime.Before(t.Add(1*time.Second)) {
-		h := w.Header()
-		delete(h, "Content-Type")
-		delete(h, "Content-Length")
-		w.WriteHeader(http.StatusNotModified)
-		return true
+	// Step 1 and 2: the representation must match the client's
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagStrongMatch(im, etag) {
+			writePreconditionFailed(w)
+			return "", true
+		}
+	} else if t, ok := parseConditionalTime(r.Header.Get("If-Unmodified-Since"), modtime); ok && modifiedSince(modtime, t) {
+		writePreconditionFailed(w)
+		return "", true
 	}
-	w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
-	return false
-}
 
-// checkETag implements If-None-Match and If-Range checks.
-//
-// The ETag or modtime must have been previously set in the
-// ResponseWriter's headers.  The modtime is only compared at second
-// granularity and may be the zero value to mean unknown.
-//
-// The return value is the effective request "Range" header to use and
-// whether this request is now considered done.
-func checkETag(w http.ResponseWriter, r *http.Request, modtime time.Time) (rangeReq string, done bool) {
-	etag := w.Header().Get("Etag")
-	rangeReq = r.Header.Get("Range")
-
-	// Invalidate the range request if the entity doesn't match the one
-	// the client was expecting.
-	// "If-Range: version" means "ignore the Range: header unless version matches the
-	// current file."
-	// We only support ETag versions.
-	// The caller must have set the ETag on the response already.
-	if ir := r.Header.Get("If-Range"); ir != "" && ir != etag {
-		// The If-Range value is typically the ETag value, but it may also be
-		// the modtime date. See golang.org/issue/8367.
-		timeMatches := false
-		if !modtime.IsZero() {
-			if t, err := http.ParseTime(ir); err == nil && t.Unix() == modtime.Unix() {
-				timeMatches = true
+	// Step 3 and 4: the client's copy may still be fresh
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagWeakMatch(inm, etag) {
+			if isGetOrHead {
+				writeNotModified(w)
+			} else {
+				writePreconditionFailed(w)
 			}
+			return "", true
 		}
-		if !timeMatches {
+	} else if t, ok := parseConditionalTime(r.Header.Get("If-Modified-Since"), modtime); ok && isGetOrHead && !modifiedSince(modtime, t) {
+		writeNotModified(w)
+		return "", true
+	}
+
+	// Step 5: a range of a different representation is of no use to
+	// the client, so the whole representation is sent instead
+	rangeReq = r.Header.Get("Range")
+	if ir := r.Header.Get("If-Range"); ir != "" && rangeReq != "" {
+		if !ifRangeMatches(ir, etag, modtime) {
 			rangeReq = ""
 		}
 	}
+	return rangeReq, false
+}
 
-	if inm := r.Header.Get("If-None-Match"); inm != "" {
-		// Must know ETag.
-		if etag == "" {
-			return rangeReq, false
-		}
-
-		// TODO(bradfitz): non-GET/HEAD requests require more work:
-		// sending a different status code on matches, and
-		// also can't use weak cache validators (those with a "W/
-		// prefix).  But most users of ServeContent will be using
-		// it on GET or HEAD, so only support those for now.
-		if r.Method != "GET" && r.Method != "HEAD" {
-			return rangeReq, false
-		}
-
-		// TODO(bradfitz): deal with comma-separated or multiple-valued
-		// list of If-None-match values.  For now just handle the common
-		// case of a single item.
-		if inm == etag || inm == "*" {
-			h := w.Header()
-			delete(h, "Content-Type")
-			delete(h, "Content-Length")
-			w.WriteHeader(http.StatusNotModified)
-			return "", true
+// parseConditionalTime parses the date of an If-Modified-Since or
+// If-Unmodified-Since header. Dates are ignored if they are invalid or
+// the modification time is unknown.
+func parseConditionalTime(value string, modtime time.Time) (time.Time, bool) {
+	if value == "" || modtime.IsZero() {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	return t, err == nil
+}
+
+// modifiedSince reports whether modtime is later than t. The HTTP date
+// format truncates sub-second precision, so use mtime >= t+1s rather
+// than mtime > t.
+func modifiedSince(modtime time.Time, t time.Time) bool {
+	return !modtime.Before(t.Add(1 * time.Second))
+}
+
+// ifRangeMatches reports whether the If-Range value ir, which is either
+// an entity tag or a date, matches the current representation. Entity
+// tags use the strong comparison and dates must match exactly.
+func ifRangeMatches(ir string, etag string, modtime time.Time) bool {
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return etag != "" && ir == etag && !strings.HasPrefix(etag, "W/")
+	}
+	// The If-Range value is typically the ETag value, but it may also be
+	// the modtime date. See golang.org/issue/8367.
+	if modtime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ir)
+	return err == nil && t.Unix() == modtime.Unix()
+}
+
+// writeNotModified responds with 304 Not Modified.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// writePreconditionFailed responds with 412 Precondition Failed.
+func writePreconditionFailed(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	w.WriteHeader(http.StatusPreconditionFailed)
+}
+
+// etagStrongMatch reports whether the If-Match header value list
+// matches etag, using the strong comparison of RFC 9110, section 8.8.3.2.
+func etagStrongMatch(list string, etag string) bool {
+	return etagListMatch(list, etag, false)
+}
+
+// etagWeakMatch reports whether the If-None-Match header value list
+// matches etag, using the weak comparison of RFC 9110, section 8.8.3.2.
+func etagWeakMatch(list string, etag string) bool {
+	return etagListMatch(list, etag, true)
+}
+
+func etagListMatch(list string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for list != "" {
+		list = textproto.TrimString(list)
+		if list == "" {
+			break
+		}
+		if list[0] == ',' {
+			list = list[1:]
+			continue
+		}
+		if list[0] == '*' {
+			return true
+		}
+		candidate, remain := scanEtag(list)
+		if candidate == "" {
+			// Not a valid list, so nothing matches
+			return false
 		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+		list = remain
 	}
-	return rangeReq, false
+	return false
+}
+
+// scanEtag returns the first entity tag in s, which may be weak, and the
+// rest of s after it. The tag is empty if s does not start with one.
+// Entity tags may contain commas, so lists of them cannot just be split.
+func scanEtag(s string) (etag string, remain string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s)-start < 2 || s[start] != '"' {
+		return "", ""
+	}
+	// ETag is either W/"text" or "text", see RFC 9110, section 8.8.3
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+			// Character values allowed in ETags
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
 }
 
 // toHTTPError returns a non-specific HTTP error message and status code
@@ -735,7 +1483,7 @@ func toHTTPError(err error) (msg string, httpStatus int) {
 	if os.IsNotExist(err) {
 		return "404 page not found", http.StatusNotFound
 	}
-	if os.IsPermission(err) {
+	if os.IsPermission(err) || err == errPasswordRequired || err == errWrongPassword {
 		return "403 Forbidden", http.StatusForbidden
 	}
 	// Default:
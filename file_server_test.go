@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -42,6 +43,49 @@ func (w *TestResponseWriter) WriteHeader(status int) {
 	w.status = status
 }
 
+// newTestRequest makes a request with body and headers from alternating
+// name and value arguments.
+func newTestRequest(method string, target string, body string, headers ...string) *http.Request {
+	r, _ := http.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+// serveTest serves the request of newTestRequest with handler.
+func serveTest(handler http.Handler, method string, target string, body string, headers ...string) *TestResponseWriter {
+	w := NewTestResponseWriter()
+	handler.ServeHTTP(w, newTestRequest(method, target, body, headers...))
+	return w
+}
+
+// serveAborted is serveTest for responses that may be aborted, and
+// reports whether handler aborted the response by panicking with
+// http.ErrAbortHandler, as the server expects.
+func serveAborted(handler http.Handler, method string, target string, body string, headers ...string) (w *TestResponseWriter, aborted bool) {
+	w = NewTestResponseWriter()
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler {
+				panic(err)
+			}
+			aborted = true
+		}
+	}()
+	handler.ServeHTTP(w, newTestRequest(method, target, body, headers...))
+	return w, false
+}
+
+// fromRemoteAddr returns a handler that serves requests with handler as
+// if they came from the client address addr.
+func fromRemoteAddr(handler http.Handler, addr string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = addr
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func TestNew(t *testing.T) {
 	assert := assert.New(t)
 	testCases := []struct {
@@ -83,7 +127,7 @@ func TestMountZip(t *testing.T) {
 	//require := require.New(t)
 
 	extensions := []string{"html", "htm"}
-	handler := EmptyFileServer("test/api/path/", "", true, extensions, nil)
+	handler := EmptyFileServer("test/api/path/", "", true, extensions, "", "", nil, nil, "")
 
 	testCases := []struct {
 		Path            string
@@ -103,9 +147,9 @@ func TestMountZip(t *testing.T) {
 				"Accept-Encoding: deflate, gzip",
 			},
 			ContentType:     "image/png",
-			ContentLength:   "4758",
-			ContentEncoding: "deflate",
-			Size:            4758,
+			ContentLength:   "5973",
+			ContentEncoding: "",
+			Size:            5973,
 			ETag:            `"1755529fb2ff"`,
 		},
 		{
@@ -127,7 +171,7 @@ func TestMountZip(t *testing.T) {
 				"Accept-Encoding: deflate, gzip",
 			},
 			ContentType:     "text/html; charset=utf-8",
-			ContentEncoding: "deflate",
+			ContentEncoding: "",
 		},
 		{
 			Path:            "/test.html",
@@ -313,6 +357,62 @@ func TestMountZip(t *testing.T) {
 	}
 }
 
+func TestListMountedFsEtag(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := EmptyFileServer("api/", "", false, nil, "", "", nil, nil, "")
+
+	w := serveTest(handler, "GET", "/api/listmountzip", "")
+	assert.Equal(200, w.status)
+	etag := w.Header().Get("Etag")
+	assert.NotEmpty(etag)
+
+	w = serveTest(handler, "GET", "/api/listmountzip", "", "If-None-Match", etag)
+	assert.Equal(304, w.status)
+	assert.Equal(0, w.buf.Len())
+
+	// Mounting an archive changes the ETag
+	mountTestZip(t, handler, `{"filePath": "testdata/testdata.zip"}`)
+	w = serveTest(handler, "GET", "/api/listmountzip", "", "If-None-Match", etag)
+	assert.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "testdata.zip")
+	assert.NotEqual(etag, w.Header().Get("Etag"))
+}
+
+func TestUnMountZip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	data, err := os.ReadFile("testdata/testdata.zip")
+	require.NoError(err)
+	require.NoError(os.WriteFile(filepath.Join(dir, "pack.zip"), data, 0644))
+
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir()).(*fileHandler)
+
+	mountTestZip(t, h, `{"filePath": "pack.zip"}`)
+	assert.Equal(200, serveTest(h, "GET", "/test.html", "").status)
+	mounted := h.mounted()
+	require.Len(mounted, 1)
+
+	w := serveTest(h, "POST", "/api/unmountzip", `{"filePath": "pack.zip"}`)
+	assert.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "Zip file unmounted!")
+
+	// The handler is back to having nothing mounted, and the file has
+	// been released.
+	assert.Empty(h.mounted())
+	assert.Nil(mounted[0].readerAt)
+	w = serveTest(h, "GET", "/test.html", "")
+	assert.Equal(404, w.status)
+	assert.Contains(w.buf.String(), "no ZIP is added")
+	assert.NoError(os.Remove(filepath.Join(dir, "pack.zip")))
+
+	w = serveTest(h, "POST", "/api/unmountzip", `{"filePath": "pack.zip"}`)
+	assert.Contains(w.buf.String(), "Zip file not mounted!")
+	assert.Equal(400, serveTest(h, "GET", "/api/unmountzip", "").status)
+}
+
 func TestServeHTTP(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -342,9 +442,9 @@ func TestServeHTTP(t *testing.T) {
 				"Accept-Encoding: deflate, gzip",
 			},
 			ContentType:     "image/png",
-			ContentLength:   "4758",
-			ContentEncoding: "deflate",
-			Size:            4758,
+			ContentLength:   "5973",
+			ContentEncoding: "",
+			Size:            5973,
 			ETag:            `"1755529fb2ff"`,
 		},
 		{
@@ -366,7 +466,7 @@ func TestServeHTTP(t *testing.T) {
 				"Accept-Encoding: deflate, gzip",
 			},
 			ContentType:     "text/html; charset=utf-8",
-			ContentEncoding: "deflate",
+			ContentEncoding: "",
 		},
 		{
 			Path:            "/test.html",
@@ -613,11 +713,13 @@ func TestCheckETag(t *testing.T) {
 		Range         string
 		IfRange       string
 		IfNoneMatch   string
+		IfMatch       string
 		ContentType   string
 		ContentLength string
 
 		RangeReq string
 		Done     bool
+		Status   int
 	}{
 		{
 			// Using the modified time instead of the ETag in If-Range header
@@ -669,7 +771,8 @@ func TestCheckETag(t *testing.T) {
 			ContentLength: "2024",
 
 			RangeReq: "",
-			Done:     false,
+			Done:     true,
+			Status:   http.StatusPreconditionFailed,
 		},
 		{
 			// Using the ETag in the If-Range header
@@ -710,6 +813,54 @@ func TestCheckETag(t *testing.T) {
 			RangeReq: "",
 			Done:     false,
 		},
+		{
+			// If-Match with one of the listed ETags matching
+			Method:        "GET",
+			Etag:          `"xxxxyyyy"`,
+			Range:         "bytes=500-999",
+			IfMatch:       `"aaaabbbb", "xxxxyyyy"`,
+			ContentType:   "text/html",
+			ContentLength: "2024",
+
+			RangeReq: "bytes=500-999",
+			Done:     false,
+		},
+		{
+			// If-Match with any current ETag
+			Method:        "GET",
+			Etag:          `"xxxxyyyy"`,
+			IfMatch:       "*",
+			ContentType:   "text/html",
+			ContentLength: "2024",
+
+			RangeReq: "",
+			Done:     false,
+		},
+		{
+			// If-Match with an out of date ETag
+			Method:        "GET",
+			Etag:          `"xxxxyyyy"`,
+			Range:         "bytes=500-999",
+			IfMatch:       `"aaaabbbb"`,
+			ContentType:   "text/html",
+			ContentLength: "2024",
+
+			RangeReq: "",
+			Done:     true,
+			Status:   http.StatusPreconditionFailed,
+		},
+		{
+			// If-Match uses the strong comparison
+			Method:        "GET",
+			Etag:          `W/"xxxxyyyy"`,
+			IfMatch:       `W/"xxxxyyyy"`,
+			ContentType:   "text/html",
+			ContentLength: "2024",
+
+			RangeReq: "",
+			Done:     true,
+			Status:   http.StatusPreconditionFailed,
+		},
 	}
 
 	for i, tc := range testCases {
@@ -727,6 +878,9 @@ func TestCheckETag(t *testing.T) {
 		if tc.IfNoneMatch != "" {
 			r.Header.Add("If-None-Match", tc.IfNoneMatch)
 		}
+		if tc.IfMatch != "" {
+			r.Header.Add("If-Match", tc.IfMatch)
+		}
 		if tc.ContentType != "" {
 			w.Header().Add("Content-Type", tc.ContentType)
 		}
@@ -734,9 +888,12 @@ func TestCheckETag(t *testing.T) {
 			w.Header().Add("Content-Length", tc.ContentLength)
 		}
 		_ = "breakpoint"
-		rangeReq, done := checkETag(w, r, tc.ModTime)
+		rangeReq, done := checkPreconditions(w, r, tc.ModTime)
 		assert.Equal(tc.RangeReq, rangeReq, fmt.Sprintf("test case #%d", i))
 		assert.Equal(tc.Done, done, fmt.Sprintf("test case #%d", i))
+		if tc.Status != 0 {
+			assert.Equal(tc.Status, w.status, fmt.Sprintf("test case #%d", i))
+		}
 		if done {
 			assert.Equal("", w.Header().Get("Content-Length"))
 			assert.Equal("", w.Header().Get("Content-Type"))
@@ -751,13 +908,14 @@ func TestCheckLastModified(t *testing.T) {
 	assert := assert.New(t)
 
 	testCases := []struct {
-		ModTime         time.Time
-		IfModifiedSince string
-		ContentType     string
-		ContentLength   string
-		LastModified    string
-		Status          int
-		Done            bool
+		ModTime           time.Time
+		IfModifiedSince   string
+		IfUnmodifiedSince string
+		ContentType       string
+		ContentLength     string
+		LastModified      string
+		Status            int
+		Done              bool
 	}{
 		{
 			ModTime:         time.Date(2020, 8, 1, 15, 3, 41, 0, time.UTC),
@@ -792,21 +950,41 @@ func TestCheckLastModified(t *testing.T) {
 			Status:          http.StatusOK,
 			Done:            false,
 		},
+		{
+			ModTime:           time.Date(2020, 8, 1, 15, 3, 41, 0, time.UTC),
+			IfUnmodifiedSince: "Sat, 01 Aug 2020 15:03:41 GMT",
+			ContentType:       "text/html",
+			ContentLength:     "3000",
+			LastModified:      "Sat, 01 Aug 2020 15:03:41 GMT",
+			Status:            http.StatusOK,
+			Done:              false,
+		},
+		{
+			ModTime:           time.Date(2020, 8, 1, 15, 3, 41, 0, time.UTC),
+			IfUnmodifiedSince: "Sat, 01 Aug 2020 15:03:40 GMT",
+			ContentType:       "text/html",
+			ContentLength:     "3000",
+			Status:            http.StatusPreconditionFailed,
+			Done:              true,
+		},
 	}
 
 	for i, tc := range testCases {
-		r := &http.Request{Header: http.Header{}}
+		r := &http.Request{Method: "GET", Header: http.Header{}}
 		w := NewTestResponseWriter()
 		if tc.IfModifiedSince != "" {
 			r.Header.Set("If-Modified-Since", tc.IfModifiedSince)
 		}
+		if tc.IfUnmodifiedSince != "" {
+			r.Header.Set("If-Unmodified-Since", tc.IfUnmodifiedSince)
+		}
 		if tc.ContentType != "" {
 			w.Header().Set("Content-Type", tc.ContentType)
 		}
 		if tc.ContentLength != "" {
 			w.Header().Set("Content-Length", tc.ContentLength)
 		}
-		done := checkLastModified(w, r, tc.ModTime)
+		_, done := checkPreconditions(w, r, tc.ModTime)
 		failText := fmt.Sprintf("test case #%d", i)
 		assert.Equal(tc.Done, done, failText)
 		assert.Equal(tc.Status, w.status, failText)
@@ -823,6 +1001,86 @@ func TestCheckLastModified(t *testing.T) {
 	}
 }
 
+func TestCheckPreconditionsOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	modtime := time.Date(2020, 8, 1, 15, 3, 41, 0, time.UTC)
+	before := "Sat, 01 Aug 2020 15:03:40 GMT"
+	after := "Sat, 01 Aug 2020 15:03:42 GMT"
+	testCases := []struct {
+		Name     string
+		Method   string
+		Header   map[string]string
+		Status   int
+		RangeReq string
+	}{
+		{
+			Name:   "If-Match takes precedence over If-Unmodified-Since",
+			Header: map[string]string{"If-Match": `"current"`, "If-Unmodified-Since": before},
+			Status: http.StatusOK,
+		},
+		{
+			Name:   "If-Unmodified-Since fails before If-None-Match is evaluated",
+			Header: map[string]string{"If-Unmodified-Since": before, "If-None-Match": `"current"`},
+			Status: http.StatusPreconditionFailed,
+		},
+		{
+			Name:   "If-None-Match takes precedence over If-Modified-Since",
+			Header: map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": after},
+			Status: http.StatusOK,
+		},
+		{
+			Name:   "If-None-Match uses the weak comparison and lists",
+			Header: map[string]string{"If-None-Match": `"stale", W/"current"`},
+			Status: http.StatusNotModified,
+		},
+		{
+			Name:   "If-None-Match fails for other methods",
+			Method: "POST",
+			Header: map[string]string{"If-None-Match": "*"},
+			Status: http.StatusPreconditionFailed,
+		},
+		{
+			Name:   "If-Modified-Since is ignored for other methods",
+			Method: "POST",
+			Header: map[string]string{"If-Modified-Since": after},
+			Status: http.StatusOK,
+		},
+		{
+			Name:     "If-Range applies after the other preconditions",
+			Header:   map[string]string{"If-Match": "*", "Range": "bytes=0-9", "If-Range": `"current"`},
+			Status:   http.StatusOK,
+			RangeReq: "bytes=0-9",
+		},
+		{
+			Name:   "A matching If-Range does not override a 304",
+			Header: map[string]string{"If-None-Match": `"current"`, "Range": "bytes=0-9", "If-Range": `"current"`},
+			Status: http.StatusNotModified,
+		},
+		{
+			Name:   "If-Range with a weak ETag never matches",
+			Header: map[string]string{"Range": "bytes=0-9", "If-Range": `W/"current"`},
+			Status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		method := tc.Method
+		if method == "" {
+			method = "GET"
+		}
+		r := &http.Request{Method: method, Header: http.Header{}}
+		for k, v := range tc.Header {
+			r.Header.Set(k, v)
+		}
+		w := NewTestResponseWriter()
+		w.Header().Set("Etag", `"current"`)
+		rangeReq, _ := checkPreconditions(w, r, modtime)
+		assert.Equal(tc.Status, w.status, tc.Name)
+		assert.Equal(tc.RangeReq, rangeReq, tc.Name)
+	}
+}
+
 func getMimeType(ext string) string {
 	mimeType := mime.TypeByExtension(ext)
 	if mimeType == "" {
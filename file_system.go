@@ -2,8 +2,12 @@ package zipfs
 
 import (
 	"archive/zip"
+	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
+	iofs "io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -12,6 +16,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/text/encoding"
@@ -22,6 +27,7 @@ import (
 	"golang.org/x/text/encoding/traditionalchinese"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -68,18 +74,77 @@ var charmapEncoders []*encoding.Encoder = []*encoding.Encoder{
 }
 
 // FileSystem is a file system based on a ZIP file.
-// It implements the http.FileSystem interface.
+// It implements the io/fs.FS, io/fs.StatFS and io/fs.ReadDirFS interfaces.
 type FileSystem struct {
+	archive
+
+	stats     ArchiveStats // See Stats
+	statsOnce sync.Once
+
+	reloadMutex sync.RWMutex  // Guards next
+	next        *FileSystem   // Archive that replaced this one, see Reload
+	handle      *FileSystem   // FileSystem this archive was reloaded into
+	reloads     atomic.Uint64 // Number of times the archive was replaced, see mountTableEtag
+
+	dirConfigs     map[string]*dirConfig
+	dirConfigMutex sync.Mutex
+
+	headerRules     []HeaderRule // See loadHeaderRules
+	headerRulesOnce sync.Once
+
+	redirectRules     []RedirectRule // See loadRedirectRules
+	redirectRulesOnce sync.Once
+
+	etag     string // See archiveEtag
+	etagOnce sync.Once
+
+	inFlight sync.WaitGroup // Requests and open files using the file system, see acquire
+
+	nested      map[string]*FileSystem // Zip files stored in this one, see openNested
+	nestedMutex sync.Mutex
+}
+
+// archive holds the fields of a FileSystem that can be copied, which Sub
+// does so that a view behaves like the archive it is made from.
+type archive struct {
 	readerAt  io.ReaderAt
 	closer    io.Closer
 	reader    *zip.Reader
 	fileInfos fileInfoMap
 	givenPath string
 	fullPath  string
+	size      int64
+	modTime   time.Time
+	subDir    string // Directory of the archive that is the root, see Sub
+	password  []byte // Decrypts encrypted entries, see SetPassword
+	degraded  bool   // Recovered from a damaged Zip file, see NewRepaired
+	madeUp    bool   // Made up from an archive of another format, see newFromTar
+	inMemory  bool   // Read into memory by NewInMemory
+	mapped    bool   // Mapped into memory by NewMapped
+
+	noSymlinks   bool              // See SetSymlinkResolution
+	nameEncoding encoding.Encoding // See SetNameEncoding
+	duplicates   DuplicatePolicy   // See SetDuplicatePolicy
+
+	// caseVariants holds the files whose names only differ in case from
+	// an earlier one, and those earlier ones, by their exact names
+	caseVariants map[string]*fileInfo
+
+	// openers read the contents of the entries of archives of other
+	// formats that are not stored in the made up Zip file, see RegisterFormat
+	openers map[*zip.File]func() (io.ReadCloser, error)
+
+	// file is the file opened by New, which WithAutoReload checks for
+	// changes
+	file os.FileInfo
+
+	nestedPrefix string // Path of this Zip file if it is nested, see fullName
 }
 
 // New will open the Zip file specified by name and
 // return a new FileSystem based on that Zip file.
+// Tar files, gzip compressed tar files, ISO 9660 images and archives
+// of the formats registered with RegisterFormat are opened as well.
 func New(name string) (*FileSystem, error) {
 	file, err := os.Open(name)
 	if err != nil {
@@ -89,13 +154,94 @@ func New(name string) (*FileSystem, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewFromReaderAt(file, fi.Size(), file, name)
+	fs, err := NewFromReaderAt(file, fi.Size(), file, name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	fs.modTime = fi.ModTime()
+	fs.file = fi
+	return fs, nil
+}
+
+// NewFromBytes returns a new FileSystem based on the Zip file held in
+// data, which must not be modified while the file system is in use.
+// The name is used to identify the file system, for example in the
+// mount list.
+func NewFromBytes(data []byte, name string) (*FileSystem, error) {
+	return NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil, name)
+}
+
+// NewFromFS will open the Zip file specified by name in fsys and return
+// a new FileSystem based on that Zip file. It is intended for Zip files
+// embedded with go:embed, which are read in place without being copied:
+//
+//	//go:embed content.zip
+//	var content embed.FS
+//
+//	fs, err := zipfs.NewFromFS(content, "content.zip")
+//
+// Files of other file systems that do not implement io.ReaderAt are
+// read into memory.
+func NewFromFS(fsys iofs.FS, name string) (*FileSystem, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var fs *FileSystem
+	if readerAt, ok := file.(io.ReaderAt); ok {
+		fs, err = NewFromReaderAt(readerAt, fi.Size(), file, name)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else {
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		fs, err = NewFromBytes(data, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fs.modTime = fi.ModTime()
+	return fs, nil
 }
 
 // NewFromReaderAt will open the Zip file accessible by readerAt with the given size.
+// Tar files, gzip compressed tar files, ISO 9660 images and archives
+// of the formats registered with RegisterFormat are opened as well.
 // The closer, if not nil, will be called when the file system is closed.
+// Data before the Zip file, such as the executable of a self-extracting
+// archive, is skipped.
 func NewFromReaderAt(readerAt io.ReaderAt, size int64, closer io.Closer, filePath string) (*FileSystem, error) {
 	zipReader, err := zip.NewReader(readerAt, size)
+	if err == zip.ErrFormat {
+		if base, ok := prependedZip64Offset(readerAt, size); ok {
+			readerAt = io.NewSectionReader(readerAt, base, size-base)
+			size -= base
+			zipReader, err = zip.NewReader(readerAt, size)
+		}
+	}
+	if err == zip.ErrFormat && (isTar(readerAt) || isGzip(readerAt)) {
+		return newFromTar(readerAt, size, closer, filePath)
+	}
+	if err == zip.ErrFormat {
+		if read := formatFor(readerAt); read != nil {
+			return newFromFormat(read, readerAt, size, closer, filePath)
+		}
+	}
+	if err == zip.ErrFormat && isISO(readerAt) {
+		return newFromISO(readerAt, size, closer, filePath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -107,14 +253,15 @@ func NewFromReaderAt(readerAt io.ReaderAt, size int64, closer io.Closer, filePat
 	// does not need to be in the FileSystem structure. Keeping it
 	// there for now but may remove it in future.
 	workingDir, _ := os.Getwd()
-	fs := &FileSystem{
+	fs := &FileSystem{archive: archive{
 		closer:    closer,
 		readerAt:  readerAt,
 		reader:    zipReader,
 		fileInfos: fileInfoMap{},
 		givenPath: filePath,
 		fullPath:  path.Join(workingDir, filePath),
-	}
+		size:      size,
+	}}
 
 	// Build a map of file paths to speed lookup.
 	// Note that this assumes that there are not a very
@@ -125,37 +272,116 @@ func NewFromReaderAt(readerAt io.ReaderAt, size int64, closer io.Closer, filePat
 	// reasonable if the ZIP file does not contain a very large number
 	// of entries.
 	for _, zf := range fs.reader.File {
-		fi := fs.fileInfos.FindOrCreate(zf.Name)
+		reconcileDataDescriptor(readerAt, size, zf)
+	}
+	fs.indexEntries()
+
+	return fs, nil
+}
+
+// indexEntries builds fileInfos from the entries of the ZIP file. With
+// DuplicateError, it returns the error of the first duplicate entry,
+// which is indexed like with DuplicateLastWins.
+func (fs *FileSystem) indexEntries() error {
+	fs.fileInfos = fileInfoMap{}
+	fs.caseVariants = nil
+	var dupErr error
+	var indexed []*fileInfo
+	for _, zf := range fs.reader.File {
+		name := fs.entryName(zf)
+		if dup := fs.duplicateEntry(name); dup != nil && !zf.Mode().IsDir() {
+			if fs.duplicates == DuplicateError && dupErr == nil {
+				dupErr = fmt.Errorf("%w: %s", errDuplicateEntry, zf.Name)
+			}
+			if fs.duplicates != DuplicateFirstWins {
+				dup.zipFile = zf
+			}
+			continue
+		}
+		fi := fs.caseVariant(name, zf)
+		if fi == nil {
+			fi = fs.fileInfos.FindOrCreate(name)
+		}
+		if fi.zipFile != nil {
+			// Another entry of the same directory
+			continue
+		}
 		fi.zipFile = zf
-		fiParent := fs.fileInfos.FindOrCreateParent(zf.Name)
+		fi.fs = fs
+		indexed = append(indexed, fi)
+		if name != zf.Name {
+			// The undecoded name is still found, see testAltEncodings
+			fs.fileInfos.addAlias(zf.Name, fi)
+		}
+	}
+
+	// Attach each fileInfo to its parent directory. Many ZIP files have
+	// no entries for directories, which are made up here, and attached
+	// to their parent in turn.
+	fs.fileInfos.FindOrCreate("/")
+	for i := 0; i < len(indexed); i++ {
+		fi := indexed[i]
+		if fi.name == "/" {
+			continue
+		}
+		dirName := parentDirName(fi.name)
+		fiParent := fs.fileInfos[dirName]
+		if fiParent == nil {
+			fiParent = fs.fileInfos.FindOrCreate(dirName)
+			indexed = append(indexed, fiParent)
+		}
 		fiParent.fileInfos = append(fiParent.fileInfos, fi)
 	}
 
 	// Sort all of the list of fileInfos in each directory.
 	for _, fi := range fs.fileInfos {
+		fi.fs = fs
 		if len(fi.fileInfos) > 1 {
-			sort.Sort(fi.fileInfos)
+			// Stable, so that entries whose names only differ in case
+			// stay in the order of the archive
+			sort.Stable(fi.fileInfos)
 		}
 	}
-
-	return fs, nil
+	return dupErr
 }
 
-// Open implements the http.FileSystem interface.
-// A http.File is returned, which can be served by
-// the http.FileServer implementation.
-func (fs *FileSystem) Open(name string) (http.File, error) {
+// Open implements the io/fs.FS interface. Names are matched
+// case-insensitively. Of files whose names only differ in case, the
+// first one in the archive is opened unless the name matches one of the
+// others exactly. Entries of Zip files stored in the archive are
+// named after them with a "!" separator, as in levels.zip!/maps/map1.dat.
+// The returned file also implements http.File and io/fs.ReadDirFile.
+// Code written for the http.FileSystem version of Open can use OpenHTTP.
+func (fs *FileSystem) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+	fs = fs.acquire()
 	fi, err := fs.openFileInfo(name)
 	if err != nil {
+		fs.inFlight.Done()
 		return nil, err
 	}
 
-	return fi.openReader(name), nil
+	f := fi.openReader(name)
+	f.release = fs.inFlight.Done
+	return f, nil
+}
+
+// OpenHTTP opens name as Open did when FileSystem implemented
+// http.FileSystem: names start with a slash as in URL paths, and may be
+// percent-encoded.
+//
+// Deprecated: Use Open, which takes io/fs names, or HTTPFileSystem.
+func (fs *FileSystem) OpenHTTP(name string) (http.File, error) {
+	return HTTPFileSystem(fs).Open(name)
 }
 
 // Close closes the file system's underlying ZIP file and
 // releases all memory allocated to internal data structures.
 func (fs *FileSystem) Close() error {
+	fs = fs.current()
+	fs.closeNested()
 	fs.reader = nil
 	fs.readerAt = nil
 	var err error
@@ -164,6 +390,9 @@ func (fs *FileSystem) Close() error {
 		fs.closer = nil
 	}
 	fs.fileInfos = nil
+	fs.dirConfigMutex.Lock()
+	fs.dirConfigs = nil
+	fs.dirConfigMutex.Unlock()
 	return err
 }
 
@@ -186,17 +415,53 @@ func (fl fileInfoList) Swap(i, j int) {
 }
 
 func (fs *FileSystem) openFileInfo(name string) (*fileInfo, error) {
+	return fs.openAllowedFileInfo(name, nil)
+}
+
+// openAllowedFileInfo is openFileInfo, except that symbolic links are
+// only followed to targets that allowed reports true for, see
+// resolveSymlinks.
+func (fs *FileSystem) openAllowedFileInfo(name string, allowed func(target string) bool) (*fileInfo, error) {
 	if fs.readerAt == nil {
 		return nil, errFileSystemClosed
 	}
+	if fs.caseVariants != nil {
+		//Check if it names one of several entries that only differ in case
+		exact, _ := url.PathUnescape(path.Clean(name))
+		if fi := fs.caseVariants[norm.NFC.String(strings.TrimLeft(exact, "/"))]; fi != nil {
+			return fi, nil
+		}
+	}
 	name, _ = url.PathUnescape(strings.ToLower(path.Clean(name)))
+	name = norm.NFC.String(name)
 	trimmedName := strings.TrimLeft(name, "/")
+	if trimmedName == "." {
+		// The root directory, as named by io/fs
+		trimmedName = ""
+	}
 
 	//Check if the UTF-8 or ASCII name exists
 	fi := fs.fileInfos[trimmedName]
+	if !fs.noSymlinks && (fi == nil || fi.isSymlink()) {
+		//Check if it is found by following symbolic links
+		linked, err := fs.resolveSymlinks(trimmedName, allowed)
+		if err == nil {
+			return linked, nil
+		}
+		if fi != nil || err != os.ErrNotExist {
+			return nil, &os.PathError{Op: "Open", Path: name, Err: err}
+		}
+	}
 	if fi == nil {
 		//Check if any of the other codes exist
 		fi = fs.testAltEncodings(name)
+		if fi == nil {
+			//Check if it is in a Zip file stored in this one
+			nested, err := fs.openNestedFileInfo(trimmedName)
+			if nested != nil || err != nil {
+				return nested, err
+			}
+		}
 		//If no Codes still exist, return nil with Error
 		if fi == nil {
 			return nil, &os.PathError{Op: "Open", Path: name, Err: os.ErrNotExist}
@@ -252,15 +517,27 @@ func (fm fileInfoMap) FindOrCreate(name string) *fileInfo {
 	return fi
 }
 
-func (fm fileInfoMap) FindOrCreateParent(name string) *fileInfo {
+// addAlias makes fi found by name as well, unless another entry is.
+func (fm fileInfoMap) addAlias(name string, fi *fileInfo) {
+	name = strings.ToLower(name)
+	for _, key := range []string{name, strings.TrimRight(name, "/")} {
+		if fm[key] == nil {
+			fm[key] = fi
+		}
+	}
+}
+
+// parentDirName returns the name of the directory holding the entry
+// name, with a trailing slash.
+func parentDirName(name string) string {
 	strippedName := strings.TrimRight(name, "/")
 	dirName := path.Dir(strippedName)
 	if dirName == "." {
-		dirName = "/"
+		return "/"
 	} else if !strings.HasSuffix(dirName, "/") {
-		dirName = dirName + "/"
+		return dirName + "/"
 	}
-	return fm.FindOrCreate(dirName)
+	return dirName
 }
 
 // fileInfo implements the os.FileInfo interface.
@@ -271,6 +548,8 @@ type fileInfo struct {
 	fileInfos fileInfoList
 	tempPath  string
 	mutex     sync.Mutex
+
+	contentHash atomic.Pointer[[sha256.Size]byte] // See WithContentHashEtags
 }
 
 func (fi *fileInfo) Name() string {
@@ -296,10 +575,20 @@ func (fi *fileInfo) Mode() os.FileMode {
 
 var dirTime = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
 
+// ModTime returns the modification time of the entry. It is taken from
+// the extended timestamp or NTFS extra field of the entry if it has one,
+// which holds the time in UTC with a precision of a second or better.
+// Otherwise it is the MS-DOS time of the entry, which has a precision of
+// two seconds and no time zone, so it is taken to be in UTC.
 func (fi *fileInfo) ModTime() time.Time {
 	if fi.zipFile == nil {
 		return dirTime
 	}
+	// archive/zip fills in Modified from the extra fields, or from the
+	// MS-DOS time if there are none
+	if !fi.zipFile.Modified.IsZero() {
+		return fi.zipFile.Modified
+	}
 	return fi.zipFile.ModTime()
 }
 
@@ -328,7 +617,7 @@ func (fi *fileInfo) readdir() ([]os.FileInfo, error) {
 
 	v := make([]os.FileInfo, len(fi.fileInfos))
 	for i, fi := range fi.fileInfos {
-		v[i] = fi
+		v[i] = fi.info()
 	}
 	return v, nil
 }
@@ -340,9 +629,15 @@ type fileReader struct {
 	file     *os.File
 	closed   bool
 	readdir  []os.FileInfo
+	pos      int64  // Bytes read from reader
+	release  func() // Lets the archive be closed after a reload, see Open
 }
 
 func (f *fileReader) Close() error {
+	if f.release != nil {
+		f.release()
+		f.release = nil
+	}
 	var errs []error
 	if f.reader != nil {
 		err := f.reader.Close()
@@ -377,12 +672,15 @@ func (f *fileReader) Read(p []byte) (n int, err error) {
 		return f.file.Read(p)
 	}
 	if f.reader == nil {
-		f.reader, err = f.fileInfo.zipFile.Open()
+		f.reader, err = f.fileInfo.open()
 		if err != nil {
 			return 0, err
 		}
+		f.pos = 0
 	}
-	return f.reader.Read(p)
+	n, err = f.reader.Read(p)
+	f.pos += int64(n)
+	return n, err
 }
 
 func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
@@ -400,12 +698,20 @@ func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
 	// A special case for when there is no file created and the seek is
 	// to the beginning of the file. Just open (or re-open) the reader
 	// at the beginning of the file.
-	if f.file == nil && offset == 0 && whence == 0 {
+	if f.file == nil && offset == 0 && whence == io.SeekStart {
 		var err error
-		f.reader, err = f.fileInfo.zipFile.Open()
+		f.reader, err = f.fileInfo.open()
+		f.pos = 0
 		return 0, err
 	}
 
+	// The temporary file starts at the beginning, so make a seek relative
+	// to the position of the reader absolute.
+	if f.file == nil && whence == io.SeekCurrent {
+		offset += f.pos
+		whence = io.SeekStart
+	}
+
 	if err := f.createTempFile(); err != nil {
 		return 0, err
 	}
@@ -414,36 +720,32 @@ func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *fileReader) Readdir(count int) ([]os.FileInfo, error) {
-	var err error
-	var osFileInfos []os.FileInfo
-
-	if count > 0 {
-		if f.readdir == nil {
-			f.readdir, err = f.fileInfo.readdir()
-			if err != nil {
-				return nil, f.pathError("Readdir", err)
-			}
-		}
-		if len(f.readdir) >= count {
-			osFileInfos = f.readdir[0:count]
-			f.readdir = f.readdir[count:]
-		} else {
-			osFileInfos = f.readdir
-			f.readdir = nil
-			err = io.EOF
-		}
-	} else {
-		osFileInfos, err = f.fileInfo.readdir()
+	if f.readdir == nil {
+		infos, err := f.fileInfo.readdir()
 		if err != nil {
 			return nil, f.pathError("Readdir", err)
 		}
+		f.readdir = infos
 	}
 
-	return osFileInfos, err
+	// Like os.File, return the entries that have not been returned yet.
+	if count <= 0 {
+		osFileInfos := f.readdir
+		f.readdir = []os.FileInfo{}
+		return osFileInfos, nil
+	}
+	if len(f.readdir) >= count {
+		osFileInfos := f.readdir[0:count]
+		f.readdir = f.readdir[count:]
+		return osFileInfos, nil
+	}
+	osFileInfos := f.readdir
+	f.readdir = f.readdir[len(f.readdir):]
+	return osFileInfos, io.EOF
 }
 
 func (f *fileReader) Stat() (os.FileInfo, error) {
-	return f.fileInfo, nil
+	return f.fileInfo.info(), nil
 }
 
 func (f *fileReader) createTempFile() error {
@@ -455,7 +757,7 @@ func (f *fileReader) createTempFile() error {
 	}
 	if f.file == nil {
 		// Open a file that contains the contents of the zip file.
-		osFile, err := createTempFile(f.fileInfo.zipFile)
+		osFile, err := createTempFile(f.fileInfo)
 		if err != nil {
 			return err
 		}
@@ -475,8 +777,8 @@ func (f *fileReader) pathError(op string, err error) error {
 
 // createTempFile creates a temporary file with the contents of the
 // zip file. Used to implement io.Seeker interface.
-func createTempFile(f *zip.File) (*os.File, error) {
-	reader, err := f.Open()
+func createTempFile(fi *fileInfo) (*os.File, error) {
+	reader, err := fi.open()
 	if err != nil {
 		return nil, err
 	}
@@ -2,9 +2,13 @@ package zipfs
 
 import (
 	"archive/zip"
+	"bytes"
 	"crypto/md5"
+	"embed"
 	"fmt"
 	"io"
+	iofs "io/fs"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
@@ -21,11 +25,11 @@ func TestFileSystem(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(fs)
 
-	f, err := fs.Open("/xxx")
+	f, err := HTTPFileSystem(fs).Open("/xxx")
 	assert.Error(err)
 	assert.Nil(f)
 
-	f, err = fs.Open("test.html")
+	f, err = HTTPFileSystem(fs).Open("test.html")
 	assert.NoError(err)
 	assert.NotNil(f)
 
@@ -39,7 +43,7 @@ func TestCaseInsensitive(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(fs)
 
-	f, err := fs.Open("test.HTML")
+	f, err := HTTPFileSystem(fs).Open("test.HTML")
 	assert.NoError(err)
 	assert.NotNil(f)
 }
@@ -52,15 +56,15 @@ func TestSpecialCharacters(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(fs)
 
-	f, err := fs.Open("Porte Fermée.txt")
+	f, err := HTTPFileSystem(fs).Open("Porte Fermée.txt")
 	assert.NoError(err)
 	assert.NotNil(f)
 
-	f, err = fs.Open("Porte%20Fermée.txt")
+	f, err = HTTPFileSystem(fs).Open("Porte%20Fermée.txt")
 	assert.NoError(err)
 	assert.NotNil(f)
 
-	f, err = fs.Open("Porte%20Ferm%C3%A9e.txt")
+	f, err = HTTPFileSystem(fs).Open("Porte%20Ferm%C3%A9e.txt")
 	assert.NoError(err)
 	assert.NotNil(f)
 }
@@ -89,7 +93,7 @@ func TestOpen(t *testing.T) {
 		},
 	}
 	for _, tc := range testCases {
-		f, err := fs.Open(tc.Path)
+		f, err := HTTPFileSystem(fs).Open(tc.Path)
 		if tc.Error == "" {
 			assert.NoError(err)
 			assert.NotNil(f)
@@ -110,7 +114,7 @@ func TestOpen(t *testing.T) {
 
 	err = fs.Close()
 	assert.NoError(err)
-	f, err := fs.Open("/img/circle.png")
+	f, err := HTTPFileSystem(fs).Open("/img/circle.png")
 	assert.Error(err)
 	assert.Nil(f)
 	assert.True(strings.Contains(err.Error(), "filesystem closed"), err.Error())
@@ -147,7 +151,7 @@ func TestReaddir(t *testing.T) {
 				"js",
 				"lots-of-files",
 				"not-a-zip-file.txt",
-				"porte ferm�e.txt",
+				"porte fermée.txt",
 				"random.dat",
 				"test.html",
 			},
@@ -190,7 +194,7 @@ func TestReaddir(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		f, err := fs.Open(tc.Path)
+		f, err := HTTPFileSystem(fs).Open(tc.Path)
 		require.NoError(err)
 		require.NotNil(f)
 
@@ -228,7 +232,7 @@ func TestReaddir(t *testing.T) {
 		}
 	}
 
-	file, err := fs.Open("/lots-of-files")
+	file, err := HTTPFileSystem(fs).Open("/lots-of-files")
 	require.NoError(err)
 	for i := 0; i < 10; i++ {
 		a, err := file.Readdir(2)
@@ -287,7 +291,7 @@ func TestFileInfo(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		file, err := fs.Open(tc.Path)
+		file, err := HTTPFileSystem(fs).Open(tc.Path)
 		require.NoError(err)
 		fi, err := file.Stat()
 		require.NoError(err)
@@ -344,7 +348,7 @@ func TestFile(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		file, err := fs.Open(tc.Path)
+		file, err := HTTPFileSystem(fs).Open(tc.Path)
 		assert.NoError(err)
 		assert.Equal(tc.MD5, calcMD5(file, tc.Size, false))
 
@@ -399,3 +403,114 @@ func TestNewFromReaderAt_WithCloser(t *testing.T) {
 	_, err = file.Seek(0, io.SeekStart)
 	require.Error(err)
 }
+
+func TestNewFromBytes(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/testdata.zip")
+	require.NoError(err)
+	fs, err := NewFromBytes(data, "testdata.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	f, err := fs.Open("test.html")
+	require.NoError(err)
+	f.Close()
+	assert.Equal("testdata.zip", fs.givenPath)
+
+	_, err = NewFromBytes([]byte("not a zip"), "bad.zip")
+	assert.Error(err)
+}
+
+//go:embed testdata/testdata.zip
+var embeddedZip embed.FS
+
+// readOnlyFS hides the io.ReaderAt implementation of the files of a
+// file system.
+type readOnlyFS struct {
+	fs iofs.FS
+}
+
+func (r readOnlyFS) Open(name string) (iofs.File, error) {
+	f, err := r.fs.Open(name)
+	return struct{ iofs.File }{f}, err
+}
+
+func TestNewFromFS(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	for _, fsys := range []iofs.FS{embeddedZip, readOnlyFS{embeddedZip}} {
+		fs, err := NewFromFS(fsys, "testdata/testdata.zip")
+		require.NoError(err)
+		f, err := fs.Open("img/circle.png")
+		require.NoError(err)
+		data, err := io.ReadAll(f)
+		require.NoError(err)
+		assert.Len(data, 5973)
+		f.Close()
+		require.NoError(fs.Close())
+	}
+
+	_, err := NewFromFS(embeddedZip, "missing.zip")
+	assert.Error(err)
+}
+
+func TestImplicitDirectories(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// The archive has no entries for directories
+	fs := newTestFileSystem(t, "site/assets/app.js", "app", "site/index.htm", "index", "readme.txt", "readme")
+	names := func(dir string) []string {
+		entries, err := fs.ReadDir(dir)
+		require.NoError(err, dir)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	assert.Equal([]string{"readme.txt", "site"}, names("."))
+	assert.Equal([]string{"assets", "index.htm"}, names("site"))
+	assert.Equal([]string{"app.js"}, names("site/assets"))
+	info, err := fs.Stat("site")
+	require.NoError(err)
+	assert.True(info.IsDir())
+
+	handler := FileServer(fs, "api/", "", false, []string{"htm"}, nil)
+	assert.Equal("index", serveTest(handler, "GET", "/site/", "").buf.String())
+	w := serveTest(handler, "GET", "/site", "")
+	assert.Equal(http.StatusMovedPermanently, w.status)
+	assert.Equal("site/", w.Header().Get("Location"))
+}
+
+// newTestFileSystem builds an in-memory ZIP file from alternating
+// name and content arguments and opens it as a FileSystem.
+func newTestFileSystem(t *testing.T, files ...string) *FileSystem {
+	t.Helper()
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		require.NoError(err)
+		_, err = w.Write([]byte(files[i+1]))
+		require.NoError(err)
+	}
+	require.NoError(zw.Close())
+
+	fs, err := NewFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil, "test.zip")
+	require.NoError(err)
+	return fs
+}
+
+// readTestFile returns the contents of the file name of fs, and fails the
+// test if it cannot be read.
+func readTestFile(t *testing.T, fs iofs.FS, name string) string {
+	t.Helper()
+	data, err := iofs.ReadFile(fs, name)
+	assert.NoError(t, err, name)
+	return string(data)
+}
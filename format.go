@@ -0,0 +1,103 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"io"
+	"sync"
+	"time"
+)
+
+// methodExternal is the compression method of the entries of a made up
+// Zip file whose contents are read by the reader of the archive they
+// came from, see FileSystem.openers.
+const methodExternal = 0xffff
+
+// Entry is a file of an archive of a format registered with
+// RegisterFormat.
+type Entry struct {
+	Name     string    // Slash separated path inside the archive
+	Dir      bool      // Whether the entry is a directory
+	Modified time.Time // Modification time
+	CRC32    uint32    // Checksum of the contents, checked as they are read unless 0
+	Size     int64     // Size of the contents
+
+	// Open returns a reader of the contents of the entry. It is not
+	// called for directories.
+	Open func() (io.ReadCloser, error)
+}
+
+// FormatReader lists the entries of the archive in r, which is size
+// bytes long.
+type FormatReader func(r io.ReaderAt, size int64) ([]Entry, error)
+
+type format struct {
+	magic string
+	read  FormatReader
+}
+
+var (
+	formats      []format
+	formatsMutex sync.RWMutex
+)
+
+// RegisterFormat registers read for the archives that start with magic,
+// so that file systems can be opened from archives of formats other
+// than Zip, tar and ISO 9660. The entries of such archives are not read
+// in place: a Zip file listing them is made up, and their contents are
+// read with the Open functions of the entries. Formats are usually
+// registered by the package that reads them when it is imported, see
+// package zipfs7z.
+func RegisterFormat(magic string, read FormatReader) {
+	formatsMutex.Lock()
+	defer formatsMutex.Unlock()
+	formats = append(formats, format{magic: magic, read: read})
+}
+
+// formatFor returns the reader of the format registered for the archive
+// in r, or nil if there is none.
+func formatFor(r io.ReaderAt) FormatReader {
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+	for _, f := range formats {
+		magic := make([]byte, len(f.magic))
+		if _, err := r.ReadAt(magic, 0); err == nil && string(magic) == f.magic {
+			return f.read
+		}
+	}
+	return nil
+}
+
+// newFromFormat returns a file system of the archive in readerAt, whose
+// entries are listed by read.
+func newFromFormat(read FormatReader, readerAt io.ReaderAt, size int64, closer io.Closer, filePath string) (*FileSystem, error) {
+	entries, err := read(readerAt, size)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &madeUpZip{}
+	for _, e := range entries {
+		name := e.Name
+		if e.Dir {
+			name += "/"
+		}
+		z.add(name, e.Modified, methodExternal, e.CRC32, e.Size)
+	}
+	zipReaderAt, zipSize, err := z.finish()
+	if err != nil {
+		return nil, err
+	}
+	fs, err := NewFromReaderAt(zipReaderAt, zipSize, closer, filePath)
+	if err != nil {
+		return nil, err
+	}
+	fs.size = size
+	fs.madeUp = true
+	fs.openers = map[*zip.File]func() (io.ReadCloser, error){}
+	for i, zf := range fs.reader.File {
+		if !entries[i].Dir {
+			fs.openers[zf] = entries[i].Open
+		}
+	}
+	return fs, nil
+}
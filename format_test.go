@@ -0,0 +1,60 @@
+package zipfs
+
+import (
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterFormat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Archives of this format list their files one per line after the
+	// magic, with the names as contents
+	modTime := time.Date(2010, 1, 2, 3, 4, 6, 0, time.UTC)
+	RegisterFormat("LINES\n", func(r io.ReaderAt, size int64) ([]Entry, error) {
+		data, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		entries := []Entry{{Name: "dir", Dir: true, Modified: modTime}}
+		for _, name := range strings.Fields(string(data[6:])) {
+			entries = append(entries, Entry{
+				Name:     "dir/" + name,
+				Modified: modTime,
+				CRC32:    crc32.ChecksumIEEE([]byte("contents of " + name)),
+				Size:     int64(len("contents of " + name)),
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("contents of " + strings.TrimSuffix(name, ".bad"))), nil
+				},
+			})
+		}
+		return entries, nil
+	})
+
+	fs, err := NewFromBytes([]byte("LINES\na.txt\nb.txt.bad\n"), "lines")
+	require.NoError(err)
+	defer fs.Close()
+
+	assert.Equal("contents of a.txt", readTestFile(t, fs, "dir/a.txt"))
+	info, err := fs.Stat("dir/a.txt")
+	require.NoError(err)
+	assert.True(modTime.Equal(info.ModTime()), info.ModTime())
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	assert.Equal("tents", serveTest(handler, "GET", "/dir/a.txt", "", "Range", "bytes=3-7").buf.String())
+
+	// The CRCs of the files are checked
+	f, err := fs.Open("dir/b.txt.bad")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	assert.Equal(errChecksum, err)
+
+	_, err = NewFromBytes([]byte("LINES?\n"), "other")
+	assert.Error(err)
+}
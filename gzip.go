@@ -0,0 +1,167 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithGzip compresses entries that are stored without compression in the
+// archive with gzip at the given compression level, for clients that
+// accept the gzip content-encoding. Only text-like content, such as
+// HTML, CSS, JavaScript, JSON and SVG, is compressed; other content is
+// usually compressed already. Levels that compress/gzip does not support
+// use gzip.DefaultCompression. Range requests are served without
+// compression. Entries are compressed while they are served, unless
+// they are kept by WithTranscodeCache.
+func WithGzip(level int) Option {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		level = gzip.DefaultCompression
+	}
+	return func(h *fileHandler) {
+		h.gzip = &gzipConfig{level: level}
+	}
+}
+
+type gzipConfig struct {
+	level   int
+	writers sync.Pool
+}
+
+// writer returns a gzip writer writing to w. It must be returned with
+// putWriter once it has been closed.
+func (c *gzipConfig) writer(w io.Writer) *gzip.Writer {
+	if gw, ok := c.writers.Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return gw
+	}
+	// The level was checked by WithGzip, so NewWriterLevel cannot fail
+	gw, _ := gzip.NewWriterLevel(w, c.level)
+	return gw
+}
+
+func (c *gzipConfig) putWriter(gw *gzip.Writer) {
+	gw.Reset(nil)
+	c.writers.Put(gw)
+}
+
+// variant returns the name of the gzip compressed variant of entries in
+// the transcode cache, which depends on the compression level.
+func (c *gzipConfig) variant() string {
+	return fmt.Sprintf("gz%d", c.level)
+}
+
+// compress writes the gzip compressed contents of fi to w.
+func (c *gzipConfig) compress(w io.Writer, fi *fileInfo) error {
+	reader, err := fi.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	gw := c.writer(w)
+	defer c.putWriter(gw)
+	if _, err := io.Copy(gw, reader); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+// gzipEligible reports whether fi is compressed with gzip for clients
+// that accept it.
+func (h *fileHandler) gzipEligible(header http.Header, fi *fileInfo, defaultMime *string) bool {
+	if fi.zipFile.Method != zip.Store || (h.phpPath != "" && checkForPhp(fi.name)) {
+		return false
+	}
+	return isTextLike(contentType(header, fi.Name(), defaultMime))
+}
+
+// useGzip reports whether fi should be served compressed with gzip.
+func (h *fileHandler) useGzip(w http.ResponseWriter, r *http.Request, fi *fileInfo, defaultMime *string) bool {
+	if h.gzip == nil || r.Header.Get("Range") != "" {
+		return false
+	}
+	if !parseAcceptEncoding(r).accepts("gzip") {
+		return false
+	}
+	return h.gzipEligible(w.Header(), fi, defaultMime)
+}
+
+// isTextLike reports whether content of type ctype is worth compressing.
+func isTextLike(ctype string) bool {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/json",
+		"application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipEtag returns the ETag of the gzip compressed variant of an entry
+// with the given ETag.
+func gzipEtag(etag string) string {
+	return etag[:len(etag)-1] + `-gz"`
+}
+
+// serveGzip serves the gzip compressed contents of fi. The Etag and
+// Content-Type headers must already have been set.
+func (h *fileHandler) serveGzip(w http.ResponseWriter, r *http.Request, fi *fileInfo) {
+	w.Header().Set("Content-Encoding", "gzip")
+	if h.serveTranscoded(w, r, fi, h.gzip.variant(), time.Time{}, func(dst io.Writer) error {
+		return h.gzip.compress(dst, fi)
+	}) {
+		return
+	}
+	w.Header().Del("Content-Encoding")
+
+	reader, err := fi.open()
+	if err != nil {
+		recordError(r, fi.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return
+	}
+	defer reader.Close()
+
+	// The compressed size is not known until the entry has been
+	// compressed, so the response is sent chunked.
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	if r.Method == "HEAD" {
+		return
+	}
+
+	sw, finish := h.streamWriter(w)
+	defer finish()
+	gw := h.gzip.writer(sw)
+	defer h.gzip.putWriter(gw)
+	if _, err := io.Copy(gw, reader); err != nil {
+		// Part of the response may have been sent, so all that can be
+		// done is to record the error, or abort the response so that
+		// it is not mistaken for the whole entry.
+		if h.verifyCRC && isCorrupt(err) {
+			h.abortCorrupt(r, fi, err)
+		}
+		recordError(r, fi.name, err)
+		gw.Close()
+		return
+	}
+	gw.Close()
+	h.logf("[Zipfs] Serving Gzip Compressed File: %s\n", fi.zipFile.Name)
+}
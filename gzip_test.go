@@ -0,0 +1,86 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("squeeze me ", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"page.html", "data.json", "photo.png"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+	}
+	fw, err := zw.Create("deflated.html")
+	require.NoError(err)
+	_, err = fw.Write([]byte(content))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithGzip(gzip.BestSpeed))
+
+	for _, name := range []string{"page.html", "data.json"} {
+		w := serveTest(handler, "GET", "/"+name, "", "Accept-Encoding", "gzip, br")
+		require.Equal(200, w.status, name)
+		assert.Equal("gzip", w.Header().Get("Content-Encoding"), name)
+		assert.Equal("Accept-Encoding", w.Header().Get("Vary"), name)
+		assert.True(strings.HasSuffix(w.Header().Get("Etag"), `-gz"`), name)
+		assert.True(w.buf.Len() < len(content), name)
+		gr, err := gzip.NewReader(bytes.NewReader(w.buf.Bytes()))
+		require.NoError(err, name)
+		data, err := io.ReadAll(gr)
+		require.NoError(err, name)
+		assert.Equal(content, string(data), name)
+	}
+
+	// Images and deflated entries are left alone
+	w := serveTest(handler, "GET", "/photo.png", "", "Accept-Encoding", "gzip")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("", w.Header().Get("Vary"))
+	assert.Equal(content, w.buf.String())
+	w = serveTest(handler, "GET", "/deflated.html", "", "Accept-Encoding", "gzip")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(content, w.buf.String())
+
+	// Other clients and range requests are served without compression
+	w = serveTest(handler, "GET", "/page.html", "")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(content, w.buf.String())
+	w = serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "br, gzip;q=0")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(content, w.buf.String())
+	w = serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "gzip", "Range", "bytes=0-5")
+	assert.Equal(206, w.status)
+	assert.Equal(content[:6], w.buf.String())
+}
+
+func TestIsTextLike(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isTextLike("text/html; charset=utf-8"))
+	assert.True(isTextLike("application/javascript"))
+	assert.True(isTextLike("application/ld+json"))
+	assert.True(isTextLike("image/svg+xml"))
+	assert.False(isTextLike("image/png"))
+	assert.False(isTextLike("application/octet-stream"))
+	assert.False(isTextLike(""))
+}
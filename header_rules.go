@@ -0,0 +1,164 @@
+package zipfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// headersFileName is the name of the header rules file that may be
+// placed in the root of an archive.
+const headersFileName = "_headers"
+
+// HeaderRule attaches extra response headers to the paths that match
+// its pattern. Patterns are absolute '/'-separated paths in the style of
+// Netlify: a ":name" segment matches any single segment, and a trailing
+// "*" matches everything below the path before it, including the path
+// itself. Other segments use the syntax of path.Match. Like file
+// lookups, matching ignores case.
+type HeaderRule struct {
+	Pattern string
+	Headers http.Header
+}
+
+// WithHeaderRules adds headers to the responses for the entries whose
+// URL paths match the rules. Every matching rule applies, in order.
+func WithHeaderRules(rules ...HeaderRule) Option {
+	return func(h *fileHandler) {
+		h.headerRules = append(h.headerRules, rules...)
+	}
+}
+
+// WithHeadersFile reads header rules from a _headers file in the root
+// of every mounted archive, and applies them to that archive's entries.
+// Patterns in the file are relative to the root of the archive, even if
+// it is mounted under a URL prefix. The file looks like this:
+//
+//	# Comments start with a hash
+//	/assets/*
+//	  Cache-Control: max-age=31536000, immutable
+//	/embed/:page
+//	  Content-Security-Policy: frame-ancestors *
+//
+// Like per-directory configuration files, the file cannot set headers
+// that the file server manages itself, and it is never served.
+func WithHeadersFile() Option {
+	return func(h *fileHandler) {
+		h.headersFile = true
+	}
+}
+
+// applyHeaderRules adds the headers of the rules that match an entry of
+// fs, which is served at the URL path name and found at fsName inside
+// the archive.
+func (h *fileHandler) applyHeaderRules(w http.ResponseWriter, fs *FileSystem, name string, fsName string) {
+	for _, rule := range h.headerRules {
+		rule.apply(w, name)
+	}
+	if h.headersFile {
+		for _, rule := range fs.loadHeaderRules(h.logError) {
+			rule.apply(w, fsName)
+		}
+	}
+}
+
+func (rule HeaderRule) apply(w http.ResponseWriter, name string) {
+	if !matchSegments(splitURLPattern(strings.ToLower(rule.Pattern)), splitSegments(lookupName(name))) {
+		return
+	}
+	for key, values := range rule.Headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+}
+
+// splitURLPattern splits a Netlify style pattern into segments for
+// matchSegments.
+func splitURLPattern(pattern string) []string {
+	segments := splitSegments(pattern)
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "*"
+		case segment == "*" && i == len(segments)-1:
+			segments[i] = "**"
+		}
+	}
+	return segments
+}
+
+// isHeadersFile reports whether the request path refers to the header
+// rules file of an archive.
+func isHeadersFile(name string) bool {
+	return strings.ToLower(strings.Trim(name, "/")) == headersFileName
+}
+
+// loadHeaderRules returns the rules in the _headers file of fs. Results
+// are cached because the archive contents cannot change, so a file that
+// cannot be read is only reported to logError the first time.
+func (fs *FileSystem) loadHeaderRules(logError func(op string, err error)) []HeaderRule {
+	fs.headerRulesOnce.Do(func() {
+		rules, err := fs.readHeaderRules()
+		if err != nil {
+			logError("HeaderRules", fmt.Errorf("%s: %w", headersFileName, err))
+		}
+		fs.headerRules = rules
+	})
+	return fs.headerRules
+}
+
+func (fs *FileSystem) readHeaderRules() ([]HeaderRule, error) {
+	fi := fs.fileInfos[headersFileName]
+	if fi == nil || fi.IsDir() {
+		return nil, nil
+	}
+
+	reader, err := fi.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxDirConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDirConfigSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxDirConfigSize)
+	}
+	return parseHeaderRules(data)
+}
+
+// parseHeaderRules parses the contents of a _headers file.
+func parseHeaderRules(data []byte) ([]HeaderRule, error) {
+	var rules []HeaderRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			rules = append(rules, HeaderRule{Pattern: line, Headers: http.Header{}})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected a path or a header", lineNum)
+		}
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("line %d: header before the first path", lineNum)
+		}
+		if dirConfigHeaderDenyList[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		rules[len(rules)-1].Headers.Add(key, strings.TrimSpace(value))
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package zipfs
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderRules(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"_headers", `# Long lived assets
+/assets/*
+  Cache-Control: max-age=31536000, immutable
+  Content-Length: 1
+
+/embed/:page
+  Content-Security-Policy: frame-ancestors *
+  X-Robots-Tag: noindex
+/*
+  X-Robots-Tag: nofollow
+`,
+		"assets/app.js", "app",
+		"embed/game.html", "game",
+		"embed/deep/page.html", "page",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithHeadersFile(),
+		WithHeaderRules(HeaderRule{Pattern: "/assets/*.js", Headers: http.Header{"Access-Control-Allow-Origin": {"*"}}}))
+
+	w := serveTest(handler, "GET", "/assets/app.js", "")
+	assert.Equal(200, w.status)
+	assert.Equal("max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	assert.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal("3", w.Header().Get("Content-Length"))
+	assert.Equal([]string{"nofollow"}, w.Header()["X-Robots-Tag"])
+
+	w = serveTest(handler, "GET", "/Embed/Game.html", "")
+	assert.Equal("frame-ancestors *", w.Header().Get("Content-Security-Policy"))
+	assert.Equal([]string{"noindex", "nofollow"}, w.Header()["X-Robots-Tag"])
+
+	w = serveTest(handler, "GET", "/embed/deep/page.html", "")
+	assert.Empty(w.Header().Get("Content-Security-Policy"))
+	assert.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+
+	assert.Equal(404, serveTest(handler, "GET", "/_headers", "").status)
+}
+
+func TestHeaderRulesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"_headers", "X-Robots-Tag: noindex\n",
+		"a.txt", "a",
+	)
+	defer fs.Close()
+	var logged bytes.Buffer
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithHeadersFile(), WithLogger(log.New(&logged, "", 0)))
+
+	for i := 0; i < 2; i++ {
+		w := serveTest(handler, "GET", "/a.txt", "")
+		assert.Equal(200, w.status)
+		assert.Empty(w.Header().Get("X-Robots-Tag"))
+	}
+	// The file is only reported once
+	assert.Equal("Error (HeaderRules): _headers: line 1: header before the first path\n", logged.String())
+}
+
+func TestParseHeaderRules(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rules, err := parseHeaderRules([]byte("/a\n  X-A: 1\n  X-A: 2\n/b\n"))
+	require.NoError(err)
+	require.Len(rules, 2)
+	assert.Equal("/a", rules[0].Pattern)
+	assert.Equal([]string{"1", "2"}, rules[0].Headers["X-A"])
+	assert.Empty(rules[1].Headers)
+
+	_, err = parseHeaderRules([]byte("X-A: 1\n/a\n"))
+	assert.Error(err)
+	_, err = parseHeaderRules([]byte("/a\nnot a header\n"))
+	assert.Error(err)
+}
@@ -0,0 +1,57 @@
+package zipfs
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// WithHiddenPatterns refuses to serve entries whose path matches any of
+// patterns, responding as if they did not exist. Patterns use the syntax
+// of path.Match. A pattern without a slash, such as ".*" or "Thumbs.db",
+// is matched against every segment of the path, while a pattern with
+// slashes, such as "__MACOSX/*", is matched against every run of that
+// many consecutive segments. Like file lookups, matching ignores case.
+// Hidden entries are also left out of directory listings. Malformed
+// patterns never match.
+func WithHiddenPatterns(patterns ...string) Option {
+	return func(h *fileHandler) {
+		for _, pattern := range patterns {
+			h.hidden = append(h.hidden, strings.ToLower(pattern))
+		}
+	}
+}
+
+// isHidden reports whether the '/'-separated path name matches any of
+// the hidden patterns.
+func (h *fileHandler) isHidden(name string) bool {
+	if len(h.hidden) == 0 {
+		return false
+	}
+	segments := splitSegments(lookupName(name))
+	for _, pattern := range h.hidden {
+		n := strings.Count(pattern, "/") + 1
+		for i := 0; i+n <= len(segments); i++ {
+			if ok, _ := path.Match(pattern, strings.Join(segments[i:i+n], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitSegments splits the '/'-separated path name into its non-empty
+// segments.
+func splitSegments(name string) []string {
+	return strings.FieldsFunc(name, func(c rune) bool { return c == '/' })
+}
+
+// lookupName returns name the way file lookups see it, so that patterns
+// cannot be bypassed by changing the case of a path or escaping it.
+func lookupName(name string) string {
+	name = strings.ToLower(name)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return name
+}
@@ -0,0 +1,49 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHiddenPatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		".zipfsrc", `{"autoIndex": true}`,
+		"index.txt", "index",
+		".git/config", "secret",
+		"docs/.env", "secret",
+		"docs/Thumbs.db", "thumbs",
+		"docs/readme.txt", "readme",
+		"__MACOSX/docs/._readme.txt", "resource fork",
+		"sub/__MACOSX/file", "resource fork",
+		"not__MACOSX/file", "visible",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithDirConfig(), WithHiddenPatterns(".*", "__MACOSX/*", "Thumbs.db", "[bad"))
+
+	for _, target := range []string{
+		"/.git/config",
+		"/.git/",
+		"/docs/.env",
+		"/docs/Thumbs.db",
+		"/__MACOSX/docs/._readme.txt",
+		"/sub/__MACOSX/file",
+		"/.GIT/config",
+		"/docs/thumbs.DB",
+		"/%252egit/config",
+	} {
+		assert.Equal(404, serveTest(handler, "GET", target, "").status, target)
+	}
+	for _, target := range []string{"/index.txt", "/docs/readme.txt", "/not__MACOSX/file"} {
+		assert.Equal(200, serveTest(handler, "GET", target, "").status, target)
+	}
+
+	w := serveTest(handler, "GET", "/docs/", "")
+	assert.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "readme.txt")
+	assert.NotContains(w.buf.String(), ".env")
+	assert.NotContains(w.buf.String(), "Thumbs.db")
+}
@@ -0,0 +1,195 @@
+package zipfs
+
+import (
+	"container/heap"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// WithHotFileTracking counts how often each entry of each mounted
+// archive is served, and reports the most requested entries through the
+// hotfiles API endpoint. At most capacity entries are tracked per
+// archive, using the Space-Saving algorithm, so memory use stays bounded
+// no matter how many distinct entries are requested. Counts of entries
+// that were tracked after another entry was evicted may be overestimated
+// by at most the reported error.
+func WithHotFileTracking(capacity int) Option {
+	return func(h *fileHandler) {
+		h.hotFiles = &hotFileTracker{
+			capacity: capacity,
+			counters: map[*FileSystem]*topK{},
+		}
+	}
+}
+
+// HotFile is an entry of the hot file report.
+type HotFile struct {
+	Path  string `json:"path"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"` // Upper bound of the overestimation of Count
+}
+
+// HotFileReport lists the most requested entries of a mounted archive.
+type HotFileReport struct {
+	Zip   string    `json:"zip"`
+	Files []HotFile `json:"files"`
+}
+
+type hotFileTracker struct {
+	capacity int
+	mutex    sync.Mutex
+	counters map[*FileSystem]*topK
+}
+
+func (t *hotFileTracker) record(fs *FileSystem, name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	counter := t.counters[fs]
+	if counter == nil {
+		counter = newTopK(t.capacity)
+		t.counters[fs] = counter
+	}
+	counter.add(name)
+}
+
+// forget drops the counters of an archive that is no longer mounted.
+func (t *hotFileTracker) forget(fs *FileSystem) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.counters, fs)
+}
+
+func (t *hotFileTracker) report(fs *FileSystem, limit int) HotFileReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	report := HotFileReport{Zip: fs.givenPath, Files: []HotFile{}}
+	if counter := t.counters[fs]; counter != nil {
+		report.Files = counter.top(limit)
+	}
+	return report
+}
+
+// topK implements the Space-Saving algorithm for finding the most
+// frequent items of a stream with bounded memory. The items are kept in
+// a min-heap by count, so that the item to replace is found at once.
+type topK struct {
+	capacity int
+	items    map[string]*topKItem
+	heap     []*topKItem
+}
+
+type topKItem struct {
+	HotFile
+	index int // Position in the heap
+}
+
+func newTopK(capacity int) *topK {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &topK{
+		capacity: capacity,
+		items:    map[string]*topKItem{},
+	}
+}
+
+func (k *topK) add(name string) {
+	if item := k.items[name]; item != nil {
+		item.Count++
+		heap.Fix(k, item.index)
+		return
+	}
+	if len(k.items) < k.capacity {
+		item := &topKItem{HotFile: HotFile{Path: name, Count: 1}}
+		k.items[name] = item
+		heap.Push(k, item)
+		return
+	}
+
+	// Replace the item with the lowest count. The new item inherits
+	// its count, which becomes the error bound.
+	min := k.heap[0]
+	delete(k.items, min.Path)
+	min.HotFile = HotFile{Path: name, Count: min.Count + 1, Error: min.Count}
+	k.items[name] = min
+	heap.Fix(k, 0)
+}
+
+func (k *topK) Len() int           { return len(k.heap) }
+func (k *topK) Less(i, j int) bool { return k.heap[i].Count < k.heap[j].Count }
+
+func (k *topK) Swap(i, j int) {
+	k.heap[i], k.heap[j] = k.heap[j], k.heap[i]
+	k.heap[i].index = i
+	k.heap[j].index = j
+}
+
+func (k *topK) Push(x any) {
+	item := x.(*topKItem)
+	item.index = len(k.heap)
+	k.heap = append(k.heap, item)
+}
+
+// Pop is only there for heap.Interface, as items are never removed.
+func (k *topK) Pop() any {
+	item := k.heap[len(k.heap)-1]
+	k.heap = k.heap[:len(k.heap)-1]
+	return item
+}
+
+// top returns up to limit items, most frequent first.
+// A limit of zero or less returns all items.
+func (k *topK) top(limit int) []HotFile {
+	files := make([]HotFile, 0, len(k.items))
+	for _, item := range k.items {
+		files = append(files, item.HotFile)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Count != files[j].Count {
+			return files[i].Count > files[j].Count
+		}
+		return files[i].Path < files[j].Path
+	})
+	if limit > 0 && len(files) > limit {
+		files = files[:limit]
+	}
+	return files
+}
+
+// List the most requested entries of the mounted archives.
+func (h *fileHandler) HotFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		h.logErrorf("HotFiles", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+	if h.hotFiles == nil {
+		http.Error(w, "Hot file tracking is not enabled.", http.StatusNotFound)
+		return
+	}
+
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "Invalid limit.", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	zip := r.URL.Query().Get("zip")
+
+	reports := []HotFileReport{}
+	for _, fse := range h.mounted() {
+		if zip != "" && fse.givenPath != zip {
+			continue
+		}
+		reports = append(reports, h.hotFiles.report(fse, limit))
+	}
+
+	makeJsonResponse(w, reports, http.StatusOK)
+}
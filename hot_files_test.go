@@ -0,0 +1,65 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopK(t *testing.T) {
+	assert := assert.New(t)
+
+	k := newTopK(2)
+	for _, name := range []string{"a", "a", "a", "b", "b", "c"} {
+		k.add(name)
+	}
+	assert.Equal([]HotFile{
+		{Path: "a", Count: 3},
+		{Path: "c", Count: 3, Error: 2},
+	}, k.top(0))
+	assert.Equal([]HotFile{{Path: "a", Count: 3}}, k.top(1))
+
+	// The item with the lowest count is replaced as counts change
+	k = newTopK(3)
+	for _, name := range []string{"a", "b", "c", "c", "c", "b", "d", "d", "e"} {
+		k.add(name)
+	}
+	assert.Equal([]HotFile{
+		{Path: "c", Count: 3},
+		{Path: "d", Count: 3, Error: 1},
+		{Path: "e", Count: 3, Error: 2},
+	}, k.top(0))
+}
+
+func TestHotFiles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"a.txt", "a",
+		"b.txt", "b",
+	)
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithHotFileTracking(10))
+
+	serveTest(handler, "GET", "/a.txt", "")
+	serveTest(handler, "GET", "/b.txt", "")
+	serveTest(handler, "GET", "/A.txt", "")
+	serveTest(handler, "GET", "/missing.txt", "")
+
+	w := serveTest(handler, "GET", "/api/hotfiles?limit=1", "")
+	assert.Equal(200, w.status)
+	var reports []HotFileReport
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &reports))
+	assert.Equal([]HotFileReport{{
+		Zip:   "test.zip",
+		Files: []HotFile{{Path: "a.txt", Count: 2}},
+	}}, reports)
+
+	w = serveTest(handler, "GET", "/api/hotfiles?zip=other.zip", "")
+	assert.Equal("[]\n", w.buf.String())
+
+	handler = FileServer(fs, "api/", "", false, nil, nil)
+	assert.Equal(404, serveTest(handler, "GET", "/api/hotfiles", "").status)
+}
@@ -0,0 +1,23 @@
+package zipfs
+
+import "net/http"
+
+// HTTPFileSystem returns fs as a http.FileSystem, for use with
+// http.FileServer and anything else that expects one. Unlike
+// FileSystem.Open, names start with a slash as in URL paths, and
+// may be percent-encoded.
+func HTTPFileSystem(fs *FileSystem) http.FileSystem {
+	return httpFileSystem{fs: fs}
+}
+
+type httpFileSystem struct {
+	fs *FileSystem
+}
+
+func (h httpFileSystem) Open(name string) (http.File, error) {
+	fi, err := h.fs.openFileInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	return fi.openReader(name), nil
+}
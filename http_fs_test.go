@@ -0,0 +1,55 @@
+package zipfs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFileSystem(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "docs/", "", "docs/Guide.txt", "read me")
+	defer fs.Close()
+	handler := http.FileServer(HTTPFileSystem(fs))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get("/docs/guide.txt")
+	require.Equal(200, w.Code)
+	assert.Equal("read me", w.Body.String())
+
+	w = get("/docs/")
+	require.Equal(200, w.Code)
+	assert.Contains(w.Body.String(), `<a href="guide.txt">guide.txt</a>`)
+
+	assert.Equal(404, get("/missing.txt").Code)
+}
+
+func TestOpenHTTP(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "docs/Guide.txt", "read me")
+	defer fs.Close()
+
+	var f http.File
+	f, err := fs.OpenHTTP("/docs/guide%2Etxt")
+	require.NoError(err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(err)
+	assert.Equal("read me", string(data))
+
+	_, err = fs.OpenHTTP("/missing.txt")
+	assert.Error(err)
+}
@@ -0,0 +1,72 @@
+package zipfs
+
+import (
+	"io"
+	"os"
+)
+
+// NewInMemory reads the Zip file specified by name into memory and
+// returns a new FileSystem based on the copy, so that serving it does not
+// read from the disk again. It suits small and medium sized archives on
+// slow storage. Like New, it opens tar files, ISO 9660 images and
+// archives of registered formats as well.
+func NewInMemory(name string) (*FileSystem, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, fi.Size())
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	fs, err := NewFromBytes(data, name)
+	if err != nil {
+		return nil, err
+	}
+	fs.modTime = fi.ModTime()
+	fs.file = fi
+	fs.inMemory = true
+	return fs, nil
+}
+
+// WithInMemoryMounts reads the archives mounted through the mountZIP API
+// endpoint into memory with NewInMemory if they are no larger than
+// maxSize bytes, or whatever their size if maxSize is 0. Larger archives
+// are read from disk as usual. Damaged archives recovered by
+// WithArchiveRepair are read from disk as well.
+func WithInMemoryMounts(maxSize int64) Option {
+	return func(h *fileHandler) {
+		h.inMemory = true
+		h.inMemoryMax = maxSize
+	}
+}
+
+// openArchive opens the archive at zipPath for mounting, according to
+// the options of WithInMemoryMounts, WithMappedMounts and
+// WithArchiveRepair.
+func (h *fileHandler) openArchive(zipPath string) (*FileSystem, error) {
+	if h.inMemory {
+		info, err := os.Stat(zipPath)
+		if err == nil && (h.inMemoryMax <= 0 || info.Size() <= h.inMemoryMax) {
+			fs, err := NewInMemory(zipPath)
+			if err == nil || !h.repair {
+				return fs, err
+			}
+		}
+	}
+	if h.mmap {
+		fs, err := NewMapped(zipPath)
+		if err == nil || !h.repair {
+			return fs, err
+		}
+	}
+	if h.repair {
+		return NewRepaired(zipPath)
+	}
+	return New(zipPath)
+}
@@ -0,0 +1,61 @@
+package zipfs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInMemory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	zipPath := filepath.Join(t.TempDir(), "site.zip")
+	writeTestZip(t, zipPath, "index.html", "in memory")
+	fs, err := NewInMemory(zipPath)
+	require.NoError(err)
+	defer fs.Close()
+
+	// The archive is no longer read from disk
+	require.NoError(os.Remove(zipPath))
+	f, err := fs.Open("index.html")
+	require.NoError(err)
+	data, err := io.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal("in memory", string(data))
+	f.Close()
+
+	_, err = NewInMemory(zipPath)
+	assert.True(os.IsNotExist(err), err)
+}
+
+func TestInMemoryMounts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "small.zip"), "small.txt", "small")
+	large := make([]byte, 2000)
+	rand.Read(large)
+	writeTestZip(t, filepath.Join(dir, "large.zip"), "large.txt", string(large))
+	handler := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithInMemoryMounts(1000))
+
+	mountTestZip(t, handler, `{"filePath": "small.zip"}`)
+	mountTestZip(t, handler, `{"filePath": "large.zip"}`)
+	w := serveTest(handler, "GET", "/api/mountstatus", "")
+	require.Equal(200, w.status)
+	var status MountStatusResponseData
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &status))
+	require.Len(status.Mounts, 2)
+	assert.True(status.Mounts[0].InMemory)
+	assert.False(status.Mounts[1].InMemory)
+
+	require.NoError(os.Remove(filepath.Join(dir, "small.zip")))
+	assert.Equal("small", serveTest(handler, "GET", "/small.txt", "").buf.String())
+}
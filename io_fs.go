@@ -0,0 +1,80 @@
+package zipfs
+
+import (
+	iofs "io/fs"
+	"path"
+	"strings"
+)
+
+var (
+	_ iofs.FS          = (*FileSystem)(nil)
+	_ iofs.StatFS      = (*FileSystem)(nil)
+	_ iofs.ReadDirFS   = (*FileSystem)(nil)
+	_ iofs.GlobFS      = (*FileSystem)(nil)
+	_ iofs.ReadDirFile = (*fileReader)(nil)
+)
+
+// ReadDir implements the io/fs.ReadDirFS interface. The entries are
+// sorted by name, and their Info method returns a *FileInfo.
+func (fs *FileSystem) ReadDir(name string) ([]iofs.DirEntry, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: iofs.ErrInvalid}
+	}
+	fi, err := fs.current().openFileInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := fi.readdir()
+	if err != nil {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return dirEntries(infos), nil
+}
+
+// ReadDir implements the io/fs.ReadDirFile interface.
+func (f *fileReader) ReadDir(count int) ([]iofs.DirEntry, error) {
+	infos, err := f.Readdir(count)
+	return dirEntries(infos), err
+}
+
+func dirEntries(infos []iofs.FileInfo) []iofs.DirEntry {
+	entries := make([]iofs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = iofs.FileInfoToDirEntry(info)
+	}
+	return entries
+}
+
+// Walk walks the tree of entries rooted at root like io/fs.WalkDir,
+// calling fn for each directory and file in lexical order. The entries
+// of Zip files stored in the archive are not walked.
+func (fs *FileSystem) Walk(root string, fn iofs.WalkDirFunc) error {
+	return iofs.WalkDir(fs, root, fn)
+}
+
+// Glob implements the io/fs.GlobFS interface. It returns the names of
+// all entries matching pattern, with the syntax of path.Match, in
+// lexical order. Like names everywhere else, patterns are matched
+// case-insensitively, and the names are returned in lower case.
+func (fs *FileSystem) Glob(pattern string) ([]string, error) {
+	pattern = strings.ToLower(pattern)
+	// Check the pattern even if nothing is matched against it
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	err := fs.Walk(".", func(name string, d iofs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+		// Patterns only match names with as many elements as they have
+		if d.IsDir() && strings.Count(name, "/") >= strings.Count(pattern, "/") {
+			return iofs.SkipDir
+		}
+		return nil
+	})
+	return matches, err
+}
@@ -0,0 +1,91 @@
+package zipfs
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	iofs "io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOFS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", `{{define "index"}}<h1>{{.}}</h1>{{end}}`,
+		"docs/", "",
+		"docs/a.txt", "a",
+		"docs/b.txt", "b",
+	)
+	defer fs.Close()
+
+	require.NoError(fstest.TestFS(fs, "index.html", "docs/a.txt", "docs/b.txt"))
+
+	var walked []string
+	err := iofs.WalkDir(fs, ".", func(name string, d iofs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	require.NoError(err)
+	assert.Equal([]string{".", "docs", "docs/a.txt", "docs/b.txt", "index.html"}, walked)
+
+	entries, err := iofs.ReadDir(fs, "docs")
+	require.NoError(err)
+	require.Len(entries, 2)
+	info, err := entries[0].Info()
+	require.NoError(err)
+	assert.Equal(int64(1), info.(*FileInfo).UncompressedSize)
+
+	tmpl, err := template.ParseFS(fs, "*.html")
+	require.NoError(err)
+	var buf bytes.Buffer
+	require.NoError(tmpl.ExecuteTemplate(&buf, "index", "hi"))
+	assert.Equal("<h1>hi</h1>", buf.String())
+
+	_, err = fs.Open("../index.html")
+	assert.True(errors.Is(err, iofs.ErrInvalid), err)
+	_, err = fs.Open("missing.txt")
+	assert.True(errors.Is(err, iofs.ErrNotExist), err)
+}
+
+func TestWalkAndGlob(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", "index",
+		"img/Logo.PNG", "logo",
+		"img/icons/a.png", "a",
+		"docs/b.txt", "b",
+	)
+	defer fs.Close()
+
+	var walked []string
+	require.NoError(fs.Walk("img", func(name string, d iofs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	}))
+	assert.Equal([]string{"img", "img/icons", "img/icons/a.png", "img/logo.png"}, walked)
+
+	glob := func(pattern string) []string {
+		matches, err := fs.Glob(pattern)
+		assert.NoError(err, pattern)
+		return matches
+	}
+	assert.Equal([]string{"img/icons/a.png", "img/logo.png"}, append(glob("img/*/*.png"), glob("IMG/*.png")...))
+	assert.Equal([]string{"docs", "img", "index.html"}, glob("*"))
+	assert.Nil(glob("*/*/*/*"))
+	_, err := fs.Glob("[")
+	assert.ErrorIs(err, path.ErrBadPattern)
+
+	// io/fs.Glob uses the method
+	matches, err := iofs.Glob(fs, "*/*.txt")
+	require.NoError(err)
+	assert.Equal([]string{"docs/b.txt"}, matches)
+}
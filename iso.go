@@ -0,0 +1,208 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const isoSectorSize = 2048
+
+var errISOFormat = errors.New("zip: invalid ISO 9660 image")
+
+// isISO reports whether the file in r is an ISO 9660 image, whose first
+// volume descriptor follows a system area of 16 sectors.
+func isISO(r io.ReaderAt) bool {
+	magic := make([]byte, 5)
+	_, err := r.ReadAt(magic, 16*isoSectorSize+1)
+	return err == nil && string(magic) == "CD001"
+}
+
+// isoRecord is a directory record of an ISO 9660 image.
+type isoRecord struct {
+	name    string
+	extents []int64 // Offsets, more than one for files of several extents
+	sizes   []int64
+	modTime time.Time
+	dir     bool
+}
+
+// newFromISO returns a file system of the ISO 9660 image in readerAt.
+// The files of an image are stored without compression, so a Zip file
+// storing them is made up like for tar files, which reads their data
+// from the image in place. Images have no checksums, so the CRCs of the
+// made up entries are left zero and are not checked. The long names of
+// the Joliet extension are used if the image has them.
+func newFromISO(readerAt io.ReaderAt, size int64, closer io.Closer, filePath string) (*FileSystem, error) {
+	root, blockSize, joliet, err := isoRoot(readerAt)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &madeUpZip{}
+	visited := map[int64]bool{}
+	var walk func(dir isoRecord, prefix string) error
+	walk = func(dir isoRecord, prefix string) error {
+		if visited[dir.extents[0]] {
+			return nil
+		}
+		visited[dir.extents[0]] = true
+		records, err := readISODir(readerAt, dir, blockSize, joliet)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			name := prefix + rec.name
+			if rec.dir {
+				z.add(name+"/", rec.modTime, zip.Store, 0, 0)
+				if err := walk(rec, name+"/"); err != nil {
+					return err
+				}
+				continue
+			}
+			var total int64
+			for _, s := range rec.sizes {
+				total += s
+			}
+			z.add(name, rec.modTime, zip.Store, 0, total)
+			for i, extent := range rec.extents {
+				z.data.append(nil, readerAt, extent, rec.sizes[i])
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+
+	zipReaderAt, zipSize, err := z.finish()
+	if err != nil {
+		return nil, err
+	}
+	fs, err := NewFromReaderAt(zipReaderAt, zipSize, closer, filePath)
+	if err != nil {
+		return nil, err
+	}
+	fs.size = size
+	fs.madeUp = true
+	return fs, nil
+}
+
+// isoRoot returns the root directory record of the image in r, from its
+// Joliet volume descriptor if it has one or its primary one otherwise.
+func isoRoot(r io.ReaderAt) (root isoRecord, blockSize int64, joliet bool, err error) {
+	desc := make([]byte, isoSectorSize)
+	found := false
+	for sector := int64(16); ; sector++ {
+		if _, err := r.ReadAt(desc, sector*isoSectorSize); err != nil {
+			return root, 0, false, errISOFormat
+		}
+		if string(desc[1:6]) != "CD001" || desc[0] == 255 {
+			break
+		}
+		escapes := string(desc[88:91])
+		isJoliet := desc[0] == 2 && (escapes == "%/@" || escapes == "%/C" || escapes == "%/E")
+		if desc[0] != 1 && !isJoliet || found && !isJoliet {
+			continue
+		}
+		blockSize = int64(binary.LittleEndian.Uint16(desc[128:]))
+		if blockSize == 0 {
+			return root, 0, false, errISOFormat
+		}
+		rec, ok := parseISORecord(desc[156:190], blockSize, false)
+		if !ok {
+			return root, 0, false, errISOFormat
+		}
+		root, joliet, found = rec, isJoliet, true
+	}
+	if !found {
+		return root, 0, false, errISOFormat
+	}
+	return root, blockSize, joliet, nil
+}
+
+// readISODir returns the records of the files and directories in dir,
+// leaving out its own and its parent's, as well as associated files.
+// The extents of files stored in several of them are joined.
+func readISODir(r io.ReaderAt, dir isoRecord, blockSize int64, joliet bool) ([]isoRecord, error) {
+	data := make([]byte, dir.sizes[0])
+	if _, err := r.ReadAt(data, dir.extents[0]); err != nil {
+		return nil, err
+	}
+	var records []isoRecord
+	continued := false
+	for pos := 0; pos < len(data); {
+		length := int(data[pos])
+		if length == 0 {
+			// Records do not cross sectors, the rest is padding
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		if pos+length > len(data) {
+			return nil, errISOFormat
+		}
+		rec, ok := parseISORecord(data[pos:pos+length], blockSize, joliet)
+		flags := data[pos+25]
+		self := length > 33 && data[pos+32] == 1 && data[pos+33] <= 1
+		pos += length
+		if !ok || self || flags&0x04 != 0 || rec.name == "" || strings.Contains(rec.name, "/") {
+			continue
+		}
+		if continued && len(records) > 0 && records[len(records)-1].name == rec.name {
+			last := &records[len(records)-1]
+			last.extents = append(last.extents, rec.extents...)
+			last.sizes = append(last.sizes, rec.sizes...)
+		} else {
+			records = append(records, rec)
+		}
+		continued = flags&0x80 != 0
+	}
+	return records, nil
+}
+
+// parseISORecord parses the directory record in b.
+func parseISORecord(b []byte, blockSize int64, joliet bool) (isoRecord, bool) {
+	if len(b) < 34 || int(b[32]) > len(b)-33 {
+		return isoRecord{}, false
+	}
+	le := binary.LittleEndian
+	return isoRecord{
+		name:    isoName(b[33:33+int(b[32])], joliet),
+		extents: []int64{int64(le.Uint32(b[2:])) * blockSize},
+		sizes:   []int64{int64(le.Uint32(b[10:]))},
+		modTime: isoTime(b[18:25]),
+		dir:     b[25]&0x02 != 0,
+	}, true
+}
+
+// isoName returns the name of a directory record, which is in UCS-2 in
+// Joliet records. The version of file names, as in ";1", is left out,
+// as is the dot of names without an extension.
+func isoName(raw []byte, joliet bool) string {
+	name := string(raw)
+	if joliet {
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(raw[2*i:])
+		}
+		name = string(utf16.Decode(units))
+	}
+	if i := strings.LastIndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// isoTime returns the time of a directory record, whose offset from UTC
+// is given in 15 minute intervals.
+func isoTime(b []byte) time.Time {
+	if b[1] == 0 || b[2] == 0 {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, zone)
+}
@@ -0,0 +1,147 @@
+package zipfs
+
+import (
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestISO returns an ISO 9660 image from alternating name and
+// content arguments, whose names are at most one directory deep. With
+// joliet, the names are only in a Joliet directory tree and the primary
+// one is empty. Files larger than a sector are stored in two extents.
+func writeTestISO(modTime time.Time, joliet bool, files ...string) []byte {
+	le, be := binary.LittleEndian, binary.BigEndian
+	both32 := func(b []byte, v int) []byte {
+		return be.AppendUint32(le.AppendUint32(b, uint32(v)), uint32(v))
+	}
+	record := func(name []byte, sector int, size int, flags byte) []byte {
+		r := []byte{0, 0}
+		r = both32(r, sector)
+		r = both32(r, size)
+		r = append(r, byte(modTime.Year()-1900), byte(modTime.Month()), byte(modTime.Day()),
+			byte(modTime.Hour()), byte(modTime.Minute()), byte(modTime.Second()), 0, flags, 0, 0)
+		r = append(r, 1, 0, 0, 1, byte(len(name)))
+		r = append(r, name...)
+		if len(r)%2 != 0 {
+			r = append(r, 0)
+		}
+		r[0] = byte(len(r))
+		return r
+	}
+	encode := func(name string) []byte {
+		if !joliet {
+			return []byte(strings.ToUpper(name))
+		}
+		var b []byte
+		for _, c := range utf16.Encode([]rune(name)) {
+			b = be.AppendUint16(b, c)
+		}
+		return b
+	}
+
+	// Sectors 16 to 18 hold the volume descriptors, 19 an empty
+	// directory, followed by the directories and then the files
+	var dirs []string
+	dirRecords := map[string][]byte{}
+	for i := 0; i < len(files); i += 2 {
+		if dir, _, ok := strings.Cut(files[i], "/"); ok && dirRecords[dir] == nil {
+			dirs = append(dirs, dir)
+			dirRecords[dir] = []byte{}
+		}
+	}
+	dirSector := func(dir string) int {
+		for i, d := range dirs {
+			if d == dir {
+				return 21 + i
+			}
+		}
+		return 20
+	}
+	image := make([]byte, (21+len(dirs))*isoSectorSize)
+	for i := 0; i < len(files); i += 2 {
+		dir, name, ok := strings.Cut(files[i], "/")
+		if !ok {
+			dir, name = "", files[i]
+		}
+		sector, data := len(image)/isoSectorSize, files[i+1]
+		image = append(image, data...)
+		image = append(image, make([]byte, (isoSectorSize-len(data)%isoSectorSize)%isoSectorSize)...)
+		if len(data) > isoSectorSize {
+			dirRecords[dir] = append(dirRecords[dir], record(encode(name+";1"), sector, isoSectorSize, 0x80)...)
+			sector, data = sector+1, data[isoSectorSize:]
+		}
+		dirRecords[dir] = append(dirRecords[dir], record(encode(name+";1"), sector, len(data), 0)...)
+	}
+	for _, dir := range dirs {
+		dirRecords[""] = append(dirRecords[""], record(encode(dir), dirSector(dir), isoSectorSize, 0x02)...)
+	}
+	for _, dir := range append([]string{""}, dirs...) {
+		data := append(record([]byte{0}, dirSector(dir), isoSectorSize, 0x02), record([]byte{1}, 20, isoSectorSize, 0x02)...)
+		copy(image[dirSector(dir)*isoSectorSize:], append(data, dirRecords[dir]...))
+	}
+	copy(image[19*isoSectorSize:], append(record([]byte{0}, 19, isoSectorSize, 0x02), record([]byte{1}, 19, isoSectorSize, 0x02)...))
+
+	descriptor := func(sector int, kind byte, root int) {
+		d := image[sector*isoSectorSize:]
+		d[0] = kind
+		copy(d[1:], "CD001\x01")
+		if kind == 2 {
+			copy(d[88:], "%/E")
+		}
+		if kind != 255 {
+			copy(d[128:], be.AppendUint16(le.AppendUint16(nil, isoSectorSize), isoSectorSize))
+			copy(d[156:], record([]byte{0}, root, isoSectorSize, 0x02))
+		}
+	}
+	if joliet {
+		descriptor(16, 1, 19)
+		descriptor(17, 2, 20)
+	} else {
+		descriptor(16, 1, 20)
+		descriptor(17, 255, 0)
+	}
+	descriptor(18, 255, 0)
+	return image
+}
+
+func TestISO(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	modTime := time.Date(1999, 12, 31, 23, 58, 0, 0, time.UTC)
+	long := strings.Repeat("compact disc ", 400)
+	for _, joliet := range []bool{false, true} {
+		data := writeTestISO(modTime, joliet, "index.html", "index", "assets/long.txt", long, "assets/readme", "readme")
+		fs, err := NewFromBytes(data, "disc.iso")
+		require.NoError(err)
+		defer fs.Close()
+
+		// Primary names are upper case, which does not matter as names
+		// are looked up case insensitively
+		assert.Equal("index", readTestFile(t, fs, "index.html"))
+		assert.Equal("readme", readTestFile(t, fs, "assets/readme"))
+		assert.Equal(long, readTestFile(t, fs, "assets/long.txt"))
+		info, err := fs.Stat("assets")
+		require.NoError(err)
+		assert.True(info.IsDir())
+		info, err = fs.Stat("assets/long.txt")
+		require.NoError(err)
+		assert.Equal(int64(len(long)), info.Size())
+		assert.True(modTime.Equal(info.ModTime()), info.ModTime())
+
+		handler := FileServer(fs, "api/", "", false, nil, nil)
+		w := serveTest(handler, "GET", "/assets/long.txt", "", "Range", "bytes=2045-2051")
+		assert.Equal(http.StatusPartialContent, w.status)
+		assert.Equal(long[2045:2052], w.buf.String())
+	}
+
+	_, err := NewFromBytes(writeTestISO(modTime, false)[:18*isoSectorSize], "truncated.iso")
+	assert.Error(err)
+}
@@ -0,0 +1,58 @@
+package zipfs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// WithLogger sends the diagnostic messages produced by the file server
+// to logger instead of standard output.
+func WithLogger(logger *log.Logger) Option {
+	return func(h *fileHandler) {
+		h.logger = logger
+	}
+}
+
+func (h *fileHandler) logf(format string, args ...interface{}) {
+	if h.slog != nil {
+		h.slog.Info(strings.TrimSpace(fmt.Sprintf(format, args...)))
+		return
+	}
+	if h.logger != nil {
+		h.logger.Printf(format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// logError logs err as the failure of op. Structured logs carry op in the
+// "op" field and err in the "error" field, other logs read
+// "Error (op): err".
+func (h *fileHandler) logError(op string, err error) {
+	h.logProblem(slog.LevelError, op, err)
+}
+
+func (h *fileHandler) logErrorf(op string, format string, args ...interface{}) {
+	h.logError(op, fmt.Errorf(format, args...))
+}
+
+// logWarningf logs a problem with op that did not make it fail, like
+// logError does at LevelWarn.
+func (h *fileHandler) logWarningf(op string, format string, args ...interface{}) {
+	h.logProblem(slog.LevelWarn, op, fmt.Errorf(format, args...))
+}
+
+func (h *fileHandler) logProblem(level slog.Level, op string, err error) {
+	if h.slog != nil {
+		h.slog.Log(context.Background(), level, op, "op", op, "error", err.Error())
+		return
+	}
+	prefix := "Error"
+	if level == slog.LevelWarn {
+		prefix = "Warning"
+	}
+	h.logf("%s (%s): %s\n", prefix, op, err)
+}
@@ -0,0 +1,37 @@
+package zipfs
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogger(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Nothing is written to standard output once a logger is given
+	stdout := os.Stdout
+	read, write, err := os.Pipe()
+	require.NoError(err)
+	os.Stdout = write
+	defer func() { os.Stdout = stdout }()
+
+	var buf bytes.Buffer
+	fs := newTestFileSystem(t, "test.txt", "hello")
+	h := FileServer(fs, "api/", "", true, nil, nil, WithLogger(log.New(&buf, "", 0)))
+	w := serveTest(h, "GET", "/test.txt", "")
+	assert.Equal("hello", w.buf.String())
+
+	os.Stdout = stdout
+	write.Close()
+	printed, err := io.ReadAll(read)
+	require.NoError(err)
+	assert.Empty(string(printed))
+	assert.Contains(buf.String(), "Serving Zipped File: test.txt")
+}
@@ -0,0 +1,161 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// madeUpZip makes up a Zip file listing the entries of an archive of
+// another format, so that it can be served like any other.
+type madeUpZip struct {
+	data    segmentReaderAt
+	entries []recoveredEntry
+}
+
+// add adds the local file header of an entry. Its data, if it has any,
+// must be appended to z.data next.
+func (z *madeUpZip) add(name string, modTime time.Time, method uint16, crc uint32, size int64) {
+	e := recoveredEntry{
+		offset:           z.data.size,
+		name:             []byte(name),
+		extra:            extendedTimestamp(modTime),
+		crc:              crc,
+		uncompressedSize: uint64(size),
+	}
+	if method == zip.Store {
+		e.compressedSize = uint64(size)
+	}
+	e.header = localFileHeader(e, modTime, method)
+	z.entries = append(z.entries, e)
+	z.data.append(append(append(e.header, e.name...), e.extra...), nil, 0, 0)
+}
+
+// finish adds the central directory and returns the Zip file.
+func (z *madeUpZip) finish() (io.ReaderAt, int64, error) {
+	if len(z.entries) == 0 {
+		return nil, 0, errNoEntries
+	}
+	z.data.append(centralDirectory(z.entries, z.data.size), nil, 0, 0)
+	return &z.data, z.data.size, nil
+}
+
+// localFileHeader returns the fixed size part of the local file header
+// of e.
+func localFileHeader(e recoveredEntry, modTime time.Time, method uint16) []byte {
+	le := binary.LittleEndian
+	date, tm := dosTime(modTime)
+	h := le.AppendUint32(nil, localHeaderSig)
+	h = le.AppendUint16(h, 20)    // Version needed
+	h = le.AppendUint16(h, 0x800) // UTF-8 names
+	h = le.AppendUint16(h, method)
+	h = le.AppendUint16(h, tm)
+	h = le.AppendUint16(h, date)
+	h = le.AppendUint32(h, e.crc)
+	h = le.AppendUint32(h, uint32(min(e.compressedSize, uint32Max)))
+	h = le.AppendUint32(h, uint32(min(e.uncompressedSize, uint32Max)))
+	h = le.AppendUint16(h, uint16(len(e.name)))
+	return le.AppendUint16(h, uint16(len(e.extra)))
+}
+
+// extendedTimestamp returns an extended timestamp extra field holding
+// the modification time t, which is more precise than the MS-DOS one.
+func extendedTimestamp(t time.Time) []byte {
+	le := binary.LittleEndian
+	field := le.AppendUint16(nil, 0x5455)
+	field = le.AppendUint16(field, 5)
+	field = append(field, 1) // Only the modification time
+	return le.AppendUint32(field, uint32(t.Unix()))
+}
+
+// dosTime returns t as an MS-DOS date and time, which cannot be before
+// 1980.
+func dosTime(t time.Time) (date uint16, tm uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, tm
+}
+
+// segmentReaderAt reads a file made up of segments that are either held
+// in memory or read from another file.
+type segmentReaderAt struct {
+	segments []segment
+	size     int64
+}
+
+type segment struct {
+	offset  int64 // In the made up file
+	size    int64
+	data    []byte
+	r       io.ReaderAt
+	rOffset int64
+}
+
+// append adds data, or size bytes of r at rOffset if data is nil, to
+// the end of the file.
+func (s *segmentReaderAt) append(data []byte, r io.ReaderAt, rOffset int64, size int64) {
+	if data != nil {
+		size = int64(len(data))
+	}
+	if size == 0 {
+		return
+	}
+	s.segments = append(s.segments, segment{offset: s.size, size: size, data: data, r: r, rOffset: rOffset})
+	s.size += size
+}
+
+func (s *segmentReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].offset+s.segments[i].size > off
+	})
+	n := 0
+	for ; i < len(s.segments) && n < len(p); i++ {
+		seg := s.segments[i]
+		start := off + int64(n) - seg.offset
+		want := p[n:]
+		if int64(len(want)) > seg.size-start {
+			want = want[:seg.size-start]
+		}
+		if seg.data != nil {
+			n += copy(want, seg.data[start:])
+			continue
+		}
+		m, err := seg.r.ReadAt(want, seg.rOffset+start)
+		n += m
+		if m < len(want) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// multiCloser closes all of its closers that are not nil.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var err error
+	for _, c := range m {
+		if c == nil {
+			continue
+		}
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package zipfs
+
+import (
+	iofs "io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+// ManifestEntry describes a file of a FileSystem in its manifest.
+type ManifestEntry struct {
+	Path    string    `json:"path"`    // Path of the file within the archive
+	Size    int64     `json:"size"`    // Uncompressed size in bytes
+	CRC32   uint32    `json:"crc32"`   // CRC-32 of the contents, 0 if unknown
+	ModTime time.Time `json:"modTime"` // Modification time
+	Method  uint16    `json:"method"`  // Compression method, such as zip.Deflate
+}
+
+// Manifest returns the files of fs, sorted by path, which is the path
+// they are stored under with the case of the archive. Directories and
+// the contents of Zip files stored in the archive are left out.
+func (fs *FileSystem) Manifest() []ManifestEntry {
+	fs = fs.current()
+	entries := []ManifestEntry{}
+	fs.Walk(".", func(name string, d iofs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fi := info.(*FileInfo)
+		entries = append(entries, ManifestEntry{
+			Path:    fs.entryName(fi.fi.zipFile),
+			Size:    fi.Size(),
+			CRC32:   fi.CRC32,
+			ModTime: fi.ModTime(),
+			Method:  fi.Method,
+		})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// ArchiveManifest is the manifest of a mounted archive in the response
+// of the manifest API endpoint.
+type ArchiveManifest struct {
+	Path      string          `json:"path"`
+	URLPrefix string          `json:"urlPrefix,omitempty"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// WithManifestEndpoint serves the manifest API endpoint, which responds
+// to GET requests with the manifests of the mounted archives, see
+// FileSystem.Manifest, so that clients can check or fetch their contents
+// in advance. The zip query parameter selects a single archive by the
+// path it was mounted from. Files left out of directory listings, such
+// as hidden ones, are left out of the manifests as well. Responses carry
+// the ETag of the mount table, like those of the listmountzip endpoint.
+func WithManifestEndpoint() Option {
+	return func(h *fileHandler) {
+		h.manifestEndpoint = true
+	}
+}
+
+// Report the files of the mounted ZIP files.
+func (h *fileHandler) Manifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		h.logErrorf("Manifest", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+	if !h.manifestEndpoint {
+		http.Error(w, "The manifest endpoint is not enabled.", http.StatusNotFound)
+		return
+	}
+	zip := r.URL.Query().Get("zip")
+
+	table, gen := h.mountTable()
+	w.Header().Set("Etag", mountTableEtag(gen, table))
+	if _, done := checkPreconditions(w, r, time.Time{}); done {
+		return
+	}
+
+	mounts, release := h.acquireMounts()
+	defer release()
+	manifests := []ArchiveManifest{}
+	for _, fse := range mounts {
+		if zip != "" && fse.givenPath != zip {
+			continue
+		}
+		prefix := h.mountPrefix(fse)
+		manifests = append(manifests, ArchiveManifest{
+			Path:      fse.givenPath,
+			URLPrefix: prefix,
+			Files:     h.listedManifest(fse, prefix),
+		})
+	}
+	if zip != "" && len(manifests) == 0 {
+		http.Error(w, "Zip not mounted.", http.StatusNotFound)
+		return
+	}
+
+	makeJsonResponse(w, manifests, http.StatusOK)
+}
+
+// listedManifest returns the manifest of the archive fs mounted at prefix,
+// without the files that directory listings leave out.
+func (h *fileHandler) listedManifest(fs *FileSystem, prefix string) []ManifestEntry {
+	entries := []ManifestEntry{}
+	for _, entry := range fs.Manifest() {
+		if h.listable(entry.Path, path.Join("/", prefix, entry.Path)) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
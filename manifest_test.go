@@ -0,0 +1,101 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "Site/Index.html", "index", "readme.txt", "readme", "site/app.js", "app")
+	manifest := fs.Manifest()
+	require.Len(manifest, 3)
+	assert.Equal("Site/Index.html", manifest[0].Path)
+	assert.Equal("readme.txt", manifest[1].Path)
+	assert.Equal("site/app.js", manifest[2].Path)
+	zf := fs.fileInfos["readme.txt"].zipFile
+	assert.Equal(ManifestEntry{
+		Path:    "readme.txt",
+		Size:    int64(len("readme")),
+		CRC32:   zf.CRC32,
+		ModTime: zf.Modified,
+		Method:  zip.Deflate,
+	}, manifest[1])
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "a.zip"), "a.txt", "a")
+	writeTestZip(t, filepath.Join(dir, "b.zip"), "b.txt", "b")
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithManifestEndpoint())
+	mountTestZip(t, h, `{"filePath": "a.zip", "urlPrefix": "/a"}`)
+	mountTestZip(t, h, `{"filePath": "b.zip"}`)
+
+	w := serveTest(h, "GET", "/api/manifest", "")
+	require.Equal(http.StatusOK, w.status)
+	var manifests []ArchiveManifest
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &manifests))
+	require.Len(manifests, 2)
+	assert.Equal("/a", manifests[0].URLPrefix)
+	require.Len(manifests[0].Files, 1)
+	assert.Equal("a.txt", manifests[0].Files[0].Path)
+
+	w = serveTest(h, "GET", "/api/manifest?zip="+filepath.Join(dir, "b.zip"), "")
+	require.Equal(http.StatusOK, w.status)
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &manifests))
+	require.Len(manifests, 1)
+	assert.Equal("b.txt", manifests[0].Files[0].Path)
+	assert.Equal(http.StatusNotFound, serveTest(h, "GET", "/api/manifest?zip=missing.zip", "").status)
+
+	// Responses are tagged with the mount table, which changes on mount
+	etag := serveTest(h, "GET", "/api/manifest", "").Header().Get("Etag")
+	require.NotEmpty(etag)
+	assert.Equal(http.StatusNotModified, serveTest(h, "GET", "/api/manifest", "", "If-None-Match", etag).status)
+	require.Equal(http.StatusOK, serveTest(h, "POST", "/api/unmountzip", `{"filePath": "b.zip"}`).status)
+	w = serveTest(h, "GET", "/api/manifest", "", "If-None-Match", etag)
+	assert.Equal(http.StatusOK, w.status)
+	assert.NotEqual(etag, w.Header().Get("Etag"))
+
+	// The endpoint is optional
+	h = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir())
+	assert.Equal(http.StatusNotFound, serveTest(h, "GET", "/api/manifest", "").status)
+}
+
+func TestManifestFiltered(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "site.zip"),
+		"index.html", "index",
+		".git/config", "config",
+		"admin/secret.txt", "secret",
+		".zipfsrc", "{}",
+		"_headers", "",
+		"_redirects", "",
+	)
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(),
+		WithManifestEndpoint(),
+		WithHiddenPatterns(".git"),
+		WithPathRules(nil, []string{"/site/admin/**"}),
+		WithDirConfig(),
+		WithHeadersFile(),
+		WithRedirectsFile(),
+	)
+	mountTestZip(t, h, `{"filePath": "site.zip", "urlPrefix": "/site"}`)
+
+	// Files left out of directory listings are left out of the manifest
+	w := serveTest(h, "GET", "/api/manifest", "")
+	require.Equal(http.StatusOK, w.status)
+	var manifests []ArchiveManifest
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &manifests))
+	require.Len(manifests, 1)
+	require.Len(manifests[0].Files, 1)
+	assert.Equal("index.html", manifests[0].Files[0].Path)
+}
@@ -0,0 +1,58 @@
+package zipfs
+
+import (
+	"errors"
+	"net/http"
+)
+
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// Allow header values of files and of PHP scripts.
+const (
+	fileMethods = "GET, HEAD, OPTIONS"
+	phpMethods  = "GET, HEAD, POST, OPTIONS"
+)
+
+// checkMethod answers OPTIONS requests with the methods allowed for a
+// file, and other requests with 405 Method Not Allowed unless they are
+// allowed, which they are for GET and HEAD, and for POST if the file is
+// a PHP script. It reports whether the request should be served.
+func (h *fileHandler) checkMethod(w http.ResponseWriter, r *http.Request, isPhp bool) bool {
+	allow := fileMethods
+	if isPhp {
+		allow = phpMethods
+	}
+	switch {
+	case r.Method == "GET" || r.Method == "HEAD":
+		return true
+	case r.Method == "POST" && isPhp:
+		return true
+	case r.Method == "OPTIONS":
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	w.Header().Set("Allow", allow)
+	err := errMethodNotAllowed
+	recordError(r, r.URL.Path, err)
+	httpError(w, r, "Method Not Allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// headWriter discards the body of the response to a HEAD request. HEAD
+// requests are served by the same code as GET requests, which skips
+// reading entries where it can, so that they get exactly the same
+// headers as GET requests; headWriter makes sure that no body is sent
+// by code that does not check for HEAD, such as error pages.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
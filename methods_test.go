@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHead(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("<p>same headers</p>\n", 500)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name   string
+		method uint16
+	}{{"deflated.html", zip.Deflate}, {"stored.html", zip.Store}, {"dir/page.html", zip.Deflate}} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	handlers := map[string]http.Handler{
+		"plain":       FileServer(fs, "api/", "", false, nil, nil),
+		"compression": FileServer(fs, "api/", "", false, nil, nil, WithCompression(true)),
+		"gzip":        FileServer(fs, "api/", "", false, nil, nil, WithGzip(gzip.BestSpeed)),
+		"zstd":        FileServer(fs, "api/", "", false, nil, nil, WithZstd(false)),
+	}
+	for name, handler := range handlers {
+		for _, target := range []string{"/deflated.html", "/stored.html", "/dir", "/missing.html"} {
+			for _, acceptEncoding := range []string{"", "gzip, deflate, zstd"} {
+				msg := name + " " + target + " " + acceptEncoding
+				get := serveTest(handler, "GET", target, "", "Accept-Encoding", acceptEncoding)
+				head := serveTest(handler, "HEAD", target, "", "Accept-Encoding", acceptEncoding)
+				assert.Equal(get.status, head.status, msg)
+				assert.Equal(get.Header(), head.Header(), msg)
+				assert.Equal(0, head.buf.Len(), msg)
+			}
+		}
+	}
+
+	w := serveTest(handlers["plain"], "HEAD", "/deflated.html", "")
+	assert.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal("10000", w.Header().Get("Content-Length"))
+	assert.NotEqual("", w.Header().Get("Etag"))
+	assert.NotEqual("", w.Header().Get("Last-Modified"))
+	assert.Contains(w.Header().Get("Content-Type"), "text/html")
+}
+
+func TestMethods(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.html", "<p>hello</p>", "script.php", "<?php echo 1;")
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithPHP("php-cgi", t.TempDir()))
+
+	w := serveTest(handler, "OPTIONS", "/page.html", "")
+	assert.Equal(http.StatusNoContent, w.status)
+	assert.Equal("GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+	assert.Equal(0, w.buf.Len())
+
+	for _, method := range []string{"POST", "PUT", "DELETE", "PATCH"} {
+		w = serveTest(handler, method, "/page.html", "")
+		assert.Equal(http.StatusMethodNotAllowed, w.status, method)
+		assert.Equal("GET, HEAD, OPTIONS", w.Header().Get("Allow"), method)
+	}
+
+	// PHP scripts also accept POST
+	w = serveTest(handler, "OPTIONS", "/script.php", "")
+	assert.Equal(http.StatusNoContent, w.status)
+	assert.Equal("GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+	w = serveTest(handler, "PUT", "/script.php", "")
+	assert.Equal(http.StatusMethodNotAllowed, w.status)
+	assert.Equal("GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+
+	// Files that do not exist are not found, whatever the method
+	w = serveTest(handler, "DELETE", "/missing.html", "")
+	assert.Equal(http.StatusNotFound, w.status)
+	assert.Equal("", w.Header().Get("Allow"))
+}
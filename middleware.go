@@ -0,0 +1,56 @@
+package zipfs
+
+import "net/http"
+
+// serveFunc is the signature shared by the handler and the optional
+// layers wrapped around it.
+type serveFunc func(http.ResponseWriter, *http.Request)
+
+// contextKey identifies values attached to the request context by the
+// optional layers.
+type contextKey int
+
+const (
+	timingsKey contextKey = iota
+	errorInfoKey
+	errorHandlerKey
+	quotaChargeKey
+)
+
+// statusWriter is a http.ResponseWriter that records the status code
+// and the number of body bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Status returns the status code of the response, which is 200
+// if nothing has been written.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var errMmapUnsupported = errors.New("zip: memory mapping is not supported on this platform")
+
+// NewMapped will open the Zip file specified by name by mapping it into
+// memory, so that it is read through the page cache of the operating
+// system without a system call per read. It suits large archives that
+// are read often. On platforms that do not support memory mapping, and
+// for empty files, it opens the file like New does. The file must not be
+// truncated while it is mapped, but it can be replaced, see Reload.
+func NewMapped(name string) (*FileSystem, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	data, err := mapFile(file, fi.Size())
+	file.Close()
+	if err != nil {
+		if err == errMmapUnsupported || fi.Size() == 0 {
+			return New(name)
+		}
+		return nil, err
+	}
+	m := &mappedReaderAt{data: data}
+	fs, err := NewFromReaderAt(m, fi.Size(), m, name)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	fs.modTime = fi.ModTime()
+	fs.file = fi
+	fs.mapped = true
+	return fs, nil
+}
+
+// WithMappedMounts opens the archives mounted through the mountZIP API
+// endpoint with NewMapped. Archives read into memory by
+// WithInMemoryMounts, and damaged archives recovered by
+// WithArchiveRepair, are not mapped.
+func WithMappedMounts(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.mmap = enabled
+	}
+}
+
+// mappedReaderAt reads a memory mapped file. Reading from it after it is
+// unmapped would crash, so it fails once it is closed instead.
+type mappedReaderAt struct {
+	data  []byte
+	mutex sync.RWMutex // Guards data against being unmapped while read
+}
+
+func (m *mappedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.data == nil {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mappedReaderAt) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.data == nil {
+		return nil
+	}
+	err := unmapFile(m.data)
+	m.data = nil
+	return err
+}
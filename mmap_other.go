@@ -0,0 +1,15 @@
+//go:build !unix
+
+package zipfs
+
+import "os"
+
+// mapFile is not supported on this platform.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// unmapFile is not supported on this platform.
+func unmapFile(data []byte) error {
+	return errMmapUnsupported
+}
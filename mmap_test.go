@@ -0,0 +1,74 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMapped(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	writeTestZip(t, zipPath, "page.txt", "mapped", "long.txt", strings.Repeat("mapped ", 1000))
+	fs, err := NewMapped(zipPath)
+	require.NoError(err)
+	if !fs.mapped {
+		fs.Close()
+		t.Skip("memory mapping is not supported")
+	}
+
+	f, err := fs.Open("long.txt")
+	require.NoError(err)
+	data, err := io.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal(strings.Repeat("mapped ", 1000), string(data))
+	f.Close()
+
+	// Replacing the file leaves the mapping of the old one intact
+	writeTestZip(t, filepath.Join(dir, "new.zip"), "page.txt", "remapped")
+	require.NoError(os.Rename(filepath.Join(dir, "new.zip"), zipPath))
+	f, err = fs.Open("page.txt")
+	require.NoError(err)
+	require.NoError(fs.Reload())
+	data, err = io.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal("mapped", string(data))
+	f.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	w := serveTest(handler, "GET", "/page.txt", "")
+	assert.Equal("remapped", w.buf.String())
+	assert.True(fs.current().mapped)
+
+	// Reading after the mapping is closed fails instead of crashing
+	m := fs.current().readerAt.(*mappedReaderAt)
+	require.NoError(fs.Close())
+	_, err = m.ReadAt(make([]byte, 4), 0)
+	assert.Equal(os.ErrClosed, err)
+}
+
+func TestMappedMounts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "site.zip"), "page.txt", "mapped")
+	handler := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithMappedMounts(true))
+	mountTestZip(t, handler, `{"filePath": "site.zip"}`)
+	assert.Equal("mapped", serveTest(handler, "GET", "/page.txt", "").buf.String())
+
+	w := serveTest(handler, "GET", "/api/mountstatus", "")
+	var status MountStatusResponseData
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &status))
+	require.Len(status.Mounts, 1)
+	_, err := mapFile(nil, 0)
+	assert.Equal(err != errMmapUnsupported, status.Mounts[0].Mapped)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package zipfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of file into memory for reading.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if size <= 0 || int64(int(size)) != size {
+		return nil, syscall.EINVAL
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile unmaps data mapped by mapFile.
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,151 @@
+package zipfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MountEventKind is the kind of change to the mounted archives that a
+// MountEvent records.
+type MountEventKind string
+
+const (
+	MountEventMount   MountEventKind = "mount"   // Through the mountZIP endpoint
+	MountEventUnmount MountEventKind = "unmount" // Through the unmountZIP endpoint
+	MountEventStage   MountEventKind = "stage"   // Through the stageZIP endpoint
+	MountEventSwap    MountEventKind = "swap"    // Through the swapZIP endpoint
+	MountEventReload  MountEventKind = "reload"  // By WithAutoReload
+)
+
+// MountEvent records an attempt to change the mounted archives, and
+// whether it succeeded.
+type MountEvent struct {
+	Kind   MountEventKind `json:"kind"`
+	Path   string         `json:"path"`             // Path of the archive, as resolved if it is valid
+	Remote string         `json:"remote,omitempty"` // Address of the client, empty for reloads
+	User   string         `json:"user,omitempty"`   // User of basic authentication, if any
+	Time   time.Time      `json:"time"`             // When the attempt was made
+	Status int            `json:"status"`           // Status code of the response, 200 for reloads
+	Error  string         `json:"error,omitempty"`  // Why the attempt failed, empty if it succeeded
+}
+
+// maxMountEventError is the length of the error messages of failed
+// attempts that are kept.
+const maxMountEventError = 512
+
+// WithMountEvents calls callback for every attempt to mount, unmount,
+// stage or swap an archive through the API, and for every reload of
+// WithAutoReload, once it has succeeded or failed. The callback is called
+// by the goroutine serving the request, or reloading the archive, so it
+// must not block for long.
+func WithMountEvents(callback func(MountEvent)) Option {
+	return func(h *fileHandler) {
+		h.mountEvents = callback
+	}
+}
+
+// WithMountAuditLog keeps the last size MountEvents, and serves them
+// from the mountevents API endpoint, oldest first.
+func WithMountAuditLog(size int) Option {
+	return func(h *fileHandler) {
+		h.mountAudit = &mountAudit{size: size}
+	}
+}
+
+// mountAudit is the audit log of WithMountAuditLog.
+type mountAudit struct {
+	size   int
+	mutex  sync.Mutex
+	events []MountEvent
+}
+
+func (a *mountAudit) add(event MountEvent) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.events = append(a.events, event)
+	if len(a.events) > a.size {
+		a.events = append([]MountEvent(nil), a.events[len(a.events)-a.size:]...)
+	}
+}
+
+// recordMountEvent passes event to the callback of WithMountEvents and
+// the audit log of WithMountAuditLog.
+func (h *fileHandler) recordMountEvent(event MountEvent) {
+	if h.mountAudit != nil {
+		h.mountAudit.add(event)
+	}
+	if h.mountEvents != nil {
+		h.mountEvents(event)
+	}
+}
+
+// auditWriter is a http.ResponseWriter that records the status code and
+// the start of the body of failed responses.
+type auditWriter struct {
+	statusWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	n, err := w.statusWriter.Write(b)
+	if w.Status() >= 400 && w.body.Len() < maxMountEventError {
+		w.body.Write(b[:min(n, maxMountEventError-w.body.Len())])
+	}
+	return n, err
+}
+
+// serveMountEndpoint serves a request to an endpoint that changes the
+// mounted archives with serve, and records it as a MountEvent of kind.
+func (h *fileHandler) serveMountEndpoint(kind MountEventKind, serve serveFunc, w http.ResponseWriter, r *http.Request) {
+	if h.mountEvents == nil && h.mountAudit == nil {
+		serve(w, r)
+		return
+	}
+	event := MountEvent{Kind: kind, Remote: clientIP(r), Time: h.now()}
+	if user, _, ok := r.BasicAuth(); ok {
+		event.User = user
+	}
+	// The body is read twice, here for the path and by serve
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var m Mount
+	if json.Unmarshal(body, &m) == nil {
+		event.Path = m.FilePath
+		if zipPath, ok := h.mountPath(m.FilePath); ok && !isRemoteArchive(m.FilePath) {
+			event.Path = zipPath
+		}
+	}
+
+	aw := &auditWriter{statusWriter: statusWriter{ResponseWriter: w}}
+	serve(aw, r)
+	event.Status = aw.Status()
+	if event.Status >= 400 {
+		event.Error = strings.TrimSpace(aw.body.String())
+		if event.Error == "" {
+			event.Error = http.StatusText(event.Status)
+		}
+	}
+	h.recordMountEvent(event)
+}
+
+// Serve the events kept by WithMountAuditLog.
+func (h *fileHandler) MountEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		h.logErrorf("MountEvents", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+	if h.mountAudit == nil {
+		http.Error(w, "The mount audit log is not enabled.", http.StatusNotFound)
+		return
+	}
+	h.mountAudit.mutex.Lock()
+	events := append([]MountEvent{}, h.mountAudit.events...)
+	h.mountAudit.mutex.Unlock()
+	makeJsonResponse(w, events, http.StatusOK)
+}
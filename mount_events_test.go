@@ -0,0 +1,71 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountEvents(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "a.zip")
+	writeTestZip(t, zipPath, "a.txt", "a")
+
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var events []MountEvent
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(),
+		WithClock(clock),
+		WithAutoReload(time.Minute),
+		WithMountEvents(func(event MountEvent) { events = append(events, event) }),
+		WithMountAuditLog(2))
+	client := fromRemoteAddr(h, "192.0.2.1:1234")
+
+	require.Equal(200, serveTest(client, "POST", "/api/mountzip", `{"filePath": "a.zip"}`).status)
+	require.Equal(404, serveTest(client, "POST", "/api/mountzip", `{"filePath": "missing.zip"}`).status)
+	require.Len(events, 2)
+	assert.Equal(MountEvent{
+		Kind:   MountEventMount,
+		Path:   zipPath,
+		Remote: "192.0.2.1",
+		Time:   clock.now,
+		Status: http.StatusOK,
+	}, events[0])
+	assert.Equal(MountEventMount, events[1].Kind)
+	assert.Equal(filepath.Join(dir, "missing.zip"), events[1].Path)
+	assert.Equal(http.StatusNotFound, events[1].Status)
+	assert.NotEmpty(events[1].Error)
+
+	// Reloads are recorded as well
+	replacement := filepath.Join(dir, "new.zip")
+	writeTestZip(t, replacement, "a.txt", "b")
+	require.NoError(os.Rename(replacement, zipPath))
+	clock.now = clock.now.Add(time.Minute)
+	require.Equal(200, serveTest(client, "GET", "/a.txt", "").status)
+	require.Len(events, 3)
+	assert.Equal(MountEvent{Kind: MountEventReload, Path: zipPath, Time: clock.now, Status: http.StatusOK}, events[2])
+
+	require.Equal(200, serveTest(client, "POST", "/api/unmountzip", `{"filePath": "a.zip"}`).status)
+	require.Len(events, 4)
+	assert.Equal(MountEventUnmount, events[3].Kind)
+
+	// Only the last events are kept
+	w := serveTest(client, "GET", "/api/mountevents", "")
+	require.Equal(http.StatusOK, w.status)
+	var logged []MountEvent
+	require.NoError(json.Unmarshal(w.buf.Bytes(), &logged))
+	require.Len(logged, 2)
+	assert.Equal(MountEventReload, logged[0].Kind)
+	assert.Equal(MountEventUnmount, logged[1].Kind)
+
+	h = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir())
+	assert.Equal(http.StatusNotFound, serveTest(h, "GET", "/api/mountevents", "").status)
+}
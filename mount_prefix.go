@@ -0,0 +1,65 @@
+package zipfs
+
+import (
+	"path"
+	"strings"
+)
+
+// WithMountPrefix serves fs only under the URL path prefix, with the
+// prefix removed from the path before looking up files. For example,
+// with the prefix "/game1/", a request for "/game1/index.html" is served
+// with "index.html" from fs. Archives without a prefix serve every path.
+// Archives are searched in the order they are mounted, whether or not
+// they have a prefix.
+func WithMountPrefix(fs *FileSystem, prefix string) Option {
+	return func(h *fileHandler) {
+		h.setMountPrefixLocked(fs, prefix)
+	}
+}
+
+// cleanMountPrefix returns prefix in the form used by stripMountPrefix:
+// lowercase, starting with a slash and without a trailing slash. The
+// empty string means no prefix.
+func cleanMountPrefix(prefix string) string {
+	prefix = strings.ToLower(path.Clean("/" + prefix))
+	if prefix == "/" {
+		return ""
+	}
+	return prefix
+}
+
+// setMountPrefixLocked sets the URL path prefix of fs. The caller must
+// hold mountMutex or be constructing the handler.
+func (h *fileHandler) setMountPrefixLocked(fs *FileSystem, prefix string) {
+	prefix = cleanMountPrefix(prefix)
+	if prefix == "" {
+		delete(h.prefixes, fs)
+		return
+	}
+	if h.prefixes == nil {
+		h.prefixes = map[*FileSystem]string{}
+	}
+	h.prefixes[fs] = prefix
+}
+
+func (h *fileHandler) mountPrefix(fs *FileSystem) string {
+	h.mountMutex.RLock()
+	defer h.mountMutex.RUnlock()
+	return h.prefixes[fs.origin()]
+}
+
+// stripMountPrefix returns the path of name within an archive mounted at
+// prefix, and reports whether name is under prefix at all.
+func stripMountPrefix(prefix string, name string) (string, bool) {
+	if prefix == "" {
+		return name, true
+	}
+	lower := strings.ToLower(name)
+	if lower == prefix {
+		return "/", true
+	}
+	if strings.HasPrefix(lower, prefix+"/") {
+		return name[len(prefix):], true
+	}
+	return "", false
+}
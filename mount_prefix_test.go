@@ -0,0 +1,67 @@
+package zipfs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripMountPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		Prefix string
+		Name   string
+		Want   string
+		OK     bool
+	}{
+		{Prefix: "", Name: "/a.html", Want: "/a.html", OK: true},
+		{Prefix: "/game1", Name: "/game1/a.html", Want: "/a.html", OK: true},
+		{Prefix: "/game1", Name: "/Game1/A.html", Want: "/A.html", OK: true},
+		{Prefix: "/game1", Name: "/game1", Want: "/", OK: true},
+		{Prefix: "/game1", Name: "/game10/a.html", OK: false},
+		{Prefix: "/game1", Name: "/a.html", OK: false},
+	}
+	for _, tc := range testCases {
+		got, ok := stripMountPrefix(tc.Prefix, tc.Name)
+		assert.Equal(tc.OK, ok, tc.Name)
+		assert.Equal(tc.Want, got, tc.Name)
+	}
+
+	assert.Equal("/game1", cleanMountPrefix("Game1/"))
+	assert.Equal("", cleanMountPrefix("/"))
+}
+
+func TestMountPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "one.zip"), "index.html", "one", "page.html", "page one")
+	writeTestZip(t, filepath.Join(dir, "two.zip"), "index.html", "two", "extra.txt", "extra")
+
+	handler := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir())
+
+	mountTestZip(t, handler, `{"filePath": "one.zip", "urlPrefix": "/game1/"}`)
+	mountTestZip(t, handler, `{"filePath": "two.zip", "urlPrefix": "/game2/"}`)
+
+	assert.Equal("page one", serveTest(handler, "GET", "/game1/page.html", "").buf.String())
+	assert.Equal("one", serveTest(handler, "GET", "/game1/", "").buf.String())
+	assert.Equal("two", serveTest(handler, "GET", "/game2/", "").buf.String())
+	assert.Equal("extra", serveTest(handler, "GET", "/game2/extra.txt", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/game1/extra.txt", "").status)
+	assert.Equal(404, serveTest(handler, "GET", "/extra.txt", "").status)
+	w := serveTest(handler, "GET", "/game1", "")
+	assert.Equal(301, w.status)
+	assert.Equal("game1/", w.Header().Get("Location"))
+
+	w = serveTest(handler, "GET", "/api/mountstatus", "")
+	assert.Contains(w.buf.String(), `"urlPrefix":"/game1"`)
+
+	// Archives given to the constructor can have a prefix too
+	fs := newTestFileSystem(t, "page.html", "page")
+	defer fs.Close()
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithMountPrefix(fs, "docs"))
+	assert.Equal("page", serveTest(handler, "GET", "/docs/page.html", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/page.html", "").status)
+}
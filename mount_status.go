@@ -0,0 +1,82 @@
+package zipfs
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MountStatus describes a mounted archive in the response of the
+// mountstatus API endpoint.
+type MountStatus struct {
+	Path      string    `json:"path"`
+	URLPrefix string    `json:"urlPrefix,omitempty"`
+	Size      int64     `json:"size"`    // Size of the ZIP file in bytes
+	Entries   int       `json:"entries"` // Number of entries in the ZIP file
+	MountTime time.Time `json:"mountTime"`
+	Degraded  bool      `json:"degraded,omitempty"` // Recovered from a damaged ZIP file
+	InMemory  bool      `json:"inMemory,omitempty"` // Read into memory, see WithInMemoryMounts
+	Mapped    bool      `json:"mapped,omitempty"`   // Mapped into memory, see WithMappedMounts
+
+	// Stats summarizes the contents of the archive if the request asks
+	// for it with the stats query parameter
+	Stats *ArchiveStats `json:"stats,omitempty"`
+}
+
+// MountStatusResponseData is the response of the mountstatus API
+// endpoint.
+type MountStatusResponseData struct {
+	Mounts []MountStatus `json:"mounts"`
+}
+
+// setMountTimeLocked records that fs has just been mounted. The caller
+// must hold mountMutex or be constructing the handler.
+func (h *fileHandler) setMountTimeLocked(fs *FileSystem) {
+	if h.mountTimes == nil {
+		h.mountTimes = map[*FileSystem]time.Time{}
+	}
+	h.mountTimes[fs] = h.now()
+}
+
+// Report the mounted ZIP files.
+func (h *fileHandler) MountStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		h.logErrorf("MountStatus", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+
+	withStats, _ := strconv.ParseBool(r.URL.Query().Get("stats"))
+
+	h.mountMutex.RLock()
+	status := MountStatusResponseData{Mounts: make([]MountStatus, 0, len(h.fs))}
+	mounts := append([]*FileSystem(nil), h.fs...)
+	// Before the archives are read, so that a reload in between changes it
+	etag := mountTableEtag(h.mountGen, mounts)
+	for _, fse := range h.fs {
+		status.Mounts = append(status.Mounts, MountStatus{
+			Path:      fse.givenPath,
+			URLPrefix: h.prefixes[fse],
+			Size:      fse.current().size,
+			Entries:   len(fse.current().reader.File),
+			MountTime: h.mountTimes[fse],
+			Degraded:  fse.Degraded(),
+			InMemory:  fse.current().inMemory,
+			Mapped:    fse.current().mapped,
+		})
+	}
+	h.mountMutex.RUnlock()
+	if withStats {
+		// Computed outside of the lock, as it takes a while the first time
+		for i, fse := range mounts {
+			stats := fse.Stats()
+			status.Mounts[i].Stats = &stats
+		}
+	}
+
+	w.Header().Set("Etag", etag)
+	if _, done := checkPreconditions(w, r, time.Time{}); done {
+		return
+	}
+	makeJsonResponse(w, status, http.StatusOK)
+}
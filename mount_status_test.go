@@ -0,0 +1,43 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := &testClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	handler := EmptyFileServer("api/", "", false, nil, "", "", nil, nil, t.TempDir(), WithClock(clock))
+	status := func() MountStatusResponseData {
+		w := serveTest(handler, "GET", "/api/mountstatus", "")
+		require.Equal(200, w.status)
+		var data MountStatusResponseData
+		require.NoError(json.Unmarshal(w.buf.Bytes(), &data))
+		return data
+	}
+
+	assert.Empty(status().Mounts)
+
+	mountTestZip(t, handler, `{"filePath": "testdata/testdata.zip"}`)
+	stat, err := os.Stat("testdata/testdata.zip")
+	require.NoError(err)
+
+	mounts := status().Mounts
+	require.Len(mounts, 1)
+	assert.Equal("testdata/testdata.zip", mounts[0].Path)
+	assert.Equal(stat.Size(), mounts[0].Size)
+	assert.True(mounts[0].Entries > 0)
+	assert.True(clock.now.Equal(mounts[0].MountTime))
+
+	etag := serveTest(handler, "GET", "/api/mountstatus", "").Header().Get("Etag")
+	assert.Equal(304, serveTest(handler, "GET", "/api/mountstatus", "", "If-None-Match", etag).status)
+	assert.Equal(400, serveTest(handler, "POST", "/api/mountstatus", "").status)
+}
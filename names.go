@@ -0,0 +1,78 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SetNameEncoding sets the legacy encoding that the names of entries
+// without the UTF-8 flag are decoded with, which is code page 437 by
+// default, as the ZIP specification says. Names without the flag that
+// are valid UTF-8 nevertheless, as written by some tools, are taken to
+// be UTF-8. The undecoded names are still found as well. It must be
+// called before fs is used.
+//
+// All names are normalized to Unicode normalization form C, as are the
+// names looked up, so that entries added on macOS, which names them in
+// form D, are found by the names typed in URLs.
+func (fs *FileSystem) SetNameEncoding(enc encoding.Encoding) {
+	fs = fs.current()
+	fs.nameEncoding = enc
+	fs.indexEntries()
+}
+
+// WithNameEncoding sets the legacy encoding of entry names in archives
+// mounted through the API, and in the archives given to the constructor,
+// see FileSystem.SetNameEncoding.
+func WithNameEncoding(enc encoding.Encoding) Option {
+	return func(h *fileHandler) {
+		h.nameEncoding = enc
+	}
+}
+
+// caseVariant returns a new fileInfo for the file zf named name if an
+// earlier file has the same name except for case, or nil otherwise.
+// Names are matched case-insensitively, so that archives of sites made
+// for Windows web servers work, and files whose names only differ in
+// case are found by the name of the first of them in the archive.
+// Each of them is found by its exact name as well though.
+func (fs *FileSystem) caseVariant(name string, zf *zip.File) *fileInfo {
+	earlier := fs.fileInfos[strings.ToLower(name)]
+	if earlier == nil || earlier.zipFile == nil || earlier.IsDir() || zf.Mode().IsDir() {
+		return nil
+	}
+	earlierName := fs.entryName(earlier.zipFile)
+	if earlierName == name {
+		return nil
+	}
+	if fs.caseVariants == nil {
+		fs.caseVariants = map[string]*fileInfo{}
+	}
+	if fs.caseVariants[earlierName] == nil {
+		fs.caseVariants[earlierName] = earlier
+	}
+	fi := &fileInfo{name: earlier.name}
+	fs.caseVariants[name] = fi
+	return fi
+}
+
+// entryName returns the name that the entry zf is found by, decoded and
+// normalized as described for SetNameEncoding.
+func (fs *FileSystem) entryName(zf *zip.File) string {
+	name := zf.Name
+	if zf.NonUTF8 && !utf8.ValidString(name) {
+		enc := fs.nameEncoding
+		if enc == nil {
+			enc = charmap.CodePage437
+		}
+		if decoded, err := enc.NewDecoder().String(name); err == nil {
+			name = decoded
+		}
+	}
+	return norm.NFC.String(name)
+}
@@ -0,0 +1,82 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestNameEncoding(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sjis, err := japanese.ShiftJIS.NewEncoder().String("日本語.txt")
+	require.NoError(err)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, header := range []*zip.FileHeader{
+		{Name: "porte ferm\x82e.txt", NonUTF8: true}, // Code page 437
+		{Name: "déjà vu.txt", NonUTF8: true},         // UTF-8 without the flag
+		{Name: "cafe\u0301/menu.txt"},                // Form D, as on macOS
+		{Name: sjis, NonUTF8: true},                  // Shift JIS
+	} {
+		w, err := zw.CreateHeader(header)
+		require.NoError(err)
+		w.Write([]byte(header.Name))
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "names.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	assert.Equal("porte ferm\x82e.txt", readTestFile(t, fs, "porte fermée.txt"))
+	assert.Equal("déjà vu.txt", readTestFile(t, fs, "déjà vu.txt"))
+	assert.Equal("cafe\u0301/menu.txt", readTestFile(t, fs, "café/menu.txt"))
+	assert.Equal("cafe\u0301/menu.txt", readTestFile(t, fs, "cafe\u0301/menu.txt"))
+	info, err := fs.Stat("café")
+	require.NoError(err)
+	assert.Equal("café", info.Name())
+
+	// The Shift JIS name is decoded as code page 437 unless configured
+	_, err = fs.Stat("日本語.txt")
+	assert.Error(err)
+	fs.SetNameEncoding(japanese.ShiftJIS)
+	info, err = fs.Stat("日本語.txt")
+	require.NoError(err)
+	assert.Equal("日本語.txt", info.Name())
+	assert.Equal(sjis, readTestFile(t, fs, "日本語.txt"))
+
+	w := serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/"+url.PathEscape("café")+"/menu.txt", "")
+	assert.Equal(http.StatusOK, w.status)
+	assert.Equal("cafe\u0301/menu.txt", w.buf.String())
+}
+
+func TestCaseInsensitiveNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"Assets/Logo.PNG", "first",
+		"assets/logo.png", "second",
+		"ASSETS/LOGO.png", "third",
+		"Index.HTML", "index",
+	)
+	assert.Equal("index", readTestFile(t, fs, "index.html"))
+	assert.Equal("index", readTestFile(t, fs, "INDEX.html"))
+	assert.Equal("first", readTestFile(t, fs, "Assets/Logo.PNG"))
+	assert.Equal("second", readTestFile(t, fs, "assets/logo.png"))
+	assert.Equal("third", readTestFile(t, fs, "ASSETS/LOGO.png"))
+	// Names that match none exactly find the first
+	assert.Equal("first", readTestFile(t, fs, "assets/LOGO.PNG"))
+
+	// The directories are the same, and list every file
+	entries, err := fs.ReadDir("ASSETS")
+	require.NoError(err)
+	assert.Len(entries, 3)
+}
@@ -0,0 +1,121 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"strings"
+)
+
+// nestedSeparator separates the name of a Zip file stored in an archive
+// from the name of an entry inside it, as in levels.zip!/maps/map1.dat.
+const nestedSeparator = "!/"
+
+// openNestedFileInfo returns the entry named by name, which is already
+// cleaned and lowercase, if it is in a Zip file stored in fs. Nested
+// archives can be nested further. It returns nil if name does not refer
+// to a nested archive.
+func (fs *FileSystem) openNestedFileInfo(name string) (*fileInfo, error) {
+	for i := strings.Index(name, nestedSeparator); i >= 0; {
+		outer := fs.fileInfos[name[:i]]
+		if outer != nil && outer.zipFile != nil && !outer.IsDir() {
+			inner, err := fs.openNested(outer)
+			if err != nil {
+				return nil, err
+			}
+			return inner.openFileInfo(name[i+len(nestedSeparator)-1:])
+		}
+		next := strings.Index(name[i+1:], nestedSeparator)
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	return nil, nil
+}
+
+// openNested returns the file system of the Zip file stored as fi. It is
+// opened once and kept until fs is closed. Entries stored without
+// compression are read in place, others are decompressed to a
+// temporary file first.
+func (fs *FileSystem) openNested(fi *fileInfo) (*FileSystem, error) {
+	fs.nestedMutex.Lock()
+	defer fs.nestedMutex.Unlock()
+	if inner := fs.nested[fi.name]; inner != nil {
+		return inner, nil
+	}
+	if fs.readerAt == nil {
+		return nil, errFileSystemClosed
+	}
+
+	name := fs.givenPath + "!/" + fi.zipFile.Name
+	var inner *FileSystem
+	if fi.zipFile.Method == zip.Store && !fi.encrypted() {
+		offset, err := fi.zipFile.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		size := fi.Size()
+		inner, err = NewFromReaderAt(io.NewSectionReader(fs.readerAt, offset, size), size, nil, name)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		file, err := createTempFile(fi)
+		if err != nil {
+			return nil, err
+		}
+		inner, err = NewFromReaderAt(file, fi.Size(), tempFileCloser{file}, name)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+	}
+	inner.fullPath = fs.fullPath
+	inner.modTime = fs.modTime
+	if err := inner.inheritSettings(fs); err != nil {
+		inner.Close()
+		return nil, err
+	}
+	inner.nestedPrefix = fi.fullName() + nestedSeparator
+
+	if fs.nested == nil {
+		fs.nested = map[string]*FileSystem{}
+	}
+	fs.nested[fi.name] = inner
+	return inner, nil
+}
+
+// closeNested closes the nested archives opened by openNested.
+func (fs *FileSystem) closeNested() {
+	fs.nestedMutex.Lock()
+	defer fs.nestedMutex.Unlock()
+	for _, inner := range fs.nested {
+		inner.Close()
+	}
+	fs.nested = nil
+}
+
+// fullName returns the name of fi, preceded by the names of the archives
+// it is nested in, if any. It tells apart entries of different archives
+// that share a name, for example in cache keys.
+func (fi *fileInfo) fullName() string {
+	if fi.fs == nil {
+		return fi.name
+	}
+	return fi.fs.nestedPrefix + fi.name
+}
+
+// tempFileCloser closes and removes a temporary file.
+type tempFileCloser struct {
+	file *os.File
+}
+
+func (c tempFileCloser) Close() error {
+	err := c.file.Close()
+	if err := os.Remove(c.file.Name()); err != nil {
+		return err
+	}
+	return err
+}
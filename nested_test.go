@@ -0,0 +1,69 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipBytes returns a ZIP file from alternating name and content
+// arguments, with entries compressed with method.
+func zipBytes(t *testing.T, method uint16, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: files[i], Method: method})
+		require.NoError(t, err)
+		w.Write([]byte(files[i+1]))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestNestedArchives(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	innermost := zipBytes(t, zip.Deflate, "deep.txt", "deepest")
+	inner := zipBytes(t, zip.Deflate, "maps/map1.dat", "map one", "maps/index.html", "maps index", "more.zip", string(innermost))
+	outer := zipBytes(t, zip.Store, "packs/levels.zip", string(inner), "maps/map1.dat", "outer map")
+	compressed := zipBytes(t, zip.Deflate, "packs/levels.zip", string(inner))
+
+	for name, data := range map[string][]byte{"stored": outer, "compressed": compressed} {
+		fs, err := NewFromBytes(data, name)
+		require.NoError(err)
+		assert.Equal("map one", readTestFile(t, fs, "packs/levels.zip!/maps/map1.dat"), name)
+		assert.Equal("map one", readTestFile(t, fs, "Packs/Levels.zip!/MAPS/map1.dat"), name)
+		assert.Equal("deepest", readTestFile(t, fs, "packs/levels.zip!/more.zip!/deep.txt"), name)
+		_, err = fs.Open("packs/levels.zip!/missing.dat")
+		assert.Error(err, name)
+		_, err = fs.Open("maps/map1.dat!/x")
+		assert.Error(err, name)
+
+		// The inner archive is opened once
+		fi, err := fs.openFileInfo("packs/levels.zip!/maps/map1.dat")
+		require.NoError(err)
+		again, err := fs.openFileInfo("packs/levels.zip!/maps/map1.dat")
+		require.NoError(err)
+		assert.True(fi == again)
+		assert.Equal("packs/levels.zip!/maps/map1.dat", fi.fullName())
+		inner := fs.nested["packs/levels.zip"]
+		require.NotNil(inner)
+		fs.Close()
+		assert.Nil(inner.readerAt)
+	}
+
+	fs, err := NewFromBytes(outer, "outer.zip")
+	require.NoError(err)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil)
+	assert.Equal("map one", serveTest(handler, "GET", "/packs/levels.zip!/maps/map1.dat", "").buf.String())
+	assert.Equal("outer map", serveTest(handler, "GET", "/maps/map1.dat", "").buf.String())
+	assert.Equal("map", serveTest(handler, "GET", "/packs/levels.zip!/maps/map1.dat", "", "Range", "bytes=0-2").buf.String())
+	assert.Equal("maps index", serveTest(handler, "GET", "/packs/levels.zip!/maps/", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/packs/levels.zip!/nothing", "").status)
+}
@@ -0,0 +1,52 @@
+package zipfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WithNotFoundPage serves the entry at name, such as "/404.html", as the
+// body of 404 responses. The page is taken from the first mounted
+// archive that has it and that serves the path of the request, so that
+// archives mounted under a URL prefix can each have their own page. If
+// no archive has the page, the handler set by WithNotFoundHandler or a
+// plain text message is used instead.
+func WithNotFoundPage(name string) Option {
+	return func(h *fileHandler) {
+		h.notFoundPage = path.Clean("/" + name)
+	}
+}
+
+// serveNotFoundPage serves the not found page with a 404 status, and
+// reports whether there was a page to serve.
+func (h *fileHandler) serveNotFoundPage(w http.ResponseWriter, r *http.Request, mounts []*FileSystem) bool {
+	for _, fse := range mounts {
+		if _, ok := stripMountPrefix(h.mountPrefix(fse), r.URL.Path); !ok {
+			continue
+		}
+		fi, err := fse.openFileInfo(h.notFoundPage)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		reader, err := fi.open()
+		if err != nil {
+			continue
+		}
+		defer reader.Close()
+
+		if mimeOverride, ok := h.mimeExts[strings.ToLower(path.Ext(fi.name))]; ok {
+			w.Header().Set("Content-Type", mimeOverride)
+		}
+		setContentType(w, fi.Name(), nil)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", fi.Size()))
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != "HEAD" {
+			io.CopyN(w, reader, fi.Size())
+		}
+		return true
+	}
+	return false
+}
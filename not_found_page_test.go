@@ -0,0 +1,55 @@
+package zipfs
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFoundPage(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "index.html", "home", "404.html", "<h1>Lost</h1>")
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithNotFoundPage("404.html"))
+	w := serveTest(handler, "GET", "/missing.html", "")
+	assert.Equal(404, w.status)
+	assert.Equal("<h1>Lost</h1>", w.buf.String())
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("13", w.Header().Get("Content-Length"))
+	assert.Equal("", w.Header().Get("Etag"))
+
+	w = serveTest(handler, "HEAD", "/missing.html", "")
+	assert.Equal(404, w.status)
+	assert.Equal("13", w.Header().Get("Content-Length"))
+	assert.Equal(0, w.buf.Len())
+
+	// The page itself is still served normally
+	w = serveTest(handler, "GET", "/404.html", "")
+	assert.Equal(200, w.status)
+
+	// Without the page in the archive the handler is used
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithNotFoundPage("/other.html"), WithNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "handled", http.StatusNotFound)
+	})))
+	w = serveTest(handler, "GET", "/missing.html", "")
+	assert.Equal(404, w.status)
+	assert.Equal("handled\n", w.buf.String())
+
+	// Each prefix mount has its own page
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "one.zip"), "404.html", "one is lost")
+	writeTestZip(t, filepath.Join(dir, "two.zip"), "404.html", "two is lost")
+	handler = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithNotFoundPage("/404.html"))
+	for _, body := range []string{`{"filePath": "one.zip", "urlPrefix": "one"}`, `{"filePath": "two.zip", "urlPrefix": "two"}`} {
+		mountTestZip(t, handler, body)
+	}
+	assert.Equal("two is lost", serveTest(handler, "GET", "/two/missing", "").buf.String())
+	assert.Equal("one is lost", serveTest(handler, "GET", "/one/missing", "").buf.String())
+	w = serveTest(handler, "GET", "/three/missing", "")
+	assert.Equal(404, w.status)
+	assert.Equal("404 page not found\n", w.buf.String())
+}
@@ -0,0 +1,237 @@
+package zipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// objectBlockSize is the least number of bytes read from an object at a
+// time. Small reads, such as those of the central directory and local
+// file headers, are served from cached blocks.
+const objectBlockSize = 64 * 1024
+
+// objectCacheBlocks is the number of blocks of an object that are kept
+// in memory.
+const objectCacheBlocks = 16
+
+var errObjectChanged = errors.New("archive has changed")
+
+// ObjectStore provides the bytes of archives held in cloud object
+// storage, such as S3 or GCS buckets, so that they can be served
+// without a local copy. Implementations typically wrap the client of
+// the storage service, and must be safe for concurrent use. Requests to
+// the service should be made with ctx, so that they are given up once it
+// is cancelled or its deadline passes.
+type ObjectStore interface {
+	// Stat returns the size, modification time and ETag of the object
+	// with the given key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// ReadRange returns a reader of the length bytes of the object
+	// starting at offset. If obj has an ETag, the read should fail if
+	// the object no longer has it, for example with an If-Match
+	// condition.
+	ReadRange(ctx context.Context, obj ObjectInfo, offset int64, length int64) (io.ReadCloser, error)
+}
+
+// ObjectInfo describes an object of an ObjectStore.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string // Identifies the version of the object, optional
+}
+
+// NewFromObjectStore returns a new FileSystem based on the Zip file
+// stored in store under key. The archive is not downloaded: the central
+// directory and the entries that are served are read with ranged reads,
+// in blocks that are cached in memory. The object is looked up and the
+// central directory is read with ctx. The entries that are served later
+// are read until the FileSystem is closed.
+func NewFromObjectStore(ctx context.Context, store ObjectStore, key string) (*FileSystem, error) {
+	obj, err := store.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	ra := &objectReaderAt{store: store, obj: obj, ctx: ctx}
+	fs, err := NewFromReaderAt(ra, obj.Size, ra, key)
+	if err != nil {
+		return nil, err
+	}
+	ra.ctx, ra.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	fs.fullPath = key
+	fs.modTime = obj.ModTime
+	return fs, nil
+}
+
+// WithObjectStore mounts archives from store when the filePath of a
+// mountZIP API request starts with the scheme, such as "s3://". The
+// rest of the path is the key of the archive in store.
+func WithObjectStore(scheme string, store ObjectStore) Option {
+	return func(h *fileHandler) {
+		if h.objectStores == nil {
+			h.objectStores = map[string]ObjectStore{}
+		}
+		h.objectStores[strings.ToLower(scheme)] = store
+	}
+}
+
+// objectReaderAt reads an object of an ObjectStore.
+type objectReaderAt struct {
+	store  ObjectStore
+	obj    ObjectInfo
+	ctx    context.Context // Passed to the reads of store
+	cancel context.CancelFunc
+
+	mutex  sync.Mutex
+	blocks []objectBlock // Most recently read blocks, see cached
+	next   int           // Index of the block to replace next
+}
+
+type objectBlock struct {
+	offset int64
+	data   []byte
+}
+
+func (ra *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= ra.obj.Size {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if remaining := ra.obj.Size - off; int64(want) > remaining {
+		want = int(remaining)
+	}
+
+	var n int
+	var err error
+	if data := ra.cached(off, want); data != nil {
+		n = copy(p, data)
+	} else if want >= objectBlockSize {
+		n, err = ra.fetch(p[:want], off)
+	} else {
+		end := off + objectBlockSize
+		if end > ra.obj.Size {
+			end = ra.obj.Size
+		}
+		block := make([]byte, end-off)
+		if _, err = ra.fetch(block, off); err == nil {
+			ra.keep(off, block)
+			n = copy(p, block)
+		}
+	}
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close cancels the reads of the object that are still in flight.
+func (ra *objectReaderAt) Close() error {
+	if ra.cancel != nil {
+		ra.cancel()
+	}
+	return nil
+}
+
+// cached returns the n bytes at off if a cached block holds them.
+func (ra *objectReaderAt) cached(off int64, n int) []byte {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+	for _, block := range ra.blocks {
+		if off >= block.offset && off+int64(n) <= block.offset+int64(len(block.data)) {
+			return block.data[off-block.offset : off-block.offset+int64(n)]
+		}
+	}
+	return nil
+}
+
+// keep caches the block data read at off, replacing the oldest block if
+// the cache is full.
+func (ra *objectReaderAt) keep(off int64, data []byte) {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+	if len(ra.blocks) < objectCacheBlocks {
+		ra.blocks = append(ra.blocks, objectBlock{offset: off, data: data})
+		return
+	}
+	ra.blocks[ra.next] = objectBlock{offset: off, data: data}
+	ra.next = (ra.next + 1) % objectCacheBlocks
+}
+
+// fetch reads len(p) bytes at off from the object.
+func (ra *objectReaderAt) fetch(p []byte, off int64) (int, error) {
+	rc, err := ra.store.ReadRange(ra.ctx, ra.obj, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// archiveScheme returns the scheme of the path of an archive given to
+// the API, such as "https" or "s3", or "" if it is a local path.
+func archiveScheme(name string) string {
+	scheme, _, ok := strings.Cut(name, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, `/\.:`) {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// isRemoteArchive reports whether the path of an archive given to the
+// API is the URL of an archive that is not on the local file system.
+func isRemoteArchive(name string) bool {
+	return archiveScheme(name) != ""
+}
+
+// mountRemote mounts the remote archive m.FilePath for the mountZIP API
+// endpoint, from an object store or the web.
+func (h *fileHandler) mountRemote(w http.ResponseWriter, r *http.Request, m Mount) {
+	var store ObjectStore
+	key := m.FilePath
+	switch scheme := archiveScheme(m.FilePath); {
+	case h.objectStores[scheme] != nil:
+		store = h.objectStores[scheme]
+		key = m.FilePath[len(scheme)+len("://"):]
+	case (scheme == "http" || scheme == "https") && h.downloadClient != nil:
+		store = httpObjectStore{client: h.downloadClient}
+	case scheme == "http" || scheme == "https":
+		h.logErrorf("MountFs", "Mount downloads are not enabled")
+		http.Error(w, "Mount downloads are not enabled.", http.StatusBadRequest)
+		return
+	default:
+		h.logErrorf("MountFs", "No object store for %s", m.FilePath)
+		http.Error(w, fmt.Sprintf("No object store for %q.", scheme), http.StatusBadRequest)
+		return
+	}
+
+	h.logf("Mounting Remote Zip: %s\n", m.FilePath)
+	newFS, err := NewFromObjectStore(r.Context(), store, key)
+	if err != nil {
+		h.logError("MountFs", err)
+		recordError(r, m.FilePath, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	newFS.givenPath = m.FilePath
+	if m.Password != "" {
+		newFS.SetPassword(m.Password)
+	}
+	if !h.verifyMount(w, r, m, newFS) {
+		return
+	}
+	h.mount(w, m.FilePath, m.URLPrefix, newFS)
+}
@@ -0,0 +1,96 @@
+package zipfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryObjectStore is an ObjectStore of objects held in memory.
+type memoryObjectStore struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+	reads   int
+}
+
+func (s *memoryObjectStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	data, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, os.ErrNotExist
+	}
+	return ObjectInfo{Key: key, Size: int64(len(data)), ModTime: time.Unix(1500000000, 0)}, nil
+}
+
+func (s *memoryObjectStore) ReadRange(ctx context.Context, obj ObjectInfo, offset int64, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.reads++
+	s.mutex.Unlock()
+	data := s.objects[obj.Key]
+	return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+}
+
+func TestObjectStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "game.zip"), "index.html", "bucket index", "data.txt", "bucket data")
+	data, err := os.ReadFile(filepath.Join(dir, "game.zip"))
+	require.NoError(err)
+	store := &memoryObjectStore{objects: map[string][]byte{"bucket/game.zip": data}}
+
+	fs, err := NewFromObjectStore(context.Background(), store, "bucket/game.zip")
+	require.NoError(err)
+	defer fs.Close()
+	f, err := fs.Open("data.txt")
+	require.NoError(err)
+	got, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(err)
+	assert.Equal("bucket data", string(got))
+	// The whole archive fits in the first block that is read
+	assert.Equal(1, store.reads)
+
+	_, err = NewFromObjectStore(context.Background(), store, "bucket/missing.zip")
+	assert.True(os.IsNotExist(err))
+
+	// Reads are given up once the context is cancelled, or the archive
+	// is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewFromObjectStore(ctx, store, "bucket/game.zip")
+	assert.ErrorIs(err, context.Canceled)
+	closed, err := NewFromObjectStore(context.Background(), store, "bucket/game.zip")
+	require.NoError(err)
+	ra := closed.readerAt.(*objectReaderAt)
+	closed.Close()
+	_, err = ra.fetch(make([]byte, 1), 0)
+	assert.ErrorIs(err, context.Canceled)
+
+	handler := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir(), WithObjectStore("S3", store))
+
+	w := serveTest(handler, "POST", "/api/mountzip", `{"filePath": "s3://bucket/game.zip"}`)
+	require.Equal(200, w.status, w.buf.String())
+	assert.Equal("bucket index", serveTest(handler, "GET", "/", "").buf.String())
+	assert.Contains(serveTest(handler, "GET", "/api/listmountzip", "").buf.String(), "s3://bucket/game.zip")
+
+	assert.Equal(400, serveTest(handler, "POST", "/api/mountzip", `{"filePath": "gs://bucket/game.zip"}`).status)
+	assert.Equal(502, serveTest(handler, "POST", "/api/mountzip", `{"filePath": "s3://bucket/missing.zip"}`).status)
+
+	require.Equal(200, serveTest(handler, "POST", "/api/unmountzip", `{"filePath": "s3://bucket/game.zip"}`).status)
+	assert.Equal(404, serveTest(handler, "GET", "/", "").status)
+}
@@ -0,0 +1,136 @@
+package zipfs
+
+import (
+	"net/http"
+	"time"
+)
+
+// Observer is told about every request served by a file server, so that
+// metrics and traces can be collected without this package depending on
+// the libraries that export them. See package zipfsprom for Prometheus
+// metrics and package zipfsotel for OpenTelemetry traces.
+type Observer interface {
+	// StartRequest is called when a request is received at start, and
+	// returns the request to serve, for example with a span in its
+	// context.
+	StartRequest(r *http.Request, start time.Time) *http.Request
+
+	// EndRequest is called once r, as returned by StartRequest, has
+	// been served.
+	EndRequest(r *http.Request, info RequestInfo)
+}
+
+// StatsObserver is an Observer that also reads the statistics of the
+// file server, such as the number of mounted archives.
+type StatsObserver interface {
+	Observer
+
+	// Attach is called once every option has been applied, with a
+	// function that returns the current statistics of the file server.
+	Attach(stats func() Stats) error
+}
+
+// RequestInfo describes a request once it has been served.
+type RequestInfo struct {
+	Status int       // Status code of the response
+	Bytes  int64     // Number of body bytes written
+	Start  time.Time // When the request was received
+	End    time.Time // When it had been served
+
+	// Entry is set if an entry was found for the request, in which case
+	// the time spent in each phase of serving it is set as well, as
+	// timed by WithSlowRequestLog.
+	Entry      bool
+	Lookup     time.Duration
+	Decompress time.Duration
+	Write      time.Duration
+}
+
+// Stats are the statistics of a file server.
+type Stats struct {
+	Mounts int          // Number of mounted archives
+	Caches []CacheStats // The caches configured with WithCache and WithTranscodeCache
+}
+
+// CacheStats counts the lookups in a cache.
+type CacheStats struct {
+	Name   string // "memory", "disk" or "transcode"
+	Hits   int64
+	Misses int64
+}
+
+// WithObserver tells o about every request served by the file server.
+// It can be given more than once, for example for metrics and traces.
+// If o is an http.Handler as well, it serves the metrics API endpoint.
+func WithObserver(o Observer) Option {
+	return func(h *fileHandler) {
+		h.observers = append(h.observers, o)
+		if handler, ok := o.(http.Handler); ok {
+			h.metricsHandler = handler
+		}
+	}
+}
+
+// attachObservers gives the observers that read the statistics of the
+// file server access to them. It is called once every option has been
+// applied, as the caches are not known before.
+func (h *fileHandler) attachObservers() {
+	for _, o := range h.observers {
+		if so, ok := o.(StatsObserver); ok {
+			if err := so.Attach(h.stats); err != nil {
+				h.logError("attachObservers", err)
+			}
+		}
+	}
+}
+
+// stats returns the current statistics of the file server.
+func (h *fileHandler) stats() Stats {
+	stats := Stats{Mounts: len(h.mounted())}
+	if h.memCache != nil {
+		stats.Caches = append(stats.Caches, CacheStats{Name: "memory", Hits: h.memCache.hits.Load(), Misses: h.memCache.misses.Load()})
+	}
+	if h.diskCache != nil {
+		stats.Caches = append(stats.Caches, CacheStats{Name: "disk", Hits: h.diskCache.hits.Load(), Misses: h.diskCache.misses.Load()})
+	}
+	if h.transcodeCache != nil {
+		stats.Caches = append(stats.Caches, CacheStats{Name: "transcode", Hits: h.transcodeCache.hits.Load(), Misses: h.transcodeCache.misses.Load()})
+	}
+	return stats
+}
+
+// withObservers serves the request and tells the observers about it.
+func (h *fileHandler) withObservers(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, r, timings := h.timeRequest(w, r)
+		for _, o := range h.observers {
+			r = o.StartRequest(r, timings.start)
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+
+		info := RequestInfo{
+			Status: sw.Status(),
+			Bytes:  sw.written,
+			Start:  timings.start,
+			End:    h.now(),
+		}
+		if !timings.lookupDone.IsZero() {
+			info.Entry = true
+			info.Lookup, info.Decompress = timings.phases(info.End)
+			info.Write = timings.writing
+		}
+		for i := len(h.observers) - 1; i >= 0; i-- {
+			h.observers[i].EndRequest(r, info)
+		}
+	}
+}
+
+// Serve the metrics of the observer of WithObserver that serves them.
+func (h *fileHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	if h.metricsHandler == nil {
+		http.Error(w, "The metrics endpoint is not enabled.", http.StatusNotFound)
+		return
+	}
+	h.metricsHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,69 @@
+package zipfs
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testObserverKey struct{}
+
+// testObserver records the requests it is told about.
+type testObserver struct {
+	name  string
+	order *[]string
+	infos []RequestInfo
+	stats func() Stats
+}
+
+func (o *testObserver) StartRequest(r *http.Request, start time.Time) *http.Request {
+	*o.order = append(*o.order, "start "+o.name)
+	return r.WithContext(context.WithValue(r.Context(), testObserverKey{}, o.name))
+}
+
+func (o *testObserver) EndRequest(r *http.Request, info RequestInfo) {
+	*o.order = append(*o.order, "end "+o.name+" "+r.Context().Value(testObserverKey{}).(string))
+	o.infos = append(o.infos, info)
+}
+
+type testStatsObserver struct {
+	testObserver
+}
+
+func (o *testStatsObserver) Attach(stats func() Stats) error {
+	o.stats = stats
+	return nil
+}
+
+func TestObserver(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var order []string
+	first := &testObserver{name: "first", order: &order}
+	second := &testStatsObserver{testObserver{name: "second", order: &order}}
+	fs := newTestFileSystem(t, "test.txt", "hello")
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := FileServer(fs, "api/", "", false, nil, nil,
+		WithClock(clock), WithCache(CacheConfig{MemoryLimit: 1 << 20, DecompressedLimit: 1 << 20}), WithObserver(first), WithObserver(second))
+
+	serveTest(h, "GET", "/test.txt", "")
+	serveTest(h, "GET", "/missing.txt", "")
+
+	// Observers are started in order and ended in reverse order
+	assert.Equal([]string{"start first", "start second", "end second second", "end first second"}, order[:4])
+	require.Len(first.infos, 2)
+	assert.Equal(RequestInfo{Status: 200, Bytes: 5, Start: clock.now, End: clock.now, Entry: true}, first.infos[0])
+	assert.Equal(RequestInfo{Status: 404, Bytes: first.infos[1].Bytes, Start: clock.now, End: clock.now}, first.infos[1])
+
+	assert.Nil(first.stats)
+	require.NotNil(second.stats)
+	assert.Equal(Stats{Mounts: 1, Caches: []CacheStats{{Name: "memory", Hits: 0, Misses: 1}}}, second.stats())
+
+	w := serveTest(h, "GET", "/api/metrics", "")
+	assert.Equal(404, w.status)
+}
@@ -0,0 +1,146 @@
+package zipfs
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Option configures optional behaviour of the HTTP handler returned
+// by FileServerWithOptions, FileServer, FileServers and EmptyFileServer. Options are applied
+// in the order they are given, so later options win.
+type Option func(*fileHandler)
+
+func (h *fileHandler) applyOptions(opts []Option) {
+	// A pointer, so that shadowHandler can copy the handler
+	h.mountMutex = &sync.RWMutex{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+
+	// The archives given to the constructor are mounted from now on,
+	// according to the configured clock.
+	for _, fs := range h.fs {
+		h.setMountTimeLocked(fs)
+		if err := h.configureArchive(fs); err != nil {
+			h.logError("configureArchive", err)
+		}
+		go h.hashContents(fs)
+	}
+	h.attachObservers()
+	if h.expvarName != "" {
+		if err := h.publishExpvar(); err != nil {
+			h.logError("publishExpvar", err)
+		}
+	}
+}
+
+// FileServerWithOptions returns a HTTP handler that serves HTTP requests
+// with the contents of fs, configured by opts. Unlike FileServer, every
+// setting has a default, so only the settings that differ need to be
+// given. If fs is nil, nothing is served until an archive is mounted
+// through the API. The API endpoints are disabled unless WithAPIPrefix
+// is given.
+func FileServerWithOptions(fs *FileSystem, opts ...Option) http.Handler {
+	h := &fileHandler{}
+	if fs != nil {
+		h.fs = []*FileSystem{fs}
+	}
+	h.applyOptions(opts)
+	if h.baseAPIPath == "" {
+		h.noAPI = true
+	}
+
+	return h
+}
+
+// WithAPIPrefix serves the API endpoints, such as mountzip, under the
+// URL path prefix.
+func WithAPIPrefix(prefix string) Option {
+	return func(h *fileHandler) {
+		h.baseAPIPath = prefix
+	}
+}
+
+// WithIndexExtensions sets the extensions of the index files that are
+// served for directories, in order of preference. For example, "html"
+// serves index.html.
+func WithIndexExtensions(exts ...string) Option {
+	return func(h *fileHandler) {
+		h.indexExts = exts
+	}
+}
+
+// WithMimeTypes overrides the Content-Type of files by extension. The
+// keys are lowercase extensions including the dot, such as ".swf". The
+// key "default" sets the type of files whose extension has no known
+// type, see also WithContentSniffing.
+func WithMimeTypes(mimeExts map[string]string) Option {
+	return func(h *fileHandler) {
+		h.mimeExts = mimeExts
+	}
+}
+
+// WithVerbose logs more detail about mounting and serving archives.
+func WithVerbose(verbose bool) Option {
+	return func(h *fileHandler) {
+		h.isVerbose = verbose
+	}
+}
+
+// WithFileSystems serves the given archives in addition to those already
+// configured. Files are looked up in the archives in order.
+func WithFileSystems(fs ...*FileSystem) Option {
+	return func(h *fileHandler) {
+		h.fs = append(h.fs, fs...)
+	}
+}
+
+// WithMountDir restricts the archives that can be mounted through the
+// API to those within dir. Relative paths given to the API are relative
+// to dir.
+func WithMountDir(dir string) Option {
+	return func(h *fileHandler) {
+		h.baseMountDir = dir
+	}
+}
+
+// WithPHP runs PHP scripts with the php-cgi executable at phpPath. The
+// scripts of mounted archives are extracted to htdocsPath and run there.
+func WithPHP(phpPath string, htdocsPath string) Option {
+	return func(h *fileHandler) {
+		h.phpPath = phpPath
+		h.htdocsPath = htdocsPath
+	}
+}
+
+// WithOverrides serves files from the local directories bases in
+// preference to the files of the archives.
+func WithOverrides(bases ...string) Option {
+	return func(h *fileHandler) {
+		h.overrideBases = bases
+	}
+}
+
+// WithCompression sends deflated entries to clients that accept the
+// deflate content-encoding without decompressing them. The data of
+// ZIP entries is raw deflate without the zlib wrapper that the deflate
+// content-encoding calls for, which most, but not all, user agents
+// accept.
+func WithCompression(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.compression = enabled
+	}
+}
+
+// WithNotFoundHandler serves requests for files that do not exist with
+// handler, instead of a plain text 404 response. The page of
+// WithNotFoundPage takes precedence, and the misses are reported as
+// errors.
+func WithNotFoundHandler(handler http.Handler) Option {
+	return func(h *fileHandler) {
+		h.notFound = handler
+		h.notFoundFallback = false
+	}
+}
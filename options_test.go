@@ -0,0 +1,138 @@
+package zipfs
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileServerWithOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs, err := New("testdata/testdata.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	// The API is disabled unless it has a prefix
+	handler := FileServerWithOptions(fs, WithIndexExtensions("html"))
+	assert.Equal(200, serveTest(handler, "GET", "/", "").status)
+	assert.Equal(404, serveTest(handler, "GET", "/listmountzip", "").status)
+
+	handler = FileServerWithOptions(fs, WithAPIPrefix("api/"))
+	w := serveTest(handler, "GET", "/api/listmountzip", "")
+	assert.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "testdata.zip")
+
+	handler = FileServerWithOptions(nil)
+	assert.Equal(404, serveTest(handler, "GET", "/test.html", "").status)
+	handler = FileServerWithOptions(nil, WithFileSystems(fs))
+	assert.Equal(200, serveTest(handler, "GET", "/test.html", "").status)
+
+	handler = FileServerWithOptions(fs, WithMimeTypes(map[string]string{".html": "text/x-test"}))
+	assert.Equal("text/x-test", serveTest(handler, "GET", "/test.html", "").Header().Get("Content-Type"))
+}
+
+func TestWithNotFoundHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "index.html", "home")
+	defer fs.Close()
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "custom: "+r.URL.Path)
+	})
+	handler := FileServerWithOptions(fs, WithNotFoundHandler(notFound))
+
+	w := serveTest(handler, "GET", "/missing.html", "")
+	assert.Equal(404, w.status)
+	assert.Equal("custom: /missing.html", w.buf.String())
+
+	handler = FileServerWithOptions(nil, WithNotFoundHandler(notFound))
+	w = serveTest(handler, "GET", "/missing.html", "")
+	assert.Equal("custom: /missing.html", w.buf.String())
+}
+
+func TestWithCompression(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("compress me ", 1000)
+	fs := newTestFileSystem(t, "page.txt", content)
+	defer fs.Close()
+
+	for _, enabled := range []bool{false, true} {
+		w := serveTest(FileServerWithOptions(fs, WithCompression(enabled)), "GET", "/page.txt", "", "Accept-Encoding", "gzip, deflate")
+		require.Equal(200, w.status)
+
+		if !enabled {
+			assert.Equal("", w.Header().Get("Content-Encoding"))
+			assert.Equal(content, w.buf.String())
+			continue
+		}
+		assert.Equal("deflate", w.Header().Get("Content-Encoding"))
+		assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+		assert.True(w.buf.Len() < len(content))
+		data, err := io.ReadAll(flate.NewReader(bytes.NewReader(w.buf.Bytes())))
+		require.NoError(err)
+		assert.Equal(content, string(data))
+	}
+}
+
+func TestWithCompressionGzip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("compress me ", 1000)
+	fs := newTestFileSystem(t, "page.txt", content)
+	defer fs.Close()
+
+	w := serveTest(FileServerWithOptions(fs, WithCompression(true)), "GET", "/page.txt", "", "Accept-Encoding", "gzip")
+	require.Equal(200, w.status)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(fmt.Sprint(w.buf.Len()), w.Header().Get("Content-Length"))
+
+	// The gzip reader checks the CRC and size in the trailer
+	gr, err := gzip.NewReader(bytes.NewReader(w.buf.Bytes()))
+	require.NoError(err)
+	data, err := io.ReadAll(gr)
+	require.NoError(err)
+	assert.Equal(content, string(data))
+
+	head := serveTest(FileServerWithOptions(fs, WithCompression(true)), "HEAD", "/page.txt", "", "Accept-Encoding", "gzip")
+	assert.Equal(w.Header().Get("Content-Length"), head.Header().Get("Content-Length"))
+	assert.Equal(0, head.buf.Len())
+}
+
+func TestVaryAcceptEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "page.txt", strings.Repeat("compress me ", 1000))
+	defer fs.Close()
+
+	handler := FileServerWithOptions(fs, WithCompression(true))
+	w := serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "deflate")
+	assert.Equal("deflate", w.Header().Get("Content-Encoding"))
+	assert.Equal([]string{"Accept-Encoding"}, w.Header().Values("Vary"))
+	etag := w.Header().Get("Etag")
+
+	// Identity responses and 304 responses vary just the same
+	w = serveTest(handler, "GET", "/page.txt", "")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal([]string{"Accept-Encoding"}, w.Header().Values("Vary"))
+	w = serveTest(handler, "GET", "/page.txt", "", "Accept-Encoding", "deflate", "If-None-Match", etag)
+	assert.Equal(304, w.status)
+	assert.Equal([]string{"Accept-Encoding"}, w.Header().Values("Vary"))
+
+	// Nothing is negotiated without compression
+	w = serveTest(FileServerWithOptions(fs), "GET", "/page.txt", "", "Accept-Encoding", "deflate")
+	assert.Equal("", w.Header().Get("Vary"))
+}
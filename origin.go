@@ -0,0 +1,319 @@
+package zipfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// WithOrigin fetches files that are not in any mounted archive from the
+// origin server at the URL origin, and keeps a copy of each fetched file
+// in cacheDir. Later requests for the file are served from cacheDir, so
+// an edge server gradually builds a complete local copy of the content
+// of the origin. The first request for a file is streamed to the client
+// while it is being cached. If client is nil, http.DefaultClient is used.
+//
+// If cacheDir is empty, fetched files are kept in memory instead, in the
+// memory cache configured by WithCache or in a cache of their own, and
+// have to be fetched again once they are evicted.
+func WithOrigin(origin string, cacheDir string, client *http.Client) Option {
+	return func(h *fileHandler) {
+		base, err := url.Parse(origin)
+		if err != nil {
+			h.logError("WithOrigin", err)
+			return
+		}
+		if client == nil {
+			client = http.DefaultClient
+		}
+		h.origin = &originCache{base: base, dir: cacheDir, client: client}
+		if cacheDir == "" {
+			h.origin.memory = newCacheStore(CacheLRU, 0, originCacheSize, 0, "")
+		}
+	}
+}
+
+// originCacheSize is the size of the memory cache of fetched files used
+// when the file server has no memory cache configured.
+const originCacheSize = 64 * 1024 * 1024
+
+type originCache struct {
+	base   *url.URL
+	dir    string
+	client *http.Client
+	memory *cacheStore // Set if files are cached in memory
+}
+
+// originMeta holds the headers of a file cached in memory, which are
+// kept with its contents in the cache.
+type originMeta struct {
+	contentType string
+	modTime     time.Time
+}
+
+// serve serves name from the cache directory, fetching it from the
+// origin first if necessary. It reports whether the request was handled,
+// which it is not if the origin does not have the file either.
+func (o *originCache) serve(w http.ResponseWriter, r *http.Request, h *fileHandler, name string) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return false
+	}
+	if o.memory != nil {
+		return o.serveMemory(w, r, h, name)
+	}
+	localPath := filepath.Join(o.dir, filepath.FromSlash(name))
+
+	if o.serveLocal(w, r, localPath) {
+		return true
+	}
+
+	resp, handled := o.fetch(w, r, h, name)
+	if resp == nil {
+		return handled
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		h.logError("origin", err)
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(localPath), ".origin-")
+	if err != nil {
+		// The file can still be passed through without caching it.
+		h.logError("origin", err)
+		copyOriginHeaders(w, resp)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == "GET" {
+			io.Copy(w, resp.Body)
+		}
+		return true
+	}
+	defer os.Remove(tempFile.Name())
+
+	// Only a plain GET can be streamed to the client while it is cached.
+	// Anything else is answered from the cached file once it is complete.
+	streaming := r.Method == "GET" && r.Header.Get("Range") == ""
+	var dst io.Writer = tempFile
+	if streaming {
+		copyOriginHeaders(w, resp)
+		w.WriteHeader(http.StatusOK)
+		dst = io.MultiWriter(tempFile, w)
+	}
+	_, err = io.Copy(dst, resp.Body)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		h.logErrorf("origin", "Failed to cache %s: %w", name, err)
+		if !streaming {
+			recordError(r, name, err)
+			httpError(w, r, "502 Bad Gateway", http.StatusBadGateway)
+		}
+		return true
+	}
+
+	if modtime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(tempFile.Name(), modtime, modtime)
+	}
+	if err := os.Rename(tempFile.Name(), localPath); err != nil {
+		h.logErrorf("origin", "Failed to cache %s: %w", name, err)
+	} else if h.isVerbose {
+		h.logf("Cached from origin: %s\n", name)
+	}
+
+	if !streaming && !o.serveLocal(w, r, localPath) {
+		httpError(w, r, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// fetch requests name from the origin. If the origin does not answer
+// with the file, it returns a nil response and reports whether the
+// request has been answered with an error, which it is not if the
+// origin does not have the file either.
+func (o *originCache) fetch(w http.ResponseWriter, r *http.Request, h *fileHandler, name string) (*http.Response, bool) {
+	req, err := http.NewRequestWithContext(r.Context(), "GET", o.base.JoinPath(name).String(), nil)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		h.logError("origin", err)
+		recordError(r, name, err)
+		httpError(w, r, "502 Bad Gateway", http.StatusBadGateway)
+		return nil, true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+		return nil, false
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		h.logErrorf("origin", "%s: %s", name, resp.Status)
+		recordError(r, name, fmt.Errorf("origin returned %s", resp.Status))
+		httpError(w, r, "502 Bad Gateway", http.StatusBadGateway)
+		return nil, true
+	}
+	return resp, true
+}
+
+// serveMemory serves name from the memory cache, fetching it from the
+// origin first if necessary.
+func (o *originCache) serveMemory(w http.ResponseWriter, r *http.Request, h *fileHandler, name string) bool {
+	cache := o.memory
+	if h.memCache != nil {
+		cache = h.memCache
+	}
+	key := cacheKey{name: name, variant: "origin"}
+
+	if data, value, ok := cache.getWithMeta(key, h.now()); ok {
+		meta, _ := value.(originMeta)
+		if meta.contentType != "" {
+			w.Header().Set("Content-Type", meta.contentType)
+		}
+		markLookupDone(r)
+		http.ServeContent(w, r, path.Base(name), meta.modTime, bytes.NewReader(data))
+		return true
+	}
+
+	resp, handled := o.fetch(w, r, h, name)
+	if resp == nil {
+		return handled
+	}
+	defer resp.Body.Close()
+
+	// Like the files cached on disk, a plain GET is streamed to the
+	// client, but without keeping more than fits in the cache. Anything
+	// else is answered from memory once the file has been fetched, or by
+	// the origin if it does not fit.
+	streaming := r.Method == "GET" && r.Header.Get("Range") == ""
+	if !streaming && resp.ContentLength > cache.limit {
+		resp.Body.Close()
+		o.proxy(w, r, h, name)
+		return true
+	}
+	buf := &cappedBuffer{limit: cache.limit}
+	var dst io.Writer = buf
+	if streaming {
+		copyOriginHeaders(w, resp)
+		w.WriteHeader(http.StatusOK)
+		dst = io.MultiWriter(buf, w)
+	}
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		h.logErrorf("origin", "Failed to cache %s: %w", name, err)
+		if !streaming {
+			recordError(r, name, err)
+			httpError(w, r, "502 Bad Gateway", http.StatusBadGateway)
+		}
+		return true
+	}
+
+	meta := originMeta{contentType: resp.Header.Get("Content-Type")}
+	meta.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	if !buf.overflow {
+		if cache.putWithMeta(key, buf.Bytes(), meta, h.now()) && h.isVerbose {
+			h.logf("Cached from origin: %s\n", name)
+		}
+	}
+
+	if !streaming {
+		if buf.overflow {
+			o.proxy(w, r, h, name)
+			return true
+		}
+		if meta.contentType != "" {
+			w.Header().Set("Content-Type", meta.contentType)
+		}
+		markLookupDone(r)
+		http.ServeContent(w, r, path.Base(name), meta.modTime, bytes.NewReader(buf.Bytes()))
+	}
+	return true
+}
+
+// proxy passes the request for name on to the origin, with its method and
+// range, and passes the response back, for files that are too large to
+// be answered from memory.
+func (o *originCache) proxy(w http.ResponseWriter, r *http.Request, h *fileHandler, name string) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, o.base.JoinPath(name).String(), nil)
+	if err == nil {
+		for _, key := range []string{"Range", "If-Range"} {
+			if value := r.Header.Get(key); value != "" {
+				req.Header.Set(key, value)
+			}
+		}
+		var resp *http.Response
+		resp, err = o.client.Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			copyOriginHeaders(w, resp)
+			for _, key := range []string{"Content-Range", "Accept-Ranges"} {
+				if value := resp.Header.Get(key); value != "" {
+					w.Header().Set(key, value)
+				}
+			}
+			markLookupDone(r)
+			w.WriteHeader(resp.StatusCode)
+			if r.Method == "GET" {
+				io.Copy(w, resp.Body)
+			}
+			return
+		}
+	}
+	h.logError("origin", err)
+	recordError(r, name, err)
+	httpError(w, r, "502 Bad Gateway", http.StatusBadGateway)
+}
+
+// cappedBuffer is a bytes.Buffer that stops keeping data once more than
+// limit bytes have been written to it. Writes always succeed.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if int64(b.Len()+len(p)) > b.limit {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// serveLocal serves a cached file, and reports whether it exists.
+func (o *originCache) serveLocal(w http.ResponseWriter, r *http.Request, localPath string) bool {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		return false
+	}
+	markLookupDone(r)
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), file)
+	return true
+}
+
+func copyOriginHeaders(w http.ResponseWriter, resp *http.Response) {
+	for _, key := range []string{"Content-Type", "Content-Length", "Last-Modified"} {
+		if value := resp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package zipfs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrigin(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		switch r.URL.Path {
+		case "/content/remote.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("from origin"))
+		case "/content/broken.txt":
+			http.Error(w, "oops", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	fs := newTestFileSystem(t, "content/local.txt", "from archive")
+	defer fs.Close()
+	cacheDir := t.TempDir()
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithOrigin(origin.URL, cacheDir, nil))
+
+	// Files in the archive never reach the origin
+	w := serveTest(handler, "GET", "/content/local.txt", "")
+	assert.Equal(200, w.status)
+	assert.Equal("from archive", w.buf.String())
+	assert.Equal(int32(0), atomic.LoadInt32(&fetches))
+
+	w = serveTest(handler, "GET", "/content/remote.txt", "")
+	assert.Equal(200, w.status)
+	assert.Equal("from origin", w.buf.String())
+	assert.Equal("text/plain", w.Header().Get("Content-Type"))
+	data, err := os.ReadFile(filepath.Join(cacheDir, "content", "remote.txt"))
+	require.NoError(err)
+	assert.Equal("from origin", string(data))
+
+	// The second request is served from the cache
+	w = serveTest(handler, "GET", "/content/remote.txt", "", "Range", "bytes=5-")
+	assert.Equal(206, w.status)
+	assert.Equal("origin", w.buf.String())
+	assert.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	assert.Equal(404, serveTest(handler, "GET", "/content/missing.txt", "").status)
+	assert.Equal(502, serveTest(handler, "GET", "/content/broken.txt", "").status)
+	_, err = os.Stat(filepath.Join(cacheDir, "content", "broken.txt"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestOriginMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		switch r.URL.Path {
+		case "/remote.txt":
+			w.Header().Set("Content-Type", "text/x-custom")
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Write([]byte("from origin"))
+		case "/large.bin":
+			http.ServeContent(w, r, "large.bin", time.Time{}, bytes.NewReader(make([]byte, 2048)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	fs := newTestFileSystem(t, "local.txt", "from archive")
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithOrigin(origin.URL, "", nil),
+		WithCache(CacheConfig{MemoryLimit: 1024}))
+
+	w := serveTest(handler, "GET", "/remote.txt", "")
+	assert.Equal(200, w.status)
+	assert.Equal("from origin", w.buf.String())
+
+	// Repeated requests are served from memory, with the same headers
+	for i := 0; i < 2; i++ {
+		w = serveTest(handler, "GET", "/remote.txt", "", "Range", "bytes=5-")
+		assert.Equal(206, w.status)
+		assert.Equal("origin", w.buf.String())
+		assert.Equal("text/x-custom", w.Header().Get("Content-Type"))
+		assert.Equal("Mon, 02 Jan 2006 15:04:05 GMT", w.Header().Get("Last-Modified"))
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	// Files that do not fit in the cache are passed through every time
+	assert.Len(serveTest(handler, "GET", "/large.bin", "").buf.Bytes(), 2048)
+	assert.Len(serveTest(handler, "GET", "/large.bin", "").buf.Bytes(), 2048)
+	assert.Equal(int32(3), atomic.LoadInt32(&fetches))
+
+	// A range request for a file that does not fit in memory is passed
+	// on to the origin
+	w = serveTest(handler, "GET", "/large.bin", "", "Range", "bytes=0-9")
+	assert.Equal(206, w.status)
+	assert.Len(w.buf.Bytes(), 10)
+	assert.Equal("bytes 0-9/2048", w.Header().Get("Content-Range"))
+	assert.Equal(int32(5), atomic.LoadInt32(&fetches))
+
+	assert.Equal(404, serveTest(handler, "GET", "/missing.txt", "").status)
+}
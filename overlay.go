@@ -0,0 +1,77 @@
+package zipfs
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WithOverlayDir overlays the local directory dir on the mounted
+// archives: files that exist in dir are served from it instead of the
+// archives, and everything else is served from the archives as usual.
+// This allows individual files to be patched during testing without
+// rebuilding the archive.
+//
+// Unlike WithOverrides, paths are looked up as they are requested, and
+// cannot escape dir. Directories of the overlay are only served if they
+// contain an index file.
+func WithOverlayDir(dir string) Option {
+	return func(h *fileHandler) {
+		h.overlay = http.Dir(dir)
+	}
+}
+
+// serveOverlay serves the request from the overlay directory if it has
+// the file name, and reports whether it did.
+func (h *fileHandler) serveOverlay(w http.ResponseWriter, r *http.Request, name string) bool {
+	if h.overlay == "" {
+		return false
+	}
+	f, err := h.overlay.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	if stat.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Let the archives decide, as they do for their own
+			// directories
+			return false
+		}
+		found := false
+		for _, extension := range h.indexExts {
+			index, err := h.overlay.Open(path.Join(name, "index."+extension))
+			if err != nil {
+				continue
+			}
+			indexStat, err := index.Stat()
+			if err != nil || indexStat.IsDir() {
+				index.Close()
+				continue
+			}
+			defer index.Close()
+			f, stat, found = index, indexStat, true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+
+	markLookupDone(r)
+	if !h.checkMethod(w, r, false) {
+		return true
+	}
+	if mimeOverride, ok := h.mimeExts[strings.ToLower(filepath.Ext(stat.Name()))]; ok {
+		w.Header().Set("Content-Type", mimeOverride)
+	}
+	h.logf("Serving overlay file: %s\n", filepath.Join(string(h.overlay), filepath.FromSlash(name)))
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), f)
+	return true
+}
@@ -0,0 +1,45 @@
+package zipfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", "zip index",
+		"Game/Main.js", "zip main",
+		"game/data.json", "zip data",
+	)
+	defer fs.Close()
+
+	root := t.TempDir()
+	overlay := filepath.Join(root, "overlay")
+	require.NoError(os.MkdirAll(filepath.Join(overlay, "Game"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(overlay, "empty"), 0755))
+	require.NoError(os.WriteFile(filepath.Join(overlay, "Game", "Main.js"), []byte("patched main"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(overlay, "index.html"), []byte("patched index"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644))
+
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil, WithOverlayDir(overlay))
+
+	assert.Equal("patched main", serveTest(handler, "GET", "/Game/Main.js", "").buf.String())
+	assert.Equal("zip data", serveTest(handler, "GET", "/game/data.json", "").buf.String())
+	assert.Equal("patched index", serveTest(handler, "GET", "/", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/empty/", "").status)
+	assert.Equal(404, serveTest(handler, "GET", "/../secret.txt", "").status)
+	assert.Equal(404, serveTest(handler, "GET", "/%252e%252e/secret.txt", "").status)
+
+	w := serveTest(handler, "GET", "/Game/Main.js", "")
+	assert.Equal("text/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = serveTest(handler, "POST", "/Game/Main.js", "")
+	assert.Equal(405, w.status)
+}
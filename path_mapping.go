@@ -0,0 +1,44 @@
+package zipfs
+
+import (
+	"path"
+	"sort"
+)
+
+// WithPathMappings maps URL path prefixes to directories of the
+// archives, so that the URL layout can differ from the layout of the
+// archives. For example, {"/assets/": "content/static/"} serves
+// /assets/logo.png with content/static/logo.png. The longest matching
+// prefix applies, and paths that match no prefix are looked up as they
+// are. Prefixes are matched ignoring case, after the mount prefix of
+// the archive has been removed.
+func WithPathMappings(mappings map[string]string) Option {
+	return func(h *fileHandler) {
+		h.pathMappings = nil
+		for prefix, dir := range mappings {
+			h.pathMappings = append(h.pathMappings, pathMapping{
+				prefix: cleanMountPrefix(prefix),
+				dir:    path.Clean("/" + dir),
+			})
+		}
+		sort.Slice(h.pathMappings, func(i, j int) bool {
+			return len(h.pathMappings[i].prefix) > len(h.pathMappings[j].prefix)
+		})
+	}
+}
+
+type pathMapping struct {
+	prefix string // In the form used by stripMountPrefix
+	dir    string
+}
+
+// mapPath returns the path of the archive that the path name is mapped
+// to.
+func (h *fileHandler) mapPath(name string) string {
+	for _, mapping := range h.pathMappings {
+		if rest, ok := stripMountPrefix(mapping.prefix, name); ok {
+			return path.Join(mapping.dir, rest)
+		}
+	}
+	return name
+}
@@ -0,0 +1,38 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMappings(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", "root index",
+		"content/static/logo.png", "logo",
+		"content/static/img/icon.png", "icon",
+		"content/static/img/special/star.png", "star",
+		"content/pages/index.html", "pages index",
+	)
+	defer fs.Close()
+
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil, WithPathMappings(map[string]string{
+		"/assets/":         "content/static/",
+		"/assets/special/": "content/static/img/special",
+		"pages":            "/content/pages/",
+	}))
+
+	assert.Equal("logo", serveTest(handler, "GET", "/assets/logo.png", "").buf.String())
+	assert.Equal("icon", serveTest(handler, "GET", "/Assets/img/icon.png", "").buf.String())
+	assert.Equal("star", serveTest(handler, "GET", "/assets/special/star.png", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/assets/img/star.png", "").status)
+	assert.Equal("pages index", serveTest(handler, "GET", "/pages/", "").buf.String())
+	assert.Equal("root index", serveTest(handler, "GET", "/", "").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/logo.png", "").status)
+
+	w := serveTest(handler, "GET", "/pages", "")
+	assert.Equal(301, w.status)
+	assert.Equal("pages/", w.Header().Get("Location"))
+}
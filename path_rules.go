@@ -0,0 +1,85 @@
+package zipfs
+
+import (
+	"path"
+	"strings"
+)
+
+// WithPathRules restricts which URL paths are served. If allow is not
+// empty, only paths that match one of its patterns are served. Paths
+// that match one of the deny patterns are never served, even if they
+// are allowed. Rules are evaluated before any lookup, and blocked paths
+// are answered as if they did not exist.
+//
+// Patterns are absolute '/'-separated paths whose segments use the
+// syntax of path.Match. A "**" segment matches any number of segments,
+// including none, so "/admin/**" matches /admin and everything below it.
+// Like file lookups, matching ignores case. Malformed patterns never
+// match.
+func WithPathRules(allow []string, deny []string) Option {
+	return func(h *fileHandler) {
+		h.pathRules = &pathRules{
+			allow: splitPatterns(allow),
+			deny:  splitPatterns(deny),
+		}
+	}
+}
+
+type pathRules struct {
+	allow [][]string
+	deny  [][]string
+}
+
+// splitPatterns splits every pattern into its segments.
+func splitPatterns(patterns []string) [][]string {
+	split := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		split = append(split, splitSegments(strings.ToLower(pattern)))
+	}
+	return split
+}
+
+// pathAllowed reports whether the path rules allow name to be served.
+func (h *fileHandler) pathAllowed(name string) bool {
+	if h.pathRules == nil {
+		return true
+	}
+	segments := splitSegments(lookupName(name))
+	for _, pattern := range h.pathRules.deny {
+		if matchSegments(pattern, segments) {
+			return false
+		}
+	}
+	if len(h.pathRules.allow) == 0 {
+		return true
+	}
+	for _, pattern := range h.pathRules.allow {
+		if matchSegments(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments reports whether the path segments match the pattern
+// segments, where "**" matches any number of segments.
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
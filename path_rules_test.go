@@ -0,0 +1,66 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathRules(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		".zipfsrc", `{"autoIndex": true}`,
+		"public/index.txt", "index",
+		"public/css/site.css", "css",
+		"public/admin/panel.txt", "panel",
+		"public/notes.bak", "backup",
+		"private/key.txt", "secret",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithDirConfig(), WithPathRules([]string{"/public/**"}, []string{"/**/admin/**", "/**/*.bak"}))
+
+	for _, target := range []string{"/public/index.txt", "/public/css/site.css", "/public/", "/Public/Index.txt"} {
+		assert.Equal(200, serveTest(handler, "GET", target, "").status, target)
+	}
+	for _, target := range []string{
+		"/private/key.txt",
+		"/",
+		"/public/admin/",
+		"/public/admin/panel.txt",
+		"/public/notes.bak",
+		"/PUBLIC/Admin/panel.txt",
+		"/public/%2561dmin/panel.txt",
+	} {
+		assert.Equal(404, serveTest(handler, "GET", target, "").status, target)
+	}
+
+	w := serveTest(handler, "GET", "/public/", "")
+	assert.Contains(w.buf.String(), "index.txt")
+	assert.NotContains(w.buf.String(), "admin")
+	assert.NotContains(w.buf.String(), "notes.bak")
+}
+
+func TestMatchSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"/admin/**", "/admin", true},
+		{"/admin/**", "/admin/a/b", true},
+		{"/admin/**", "/administrator", false},
+		{"/**", "/", true},
+		{"/**/*.bak", "/a.bak", true},
+		{"/**/*.bak", "/a/b/c.bak", true},
+		{"/*/index.html", "/a/index.html", true},
+		{"/*/index.html", "/a/b/index.html", false},
+		{"/[bad", "/[bad", false},
+	}
+	for _, test := range tests {
+		assert.Equal(test.match, matchSegments(splitSegments(test.pattern), splitSegments(test.name)), test.pattern+" "+test.name)
+	}
+}
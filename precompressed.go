@@ -0,0 +1,104 @@
+package zipfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WithPrecompressed serves entries that have a precompressed sibling in
+// the archive, such as app.js.br or app.js.gz next to app.js, with the
+// sibling and the matching Content-Encoding when the client accepts it.
+// The encoding with the highest q-value is chosen, and brotli is
+// preferred over gzip when they are equal. Clients that accept neither
+// are served the original entry.
+func WithPrecompressed(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.precompressed = enabled
+	}
+}
+
+// precompressedEncodings are the suffixes of precompressed siblings, in
+// order of preference.
+var precompressedEncodings = []struct {
+	ext      string
+	encoding string
+}{
+	{ext: ".br", encoding: "br"},
+	{ext: ".gz", encoding: "gzip"},
+}
+
+// servePrecompressed serves a precompressed sibling of fi if there is
+// one the client accepts, and reports whether it did.
+func (h *fileHandler) servePrecompressed(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, defaultMime *string) bool {
+	if h.phpPath != "" && checkForPhp(fi.name) {
+		return false
+	}
+
+	variants := map[string]*fileInfo{}
+	var encodings []string
+	for _, enc := range precompressedEncodings {
+		variant, err := h.openServable(fs, fi.name+enc.ext)
+		if err != nil || variant.IsDir() {
+			continue
+		}
+		variants[enc.encoding] = variant
+		encodings = append(encodings, enc.encoding)
+	}
+	if len(encodings) == 0 {
+		return false
+	}
+
+	encoding := parseAcceptEncoding(r).preferred(encodings...)
+	if encoding == "" {
+		// The response depends on Accept-Encoding even when the
+		// original entry is served.
+		addVary(w.Header(), "Accept-Encoding")
+		return false
+	}
+	h.serveVariant(w, r, fi, variants[encoding], encoding, defaultMime)
+	return true
+}
+
+// serveVariant serves the entry variant, which holds the contents of fi
+// in the given content-encoding.
+func (h *fileHandler) serveVariant(w http.ResponseWriter, r *http.Request, fi *fileInfo, variant *fileInfo, encoding string, defaultMime *string) {
+	addVary(w.Header(), "Accept-Encoding")
+	modtime := h.lastModified(variant)
+
+	// Every variant has its own ETag, so that caches do not mix them up.
+	w.Header().Set("Etag", h.entryEtag(variant))
+	rangeReq, done := checkPreconditions(w, r, modtime)
+	if done {
+		return
+	}
+
+	setContentType(w, fi.Name(), defaultMime)
+	w.Header().Set("Content-Encoding", encoding)
+	if rangeReq != "" {
+		sr := newSkipReader(variant, variant.Size())
+		defer sr.Close()
+		serveRanges(w, r, fi.Name(), modtime, variant.Size(), sr)
+		return
+	}
+
+	reader, err := variant.open()
+	if err != nil {
+		recordError(r, variant.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return
+	}
+	defer reader.Close()
+
+	size := variant.Size()
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	if r.Method != "HEAD" {
+		sw, finish := h.streamWriter(w)
+		defer finish()
+		if _, err := io.CopyN(sw, h.verified(variant, reader), size); err == errChecksum {
+			h.abortCorrupt(r, variant, err)
+		}
+	}
+	h.logf("[Zipfs] Serving Precompressed File: %s\n", variant.name)
+}
@@ -0,0 +1,58 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecompressed(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"app.js", "original",
+		"app.js.br", "brotli",
+		"app.js.gz", "gzipped",
+		"style.css", "plain",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithPrecompressed(true))
+
+	testCases := []struct {
+		AcceptEncoding  string
+		ContentEncoding string
+		Body            string
+	}{
+		{AcceptEncoding: "gzip, deflate, br", ContentEncoding: "br", Body: "brotli"},
+		{AcceptEncoding: "gzip", ContentEncoding: "gzip", Body: "gzipped"},
+		{AcceptEncoding: "", ContentEncoding: "", Body: "original"},
+		{AcceptEncoding: "br;q=0.5, gzip", ContentEncoding: "gzip", Body: "gzipped"},
+		{AcceptEncoding: "br;q=0, *", ContentEncoding: "gzip", Body: "gzipped"},
+		{AcceptEncoding: "gzip;q=0", ContentEncoding: "", Body: "original"},
+	}
+	etags := map[string]bool{}
+	for _, tc := range testCases {
+		w := serveTest(handler, "GET", "/app.js", "", "Accept-Encoding", tc.AcceptEncoding)
+		assert.Equal(200, w.status, tc.AcceptEncoding)
+		assert.Equal(tc.ContentEncoding, w.Header().Get("Content-Encoding"), tc.AcceptEncoding)
+		assert.Equal(tc.Body, w.buf.String(), tc.AcceptEncoding)
+		assert.Equal([]string{"Accept-Encoding"}, w.Header().Values("Vary"), tc.AcceptEncoding)
+		assert.Contains(w.Header().Get("Content-Type"), "javascript", tc.AcceptEncoding)
+		etags[w.Header().Get("Etag")] = true
+	}
+	assert.Len(etags, 3)
+
+	// Siblings can still be requested directly
+	w := serveTest(handler, "GET", "/app.js.gz", "", "Accept-Encoding", "br")
+	assert.Equal("gzipped", w.buf.String())
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+
+	// Entries without siblings are not affected
+	w = serveTest(handler, "GET", "/style.css", "", "Accept-Encoding", "br")
+	assert.Equal("plain", w.buf.String())
+	assert.Equal("", w.Header().Get("Vary"))
+
+	// Disabled by default
+	w = serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/app.js", "", "Accept-Encoding", "br")
+	assert.Equal("original", w.buf.String())
+}
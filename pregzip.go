@@ -0,0 +1,79 @@
+package zipfs
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gzipPregen holds the options of WithGzipPregeneration.
+type gzipPregen struct {
+	minSize int64
+	maxSize int64
+}
+
+// WithGzipPregeneration compresses the entries of archives mounted
+// through the mountZIP API endpoint, or reloaded by WithAutoReload, in
+// the background, so that the first request for each of them does not
+// wait for it. Only the entries that WithGzip would compress are, and of
+// those only the ones of at least minSize bytes and at most maxSize
+// bytes, or whatever their size if maxSize is 0. The variants are kept in
+// the cache of WithTranscodeCache, so both options must be given as well
+// for it to have any effect. Entries are compressed one at a time, and
+// compressing stops when the archive is unmounted.
+func WithGzipPregeneration(minSize int64, maxSize int64) Option {
+	return func(h *fileHandler) {
+		h.gzipPregen = &gzipPregen{minSize: minSize, maxSize: maxSize}
+	}
+}
+
+// pregenerateGzip compresses the eligible entries of the mounted archive
+// fs into the transcode cache, see WithGzipPregeneration.
+func (h *fileHandler) pregenerateGzip(fs *FileSystem) {
+	p := h.gzipPregen
+	if p == nil || h.gzip == nil || h.transcodeCache == nil {
+		return
+	}
+	var defaultMime *string
+	if mime, ok := h.mimeExts["default"]; ok {
+		defaultMime = &mime
+	}
+	for _, fi := range fs.fileInfos {
+		if fi.IsDir() || fi.Size() < p.minSize || p.maxSize > 0 && fi.Size() > p.maxSize {
+			continue
+		}
+		header := http.Header{}
+		if mime, ok := h.mimeExts[strings.ToLower(filepath.Ext(path.Base(fi.Name())))]; ok {
+			header.Set("Content-Type", mime)
+		}
+		if !h.gzipEligible(header, fi, defaultMime) {
+			continue
+		}
+		name, ok := transcodeFileName(fi, h.gzip.variant())
+		if !ok {
+			continue
+		}
+		if !h.holdMounted(fs, fs) {
+			return
+		}
+
+		file, temporary, err := h.transcodeCache.openFile(name, h.now(), func(dst io.Writer) error {
+			return h.gzip.compress(dst, fi)
+		})
+		fs.inFlight.Done()
+		if err != nil {
+			h.logError("pregenerateGzip", err)
+			continue
+		}
+		file.Close()
+		if temporary {
+			os.Remove(file.Name())
+		}
+	}
+	if h.isVerbose {
+		h.logf("Gzip Pregenerated: %s\n", fs.givenPath)
+	}
+}
@@ -0,0 +1,67 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipPregeneration(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	page := strings.Repeat("pregenerated ", 500)
+	f, err := os.Create(filepath.Join(dir, "site.zip"))
+	require.NoError(err)
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"page.html":  page,
+		"small.css":  "body {}",
+		"image.png":  strings.Repeat("\x89PNG", 1000),
+		"large.html": strings.Repeat("too large ", 1000),
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		require.NoError(err)
+		w.Write([]byte(content))
+	}
+	require.NoError(zw.Close())
+	require.NoError(f.Close())
+
+	cacheDir := t.TempDir()
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(),
+		WithGzip(gzip.BestSpeed),
+		WithTranscodeCache(cacheDir, 1<<20),
+		WithGzipPregeneration(100, 8000),
+	)
+	mountTestZip(t, h, `{"filePath": "site.zip"}`)
+
+	// Only the text entry within the size bounds is compressed
+	want := fmt.Sprintf("%08x-%x.gz%d", crc32.ChecksumIEEE([]byte(page)), len(page), gzip.BestSpeed)
+	files := func() []string {
+		entries, _ := os.ReadDir(cacheDir)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	assert.Eventually(func() bool {
+		return len(files()) > 0 && files()[0] == want
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal([]string{want}, files())
+
+	w := serveTest(h, "GET", "/page.html", "", "Accept-Encoding", "gzip")
+	assert.Equal(200, w.status)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+}
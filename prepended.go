@@ -0,0 +1,75 @@
+package zipfs
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	directoryEndSig       = 0x06054b50
+	directoryEndLen       = 22
+	directory64EndSig     = 0x06064b50
+	directory64EndLen     = 56
+	directory64LocatorSig = 0x07064b50
+	directory64LocatorLen = 20
+)
+
+// prependedZip64Offset returns the offset in r of a Zip64 file that has
+// data before it, such as the executable of a self-extracting archive.
+// The offsets in a Zip file are from its own start, so the reader must
+// know where it starts to find its entries. archive/zip works this out
+// from the end of central directory record, but not when it is preceded
+// by a Zip64 one, whose offset it trusts to be from the start of r. It
+// is not ok if r is not such a file.
+func prependedZip64Offset(r io.ReaderAt, size int64) (int64, bool) {
+	end := findDirectoryEnd(r, size)
+	if end < directory64LocatorLen {
+		return 0, false
+	}
+	locator := make([]byte, directory64LocatorLen)
+	if _, err := r.ReadAt(locator, end-directory64LocatorLen); err != nil ||
+		binary.LittleEndian.Uint32(locator) != directory64LocatorSig {
+		return 0, false
+	}
+	recorded := int64(binary.LittleEndian.Uint64(locator[8:]))
+
+	// The Zip64 end of central directory record directly precedes the
+	// locator. Its size is only larger than usual if it has extensible
+	// data, which is rare, so look for the signature further back too.
+	record := make([]byte, 12)
+	for pos := end - directory64LocatorLen - directory64EndLen; pos >= 0 && pos > end-64*1024; pos-- {
+		if _, err := r.ReadAt(record, pos); err != nil {
+			return 0, false
+		}
+		if binary.LittleEndian.Uint32(record) != directory64EndSig ||
+			pos+12+int64(binary.LittleEndian.Uint64(record[4:])) != end-directory64LocatorLen {
+			continue
+		}
+		if base := pos - recorded; base > 0 {
+			return base, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// findDirectoryEnd returns the offset of the end of central directory
+// record in r, which is followed by a comment of up to 64 KiB, or -1 if
+// there is none.
+func findDirectoryEnd(r io.ReaderAt, size int64) int64 {
+	start := size - directoryEndLen - 0xffff
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, size-start)
+	if _, err := r.ReadAt(buf, start); err != nil && err != io.EOF {
+		return -1
+	}
+	for i := len(buf) - directoryEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == directoryEndSig &&
+			i+directoryEndLen+int(binary.LittleEndian.Uint16(buf[i+20:])) <= len(buf) {
+			return start + int64(i)
+		}
+	}
+	return -1
+}
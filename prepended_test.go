@@ -0,0 +1,88 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withZip64End returns the Zip file data with its end of central
+// directory record replaced by Zip64 records, as written for archives
+// too large for the original ones.
+func withZip64End(t *testing.T, data []byte) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	end := findDirectoryEnd(bytes.NewReader(data), int64(len(data)))
+	require.True(t, end > 0)
+	records := uint64(le.Uint16(data[end+10:]))
+	dirSize := uint64(le.Uint32(data[end+12:]))
+	dirOffset := uint64(le.Uint32(data[end+16:]))
+
+	out := append([]byte(nil), data[:end]...)
+	out = le.AppendUint32(out, directory64EndSig)
+	out = le.AppendUint64(out, directory64EndLen-12)
+	out = le.AppendUint16(out, 45)
+	out = le.AppendUint16(out, 45)
+	out = le.AppendUint64(out, 0)
+	out = le.AppendUint64(out, records)
+	out = le.AppendUint64(out, records)
+	out = le.AppendUint64(out, dirSize)
+	out = le.AppendUint64(out, dirOffset)
+	out = le.AppendUint32(out, directory64LocatorSig)
+	out = le.AppendUint32(out, 0)
+	out = le.AppendUint64(out, uint64(end))
+	out = le.AppendUint32(out, 1)
+	out = le.AppendUint32(out, directoryEndSig)
+	out = le.AppendUint32(out, 0)
+	out = le.AppendUint16(out, 0xffff)
+	out = le.AppendUint16(out, 0xffff)
+	out = le.AppendUint32(out, 0xffffffff)
+	out = le.AppendUint32(out, 0xffffffff)
+	return le.AppendUint16(out, 0)
+}
+
+func TestPrependedData(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "stored.txt", Method: zip.Store})
+	require.NoError(err)
+	w.Write([]byte("0123456789"))
+	w, err = zw.Create("deflated.txt")
+	require.NoError(err)
+	w.Write([]byte("deflated"))
+	require.NoError(zw.Close())
+	stub := bytes.Repeat([]byte("MZ self-extractor "), 100)
+
+	for name, data := range map[string][]byte{
+		"zip":   append(append([]byte(nil), stub...), buf.Bytes()...),
+		"zip64": append(append([]byte(nil), stub...), withZip64End(t, buf.Bytes())...),
+	} {
+		fs, err := NewFromBytes(data, name)
+		require.NoError(err, name)
+		for entry, want := range map[string]string{"stored.txt": "0123456789", "deflated.txt": "deflated"} {
+			f, err := fs.Open(entry)
+			require.NoError(err, name)
+			got, err := io.ReadAll(f)
+			assert.NoError(err, name)
+			assert.Equal(want, string(got), name)
+			f.Close()
+		}
+
+		// Stored entries are read in place
+		rw := serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/stored.txt", "", "Range", "bytes=2-4")
+		assert.Equal(206, rw.status, name)
+		assert.Equal("234", rw.buf.String(), name)
+		fs.Close()
+	}
+
+	_, ok := prependedZip64Offset(bytes.NewReader(withZip64End(t, buf.Bytes())), int64(buf.Len()+directory64EndLen+directory64LocatorLen))
+	assert.False(ok)
+}
@@ -0,0 +1,251 @@
+package zipfs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// QuotaAction is what happens to a client that exceeds its byte quota.
+type QuotaAction int
+
+const (
+	// QuotaReject answers requests from the client with
+	// 429 Too Many Requests until it is back under its quota.
+	QuotaReject QuotaAction = iota
+
+	// QuotaThrottle keeps serving the client, but limits it to an
+	// average of limit/window bytes per second until it is back under
+	// its quota.
+	QuotaThrottle
+)
+
+// quotaSlots is the number of buckets the sliding window is divided into.
+const quotaSlots = 10
+
+// WithByteQuota limits the number of decompressed bytes served to each
+// client IP address within a sliding window. It protects against clients
+// that repeatedly request huge entries. An entry counts the uncompressed
+// size of the range served, even if it is sent deflated, gzipped or with
+// another content-encoding, so that compression does not raise the
+// quota. Other responses count the bytes of their bodies. Headers, and
+// responses without a body such as 304 Not Modified, count nothing. The
+// client address is
+// taken from the request's RemoteAddr, so a server behind a reverse proxy
+// must set RemoteAddr to the real client address before calling the
+// file server.
+func WithByteQuota(limit int64, window time.Duration, action QuotaAction) Option {
+	return func(h *fileHandler) {
+		h.quota = &byteQuota{
+			limit:   limit,
+			window:  window,
+			action:  action,
+			clients: map[string]*quotaUsage{},
+		}
+	}
+}
+
+type byteQuota struct {
+	limit     int64
+	window    time.Duration
+	action    QuotaAction
+	mutex     sync.Mutex
+	clients   map[string]*quotaUsage
+	lastPrune time.Time
+}
+
+// quotaUsage counts the bytes served to a client in each slot of the
+// window. A slot is only valid if its ID is within the current window.
+type quotaUsage struct {
+	bytes [quotaSlots]int64
+	ids   [quotaSlots]int64
+}
+
+func (q *byteQuota) slotID(now time.Time) int64 {
+	slot := int64(q.window / quotaSlots)
+	if slot <= 0 {
+		slot = 1
+	}
+	return now.UnixNano() / slot
+}
+
+// used returns the bytes served to the client in the current window
+// and how long it will take for the oldest of them to leave the window.
+func (q *byteQuota) used(ip string, now time.Time) (int64, time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	u := q.clients[ip]
+	if u == nil {
+		return 0, 0
+	}
+
+	id := q.slotID(now)
+	var total int64
+	oldest := id
+	for i := range u.bytes {
+		if u.ids[i] > id-quotaSlots && u.bytes[i] > 0 {
+			total += u.bytes[i]
+			if u.ids[i] < oldest {
+				oldest = u.ids[i]
+			}
+		}
+	}
+	if total == 0 {
+		delete(q.clients, ip)
+		return 0, 0
+	}
+
+	slot := q.window / quotaSlots
+	expires := time.Unix(0, (oldest+quotaSlots)*int64(slot))
+	return total, expires.Sub(now)
+}
+
+func (q *byteQuota) add(ip string, n int64, now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	u := q.clients[ip]
+	if u == nil {
+		u = &quotaUsage{}
+		q.clients[ip] = u
+	}
+	id := q.slotID(now)
+	i := id % quotaSlots
+	if u.ids[i] != id {
+		u.ids[i] = id
+		u.bytes[i] = 0
+	}
+	u.bytes[i] += n
+}
+
+// prune removes clients that have not been served anything in the
+// current window. It does nothing if the clients have already been
+// pruned within the last window.
+func (q *byteQuota) prune(now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if now.Sub(q.lastPrune) < q.window {
+		return
+	}
+	q.lastPrune = now
+
+	id := q.slotID(now)
+	for ip, u := range q.clients {
+		active := false
+		for i := range u.ids {
+			if u.ids[i] > id-quotaSlots && u.bytes[i] > 0 {
+				active = true
+				break
+			}
+		}
+		if !active {
+			delete(q.clients, ip)
+		}
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// quotaCharge holds the uncompressed size of the entry served for a
+// request, see chargeEntry.
+type quotaCharge struct {
+	size    int64 // Negative unless an entry is served
+	charged bool
+}
+
+// chargeEntry makes the byte quota count the uncompressed size of fi
+// for the response to r when it is sent with a content-encoding, instead
+// of the bytes written. Responses without one are ranges of fi, or all of
+// it, whose bytes are counted as they are written.
+func chargeEntry(r *http.Request, fi *fileInfo) {
+	if c, ok := r.Context().Value(quotaChargeKey).(*quotaCharge); ok {
+		c.size = fi.Size()
+	}
+}
+
+// quotaWriter counts the bytes written to a client against its quota,
+// and paces the writes of throttled clients.
+type quotaWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	quota  *byteQuota
+	clock  Clock
+	ip     string
+	charge *quotaCharge
+}
+
+func (w *quotaWriter) Write(b []byte) (int, error) {
+	if w.quota.action == QuotaThrottle {
+		if used, _ := w.quota.used(w.ip, w.clock.Now()); used >= w.quota.limit {
+			rate := float64(w.quota.limit) / w.quota.window.Seconds()
+			delay := time.Duration(float64(len(b)) / rate * float64(time.Second))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-w.ctx.Done():
+				timer.Stop()
+				return 0, w.ctx.Err()
+			}
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	if w.charge.size < 0 || w.Header().Get("Content-Encoding") == "" {
+		w.quota.add(w.ip, int64(n), w.clock.Now())
+	} else if !w.charge.charged {
+		w.charge.charged = true
+		w.quota.add(w.ip, w.charge.size, w.clock.Now())
+	}
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *quotaWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withByteQuota enforces the byte quota of the client.
+func (h *fileHandler) withByteQuota(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		now := h.now()
+
+		used, retryAfter := h.quota.used(ip, now)
+		if used >= h.quota.limit && h.quota.action == QuotaReject {
+			err := fmt.Errorf("client %s exceeded byte quota (%d of %d bytes)", ip, used, h.quota.limit)
+			recordError(r, "", err)
+			seconds := int64(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+			http.Error(w, "429 Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		charge := &quotaCharge{size: -1}
+		r = r.WithContext(context.WithValue(r.Context(), quotaChargeKey, charge))
+		next(&quotaWriter{
+			ResponseWriter: w,
+			ctx:            r.Context(),
+			quota:          h.quota,
+			clock:          h.timeSource(),
+			ip:             ip,
+			charge:         charge,
+		}, r)
+
+		h.quota.prune(h.now())
+	}
+}
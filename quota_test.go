@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestByteQuota(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "big.txt", strings.Repeat("x", 600))
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithClock(clock),
+		WithByteQuota(1000, time.Minute, QuotaReject),
+	)
+
+	assert.Equal(200, serveTest(fromRemoteAddr(handler, "10.0.0.1:1234"), "GET", "/big.txt", "").status)
+	assert.Equal(200, serveTest(fromRemoteAddr(handler, "10.0.0.1:1235"), "GET", "/big.txt", "").status)
+
+	w := serveTest(fromRemoteAddr(handler, "10.0.0.1:1236"), "GET", "/big.txt", "")
+	assert.Equal(429, w.status)
+	assert.Equal("60", w.Header().Get("Retry-After"))
+
+	// Other clients are not affected
+	assert.Equal(200, serveTest(fromRemoteAddr(handler, "10.0.0.2:1234"), "GET", "/big.txt", "").status)
+
+	// The window slides
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(429, serveTest(fromRemoteAddr(handler, "10.0.0.1:1236"), "GET", "/big.txt", "").status)
+	clock.now = clock.now.Add(31 * time.Second)
+	assert.Equal(200, serveTest(fromRemoteAddr(handler, "10.0.0.1:1236"), "GET", "/big.txt", "").status)
+
+	// Compressed responses count the uncompressed size of the entry
+	handler = FileServer(fs, "api/", "", false, nil, nil,
+		WithClock(clock),
+		WithCompression(true),
+		WithByteQuota(1000, time.Minute, QuotaReject),
+	)
+	client := fromRemoteAddr(handler, "10.0.0.3:1234")
+	for _, encoding := range []string{"deflate", "gzip"} {
+		w := serveTest(client, "GET", "/big.txt", "", "Accept-Encoding", encoding)
+		assert.Equal(200, w.status)
+		assert.Equal(encoding, w.Header().Get("Content-Encoding"))
+		assert.Less(w.buf.Len(), 600)
+	}
+	assert.Equal(429, serveTest(client, "GET", "/big.txt", "", "Accept-Encoding", "deflate").status)
+
+	// Ranges count the bytes of the range
+	handler = FileServer(fs, "api/", "", false, nil, nil,
+		WithClock(clock),
+		WithByteQuota(1000, time.Minute, QuotaReject),
+	)
+	client = fromRemoteAddr(handler, "10.0.0.4:1234")
+	for i := 0; i < 9; i++ {
+		assert.Equal(206, serveTest(client, "GET", "/big.txt", "", "Range", "bytes=0-99").status)
+	}
+	assert.Equal(200, serveTest(client, "GET", "/big.txt", "").status)
+	assert.Equal(429, serveTest(client, "GET", "/big.txt", "").status)
+}
+
+func TestByteQuotaThrottle(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "big.txt", strings.Repeat("x", 600))
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithClock(clock),
+		WithByteQuota(1000, 100*time.Millisecond, QuotaThrottle),
+	)
+
+	client := fromRemoteAddr(handler, "10.0.0.1:1234")
+	serveTest(client, "GET", "/big.txt", "")
+	serveTest(client, "GET", "/big.txt", "")
+	// Over quota: 600 bytes at 10000 bytes per second take 60ms
+	start := time.Now()
+	w := serveTest(client, "GET", "/big.txt", "")
+	elapsed := time.Since(start)
+	assert.Equal(200, w.status)
+	assert.Equal(600, w.buf.Len())
+	assert.True(elapsed >= 50*time.Millisecond, elapsed)
+}
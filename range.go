@@ -0,0 +1,110 @@
+package zipfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveRanges serves a range request for content of the given size with
+// http.ServeContent, with the following changes to follow RFC 9110:
+// Range headers with units other than bytes are ignored, rather than
+// answered with 416 Range Not Satisfiable, and every 416 response has a
+// "Content-Range: bytes */size" header.
+func serveRanges(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, size int64, content io.ReadSeeker) {
+	if rangeReq := r.Header.Get("Range"); rangeReq != "" && !strings.HasPrefix(rangeReq, "bytes=") {
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+	}
+	http.ServeContent(&rangeWriter{ResponseWriter: w, size: size}, r, name, modtime, content)
+}
+
+// rangeWriter adds the Content-Range header to 416 responses.
+type rangeWriter struct {
+	http.ResponseWriter
+	size int64
+}
+
+func (w *rangeWriter) WriteHeader(code int) {
+	if code == http.StatusRequestedRangeNotSatisfiable && w.Header().Get("Content-Range") == "" {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", w.size))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *rangeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var errNegativeSeek = errors.New("zipfs: negative position")
+
+// skipReader is an io.ReadSeeker over the decompressed contents of a
+// zip entry. Seeking is done by decompressing and discarding everything
+// before the new position, reopening the entry to seek backwards. This
+// allows range requests for compressed entries to be served without a
+// temporary copy of the entry.
+type skipReader struct {
+	fi     *fileInfo
+	size   int64
+	reader io.ReadCloser
+	pos    int64 // Position of reader in the contents
+	offset int64 // Position set by Seek
+}
+
+func newSkipReader(fi *fileInfo, size int64) *skipReader {
+	return &skipReader{fi: fi, size: size}
+}
+
+func (s *skipReader) Read(p []byte) (int, error) {
+	if s.reader == nil || s.offset < s.pos {
+		if s.reader != nil {
+			s.reader.Close()
+		}
+		reader, err := s.fi.open()
+		if err != nil {
+			s.reader = nil
+			return 0, err
+		}
+		s.reader = reader
+		s.pos = 0
+	}
+	if s.offset > s.pos {
+		n, err := io.CopyN(io.Discard, s.reader, s.offset-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.reader.Read(p)
+	s.pos += int64(n)
+	s.offset = s.pos
+	return n, err
+}
+
+func (s *skipReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 {
+		return 0, errNegativeSeek
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *skipReader) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	err := s.reader.Close()
+	s.reader = nil
+	return err
+}
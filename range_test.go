@@ -0,0 +1,179 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var sb strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, "%05d\n", i)
+	}
+	content := sb.String()
+	fs := newTestFileSystem(t, "numbers.txt", content)
+	defer fs.Close()
+	fi, err := fs.openFileInfo("numbers.txt")
+	require.NoError(err)
+
+	sr := newSkipReader(fi, fi.Size())
+	defer sr.Close()
+	read := func(offset int64, n int) string {
+		pos, err := sr.Seek(offset, io.SeekStart)
+		require.NoError(err)
+		assert.Equal(offset, pos)
+		b := make([]byte, n)
+		_, err = io.ReadFull(sr, b)
+		require.NoError(err)
+		return string(b)
+	}
+
+	assert.Equal("10000\n", read(60000, 6))
+	assert.Equal("10001\n", read(60006, 6))
+	assert.Equal("00001\n", read(6, 6)) // Seeking backwards
+	size, err := sr.Seek(0, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(len(content)), size)
+	_, err = sr.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+	_, err = sr.Seek(-1, io.SeekStart)
+	assert.Error(err)
+}
+
+func TestRangeDeflated(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("0123456789", 10000)
+	fs := newTestFileSystem(t, "video.mp4", content)
+	defer fs.Close()
+	require.Equal(zip.Deflate, fs.reader.File[0].Method)
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+
+	w := serveTest(handler, "GET", "/video.mp4", "", "Range", "bytes=50000-50009")
+	require.Equal(206, w.status)
+	assert.Equal("0123456789", w.buf.String())
+	assert.Equal("bytes 50000-50009/100000", w.Header().Get("Content-Range"))
+	etag := w.Header().Get("Etag")
+
+	w = serveTest(handler, "GET", "/video.mp4", "", "Range", "bytes=99995-", "If-Range", etag)
+	assert.Equal(206, w.status)
+	assert.Equal("56789", w.buf.String())
+
+	// A stale If-Range gets the whole entry
+	w = serveTest(handler, "GET", "/video.mp4", "", "Range", "bytes=99995-", "If-Range", `"stale"`)
+	assert.Equal(200, w.status)
+	assert.Equal(content, w.buf.String())
+
+	// Ranges out of order need the entry to be reopened
+	w = serveTest(handler, "GET", "/video.mp4", "", "Range", "bytes=90000-90002,3-5")
+	assert.Equal(206, w.status)
+	assert.Contains(w.Header().Get("Content-Type"), "multipart/byteranges")
+	assert.Contains(w.buf.String(), "\r\n\r\n012\r\n")
+	assert.Contains(w.buf.String(), "\r\n\r\n345\r\n")
+}
+
+func TestMultipartRanges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("abcdefghij", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("level%d.dat", method), Method: method})
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	mimeExts := map[string]string{".dat": "application/x-level"}
+	handlers := []http.Handler{
+		FileServer(fs, "api/", "", false, nil, mimeExts),
+		FileServer(fs, "api/", "", false, nil, mimeExts, WithCache(CacheConfig{DiskLimit: 1 << 20, DiskDir: t.TempDir()})),
+	}
+	for i, handler := range handlers {
+		for _, name := range []string{"/level0.dat", "/level8.dat"} {
+			w := serveTest(handler, "GET", name, "", "Range", "bytes=0-2, 5000-5004, 9998-")
+			require.Equal(206, w.status, i, name)
+
+			mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+			require.NoError(err)
+			assert.Equal("multipart/byteranges", mediaType)
+			assert.Equal(fmt.Sprint(w.buf.Len()), w.Header().Get("Content-Length"))
+
+			mr := multipart.NewReader(bytes.NewReader(w.buf.Bytes()), params["boundary"])
+			var parts []string
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(err)
+				assert.Equal("application/x-level", part.Header.Get("Content-Type"))
+				data, err := io.ReadAll(part)
+				require.NoError(err)
+				parts = append(parts, part.Header.Get("Content-Range")+" "+string(data))
+			}
+			assert.Equal([]string{
+				"bytes 0-2/10000 abc",
+				"bytes 5000-5004/10000 abcde",
+				"bytes 9998-9999/10000 ij",
+			}, parts, i, name)
+		}
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	content := strings.Repeat("abcdefghij", 1000)
+	fs := newTestFileSystem(t, "page.txt", content)
+	defer fs.Close()
+
+	handlers := []http.Handler{
+		FileServer(fs, "api/", "", false, nil, nil),
+		FileServer(fs, "api/", "", false, nil, nil, WithCache(CacheConfig{DiskLimit: 1 << 20, DiskDir: t.TempDir()})),
+	}
+	testCases := []struct {
+		Range        string
+		Status       int
+		ContentRange string
+		Body         string
+	}{
+		{Range: "bytes=-5", Status: 206, ContentRange: "bytes 9995-9999/10000", Body: "fghij"},
+		{Range: "bytes=-20000", Status: 206, ContentRange: "bytes 0-9999/10000", Body: content},
+		{Range: "bytes=9998-20000", Status: 206, ContentRange: "bytes 9998-9999/10000", Body: "ij"},
+		{Range: "bytes=20000-", Status: 416, ContentRange: "bytes */10000"},
+		{Range: "bytes=5-2", Status: 416, ContentRange: "bytes */10000"},
+		{Range: "bytes=abc", Status: 416, ContentRange: "bytes */10000"},
+		{Range: "items=0-5", Status: 200, Body: content},
+	}
+	for i, handler := range handlers {
+		for _, tc := range testCases {
+			w := serveTest(handler, "GET", "/page.txt", "", "Range", tc.Range)
+			assert.Equal(tc.Status, w.status, i, tc.Range)
+			assert.Equal(tc.ContentRange, w.Header().Get("Content-Range"), i, tc.Range)
+			if tc.Body != "" {
+				assert.Equal(tc.Body, w.buf.String(), i, tc.Range)
+			}
+		}
+	}
+}
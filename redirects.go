@@ -0,0 +1,236 @@
+package zipfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// redirectsFileName is the name of the redirect rules file that may be
+// placed in the root of an archive.
+const redirectsFileName = "_redirects"
+
+// RedirectRule redirects or rewrites requests for the paths that match
+// From. From is an absolute '/'-separated path in the style of Netlify:
+// a ":name" segment matches any single segment, and a trailing "*"
+// matches everything below the path before it, including the path
+// itself. Literal segments are matched ignoring case. To may refer to
+// the matched segments as ":name" and to the part matched by "*" as
+// ":splat".
+//
+// Status is the status code of the redirect, 301 if it is zero. A
+// status of 200 rewrites the request to the path To instead, so that it
+// is served as if that path had been requested.
+type RedirectRule struct {
+	From   string
+	To     string
+	Status int
+}
+
+// WithRedirectRules redirects or rewrites the requests that match the
+// rules before any file is looked up. The first matching rule applies.
+func WithRedirectRules(rules ...RedirectRule) Option {
+	return func(h *fileHandler) {
+		h.redirectRules = append(h.redirectRules, rules...)
+	}
+}
+
+// WithRedirectsFile reads redirect rules from a _redirects file in the
+// root of every mounted archive. The rules apply after those given to
+// WithRedirectRules, in the order the archives are mounted. Paths in the
+// file are relative to the root of the archive, even if it is mounted
+// under a URL prefix. Every line holds one rule, made of the path, the
+// target and an optional status:
+//
+//	# Comments start with a hash
+//	/old/*        /new/:splat
+//	/play/:game   /games/:game/index.html  200
+//	/forum        https://forum.example.com  302
+//
+// The file itself is never served.
+func WithRedirectsFile() Option {
+	return func(h *fileHandler) {
+		h.redirectsFile = true
+	}
+}
+
+// applyRedirects redirects the request for the URL path name if a rule
+// matches it. It returns the path to serve instead if a rule rewrites
+// the request, and reports whether the response has been written.
+func (h *fileHandler) applyRedirects(w http.ResponseWriter, r *http.Request, mounts []*FileSystem, name string) (rewrite string, done bool) {
+	for _, rule := range h.redirectRules {
+		if to, ok := rule.match(name); ok {
+			return h.redirect(w, r, rule, to)
+		}
+	}
+	if !h.redirectsFile {
+		return "", false
+	}
+	for _, fs := range mounts {
+		prefix := h.mountPrefix(fs)
+		fsName, ok := stripMountPrefix(prefix, name)
+		if !ok {
+			continue
+		}
+		for _, rule := range fs.loadRedirectRules(h.logError) {
+			if to, ok := rule.match(fsName); ok {
+				if strings.HasPrefix(to, "/") {
+					to = prefix + to
+				}
+				return h.redirect(w, r, rule, to)
+			}
+		}
+	}
+	return "", false
+}
+
+// redirect answers the request with the redirect to the target of rule,
+// or returns the target if rule is a rewrite.
+func (h *fileHandler) redirect(w http.ResponseWriter, r *http.Request, rule RedirectRule, to string) (rewrite string, done bool) {
+	if rule.Status == http.StatusOK {
+		h.logf("[Zipfs] Rewriting %s to %s\n", r.URL.Path, to)
+		return to, false
+	}
+	if r.URL.RawQuery != "" && !strings.Contains(to, "?") {
+		to += "?" + r.URL.RawQuery
+	}
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, to, status)
+	return "", true
+}
+
+// match returns the target of rule for the path name, and reports
+// whether the rule matches it.
+func (rule RedirectRule) match(name string) (string, bool) {
+	pattern := splitSegments(rule.From)
+	segments := splitSegments(name)
+	captures := map[string]string{}
+	for i, p := range pattern {
+		if p == "*" && i == len(pattern)-1 {
+			captures["splat"] = strings.Join(segments[i:], "/")
+			return expandRedirectTarget(rule.To, captures), true
+		}
+		if i >= len(segments) {
+			return "", false
+		}
+		if strings.HasPrefix(p, ":") {
+			captures[p[1:]] = segments[i]
+		} else if !strings.EqualFold(p, segments[i]) {
+			return "", false
+		}
+	}
+	if len(pattern) != len(segments) {
+		return "", false
+	}
+	return expandRedirectTarget(rule.To, captures), true
+}
+
+// expandRedirectTarget replaces the ":name" placeholders in to with the
+// captured segments. Longer names are replaced first, so that ":page"
+// is not mistaken for ":p" followed by "age".
+func expandRedirectTarget(to string, captures map[string]string) string {
+	for len(captures) > 0 {
+		longest := ""
+		for key := range captures {
+			if len(key) > len(longest) {
+				longest = key
+			}
+		}
+		to = strings.ReplaceAll(to, ":"+longest, captures[longest])
+		delete(captures, longest)
+	}
+	return to
+}
+
+// isRedirectsFile reports whether the request path refers to the
+// redirect rules file of an archive.
+func isRedirectsFile(name string) bool {
+	return strings.ToLower(strings.Trim(name, "/")) == redirectsFileName
+}
+
+// loadRedirectRules returns the rules in the _redirects file of fs.
+// Results are cached because the archive contents cannot change, so a
+// file that cannot be read is only reported to logError the first time.
+func (fs *FileSystem) loadRedirectRules(logError func(op string, err error)) []RedirectRule {
+	fs.redirectRulesOnce.Do(func() {
+		rules, err := fs.readRedirectRules()
+		if err != nil {
+			logError("RedirectRules", fmt.Errorf("%s: %w", redirectsFileName, err))
+		}
+		fs.redirectRules = rules
+	})
+	return fs.redirectRules
+}
+
+func (fs *FileSystem) readRedirectRules() ([]RedirectRule, error) {
+	fi := fs.fileInfos[redirectsFileName]
+	if fi == nil || fi.IsDir() {
+		return nil, nil
+	}
+
+	reader, err := fi.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxDirConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDirConfigSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxDirConfigSize)
+	}
+	return parseRedirectRules(data)
+}
+
+// parseRedirectRules parses the contents of a _redirects file.
+func parseRedirectRules(data []byte) ([]RedirectRule, error) {
+	var rules []RedirectRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 || !strings.HasPrefix(fields[0], "/") {
+			return nil, fmt.Errorf("line %d: expected a path, a target and an optional status", lineNum)
+		}
+
+		rule := RedirectRule{From: fields[0], To: fields[1]}
+		if len(fields) == 3 {
+			// Rules always apply before the files, so forcing them
+			// with a trailing "!" makes no difference.
+			status, err := strconv.Atoi(strings.TrimSuffix(fields[2], "!"))
+			if err != nil || !validRedirectStatus(status) {
+				return nil, fmt.Errorf("line %d: unsupported status %s", lineNum, fields[2])
+			}
+			rule.Status = status
+		}
+		if rule.Status == http.StatusOK && !strings.HasPrefix(rule.To, "/") {
+			return nil, fmt.Errorf("line %d: can only rewrite to a path", lineNum)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func validRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusOK,
+		http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package zipfs
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectRules(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"_redirects", `# Moved content
+/old/*          /new/:splat
+/play/:game     /games/:game/main.html  200
+/forum          https://forum.example.com  302!
+`,
+		"new/page.html", "new page",
+		"games/pong/main.html", "pong",
+		"old/page.html", "stale",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithRedirectsFile(),
+		WithRedirectRules(RedirectRule{From: "/old/keep.html", To: "/new/page.html", Status: http.StatusTemporaryRedirect}))
+
+	w := serveTest(handler, "GET", "/old/page.html?x=1", "")
+	assert.Equal(301, w.status)
+	assert.Equal("/new/page.html?x=1", w.Header().Get("Location"))
+
+	w = serveTest(handler, "GET", "/OLD/keep.html", "")
+	assert.Equal(307, w.status)
+	assert.Equal("/new/page.html", w.Header().Get("Location"))
+
+	w = serveTest(handler, "GET", "/play/pong", "")
+	assert.Equal(200, w.status)
+	assert.Equal("pong", w.buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/play/tetris", "").status)
+
+	w = serveTest(handler, "GET", "/forum", "")
+	assert.Equal(302, w.status)
+	assert.Equal("https://forum.example.com", w.Header().Get("Location"))
+
+	assert.Equal(200, serveTest(handler, "GET", "/new/page.html", "").status)
+	assert.Equal(404, serveTest(handler, "GET", "/_redirects", "").status)
+}
+
+func TestRedirectRulesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"_redirects", "/old\n",
+		"old", "old",
+	)
+	defer fs.Close()
+	var logged bytes.Buffer
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithRedirectsFile(), WithLogger(log.New(&logged, "", 0)))
+
+	for i := 0; i < 2; i++ {
+		w := serveTest(handler, "GET", "/old", "")
+		assert.Equal(200, w.status)
+	}
+	// The file is only reported once
+	assert.Equal("Error (RedirectRules): _redirects: line 1: expected a path, a target and an optional status\n", logged.String())
+}
+
+func TestParseRedirectRules(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rules, err := parseRedirectRules([]byte("/a /b\n\n/c/:id /d/:id 308\n"))
+	require.NoError(err)
+	assert.Equal([]RedirectRule{{From: "/a", To: "/b"}, {From: "/c/:id", To: "/d/:id", Status: 308}}, rules)
+
+	for _, data := range []string{"/a\n", "/a /b 404\n", "/a https://example.com 200\n", "a /b\n"} {
+		_, err := parseRedirectRules([]byte(data))
+		assert.Error(err, data)
+	}
+
+	to, ok := RedirectRule{From: "/u/:p/:page", To: "/:page/:p"}.match("/u/x/y")
+	assert.True(ok)
+	assert.Equal("/y/x", to)
+}
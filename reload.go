@@ -0,0 +1,213 @@
+package zipfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// autoReload holds the state of WithAutoReload.
+type autoReload struct {
+	interval  time.Duration
+	mutex     sync.Mutex // Held while checking, so only one request checks
+	lastCheck time.Time
+}
+
+// WithAutoReload reopens mounted archives whose file changed on disk,
+// so that a deployment can simply overwrite or replace a Zip file. The
+// files are checked for a different size or modification time, or for
+// having been replaced, at most once per interval, by the first request
+// served after it has passed. A changed archive is swapped like by the
+// swapZIP API endpoint, so requests already being served finish reading
+// the old one. An archive that cannot be opened, for example because it
+// is still being written, is checked again after the next interval.
+// Only archives opened from a file by New, NewRepaired, NewInMemory or
+// NewMapped are checked.
+func WithAutoReload(interval time.Duration) Option {
+	return func(h *fileHandler) {
+		h.autoReload = &autoReload{interval: interval}
+	}
+}
+
+// reloadChanged reopens the mounted archives whose file changed, if the
+// interval of WithAutoReload has passed since they were last checked.
+func (h *fileHandler) reloadChanged() {
+	a := h.autoReload
+	if a == nil || !a.mutex.TryLock() {
+		return
+	}
+	defer a.mutex.Unlock()
+	now := h.now()
+	if now.Sub(a.lastCheck) < a.interval {
+		return
+	}
+	a.lastCheck = now
+
+	for _, fs := range h.mounted() {
+		cur := fs.current()
+		if cur.file == nil {
+			continue
+		}
+		info, err := os.Stat(cur.givenPath)
+		if err != nil || os.SameFile(info, cur.file) && info.Size() == cur.file.Size() && info.ModTime().Equal(cur.file.ModTime()) {
+			continue
+		}
+		h.reload(fs)
+	}
+}
+
+// reload reopens the mounted archive fs and swaps it for the new one.
+func (h *fileHandler) reload(fs *FileSystem) {
+	cur := fs.current()
+	open := h.openArchive
+	if cur.degraded {
+		open = NewRepaired
+	}
+	newFS, err := open(cur.givenPath)
+	if err == nil {
+		err = newFS.inheritSettings(cur)
+		if err == nil && h.mountVerify {
+			err = newFS.Verify(h.verifyWorkers)
+		}
+		if err != nil {
+			newFS.Close()
+		}
+	}
+	if err != nil {
+		h.logError("reload", err)
+		h.recordMountEvent(MountEvent{Kind: MountEventReload, Path: cur.givenPath, Time: h.now(),
+			Status: http.StatusInternalServerError, Error: err.Error()})
+		return
+	}
+
+	h.mountMutex.Lock()
+	var old *FileSystem
+	if slices.Contains(h.fs, fs) {
+		old = h.replaceMountLocked(fs.givenPath, newFS)
+	}
+	h.mountMutex.Unlock()
+	if old == nil {
+		// Unmounted or swapped while it was reopened
+		newFS.Close()
+		return
+	}
+
+	if err := h.extractPhpFiles(newFS); err != nil {
+		h.logError("reload", err)
+	}
+	go h.retire(old)
+	go h.pregenerateGzip(newFS)
+	go h.hashContents(newFS)
+	h.logMount("Zip Reloaded", cur.givenPath)
+	h.recordMountEvent(MountEvent{Kind: MountEventReload, Path: cur.givenPath, Time: h.now(), Status: http.StatusOK})
+}
+
+var errNotReloadable = errors.New("zip: file system was not opened from a file")
+
+// Reload reopens the file that fs was opened from by New, NewRepaired,
+// NewInMemory or NewMapped, so that changes made to it since are served.
+// See ReplaceWith.
+func (fs *FileSystem) Reload() error {
+	cur := fs.current()
+	if cur.file == nil {
+		return errNotReloadable
+	}
+	return fs.ReplaceWith(cur.givenPath)
+}
+
+// ReplaceWith opens the archive name and atomically replaces the archive
+// of fs with it. From then on, fs serves the new archive, while files
+// opened before, and requests already being served by a file server,
+// keep reading the old one. It is closed once they are all closed or
+// finished. Views made by Sub before are of the old archive, and must
+// not be used after it is replaced. If name cannot be opened, fs is left
+// unchanged. The password set with SetPassword, and the settings of
+// SetSymlinkResolution, SetNameEncoding and SetDuplicatePolicy, are
+// kept.
+func (fs *FileSystem) ReplaceWith(name string) error {
+	cur := fs.current()
+	if cur.readerAt == nil {
+		return errFileSystemClosed
+	}
+	open := New
+	if cur.degraded {
+		open = NewRepaired
+	} else if cur.inMemory {
+		open = NewInMemory
+	} else if cur.mapped {
+		open = NewMapped
+	}
+	newFS, err := open(name)
+	if err != nil {
+		return err
+	}
+	if err := newFS.inheritSettings(cur); err != nil {
+		newFS.Close()
+		return err
+	}
+	newFS.handle = fs.origin()
+
+	// Another reload may have replaced cur in the meantime
+	for {
+		cur.reloadMutex.Lock()
+		next := cur.next
+		if next == nil {
+			cur.next = newFS
+			cur.reloadMutex.Unlock()
+			break
+		}
+		cur.reloadMutex.Unlock()
+		cur = next
+	}
+	fs.origin().reloads.Add(1)
+	go func() {
+		cur.inFlight.Wait()
+		cur.closeNested()
+		if cur.closer != nil {
+			cur.closer.Close()
+		}
+	}()
+	return nil
+}
+
+// current returns the archive that fs serves, which is fs itself unless
+// it was replaced by Reload or ReplaceWith.
+func (fs *FileSystem) current() *FileSystem {
+	for {
+		fs.reloadMutex.RLock()
+		next := fs.next
+		fs.reloadMutex.RUnlock()
+		if next == nil {
+			return fs
+		}
+		fs = next
+	}
+}
+
+// acquire returns the archive that fs serves, which is not closed by a
+// reload until inFlight.Done is called on it.
+func (fs *FileSystem) acquire() *FileSystem {
+	for {
+		fs.reloadMutex.RLock()
+		next := fs.next
+		if next == nil {
+			fs.inFlight.Add(1)
+			fs.reloadMutex.RUnlock()
+			return fs
+		}
+		fs.reloadMutex.RUnlock()
+		fs = next
+	}
+}
+
+// origin returns the FileSystem whose archive fs replaced, or fs itself.
+// It identifies a mount across reloads.
+func (fs *FileSystem) origin() *FileSystem {
+	if fs.handle != nil {
+		return fs.handle
+	}
+	return fs
+}
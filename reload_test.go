@@ -0,0 +1,109 @@
+package zipfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	writeTestZip(t, zipPath, "index.html", "one")
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir(),
+		WithClock(clock),
+		WithAutoReload(time.Minute),
+	).(*fileHandler)
+	mountTestZip(t, h, `{"filePath": "site.zip", "urlPrefix": "/app"}`)
+	assert.Equal("one", serveTest(h, "GET", "/app/", "").buf.String())
+
+	// Replaced by a new file, which is only noticed after the interval
+	replacement := filepath.Join(dir, "new.zip")
+	writeTestZip(t, replacement, "index.html", "two")
+	require.NoError(os.Rename(replacement, zipPath))
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal("one", serveTest(h, "GET", "/app/", "").buf.String())
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal("two", serveTest(h, "GET", "/app/", "").buf.String())
+	require.Len(h.mounted(), 1)
+	assert.Equal("/app", h.prefixes[h.mounted()[0]])
+
+	// A file that cannot be opened keeps the old archive mounted
+	require.NoError(os.WriteFile(replacement, []byte("not a zip"), 0644))
+	require.NoError(os.Rename(replacement, zipPath))
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal("two", serveTest(h, "GET", "/app/", "").buf.String())
+
+	writeTestZip(t, zipPath, "index.html", "three, overwritten in place")
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal("three, overwritten in place", serveTest(h, "GET", "/app/", "").buf.String())
+}
+
+func TestReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	writeTestZip(t, zipPath, "index.html", "one", "old.txt", "old")
+	writeTestZip(t, filepath.Join(dir, "other.zip"), "index.html", "other")
+	fs, err := New(zipPath)
+	require.NoError(err)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, []string{"html"}, nil)
+	assert.Equal("one", serveTest(handler, "GET", "/", "").buf.String())
+
+	// A file opened before the reload keeps reading the old archive
+	opened, err := fs.Open("index.html")
+	require.NoError(err)
+	writeTestZip(t, filepath.Join(dir, "new.zip"), "index.html", "two")
+	require.NoError(os.Rename(filepath.Join(dir, "new.zip"), zipPath))
+	require.NoError(fs.Reload())
+	assert.Equal("two", serveTest(handler, "GET", "/", "").buf.String())
+	_, err = fs.Stat("old.txt")
+	assert.True(errors.Is(err, os.ErrNotExist), err)
+	data, err := io.ReadAll(opened)
+	assert.NoError(err)
+	assert.Equal("one", string(data))
+	require.NoError(opened.Close())
+
+	require.NoError(fs.ReplaceWith(filepath.Join(dir, "other.zip")))
+	assert.Equal("other", serveTest(handler, "GET", "/", "").buf.String())
+	assert.Error(fs.ReplaceWith(filepath.Join(dir, "missing.zip")))
+	assert.Equal("other", serveTest(handler, "GET", "/", "").buf.String())
+
+	assert.Equal(errNotReloadable, newTestFileSystem(t, "index.html", "bytes").Reload())
+}
+
+func TestReplaceWithEtag(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "one.zip"), "index.html", "one")
+	writeTestZip(t, filepath.Join(dir, "two.zip"), "index.html", "two", "other.txt", "other")
+	fs, err := New(filepath.Join(dir, "one.zip"))
+	require.NoError(err)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+
+	// Replacing a mounted archive changes the mount table responses
+	for _, target := range []string{"/api/mountstatus", "/api/listmountzip"} {
+		etag := serveTest(handler, "GET", target, "").Header().Get("Etag")
+		require.NotEmpty(etag)
+		require.NoError(fs.ReplaceWith(filepath.Join(dir, "two.zip")))
+		w := serveTest(handler, "GET", target, "", "If-None-Match", etag)
+		assert.Equal(200, w.status, target)
+		assert.NotEqual(etag, w.Header().Get("Etag"), target)
+	}
+}
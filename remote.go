@@ -0,0 +1,101 @@
+package zipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errNoRangeSupport = errors.New("server does not support range requests")
+
+// NewFromURL returns a new FileSystem based on the Zip file at the http
+// or https url. The archive is not downloaded: the central directory and
+// the entries that are served are read with HTTP range requests, so the
+// server must support them. Reads fail once the remote file changes, as
+// detected by its ETag or Last-Modified time. If client is nil,
+// http.DefaultClient is used.
+func NewFromURL(client *http.Client, url string) (*FileSystem, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return NewFromObjectStore(context.Background(), httpObjectStore{client: client}, url)
+}
+
+// httpObjectStore is an ObjectStore whose keys are http or https URLs.
+type httpObjectStore struct {
+	client *http.Client
+}
+
+// Stat requests the first byte of the remote file to learn its size and
+// validators, and to check that the server supports range requests.
+func (s httpObjectStore) Stat(ctx context.Context, url string) (ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return ObjectInfo{}, fmt.Errorf("open %s: %w", url, errNoRangeSupport)
+	default:
+		return ObjectInfo{}, fmt.Errorf("open %s: %s", url, resp.Status)
+	}
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if !ok || err != nil || size < 0 {
+		return ObjectInfo{}, fmt.Errorf("open %s: invalid Content-Range %q", url, resp.Header.Get("Content-Range"))
+	}
+	obj := ObjectInfo{Key: url, Size: size}
+	if etag := resp.Header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		obj.ETag = etag
+	}
+	obj.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return obj, nil
+}
+
+func (s httpObjectStore) ReadRange(ctx context.Context, obj ObjectInfo, offset int64, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", obj.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	if obj.ETag != "" {
+		req.Header.Set("If-Match", obj.ETag)
+	} else if !obj.ModTime.IsZero() {
+		req.Header.Set("If-Unmodified-Since", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var status error
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			status = fmt.Errorf("read %s: unexpected Content-Range %q", obj.Key, resp.Header.Get("Content-Range"))
+		}
+	case http.StatusPreconditionFailed:
+		status = fmt.Errorf("read %s: %w", obj.Key, errObjectChanged)
+	case http.StatusOK:
+		status = fmt.Errorf("read %s: %w", obj.Key, errNoRangeSupport)
+	default:
+		status = fmt.Errorf("read %s: %s", obj.Key, resp.Status)
+	}
+	if status != nil {
+		resp.Body.Close()
+		return nil, status
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,112 @@
+package zipfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	big := strings.Repeat("0123456789", 20000)
+	writeTestZip(t, filepath.Join(dir, "remote.zip"), "index.html", "remote index", "big.txt", big)
+	data, err := os.ReadFile(filepath.Join(dir, "remote.zip"))
+	require.NoError(err)
+
+	var requests int32
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Etag", etag.Load().(string))
+		http.ServeContent(w, r, "remote.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	fs, err := NewFromURL(nil, server.URL+"/remote.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	f, err := fs.Open("index.html")
+	require.NoError(err)
+	got, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(err)
+	assert.Equal("remote index", string(got))
+
+	// Small reads are served from blocks that were already fetched
+	before := atomic.LoadInt32(&requests)
+	f, err = fs.Open("index.html")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	f.Close()
+	require.NoError(err)
+	assert.Equal(before, atomic.LoadInt32(&requests))
+
+	f, err = fs.Open("big.txt")
+	require.NoError(err)
+	got, err = io.ReadAll(f)
+	f.Close()
+	require.NoError(err)
+	assert.Equal(big, string(got))
+
+	// Reads fail once the remote file changes
+	etag.Store(`"v2"`)
+	fs.readerAt.(*objectReaderAt).blocks = nil
+	f, err = fs.Open("index.html")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	f.Close()
+	assert.True(errors.Is(err, errObjectChanged), err)
+
+	// Servers without range support are rejected
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer plain.Close()
+	_, err = NewFromURL(nil, plain.URL)
+	assert.True(errors.Is(err, errNoRangeSupport), err)
+}
+
+func TestMountRemote(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "remote.zip"), "index.html", "remote index")
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	remoteURL := server.URL + "/remote.zip"
+
+	handler := EmptyFileServer("api/", "", false, []string{"html"}, t.TempDir(), "", nil, nil, t.TempDir())
+	w := serveTest(handler, "POST", "/api/mountzip", `{"filePath": "`+remoteURL+`"}`)
+	assert.Equal(400, w.status)
+
+	handler = EmptyFileServer("api/", "", false, []string{"html"}, t.TempDir(), "", nil, nil, t.TempDir(), WithMountDownloads(nil))
+	w = serveTest(handler, "POST", "/api/mountzip", `{"filePath": "`+remoteURL+`", "urlPrefix": "/game/"}`)
+	require.Equal(200, w.status, w.buf.String())
+	assert.Equal("remote index", serveTest(handler, "GET", "/game/", "").buf.String())
+	assert.Contains(serveTest(handler, "GET", "/api/listmountzip", "").buf.String(), remoteURL)
+
+	w = serveTest(handler, "POST", "/api/mountzip", `{"filePath": "`+server.URL+`/missing.zip"}`)
+	assert.Equal(502, w.status)
+
+	w = serveTest(handler, "POST", "/api/unmountzip", `{"filePath": "`+remoteURL+`"}`)
+	require.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "Zip file unmounted!")
+	assert.Equal(404, serveTest(handler, "GET", "/game/", "").status)
+}
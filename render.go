@@ -0,0 +1,91 @@
+package zipfs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// renderCacheSize is the size of the cache of rendered pages used when
+// the file server has no memory cache configured.
+const renderCacheSize = 16 * 1024 * 1024
+
+// Renderer renders entries to HTML pages, see WithRenderer.
+type Renderer interface {
+	// Render writes the HTML page of the entry at name, the path of the
+	// entry inside its archive, whose contents are source.
+	Render(w io.Writer, name string, source []byte) error
+}
+
+// WithRenderer serves the entries whose names end with ext, such as
+// ".md", as HTML pages rendered by renderer, unless they are downloaded,
+// see WithDownloadParam. Rendered pages are cached in the memory cache
+// configured by WithCache, or in a small cache of their own. See package
+// zipfsmd for markdown.
+func WithRenderer(ext string, renderer Renderer) Option {
+	return func(h *fileHandler) {
+		if h.renderers == nil {
+			h.renderers = map[string]Renderer{}
+			h.renderCache = newCacheStore(CacheLRU, 0, renderCacheSize, 0, "")
+		}
+		h.renderers[strings.ToLower(ext)] = renderer
+	}
+}
+
+// rendererFor returns the renderer of the entry name, or nil if it is
+// served as it is.
+func (h *fileHandler) rendererFor(name string) Renderer {
+	return h.renderers[strings.ToLower(path.Ext(name))]
+}
+
+// serveRendered renders fi to HTML with renderer and serves the result.
+func (h *fileHandler) serveRendered(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo, renderer Renderer) {
+	etag := h.entryEtag(fi)
+	etag = etag[:len(etag)-1] + "-" + strings.ToLower(strings.TrimPrefix(path.Ext(fi.name), ".")) + `"`
+
+	cache := h.renderCache
+	if h.memCache != nil {
+		cache = h.memCache
+	}
+	key := cacheKey{fs: fs, name: fi.fullName(), variant: etag}
+
+	page, _, ok := cache.get(key, h.now())
+	var err error
+	if !ok {
+		page, err = render(renderer, fi)
+		if err == nil {
+			cache.put(key, page, "", int64(len(page)), h.now())
+		}
+	}
+	if err != nil {
+		recordError(r, fi.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Etag", etag)
+	http.ServeContent(w, r, fi.Name(), h.lastModified(fi), bytes.NewReader(page))
+}
+
+// render reads fi and renders it to HTML with renderer.
+func render(renderer Renderer, fi *fileInfo) ([]byte, error) {
+	reader, err := fi.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	source, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, fi.name, source); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+package zipfs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRenderer renders entries as a paragraph of their upper cased
+// contents, and counts the entries it rendered.
+type testRenderer struct {
+	renders int
+}
+
+func (r *testRenderer) Render(w io.Writer, name string, source []byte) error {
+	r.renders++
+	if strings.HasPrefix(name, "broken") {
+		return errors.New("cannot render")
+	}
+	_, err := io.WriteString(w, "<p>"+strings.ToUpper(string(source))+"</p>")
+	return err
+}
+
+func TestRenderer(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"readme.md", "hello",
+		"NOTES.MD", "notes",
+		"broken.md", "broken",
+		"notes.txt", "plain",
+	)
+	defer fs.Close()
+
+	renderer := &testRenderer{}
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithRenderer(".md", renderer))
+	w := serveTest(handler, "GET", "/readme.md", "")
+	assert.Equal(200, w.status)
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("<p>HELLO</p>", w.buf.String())
+	assert.Equal("<p>NOTES</p>", serveTest(handler, "GET", "/NOTES.MD", "").buf.String())
+	assert.Equal("plain", serveTest(handler, "GET", "/notes.txt", "").buf.String())
+
+	// Rendered pages are cached and have an ETag of their own
+	etag := w.Header().Get("Etag")
+	assert.True(strings.HasSuffix(etag, `-md"`), etag)
+	assert.Equal(304, serveTest(handler, "GET", "/readme.md", "", "If-None-Match", etag).status)
+	assert.Equal("<p>HELLO</p>", serveTest(handler, "GET", "/readme.md", "").buf.String())
+	assert.Equal(2, renderer.renders)
+
+	assert.Equal(500, serveTest(handler, "GET", "/broken.md", "").status)
+}
@@ -0,0 +1,300 @@
+package zipfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	localHeaderSig = 0x04034b50
+	localHeaderLen = 30
+	zip64ExtraID   = 0x0001
+	uint32Max      = 0xffffffff
+)
+
+var errNoEntries = errors.New("zip: no entries could be recovered")
+
+// NewRepaired is like New, but if the central directory of the Zip file
+// is truncated or damaged, it recovers the entries whose local headers
+// and data are intact instead of failing. The file system is marked as
+// degraded, see Degraded.
+func NewRepaired(name string) (*FileSystem, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	fs, err := NewFromReaderAt(file, fi.Size(), file, name)
+	if err != nil {
+		fs, err = newRecovered(file, fi.Size(), file, name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	fs.modTime = fi.ModTime()
+	fs.file = fi
+	return fs, nil
+}
+
+// Degraded reports whether the file system was recovered from a damaged
+// Zip file by NewRepaired, so that it may be missing entries.
+func (fs *FileSystem) Degraded() bool {
+	return fs.current().degraded
+}
+
+// WithArchiveRepair mounts archives through the mountZIP API endpoint
+// with NewRepaired, so that archives with a damaged central directory
+// serve what can be recovered of them. They are reported as degraded by
+// the mountStatus API endpoint.
+func WithArchiveRepair(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.repair = enabled
+	}
+}
+
+// newRecovered returns a file system of the entries found by scanning
+// readerAt for local file headers. A central directory listing them is
+// made up and read after the end of the Zip file, so the entries are
+// read like those of an intact one.
+func newRecovered(readerAt io.ReaderAt, size int64, closer io.Closer, filePath string) (*FileSystem, error) {
+	entries := scanLocalHeaders(readerAt, size)
+	if len(entries) == 0 {
+		return nil, errNoEntries
+	}
+	dir := centralDirectory(entries, size)
+	fs, err := NewFromReaderAt(&appendedReaderAt{readerAt, size, dir}, size+int64(len(dir)), closer, filePath)
+	if err != nil {
+		return nil, err
+	}
+	fs.size = size
+	fs.degraded = true
+	return fs, nil
+}
+
+// recoveredEntry is an entry found by its local file header.
+type recoveredEntry struct {
+	offset           int64 // Of the local file header
+	header           []byte
+	name             []byte
+	extra            []byte
+	crc              uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+}
+
+// scanLocalHeaders returns the entries of the Zip file in r whose data
+// is complete. The data of an entry is skipped, so that the local
+// headers of Zip files stored in it are not mistaken for its own.
+func scanLocalHeaders(r io.ReaderAt, size int64) []recoveredEntry {
+	var entries []recoveredEntry
+	for pos := findSignature(r, size, 0, localHeaderSig); pos < size; {
+		e, end, ok := readLocalHeader(r, size, pos)
+		if !ok {
+			pos = findSignature(r, size, pos+1, localHeaderSig)
+			continue
+		}
+		entries = append(entries, e)
+		pos = findSignature(r, size, end, localHeaderSig)
+	}
+	return entries
+}
+
+// findSignature returns the offset of the first occurrence of sig in r
+// at or after pos, or size if there is none.
+func findSignature(r io.ReaderAt, size int64, pos int64, sig uint32) int64 {
+	want := binary.LittleEndian.AppendUint32(nil, sig)
+	buf := make([]byte, 64*1024)
+	for pos+4 <= size {
+		n, err := r.ReadAt(buf, pos)
+		if n < 4 {
+			break
+		}
+		if i := bytes.Index(buf[:n], want); i >= 0 {
+			return pos + int64(i)
+		}
+		if err != nil {
+			break
+		}
+		// The signature may straddle the chunks
+		pos += int64(n) - 3
+	}
+	return size
+}
+
+// readLocalHeader reads the entry whose local file header is at pos and
+// returns it with the offset where its data ends. It is not ok if the
+// header or the data is incomplete.
+func readLocalHeader(r io.ReaderAt, size int64, pos int64) (recoveredEntry, int64, bool) {
+	e := recoveredEntry{offset: pos, header: make([]byte, localHeaderLen)}
+	if _, err := r.ReadAt(e.header, pos); err != nil {
+		return e, 0, false
+	}
+	flags := binary.LittleEndian.Uint16(e.header[6:])
+	e.crc = binary.LittleEndian.Uint32(e.header[14:])
+	e.compressedSize = uint64(binary.LittleEndian.Uint32(e.header[18:]))
+	e.uncompressedSize = uint64(binary.LittleEndian.Uint32(e.header[22:]))
+	nameLen := int64(binary.LittleEndian.Uint16(e.header[26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(e.header[28:]))
+	dataStart := pos + localHeaderLen + nameLen + extraLen
+	if nameLen == 0 || dataStart > size {
+		return e, 0, false
+	}
+	buf := make([]byte, nameLen+extraLen)
+	if _, err := r.ReadAt(buf, pos+localHeaderLen); err != nil {
+		return e, 0, false
+	}
+	e.name, e.extra = buf[:nameLen], buf[nameLen:]
+	if e.compressedSize == uint32Max || e.uncompressedSize == uint32Max {
+		if field := extraField(e.extra, zip64ExtraID); len(field) >= 16 {
+			e.uncompressedSize = binary.LittleEndian.Uint64(field)
+			e.compressedSize = binary.LittleEndian.Uint64(field[8:])
+		}
+	}
+
+	if flags&0x8 == 0 {
+		end := dataStart + int64(e.compressedSize)
+		return e, end, end <= size && end >= dataStart
+	}
+
+	// The sizes follow the data. The signature of the data descriptor
+	// is optional, but written by all common tools.
+	d, ok := findDataDescriptor(r, size, dataStart)
+	e.crc, e.compressedSize, e.uncompressedSize = d.crc, d.compressedSize, d.uncompressedSize
+	return e, d.end, ok
+}
+
+// extraField returns the data of the field with id in the extra data of
+// a header, or nil if there is none.
+func extraField(extra []byte, id uint16) []byte {
+	for len(extra) >= 4 {
+		fieldID := binary.LittleEndian.Uint16(extra)
+		fieldLen := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+fieldLen {
+			break
+		}
+		if fieldID == id {
+			return extra[4 : 4+fieldLen]
+		}
+		extra = extra[4+fieldLen:]
+	}
+	return nil
+}
+
+// centralDirectory returns a central directory and end of central
+// directory record listing entries, for a Zip file where it starts at
+// offset.
+func centralDirectory(entries []recoveredEntry, offset int64) []byte {
+	var dir []byte
+	le := binary.LittleEndian
+	for _, e := range entries {
+		// Drop the Zip64 field of the local header, whose layout differs
+		// from that of the central directory
+		var extra, zip64 []byte
+		for rest := e.extra; len(rest) >= 4; {
+			fieldLen := 4 + int(le.Uint16(rest[2:]))
+			if fieldLen > len(rest) {
+				break
+			}
+			if le.Uint16(rest) != zip64ExtraID {
+				extra = append(extra, rest[:fieldLen]...)
+			}
+			rest = rest[fieldLen:]
+		}
+		size32 := func(n uint64) uint32 {
+			if n >= uint32Max {
+				zip64 = le.AppendUint64(zip64, n)
+				return uint32Max
+			}
+			return uint32(n)
+		}
+		uncompressed := size32(e.uncompressedSize)
+		compressed := size32(e.compressedSize)
+		headerOffset := size32(uint64(e.offset))
+		if zip64 != nil {
+			extra = le.AppendUint16(extra, zip64ExtraID)
+			extra = le.AppendUint16(extra, uint16(len(zip64)))
+			extra = append(extra, zip64...)
+		}
+
+		dir = le.AppendUint32(dir, 0x02014b50)
+		dir = le.AppendUint16(dir, 20)       // Version made by
+		dir = append(dir, e.header[4:14]...) // Version needed, flags, method, time and date
+		dir = le.AppendUint32(dir, e.crc)
+		dir = le.AppendUint32(dir, compressed)
+		dir = le.AppendUint32(dir, uncompressed)
+		dir = le.AppendUint16(dir, uint16(len(e.name)))
+		dir = le.AppendUint16(dir, uint16(len(extra)))
+		dir = append(dir, make([]byte, 10)...) // Comment, disk and attributes
+		dir = le.AppendUint32(dir, headerOffset)
+		dir = append(dir, e.name...)
+		dir = append(dir, extra...)
+	}
+
+	dirSize := uint64(len(dir))
+	records := uint64(len(entries))
+	if records >= 0xffff || dirSize >= uint32Max || uint64(offset) >= uint32Max {
+		end := offset + int64(dirSize)
+		dir = le.AppendUint32(dir, directory64EndSig)
+		dir = le.AppendUint64(dir, directory64EndLen-12) // Size of the rest of the record
+		dir = le.AppendUint16(dir, 45)
+		dir = le.AppendUint16(dir, 45)
+		dir = le.AppendUint32(dir, 0)
+		dir = le.AppendUint32(dir, 0)
+		dir = le.AppendUint64(dir, records)
+		dir = le.AppendUint64(dir, records)
+		dir = le.AppendUint64(dir, dirSize)
+		dir = le.AppendUint64(dir, uint64(offset))
+		dir = le.AppendUint32(dir, directory64LocatorSig)
+		dir = le.AppendUint32(dir, 0)
+		dir = le.AppendUint64(dir, uint64(end))
+		dir = le.AppendUint32(dir, 1)
+		records, dirSize, offset = 0xffff, uint32Max, uint32Max
+	}
+	dir = le.AppendUint32(dir, directoryEndSig)
+	dir = le.AppendUint32(dir, 0) // Disk numbers
+	dir = le.AppendUint16(dir, uint16(records))
+	dir = le.AppendUint16(dir, uint16(records))
+	dir = le.AppendUint32(dir, uint32(dirSize))
+	dir = le.AppendUint32(dir, uint32(offset))
+	return le.AppendUint16(dir, 0) // Comment length
+}
+
+// appendedReaderAt reads the first size bytes of r followed by tail.
+type appendedReaderAt struct {
+	r    io.ReaderAt
+	size int64
+	tail []byte
+}
+
+func (a *appendedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	if off < a.size {
+		want := p
+		if int64(len(want)) > a.size-off {
+			want = want[:a.size-off]
+		}
+		var err error
+		n, err = a.r.ReadAt(want, off)
+		if n < len(want) {
+			return n, err
+		}
+	}
+	if tailOff := off + int64(n) - a.size; tailOff >= 0 && tailOff < int64(len(a.tail)) {
+		n += copy(p[n:], a.tail[tailOff:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
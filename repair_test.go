@@ -0,0 +1,96 @@
+package zipfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepaired(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "good.zip")
+	long := strings.Repeat("compressed ", 1000)
+	writeTestZip(t, name, "index.html", "first", "a/long.txt", long, "last.txt", "last")
+	data, err := os.ReadFile(name)
+	require.NoError(err)
+
+	fs, err := NewRepaired(name)
+	require.NoError(err)
+	assert.False(fs.Degraded())
+	fs.Close()
+
+	// Without the central directory, the entries are found by their
+	// local headers
+	directory := bytes.Index(data, []byte("PK\x01\x02"))
+	require.True(directory > 0)
+	truncated := filepath.Join(dir, "truncated.zip")
+	require.NoError(os.WriteFile(truncated, data[:directory], 0644))
+	_, err = New(truncated)
+	assert.Error(err)
+
+	fs, err = NewRepaired(truncated)
+	require.NoError(err)
+	assert.True(fs.Degraded())
+	assert.Equal(int64(directory), fs.size)
+	for name, want := range map[string]string{"index.html": "first", "a/long.txt": long, "last.txt": "last"} {
+		f, err := fs.Open(name)
+		require.NoError(err, name)
+		got, err := io.ReadAll(f)
+		assert.NoError(err, name)
+		assert.Equal(want, string(got), name)
+		f.Close()
+	}
+	assert.NoError(fs.Verify(1))
+	fs.Close()
+
+	// An entry cut short is left out
+	cut := filepath.Join(dir, "cut.zip")
+	require.NoError(os.WriteFile(cut, data[:directory-10], 0644))
+	fs, err = NewRepaired(cut)
+	require.NoError(err)
+	_, err = fs.Open("last.txt")
+	assert.Error(err)
+	_, err = fs.Open("a/long.txt")
+	assert.NoError(err)
+	fs.Close()
+
+	require.NoError(os.WriteFile(filepath.Join(dir, "empty.zip"), []byte("not a zip"), 0644))
+	_, err = NewRepaired(filepath.Join(dir, "empty.zip"))
+	assert.Equal(errNoEntries, err)
+
+	// Repaired mounts are reported as degraded
+	handler := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithArchiveRepair(true))
+	mountTestZip(t, handler, `{"filePath": "truncated.zip"}`)
+	assert.Equal("last", serveTest(handler, "GET", "/last.txt", "").buf.String())
+	assert.Contains(serveTest(handler, "GET", "/api/mountstatus", "").buf.String(), `"degraded":true`)
+
+	handler = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir())
+	assert.Equal(404, serveTest(handler, "POST", "/api/mountzip", `{"filePath": "truncated.zip"}`).status)
+}
+
+func TestCentralDirectoryZip64(t *testing.T) {
+	assert := assert.New(t)
+
+	entries := []recoveredEntry{{
+		offset:           0,
+		header:           make([]byte, localHeaderLen),
+		name:             []byte("big.bin"),
+		compressedSize:   5 << 30,
+		uncompressedSize: 6 << 30,
+	}}
+	dir := centralDirectory(entries, 5<<30+37)
+	assert.Equal([]byte("PK\x06\x06"), dir[len(dir)-22-20-56:][:4])
+	assert.Equal([]byte("PK\x06\x07"), dir[len(dir)-22-20:][:4])
+	assert.Equal([]byte{0xff, 0xff, 0xff, 0xff}, dir[len(dir)-6:][:4])
+	field := extraField(dir[46+len("big.bin"):], zip64ExtraID)
+	assert.Len(field, 16)
+}
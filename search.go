@@ -0,0 +1,117 @@
+package zipfs
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// defaultSearchLimit is the number of matches the search API endpoint
+// returns per archive unless the request asks for another number.
+const defaultSearchLimit = 1000
+
+// SearchResult holds the files of a mounted archive that match the
+// query of the search API endpoint.
+type SearchResult struct {
+	Path      string   `json:"path"`
+	URLPrefix string   `json:"urlPrefix,omitempty"`
+	Matches   []string `json:"matches"`
+	Truncated bool     `json:"truncated,omitempty"` // More files match than were returned
+}
+
+// searchMatcher returns a function that reports whether the path of a
+// file matches query. Queries with any of the characters *?[ are
+// patterns with the syntax of path.Match, which are matched against the
+// whole path if they have a slash, and against the base name otherwise.
+// Other queries match paths that contain them. Both are matched
+// case-insensitively.
+func searchMatcher(query string) (func(name string) bool, error) {
+	query = strings.ToLower(query)
+	if !strings.ContainsAny(query, "*?[") {
+		return func(name string) bool {
+			return strings.Contains(strings.ToLower(name), query)
+		}, nil
+	}
+	if _, err := path.Match(query, ""); err != nil {
+		return nil, err
+	}
+	wholePath := strings.Contains(query, "/")
+	return func(name string) bool {
+		name = strings.ToLower(name)
+		if !wholePath {
+			name = path.Base(name)
+		}
+		ok, _ := path.Match(strings.TrimPrefix(query, "/"), name)
+		return ok
+	}, nil
+}
+
+// WithSearchEndpoint serves the search API endpoint, which responds to
+// GET requests with the files of the mounted archives whose paths match
+// the q query parameter, see searchMatcher. The limit query parameter
+// bounds the number of matches per archive, and the zip query parameter
+// selects a single archive by the path it was mounted from. Files left
+// out of directory listings, such as hidden ones, never match.
+func WithSearchEndpoint() Option {
+	return func(h *fileHandler) {
+		h.searchEndpoint = true
+	}
+}
+
+// Search the files of the mounted ZIP files.
+func (h *fileHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		h.logErrorf("Search", "Invalid request, not a GET")
+		http.Error(w, "GET request expected.", http.StatusBadRequest)
+		return
+	}
+	if !h.searchEndpoint {
+		http.Error(w, "The search endpoint is not enabled.", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Missing query.", http.StatusBadRequest)
+		return
+	}
+	match, err := searchMatcher(query)
+	if err != nil {
+		http.Error(w, "Invalid pattern.", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit.", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	zip := r.URL.Query().Get("zip")
+
+	mounts, release := h.acquireMounts()
+	defer release()
+	results := []SearchResult{}
+	for _, fse := range mounts {
+		if zip != "" && fse.givenPath != zip {
+			continue
+		}
+		prefix := h.mountPrefix(fse)
+		result := SearchResult{Path: fse.givenPath, URLPrefix: prefix, Matches: []string{}}
+		for _, entry := range h.listedManifest(fse, prefix) {
+			if !match(entry.Path) {
+				continue
+			}
+			if len(result.Matches) == limit {
+				result.Truncated = true
+				break
+			}
+			result.Matches = append(result.Matches, entry.Path)
+		}
+		results = append(results, result)
+	}
+
+	makeJsonResponse(w, results, http.StatusOK)
+}
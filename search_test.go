@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"Site/Index.html", "index",
+		"site/img/logo.png", "logo",
+		"site/img/icon.png", "icon",
+		"docs/readme.txt", "readme",
+	)
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithSearchEndpoint())
+	search := func(query string, extra string) ([]string, bool, int) {
+		w := serveTest(handler, "GET", "/api/search?q="+url.QueryEscape(query)+extra, "")
+		if w.status != http.StatusOK {
+			return nil, false, w.status
+		}
+		var results []SearchResult
+		require.NoError(json.Unmarshal(w.buf.Bytes(), &results))
+		require.Len(results, 1)
+		return results[0].Matches, results[0].Truncated, w.status
+	}
+
+	matches, _, _ := search("INDEX", "")
+	assert.Equal([]string{"Site/Index.html"}, matches)
+	matches, _, _ = search("*.png", "")
+	assert.Equal([]string{"site/img/icon.png", "site/img/logo.png"}, matches)
+	matches, _, _ = search("site/*", "")
+	assert.Equal([]string{"Site/Index.html"}, matches)
+	matches, truncated, _ := search("site", "&limit=2")
+	assert.Len(matches, 2)
+	assert.True(truncated)
+	matches, _, _ = search("missing", "")
+	assert.Empty(matches)
+
+	_, _, status := search("[", "")
+	assert.Equal(http.StatusBadRequest, status)
+	_, _, status = search("", "")
+	assert.Equal(http.StatusBadRequest, status)
+	_, _, status = search("site", "&limit=none")
+	assert.Equal(http.StatusBadRequest, status)
+
+	// The endpoint is optional
+	handler = FileServer(fs, "api/", "", false, nil, nil)
+	assert.Equal(http.StatusNotFound, serveTest(handler, "GET", "/api/search?q=site", "").status)
+}
+
+func TestSearchFiltered(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"index.html", "index",
+		".git/config", "config",
+		"admin/secret.txt", "secret",
+		".zipfsrc", "{}",
+		"_headers", "",
+		"_redirects", "",
+	)
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithSearchEndpoint(),
+		WithHiddenPatterns(".git"),
+		WithPathRules(nil, []string{"/admin/**"}),
+		WithDirConfig(),
+		WithHeadersFile(),
+		WithRedirectsFile(),
+	)
+
+	// Files left out of directory listings never match
+	for _, query := range []string{"*", "config", "secret", "zipfsrc", "_"} {
+		w := serveTest(handler, "GET", "/api/search?q="+url.QueryEscape(query), "")
+		require.Equal(http.StatusOK, w.status)
+		var results []SearchResult
+		require.NoError(json.Unmarshal(w.buf.Bytes(), &results))
+		require.Len(results, 1)
+		if query == "*" {
+			assert.Equal([]string{"index.html"}, results[0].Matches)
+		} else {
+			assert.Empty(results[0].Matches, query)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package zipfs
+
+import "net/http"
+
+// WithSecurityHeaders sets X-Content-Type-Options, X-Frame-Options and
+// Referrer-Policy on every response, and Content-Security-Policy if csp
+// is not empty. Headers that are already set when the response is
+// written, for example by header rules, are left alone.
+func WithSecurityHeaders(csp string) Option {
+	return func(h *fileHandler) {
+		h.securityHeaders = http.Header{
+			"X-Content-Type-Options": {"nosniff"},
+			"X-Frame-Options":        {"SAMEORIGIN"},
+			"Referrer-Policy":        {"strict-origin-when-cross-origin"},
+		}
+		if csp != "" {
+			h.securityHeaders.Set("Content-Security-Policy", csp)
+		}
+	}
+}
+
+// securityHeaderWriter is a http.ResponseWriter that adds the missing
+// security headers just before the response is written.
+type securityHeaderWriter struct {
+	http.ResponseWriter
+	headers http.Header
+	applied bool
+}
+
+func (w *securityHeaderWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	header := w.ResponseWriter.Header()
+	for key, values := range w.headers {
+		if _, ok := header[key]; !ok {
+			header[key] = values
+		}
+	}
+}
+
+func (w *securityHeaderWriter) WriteHeader(status int) {
+	w.apply()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *securityHeaderWriter) Write(b []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *securityHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withSecurityHeaders serves the request with the security headers.
+func (h *fileHandler) withSecurityHeaders(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &securityHeaderWriter{ResponseWriter: w, headers: h.securityHeaders}
+		next(sw, r)
+		// Responses without a body are written after the handler returns
+		sw.apply()
+	}
+}
@@ -0,0 +1,36 @@
+package zipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"page.html", "<p>hi</p>",
+		"embed/game.html", "<p>game</p>",
+		"_headers", "/embed/*\n  X-Frame-Options: ALLOWALL\n",
+	)
+	defer fs.Close()
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithHeadersFile(), WithSecurityHeaders("default-src 'self'"))
+
+	for _, w := range []*TestResponseWriter{
+		serveTest(handler, "GET", "/page.html", ""),
+		serveTest(handler, "GET", "/missing.html", ""),
+		serveTest(handler, "HEAD", "/page.html", ""),
+		serveTest(handler, "GET", "/api/mounts", ""),
+	} {
+		assert.Equal("nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal("SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+		assert.Equal("strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+		assert.Equal("default-src 'self'", w.Header().Get("Content-Security-Policy"))
+	}
+
+	w := serveTest(handler, "GET", "/embed/game.html", "")
+	assert.Equal([]string{"ALLOWALL"}, w.Header()["X-Frame-Options"])
+	assert.Equal("nosniff", w.Header().Get("X-Content-Type-Options"))
+}
@@ -0,0 +1,168 @@
+package zipfs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// WithShadow resolves every GET and HEAD request against fs as well as
+// the mounted archives, and logs any difference in the status, ETag or
+// Content-Length of the two responses. This allows a rebuilt archive to
+// be validated against real traffic before it replaces the current one.
+// Shadow requests run in the background after the real response has
+// been served, at most 16 at a time, and requests that arrive while as
+// many run are not compared. PHP scripts are never executed against fs.
+func WithShadow(fs *FileSystem) Option {
+	return func(h *fileHandler) {
+		h.shadow = &shadowServer{fs: fs, slots: make(chan struct{}, maxShadowRequests)}
+	}
+}
+
+// maxShadowRequests is the number of shadow requests that run at the
+// same time. Requests that arrive while they all run are not shadowed.
+const maxShadowRequests = 16
+
+type shadowServer struct {
+	fs      *FileSystem
+	wg      sync.WaitGroup // Tracks shadow requests in flight
+	slots   chan struct{}  // Holds a value for every shadow request in flight
+	once    sync.Once
+	handler *fileHandler // See shadowHandler
+}
+
+// shadowResult is the part of a response that is compared.
+type shadowResult struct {
+	status        int
+	etag          string
+	contentLength string
+}
+
+func (s shadowResult) String() string {
+	return fmt.Sprintf("status %d, etag %s, length %s", s.status, s.etag, s.contentLength)
+}
+
+// discardWriter is a http.ResponseWriter that keeps the headers and
+// status but throws the body away.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// shadowHandler returns a handler that serves files from the shadow
+// file system with the same settings as h. It is made once every option
+// has been applied, on the first shadow request.
+func (h *fileHandler) shadowHandler() *fileHandler {
+	s := h.shadow
+	s.once.Do(func() {
+		sh := *h
+		sh.fs = []*FileSystem{s.fs}
+		sh.isVerbose = false
+		sh.mountMutex = &sync.RWMutex{}
+		sh.staged = nil
+		sh.mountTimes = nil
+		sh.prefixes = nil
+		// Shadow requests must not have side effects, or be counted
+		// as real traffic
+		sh.shadow = nil
+		sh.autoReload = nil
+		sh.hotFiles = nil
+		sh.quota = nil
+		sh.origin = nil
+		if sh.notFoundFallback {
+			sh.notFound = nil
+		}
+		sh.memCache = nil
+		sh.diskCache = nil
+		if err := sh.configureArchive(s.fs); err != nil {
+			h.logError("shadowHandler", err)
+		}
+		go sh.hashContents(s.fs)
+		s.handler = &sh
+	})
+	return s.handler
+}
+
+func (h *fileHandler) isAPIRequest(r *http.Request) bool {
+	if h.noAPI {
+		return false
+	}
+	urlPath := path.Join("/", strings.ToLower(r.URL.Path))
+	basePath := path.Join("/", strings.ToLower(h.baseAPIPath))
+	return strings.HasPrefix(urlPath, basePath+"/")
+}
+
+// withShadow serves the request and then compares the response with
+// the one the shadow file system would have produced.
+func (h *fileHandler) withShadow(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != "GET" && r.Method != "HEAD") || h.isAPIRequest(r) || checkForPhp(strings.ToLower(r.URL.Path)) {
+			next(w, r)
+			return
+		}
+
+		// The request may be modified while it is served, so take a copy
+		// before handing it over.
+		shadowReq := r.Clone(context.Background())
+		method := r.Method
+
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+		primary := shadowResult{
+			status:        sw.Status(),
+			etag:          w.Header().Get("Etag"),
+			contentLength: w.Header().Get("Content-Length"),
+		}
+
+		select {
+		case h.shadow.slots <- struct{}{}:
+		default:
+			// Too busy, so this request is not compared
+			return
+		}
+		h.shadow.wg.Add(1)
+		go func() {
+			defer func() {
+				<-h.shadow.slots
+				h.shadow.wg.Done()
+			}()
+
+			// HEAD avoids decompressing the body of the shadow entry.
+			shadowReq.Method = "HEAD"
+			dw := &discardWriter{header: make(http.Header)}
+			h.shadowHandler().serveHTTP(dw, shadowReq)
+			shadow := shadowResult{
+				status:        dw.status,
+				etag:          dw.header.Get("Etag"),
+				contentLength: dw.header.Get("Content-Length"),
+			}
+			if shadow.status == 0 {
+				shadow.status = http.StatusOK
+			}
+
+			if shadow != primary {
+				h.logf("Shadow mismatch: %s %s: served %s, shadow %s\n", method, shadowReq.URL.Path, primary, shadow)
+			}
+		}()
+	}
+}
@@ -0,0 +1,82 @@
+package zipfs
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitShadow waits until the shadow requests of handler have been served
+// and compared.
+func waitShadow(handler http.Handler) {
+	handler.(*fileHandler).shadow.wg.Wait()
+}
+
+func TestShadow(t *testing.T) {
+	assert := assert.New(t)
+
+	current := newTestFileSystem(t,
+		"same.txt", "same",
+		"changed.txt", "old content",
+		"removed.txt", "gone soon",
+	)
+	next := newTestFileSystem(t,
+		"same.txt", "same",
+		"changed.txt", "new content!",
+	)
+
+	var buf bytes.Buffer
+	handler := FileServer(current, "api/", "", false, nil, nil,
+		WithLogger(log.New(&buf, "", 0)),
+		WithShadow(next),
+	)
+
+	// The shadow requests are waited for, to compare the responses
+	w := serveTest(handler, "GET", "/same.txt", "")
+	waitShadow(handler)
+	assert.Equal("same", w.buf.String())
+	assert.Empty(buf.String())
+
+	w = serveTest(handler, "GET", "/changed.txt", "")
+	waitShadow(handler)
+	assert.Equal("old content", w.buf.String())
+	assert.Contains(buf.String(), "Shadow mismatch: GET /changed.txt")
+	assert.Contains(buf.String(), "length 11")
+	assert.Contains(buf.String(), "length 12")
+
+	buf.Reset()
+	w = serveTest(handler, "GET", "/removed.txt", "")
+	waitShadow(handler)
+	assert.Equal(200, w.status)
+	assert.Contains(buf.String(), "status 200")
+	assert.Contains(buf.String(), "shadow status 404")
+
+	buf.Reset()
+	serveTest(handler, "GET", "/missing.txt", "")
+	waitShadow(handler)
+	assert.Empty(buf.String())
+
+	// The shadow handler has the same settings as the real one
+	buf.Reset()
+	handler = FileServer(current, "api/", "", false, nil, nil,
+		WithLogger(log.New(&buf, "", 0)),
+		WithWeakEtags(),
+		WithShadow(next),
+	)
+	w = serveTest(handler, "GET", "/same.txt", "")
+	waitShadow(handler)
+	assert.Equal("same", w.buf.String())
+	assert.Empty(buf.String())
+
+	// Requests are not compared while the shadow is busy
+	shadow := handler.(*fileHandler).shadow
+	for i := 0; i < maxShadowRequests; i++ {
+		shadow.slots <- struct{}{}
+	}
+	serveTest(handler, "GET", "/changed.txt", "")
+	waitShadow(handler)
+	assert.Empty(buf.String())
+}
@@ -0,0 +1,130 @@
+package zipfs
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithSlowRequestLog logs every request whose total serve time or
+// time to first byte exceeds threshold. The log message includes the
+// time spent in each phase of the request (lookup, decompress and
+// write) so that the dominating phase can be identified.
+func WithSlowRequestLog(threshold time.Duration) Option {
+	return func(h *fileHandler) {
+		h.slowThreshold = threshold
+	}
+}
+
+// requestTimings records when the phases of a request happened.
+// Time spent in neither lookup nor write is attributed to
+// decompression, which dominates reading entries from the archive.
+type requestTimings struct {
+	clock      Clock
+	start      time.Time
+	lookupDone time.Time
+	firstByte  time.Time
+	writing    time.Duration
+}
+
+// timingsFrom returns the timings attached to the request,
+// or nil if the request is not being timed.
+func timingsFrom(r *http.Request) *requestTimings {
+	t, _ := r.Context().Value(timingsKey).(*requestTimings)
+	return t
+}
+
+// markLookupDone records the end of the lookup phase of the request.
+func markLookupDone(r *http.Request) {
+	if t := timingsFrom(r); t != nil && t.lookupDone.IsZero() {
+		t.lookupDone = t.clock.Now()
+	}
+}
+
+// timingWriter is a http.ResponseWriter that records the time to
+// first byte and the time spent writing to the client.
+type timingWriter struct {
+	http.ResponseWriter
+	timings *requestTimings
+}
+
+func (w *timingWriter) WriteHeader(status int) {
+	if w.timings.firstByte.IsZero() {
+		w.timings.firstByte = w.timings.clock.Now()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	start := w.timings.clock.Now()
+	if w.timings.firstByte.IsZero() {
+		w.timings.firstByte = start
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.timings.writing += w.timings.clock.Now().Sub(start)
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withSlowRequestLog serves the request and logs it if it was slow.
+func (h *fileHandler) withSlowRequestLog(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, r, timings := h.timeRequest(w, r)
+		next(w, r)
+		h.logSlowRequest(r, timings)
+	}
+}
+
+// timeRequest attaches timings to the request, unless an outer layer
+// already has, and returns the writer that records the writes.
+func (h *fileHandler) timeRequest(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, *requestTimings) {
+	if timings := timingsFrom(r); timings != nil {
+		return w, r, timings
+	}
+	timings := &requestTimings{clock: h.timeSource(), start: h.now()}
+	r = r.WithContext(context.WithValue(r.Context(), timingsKey, timings))
+	return &timingWriter{ResponseWriter: w, timings: timings}, r, timings
+}
+
+// phases returns the time spent in the lookup and decompress phases of
+// a request that ended at end.
+func (t *requestTimings) phases(end time.Time) (lookup time.Duration, decompress time.Duration) {
+	lookupDone := t.lookupDone
+	if lookupDone.IsZero() {
+		lookupDone = end
+	}
+	lookup = lookupDone.Sub(t.start)
+	decompress = end.Sub(lookupDone) - t.writing
+	if decompress < 0 {
+		decompress = 0
+	}
+	return lookup, decompress
+}
+
+func (h *fileHandler) logSlowRequest(r *http.Request, timings *requestTimings) {
+	end := h.now()
+	total := end.Sub(timings.start)
+	ttfb := total
+	if !timings.firstByte.IsZero() {
+		ttfb = timings.firstByte.Sub(timings.start)
+	}
+	if total < h.slowThreshold && ttfb < h.slowThreshold {
+		return
+	}
+
+	lookup, decompress := timings.phases(end)
+
+	dominant := "lookup"
+	if decompress > lookup && decompress >= timings.writing {
+		dominant = "decompress"
+	} else if timings.writing > lookup {
+		dominant = "write"
+	}
+
+	h.logf("Slow request: %s %s took %s (first byte %s; lookup %s, decompress %s, write %s; dominated by %s)\n",
+		r.Method, r.URL.Path, total, ttfb, lookup, decompress, timings.writing, dominant)
+}
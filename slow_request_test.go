@@ -0,0 +1,47 @@
+package zipfs
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type slowResponseWriter struct {
+	*TestResponseWriter
+	delay time.Duration
+}
+
+func (w *slowResponseWriter) Write(b []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.TestResponseWriter.Write(b)
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t, "test.txt", "hello")
+	r := &http.Request{
+		URL:    &url.URL{Path: "/test.txt"},
+		Header: make(http.Header),
+		Method: "GET",
+	}
+
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithLogger(logger), WithSlowRequestLog(time.Hour))
+	handler.ServeHTTP(NewTestResponseWriter(), r)
+	assert.Empty(buf.String())
+
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithLogger(logger), WithSlowRequestLog(10*time.Millisecond))
+	w := &slowResponseWriter{TestResponseWriter: NewTestResponseWriter(), delay: 20 * time.Millisecond}
+	handler.ServeHTTP(w, r)
+	assert.Equal("hello", w.buf.String())
+	assert.Contains(buf.String(), "Slow request: GET /test.txt")
+	assert.Contains(buf.String(), "dominated by write")
+}
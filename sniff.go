@@ -0,0 +1,46 @@
+package zipfs
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// WithContentSniffing detects the Content-Type of entries whose
+// extension has no known type from their first bytes, using
+// http.DetectContentType, instead of serving them as
+// application/octet-stream. Types set with WithMimeTypes, including
+// the "default" type, take precedence.
+func WithContentSniffing(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.sniff = enabled
+	}
+}
+
+// sniffContentType sets the Content-Type of fi from its contents if it
+// cannot be determined otherwise.
+func (h *fileHandler) sniffContentType(w http.ResponseWriter, fi *fileInfo) {
+	if !h.sniff || w.Header().Get("Content-Type") != "" {
+		return
+	}
+	if _, ok := h.mimeExts["default"]; ok {
+		return
+	}
+	if mime.TypeByExtension(path.Ext(fi.Name())) != "" {
+		return
+	}
+
+	reader, err := fi.open()
+	if err != nil {
+		// Serving the entry reports the error
+		return
+	}
+	defer reader.Close()
+	buf := make([]byte, sniffLen)
+	n, _ := io.ReadFull(reader, buf)
+	w.Header().Set("Content-Type", http.DetectContentType(buf[:n]))
+}
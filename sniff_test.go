@@ -0,0 +1,40 @@
+package zipfs
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentSniffing(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestFileSystem(t,
+		"page", "<!DOCTYPE html><p>hi</p>",
+		"image.dat", "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR",
+		"blob", "\x00\x01\x02\x03",
+		"style.css", "<html>",
+		"game.unity3d", "<html>",
+	)
+	defer fs.Close()
+
+	contentType := func(handler http.Handler, target string) string {
+		w := serveTest(handler, "GET", target, "")
+		return w.Header().Get("Content-Type")
+	}
+
+	handler := FileServer(fs, "api/", "", false, nil, map[string]string{".unity3d": "application/vnd.unity"},
+		WithContentSniffing(true))
+	assert.Equal("text/html; charset=utf-8", contentType(handler, "/page"))
+	assert.Equal("image/png", contentType(handler, "/image.dat"))
+	assert.Equal("application/octet-stream", contentType(handler, "/blob"))
+	assert.Equal("text/css; charset=utf-8", contentType(handler, "/style.css"))
+	assert.Equal("application/vnd.unity", contentType(handler, "/game.unity3d"))
+
+	handler = FileServer(fs, "api/", "", false, nil, nil)
+	assert.Equal("application/octet-stream", contentType(handler, "/page"))
+
+	handler = FileServer(fs, "api/", "", false, nil, map[string]string{"default": "text/plain"}, WithContentSniffing(true))
+	assert.Equal("text/plain", contentType(handler, "/image.dat"))
+}
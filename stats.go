@@ -0,0 +1,94 @@
+package zipfs
+
+import (
+	iofs "io/fs"
+	"sort"
+)
+
+// largestEntries is the number of largest files listed in ArchiveStats.
+const largestEntries = 10
+
+// ArchiveStats summarizes the contents of a FileSystem.
+type ArchiveStats struct {
+	Files            int   `json:"files"`
+	Directories      int   `json:"directories"` // Including the ones without entries
+	CompressedSize   int64 `json:"compressedSize"`
+	UncompressedSize int64 `json:"uncompressedSize"`
+
+	// Methods breaks the files down by compression method, such as
+	// zip.Deflate
+	Methods map[uint16]MethodStats `json:"methods"`
+
+	// Largest lists the largest files by uncompressed size, largest
+	// first
+	Largest []ManifestEntry `json:"largest"`
+}
+
+// MethodStats summarizes the files of a FileSystem that are compressed
+// with one method.
+type MethodStats struct {
+	Files            int   `json:"files"`
+	CompressedSize   int64 `json:"compressedSize"`
+	UncompressedSize int64 `json:"uncompressedSize"`
+}
+
+// Stats returns a summary of the contents of fs, leaving out the
+// contents of Zip files stored in the archive. It is computed the first
+// time it is asked for.
+func (fs *FileSystem) Stats() ArchiveStats {
+	fs = fs.current()
+	fs.statsOnce.Do(func() {
+		fs.stats = fs.computeStats()
+	})
+	stats := fs.stats
+	// The caller may change the result
+	stats.Methods = make(map[uint16]MethodStats, len(fs.stats.Methods))
+	for method, m := range fs.stats.Methods {
+		stats.Methods[method] = m
+	}
+	stats.Largest = append([]ManifestEntry(nil), fs.stats.Largest...)
+	return stats
+}
+
+func (fs *FileSystem) computeStats() ArchiveStats {
+	stats := ArchiveStats{Methods: map[uint16]MethodStats{}, Largest: []ManifestEntry{}}
+	fs.Walk(".", func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name != "." {
+				stats.Directories++
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fi := info.(*FileInfo)
+		stats.Files++
+		stats.CompressedSize += fi.CompressedSize
+		stats.UncompressedSize += fi.UncompressedSize
+		m := stats.Methods[fi.Method]
+		m.Files++
+		m.CompressedSize += fi.CompressedSize
+		m.UncompressedSize += fi.UncompressedSize
+		stats.Methods[fi.Method] = m
+		stats.Largest = append(stats.Largest, ManifestEntry{
+			Path:    fs.entryName(fi.fi.zipFile),
+			Size:    fi.UncompressedSize,
+			CRC32:   fi.CRC32,
+			ModTime: fi.ModTime(),
+			Method:  fi.Method,
+		})
+		return nil
+	})
+	sort.SliceStable(stats.Largest, func(i, j int) bool {
+		return stats.Largest[i].Size > stats.Largest[j].Size
+	})
+	if len(stats.Largest) > largestEntries {
+		stats.Largest = stats.Largest[:largestEntries]
+	}
+	return stats
+}
@@ -0,0 +1,67 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, header := range []*zip.FileHeader{
+		{Name: "big.txt", Method: zip.Deflate},
+		{Name: "assets/stored.bin", Method: zip.Store},
+		{Name: "assets/small.txt", Method: zip.Deflate},
+	} {
+		w, err := zw.CreateHeader(header)
+		require.NoError(err)
+		size := map[string]int{"big.txt": 5000, "assets/stored.bin": 300, "assets/small.txt": 10}[header.Name]
+		w.Write([]byte(strings.Repeat("x", size)))
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "stats.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	stats := fs.Stats()
+	assert.Equal(3, stats.Files)
+	assert.Equal(1, stats.Directories)
+	assert.Equal(int64(5310), stats.UncompressedSize)
+	deflated := stats.Methods[zip.Deflate]
+	assert.Equal(2, deflated.Files)
+	assert.Equal(int64(5010), deflated.UncompressedSize)
+	assert.Less(deflated.CompressedSize, deflated.UncompressedSize)
+	assert.Equal(MethodStats{Files: 1, CompressedSize: 300, UncompressedSize: 300}, stats.Methods[zip.Store])
+	assert.Equal(deflated.CompressedSize+300, stats.CompressedSize)
+	require.Len(stats.Largest, 3)
+	assert.Equal("big.txt", stats.Largest[0].Path)
+	assert.Equal("assets/stored.bin", stats.Largest[1].Path)
+
+	// Changing the result does not change the next one
+	stats.Largest[0].Path = "changed"
+	assert.Equal("big.txt", fs.Stats().Largest[0].Path)
+
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	status := func(query string) MountStatusResponseData {
+		w := serveTest(handler, "GET", "/api/mountstatus"+query, "")
+		require.Equal(http.StatusOK, w.status)
+		var data MountStatusResponseData
+		require.NoError(json.Unmarshal(w.buf.Bytes(), &data))
+		require.Len(data.Mounts, 1)
+		return data
+	}
+	assert.Nil(status("").Mounts[0].Stats)
+	withStats := status("?stats=true").Mounts[0].Stats
+	require.NotNil(withStats)
+	assert.Equal(fs.Stats(), *withStats)
+}
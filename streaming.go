@@ -0,0 +1,91 @@
+package zipfs
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithStreaming flushes responses to the client at least every
+// flushInterval while entries are being decompressed, so that clients
+// can start processing long pages, logs or media before the whole entry
+// has been served. A flushInterval of zero flushes after every write.
+// If writeTimeout is not zero, every chunk written to the client must be
+// written within writeTimeout, which stops slow clients from holding on
+// to a connection forever without limiting the total time a large entry
+// takes to serve.
+func WithStreaming(flushInterval time.Duration, writeTimeout time.Duration) Option {
+	return func(h *fileHandler) {
+		h.streaming = &streamingConfig{
+			flushInterval: flushInterval,
+			writeTimeout:  writeTimeout,
+		}
+	}
+}
+
+type streamingConfig struct {
+	flushInterval time.Duration
+	writeTimeout  time.Duration
+}
+
+// streamWriter is a http.ResponseWriter that flushes periodically and
+// sets a write deadline for every chunk, using a http.ResponseController.
+type streamWriter struct {
+	http.ResponseWriter
+	rc        *http.ResponseController
+	config    *streamingConfig
+	clock     Clock
+	lastFlush time.Time
+	noExtend  bool // The writer does not support write deadlines
+}
+
+// streamWriter wraps w if streaming is enabled. The returned function
+// must be called once the response has been written.
+func (h *fileHandler) streamWriter(w http.ResponseWriter) (http.ResponseWriter, func()) {
+	if h.streaming == nil {
+		return w, func() {}
+	}
+	sw := &streamWriter{
+		ResponseWriter: w,
+		rc:             http.NewResponseController(w),
+		config:         h.streaming,
+		clock:          h.timeSource(),
+		lastFlush:      h.now(),
+	}
+	return sw, sw.finish
+}
+
+// finish clears the write deadline, which would otherwise still apply
+// to the next request on the same connection.
+func (w *streamWriter) finish() {
+	if w.config.writeTimeout > 0 && !w.noExtend {
+		w.rc.SetWriteDeadline(time.Time{})
+	}
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	if w.config.writeTimeout > 0 && !w.noExtend {
+		// Deadlines are enforced by the network connection, so they
+		// must use the real time rather than the file server's clock.
+		if err := w.rc.SetWriteDeadline(time.Now().Add(w.config.writeTimeout)); err != nil {
+			w.noExtend = true
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	if now := w.clock.Now(); now.Sub(w.lastFlush) >= w.config.flushInterval {
+		w.lastFlush = now
+		// Flushing is only an optimization, so writers that cannot
+		// flush are still served.
+		w.rc.Flush()
+	}
+	return n, nil
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
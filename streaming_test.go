@@ -0,0 +1,76 @@
+package zipfs
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flushRecorder counts the writes and flushes of a response.
+type flushRecorder struct {
+	*TestResponseWriter
+	writes  int
+	flushes int
+}
+
+func (w *flushRecorder) Write(b []byte) (int, error) {
+	w.writes++
+	return w.TestResponseWriter.Write(b)
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushes++
+}
+
+func TestStreaming(t *testing.T) {
+	assert := assert.New(t)
+
+	content := strings.Repeat("streamed line\n", 20000)
+	fs := newTestFileSystem(t, "log.txt", content)
+	defer fs.Close()
+
+	r := newTestRequest("GET", "/log.txt", "")
+	w := &flushRecorder{TestResponseWriter: NewTestResponseWriter()}
+	FileServer(fs, "api/", "", false, nil, nil).ServeHTTP(w, r)
+	assert.Equal(content, w.buf.String())
+	assert.Equal(0, w.flushes)
+
+	w = &flushRecorder{TestResponseWriter: NewTestResponseWriter()}
+	FileServer(fs, "api/", "", false, nil, nil, WithStreaming(0, 0)).ServeHTTP(w, r)
+	assert.Equal(content, w.buf.String())
+	assert.True(w.writes > 1)
+	assert.Equal(w.writes, w.flushes)
+
+	// Nothing is flushed until the interval has passed
+	clock := &testClock{now: time.Unix(1000, 0)}
+	w = &flushRecorder{TestResponseWriter: NewTestResponseWriter()}
+	FileServer(fs, "api/", "", false, nil, nil, WithClock(clock), WithStreaming(time.Second, 0)).ServeHTTP(w, r)
+	assert.Equal(0, w.flushes)
+}
+
+func TestStreamingWriteTimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t, "page.html", "<p>hello</p>")
+	defer fs.Close()
+	server := httptest.NewServer(FileServer(fs, "api/", "", false, nil, nil, WithStreaming(0, 50*time.Millisecond)))
+	defer server.Close()
+
+	// The deadline of the first request must not break the next request
+	// on the same connection.
+	for i := 0; i < 2; i++ {
+		resp, err := server.Client().Get(server.URL + "/page.html")
+		require.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(err)
+		assert.Equal("<p>hello</p>", string(body))
+		time.Sleep(100 * time.Millisecond)
+	}
+}
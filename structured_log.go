@@ -0,0 +1,76 @@
+package zipfs
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// WithSlog sends the diagnostic messages produced by the file server to
+// logger with structured fields, instead of to the logger of WithLogger
+// or standard output. Errors are logged at LevelError and warnings at
+// LevelWarn, with the failed operation in the "op" field and the error
+// in the "error" field, and other messages are logged at LevelInfo.
+// Changes to the mounted archives carry the path of the archive in the
+// "zip" field.
+// Requests that fail are logged as well, once they have been served: at
+// LevelError if the server failed, at LevelWarn if serving an entry
+// failed otherwise and at LevelDebug if nothing was found. If
+// logRequests is set, every request is logged, and the requests for
+// which nothing was found are logged at LevelInfo.
+func WithSlog(logger *slog.Logger, logRequests bool) Option {
+	return func(h *fileHandler) {
+		h.slog = logger
+		h.slogRequests = logRequests
+	}
+}
+
+// logMount logs msg about the mounted archive at zipPath, with the path
+// in the "zip" field of structured logs and any other fields given as
+// alternating keys and values.
+func (h *fileHandler) logMount(msg string, zipPath string, args ...any) {
+	if h.slog != nil {
+		h.slog.Info(msg, append([]any{"zip", zipPath}, args...)...)
+		return
+	}
+	h.logf("%s: %s\n", msg, zipPath)
+}
+
+// withSlog serves the request and logs it to the structured logger if it
+// failed, or if every request is logged.
+func (h *fileHandler) withSlog(next serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := h.now()
+		r, info := withErrorInfo(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+
+		status := sw.Status()
+		args := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", sw.written,
+			"duration", h.now().Sub(start),
+			"remote", r.RemoteAddr,
+		}
+		if info.path != "" {
+			args = append(args, "entry", info.path)
+		}
+		if info.err != nil {
+			args = append(args, "error", info.err.Error())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400 && info.err != nil && status != http.StatusNotFound:
+			level = slog.LevelWarn
+		case status >= 400 && !h.slogRequests:
+			level = slog.LevelDebug
+		case !h.slogRequests:
+			return
+		}
+		h.slog.Log(r.Context(), level, "Request", args...)
+	}
+}
@@ -0,0 +1,82 @@
+package zipfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	records := func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]any
+			require.NoError(json.Unmarshal([]byte(line), &record))
+			// Verbose messages about serving files are left out
+			if msg, _ := record["msg"].(string); !strings.HasPrefix(msg, "[Zipfs]") {
+				records = append(records, record)
+			}
+		}
+		buf.Reset()
+		return records
+	}
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "a.zip"), "a.txt", "hello")
+	h := EmptyFileServer("api/", "", true, nil, dir, "", nil, nil, t.TempDir(), WithSlog(logger, false))
+
+	mountTestZip(t, h, `{"filePath": "a.zip"}`)
+	var mounted map[string]any
+	for _, record := range records() {
+		if record["msg"] == "Zip Mounted" {
+			mounted = record
+		}
+	}
+	require.NotNil(mounted)
+	assert.Equal("INFO", mounted["level"])
+	assert.Equal(filepath.Join(dir, "a.zip"), mounted["zip"])
+
+	// Successful requests are not logged unless asked for
+	require.Equal(200, serveTest(h, "GET", "/a.txt", "").status)
+	assert.Empty(records())
+
+	require.Equal(404, serveTest(h, "GET", "/missing.txt", "").status)
+	logged := records()
+	require.Len(logged, 1)
+	assert.Equal("DEBUG", logged[0]["level"])
+	assert.Equal("Request", logged[0]["msg"])
+	assert.Equal("/missing.txt", logged[0]["path"])
+	assert.Equal(float64(404), logged[0]["status"])
+
+	require.Equal(400, serveTest(h, "GET", "/api/mountzip", "").status)
+	logged = records()
+	require.NotEmpty(logged)
+	assert.Equal("ERROR", logged[0]["level"])
+	assert.Equal("MountFs", logged[0]["msg"])
+	assert.Equal("MountFs", logged[0]["op"])
+	assert.Equal("Invalid request, not a POST", logged[0]["error"])
+
+	h = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithSlog(logger, true))
+	mountTestZip(t, h, `{"filePath": "a.zip"}`)
+	buf.Reset()
+	require.Equal(200, serveTest(h, "GET", "/a.txt", "").status)
+	logged = records()
+	require.Len(logged, 1)
+	assert.Equal("INFO", logged[0]["level"])
+	assert.Equal("/a.txt", logged[0]["path"])
+	assert.Equal(float64(len("hello")), logged[0]["bytes"])
+}
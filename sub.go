@@ -0,0 +1,92 @@
+package zipfs
+
+import (
+	iofs "io/fs"
+	"path"
+	"strings"
+)
+
+// Sub returns a view of the directory dir of fs, in which dir is the
+// root, so that one archive can back several handlers that each serve
+// only part of it. Files outside of dir cannot be reached through the
+// view. Names are matched case-insensitively, as by Open.
+//
+// The view shares the ZIP file of fs, which must stay open while the
+// view is in use. Closing the view does not close fs. Views cannot be
+// downloaded with the downloadzip API endpoint, as that would expose
+// the whole archive.
+func (fs *FileSystem) Sub(dir string) (*FileSystem, error) {
+	if !iofs.ValidPath(dir) {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: iofs.ErrInvalid}
+	}
+	fs = fs.current()
+	root, err := fs.openFileInfo(dir)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: err}
+	}
+	if !root.IsDir() {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: errNotDirectory}
+	}
+
+	prefix := root.name
+	if prefix == "/" {
+		prefix = ""
+	}
+	// The view is not opened from a file of its own, so it is neither
+	// closed nor reloaded with fs
+	sub := &FileSystem{archive: fs.archive}
+	sub.closer = nil
+	sub.file = nil
+	sub.givenPath = path.Join(fs.givenPath, strings.TrimSuffix(prefix, "/"))
+	sub.subDir = path.Join(fs.subDir, strings.TrimSuffix(prefix, "/"))
+	sub.fileInfos = fileInfoMap{}
+	subRoot := sub.fileInfos.copyTree(root, prefix)
+	sub.fileInfos["/"] = subRoot
+	sub.fileInfos[""] = subRoot
+	sub.caseVariants = nil
+	for name, fi := range fs.caseVariants {
+		// Exact names keep their case, while prefix is lowercase
+		if len(name) <= len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) {
+			continue
+		}
+		if sub.caseVariants == nil {
+			sub.caseVariants = map[string]*fileInfo{}
+		}
+		sub.caseVariants[name[len(prefix):]] = &fileInfo{
+			name:    strings.TrimPrefix(fi.name, prefix),
+			zipFile: fi.zipFile,
+		}
+	}
+	for _, fi := range sub.fileInfos {
+		fi.fs = sub
+	}
+	for _, fi := range sub.caseVariants {
+		fi.fs = sub
+	}
+	return sub, nil
+}
+
+// copyTree adds a copy of fi and everything below it to fm, with prefix
+// removed from their names, and returns the copy of fi.
+func (fm fileInfoMap) copyTree(fi *fileInfo, prefix string) *fileInfo {
+	name := strings.TrimPrefix(fi.name, prefix)
+	if name == "" {
+		name = "/"
+	}
+	fiCopy := &fileInfo{
+		name:      name,
+		zipFile:   fi.zipFile,
+		fileInfos: make(fileInfoList, 0, len(fi.fileInfos)),
+	}
+	if fm[name] == nil {
+		// Files whose names only differ in case are found by the first
+		fm[name] = fiCopy
+	}
+	if stripped := strings.TrimRight(name, "/"); stripped != name && stripped != "" {
+		fm[stripped] = fiCopy
+	}
+	for _, child := range fi.fileInfos {
+		fiCopy.fileInfos = append(fiCopy.fileInfos, fm.copyTree(child, prefix))
+	}
+	return fiCopy
+}
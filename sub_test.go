@@ -0,0 +1,91 @@
+package zipfs
+
+import (
+	"archive/zip"
+	iofs "io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSub(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestFileSystem(t,
+		"secret.txt", "secret",
+		"Sites/", "",
+		"Sites/blue/", "",
+		"Sites/blue/index.html", "blue",
+		"Sites/blue/css/", "",
+		"Sites/blue/css/site.css", "css",
+		"Sites/green/", "",
+		"Sites/green/index.html", "green",
+	)
+	defer fs.Close()
+
+	blue, err := fs.Sub("sites/Blue")
+	require.NoError(err)
+	require.NoError(fstest.TestFS(blue, "index.html", "css/site.css"))
+	green, err := fs.Sub("Sites/green")
+	require.NoError(err)
+
+	_, err = fs.Sub("secret.txt")
+	assert.Error(err)
+	_, err = fs.Sub("missing")
+	assert.Error(err)
+	_, err = fs.Sub("../x")
+	assert.Error(err)
+
+	blueHandler := FileServer(blue, "api/", "", false, []string{"html"}, nil)
+	greenHandler := FileServer(green, "api/", "", false, []string{"html"}, nil)
+	assert.Equal("blue", serveTest(blueHandler, "GET", "/", "").buf.String())
+	assert.Equal("css", serveTest(blueHandler, "GET", "/css/site.css", "").buf.String())
+	assert.Equal("green", serveTest(greenHandler, "GET", "/", "").buf.String())
+	assert.Equal(404, serveTest(blueHandler, "GET", "/secret.txt", "").status)
+	assert.Equal(404, serveTest(blueHandler, "GET", "/../secret.txt", "").status)
+	assert.Equal(404, serveTest(blueHandler, "GET", "/green/", "").status)
+
+	// A view of a view is rooted at the inner directory
+	css, err := blue.Sub("css")
+	require.NoError(err)
+	require.NoError(fstest.TestFS(css, "site.css"))
+
+	// Closing a view leaves the archive open
+	require.NoError(blue.Close())
+	assert.Equal("green", serveTest(greenHandler, "GET", "/", "").buf.String())
+}
+
+func TestSubBehavesLikeArchive(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	inner := zipBytes(t, zip.Deflate, "map.dat", "map")
+	fs, err := NewFromBytes(zipBytes(t, zip.Store,
+		"Site/Logo.PNG", "first",
+		"site/logo.png", "second",
+		"site/levels.zip", string(inner),
+	), "site.zip")
+	require.NoError(err)
+	defer fs.Close()
+	fs.SetSymlinkResolution(false)
+
+	site, err := fs.Sub("site")
+	require.NoError(err)
+	assert.True(site.noSymlinks)
+
+	// Entries whose names only differ in case are found by their exact
+	// names, as in the archive
+	for name, want := range map[string]string{"Logo.PNG": "first", "logo.png": "second", "LOGO.png": "first"} {
+		data, err := iofs.ReadFile(site, name)
+		require.NoError(err, name)
+		assert.Equal(want, string(data), name)
+	}
+
+	// Nested archives are opened
+	data, err := iofs.ReadFile(site, "levels.zip!/map.dat")
+	require.NoError(err)
+	assert.Equal("map", string(data))
+}
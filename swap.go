@@ -0,0 +1,285 @@
+package zipfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Stage is the request body of the stageZIP API endpoint.
+type Stage struct {
+	FilePath  string `json:"filePath"`  // Mounted archive to replace
+	StagePath string `json:"stagePath"` // Archive replacing it
+}
+
+// StageResponseData describes an archive staged by the stageZIP API
+// endpoint.
+type StageResponseData struct {
+	FilePath string `json:"filePath"`
+	// StagePath is the path that the archive is mounted from once it has
+	// been swapped in, so it is the path to give to unmountZIP, stageZIP
+	// and swapZIP from then on, instead of FilePath.
+	StagePath string `json:"stagePath"`
+	Files     int    `json:"files"`
+}
+
+// mounted returns a copy of the list of mounted archives.
+func (h *fileHandler) mounted() []*FileSystem {
+	mounts, _ := h.mountTable()
+	return mounts
+}
+
+// mountTable returns a copy of the list of mounted archives and its
+// generation, which changes whenever an archive is mounted, unmounted or
+// swapped.
+func (h *fileHandler) mountTable() ([]*FileSystem, uint64) {
+	h.mountMutex.RLock()
+	defer h.mountMutex.RUnlock()
+	return append([]*FileSystem(nil), h.fs...), h.mountGen
+}
+
+// acquireMounts returns the mounted archives for serving a request. They
+// are not closed by unmounting, swapping or reloading them until release
+// is called. Archives that were reloaded are returned as their current
+// archive, see FileSystem.origin.
+func (h *fileHandler) acquireMounts() ([]*FileSystem, func()) {
+	h.mountMutex.RLock()
+	mounts := append([]*FileSystem(nil), h.fs...)
+	for i, fse := range mounts {
+		mounts[i] = fse.acquire()
+	}
+	h.mountMutex.RUnlock()
+
+	return mounts, func() {
+		for _, fse := range mounts {
+			fse.inFlight.Done()
+		}
+	}
+}
+
+// retire waits for the requests still using an archive that is no
+// longer mounted, then closes it and drops its cached data. It must be
+// called after the archive is removed from h.fs.
+func (h *fileHandler) retire(fs *FileSystem) {
+	fs.current().inFlight.Wait()
+	h.mountMutex.Lock()
+	delete(h.prefixes, fs)
+	h.mountMutex.Unlock()
+	if err := fs.Close(); err != nil {
+		h.logErrorf("retire", "Failed to close zip file %s: %w", fs.givenPath, err)
+	}
+	if h.hotFiles != nil {
+		h.hotFiles.forget(fs)
+	}
+	h.dropCached(fs)
+}
+
+// holdMounted keeps retire from closing gen, the archive that is served
+// for the mounted fs, and reports whether fs is still mounted and still
+// serves gen. If so, the caller must call gen.inFlight.Done when it is
+// done reading gen.
+func (h *fileHandler) holdMounted(fs *FileSystem, gen *FileSystem) bool {
+	h.mountMutex.RLock()
+	defer h.mountMutex.RUnlock()
+	if !slices.Contains(h.fs, fs) {
+		return false
+	}
+	if acquired := fs.acquire(); acquired != gen {
+		acquired.inFlight.Done()
+		return false
+	}
+	return true
+}
+
+// replaceMountLocked replaces the archive mounted from zipPath with
+// newFS, keeping its position and URL path prefix, and returns the old
+// archive, or nil if nothing is mounted from zipPath. Requests that
+// already acquired the old archive keep reading from it, so it must be
+// closed with retire. The caller must hold mountMutex.
+func (h *fileHandler) replaceMountLocked(zipPath string, newFS *FileSystem) *FileSystem {
+	for i, fse := range h.fs {
+		if fse.givenPath == zipPath {
+			h.fs[i] = newFS
+			delete(h.mountTimes, fse)
+			h.setMountTimeLocked(newFS)
+			h.setMountPrefixLocked(newFS, h.prefixes[fse])
+			h.mountGen++
+			return fse
+		}
+	}
+	return nil
+}
+
+// mountPath resolves the path of an archive given to the API, and
+// reports whether it is within the base mount directory.
+func (h *fileHandler) mountPath(filePath string) (string, bool) {
+	var zipPath string
+	if filepath.IsAbs(filePath) {
+		zipPath = path.Clean(filePath)
+	} else {
+		zipPath = path.Clean(path.Join(h.baseMountDir, filePath))
+	}
+	return zipPath, strings.HasPrefix(zipPath, h.baseMountDir)
+}
+
+// validateArchive checks that the local header of every entry of fs can
+// be read.
+func validateArchive(fs *FileSystem) error {
+	for _, f := range fs.reader.File {
+		if _, err := f.DataOffset(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stage a new ZIP file to replace a mounted one.
+func (h *fileHandler) StageFs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		h.logErrorf("StageFs", "Invalid request, not a POST")
+		http.Error(w, "POST request expected.", http.StatusBadRequest)
+		return
+	}
+
+	var s Stage
+	err := json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		h.logError("StageFs", err)
+		recordError(r, "", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zipPath, ok := h.mountPath(s.FilePath)
+	stagePath, stageOk := h.mountPath(s.StagePath)
+	if !ok || !stageOk {
+		h.logErrorf("StageFs", "Illegal path access (%s, %s)", s.FilePath, s.StagePath)
+		http.Error(w, "Illegal path access", http.StatusBadRequest)
+		return
+	}
+
+	var mounted *FileSystem
+	for _, fse := range h.mounted() {
+		if fse.givenPath == zipPath {
+			mounted = fse
+		} else if fse.givenPath == stagePath {
+			h.logErrorf("StageFs", "Zip already mounted (%s)", stagePath)
+			http.Error(w, "Staged zip file is already mounted.", http.StatusBadRequest)
+			return
+		}
+	}
+	if mounted == nil {
+		h.logErrorf("StageFs", "Zip not mounted (%s)", zipPath)
+		http.Error(w, "Zip file not mounted.", http.StatusNotFound)
+		return
+	}
+
+	h.logf("Staging Zip: %s -> %s\n", zipPath, stagePath)
+	// The staged archive is opened and configured like the one it
+	// replaces, so that swapping it in changes nothing but the contents
+	newFS, err := h.openArchive(stagePath)
+	if err == nil {
+		err = newFS.inheritSettings(mounted.current())
+		if err == nil {
+			err = h.configureArchive(newFS)
+		}
+		if err == nil {
+			err = validateArchive(newFS)
+		}
+		if err == nil && h.mountVerify {
+			err = newFS.Verify(h.verifyWorkers)
+		}
+		if err != nil {
+			newFS.Close()
+		}
+	}
+	if err != nil {
+		h.logError("StageFs", err)
+		recordError(r, stagePath, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	h.mountMutex.Lock()
+	if h.staged == nil {
+		h.staged = map[string]*FileSystem{}
+	}
+	if old := h.staged[zipPath]; old != nil {
+		old.Close()
+	}
+	h.staged[zipPath] = newFS
+	h.mountMutex.Unlock()
+
+	makeJsonResponse(w, StageResponseData{
+		FilePath:  zipPath,
+		StagePath: stagePath,
+		Files:     len(newFS.reader.File),
+	}, http.StatusOK)
+}
+
+// Atomically replace a mounted ZIP file with the one staged for it.
+// Requests already being served keep using the old ZIP file, which is
+// closed once they have finished. The mount keeps its position and URL
+// prefix, but is known by the path of the staged ZIP file from then on.
+func (h *fileHandler) SwapFs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		h.logErrorf("SwapFs", "Invalid request, not a POST")
+		http.Error(w, "POST request expected.", http.StatusBadRequest)
+		return
+	}
+
+	var m Mount
+	err := json.NewDecoder(r.Body).Decode(&m)
+	if err != nil {
+		h.logError("SwapFs", err)
+		recordError(r, "", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zipPath, ok := h.mountPath(m.FilePath)
+	if !ok {
+		h.logErrorf("SwapFs", "Illegal path access (%s) %s", m.FilePath, zipPath)
+		http.Error(w, "Illegal path access", http.StatusBadRequest)
+		return
+	}
+
+	h.mountMutex.Lock()
+	newFS := h.staged[zipPath]
+	if newFS == nil {
+		h.mountMutex.Unlock()
+		h.logErrorf("SwapFs", "No zip staged for %s", zipPath)
+		http.Error(w, "No zip file staged.", http.StatusNotFound)
+		return
+	}
+	old := h.replaceMountLocked(zipPath, newFS)
+	if old == nil {
+		h.mountMutex.Unlock()
+		h.logErrorf("SwapFs", "Zip not mounted (%s)", zipPath)
+		http.Error(w, "Zip file not mounted.", http.StatusNotFound)
+		return
+	}
+	delete(h.staged, zipPath)
+	h.mountMutex.Unlock()
+
+	// PHP scripts are run from htdocs, so they must be updated as well.
+	if err := h.extractPhpFiles(newFS); err != nil {
+		h.logError("SwapFs", err)
+	}
+
+	go h.retire(old)
+	go h.pregenerateGzip(newFS)
+	go h.hashContents(newFS)
+	if h.slog != nil {
+		h.logMount("Zip Swapped", zipPath, "replacement", newFS.givenPath)
+	} else {
+		h.logf("Zip Swapped: %s -> %s\n", zipPath, newFS.givenPath)
+	}
+
+	makeJsonResponse(w, SimpleResponseData{
+		Message: "Zip file swapped!",
+	}, http.StatusOK)
+}
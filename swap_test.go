@@ -0,0 +1,129 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestZip writes a ZIP file from alternating name and content
+// arguments.
+func writeTestZip(t *testing.T, name string, files ...string) {
+	t.Helper()
+	require := require.New(t)
+
+	f, err := os.Create(name)
+	require.NoError(err)
+	zw := zip.NewWriter(f)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		require.NoError(err)
+		_, err = w.Write([]byte(files[i+1]))
+		require.NoError(err)
+	}
+	require.NoError(zw.Close())
+	require.NoError(f.Close())
+}
+
+// mountTestZip mounts a ZIP file through the mountzip endpoint of handler
+// with the JSON body, and fails the test unless it is mounted.
+func mountTestZip(t *testing.T, handler http.Handler, body string) {
+	t.Helper()
+	w := serveTest(handler, "POST", "/api/mountzip", body)
+	require.Equal(t, http.StatusOK, w.status, w.buf.String())
+}
+
+func TestSwapFs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "blue.zip"), "index.html", "blue")
+	writeTestZip(t, filepath.Join(dir, "green.zip"), "index.html", "green")
+	require.NoError(os.WriteFile(filepath.Join(dir, "broken.zip"), []byte("not a zip"), 0644))
+
+	h := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir()).(*fileHandler)
+	mountTestZip(t, h, `{"filePath": "blue.zip"}`)
+	assert.Equal("blue", serveTest(h, "GET", "/", "").buf.String())
+
+	assert.Equal(404, serveTest(h, "POST", "/api/swapzip", `{"filePath": "blue.zip"}`).status)
+	assert.Equal(404, serveTest(h, "POST", "/api/stagezip", `{"filePath": "other.zip", "stagePath": "green.zip"}`).status)
+	assert.Equal(422, serveTest(h, "POST", "/api/stagezip", `{"filePath": "blue.zip", "stagePath": "broken.zip"}`).status)
+
+	w := serveTest(h, "POST", "/api/stagezip", `{"filePath": "blue.zip", "stagePath": "green.zip"}`)
+	require.Equal(200, w.status, w.buf.String())
+	assert.Contains(w.buf.String(), `"files":1`)
+	assert.Equal("blue", serveTest(h, "GET", "/", "").buf.String())
+
+	// A request in flight keeps the old archive open through the swap
+	mounts, release := h.acquireMounts()
+	require.Equal(200, serveTest(h, "POST", "/api/swapzip", `{"filePath": "blue.zip"}`).status)
+	assert.Equal("green", serveTest(h, "GET", "/", "").buf.String())
+
+	assert.Equal("blue", readTestFile(t, mounts[0], "index.html"))
+	release()
+
+	require.Len(h.mounted(), 1)
+	assert.Equal(filepath.Join(dir, "green.zip"), h.mounted()[0].givenPath)
+	assert.Equal(404, serveTest(h, "POST", "/api/swapzip", `{"filePath": "blue.zip"}`).status)
+	assert.Equal(200, serveTest(h, "POST", "/api/unmountzip", `{"filePath": "green.zip"}`).status)
+}
+
+func TestStageFsSettings(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "blue.zip"), "index.html", "blue")
+	writeTestZip(t, filepath.Join(dir, "green.zip"), "index.html", "green")
+
+	// Staged archives are opened and configured like mounted ones
+	h := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir(),
+		WithInMemoryMounts(0),
+		WithSymlinkResolution(false),
+	).(*fileHandler)
+	mountTestZip(t, h, `{"filePath": "blue.zip", "password": "secret"}`)
+	require.Equal(200, serveTest(h, "POST", "/api/stagezip", `{"filePath": "blue.zip", "stagePath": "green.zip"}`).status)
+	require.Equal(200, serveTest(h, "POST", "/api/swapzip", `{"filePath": "blue.zip"}`).status)
+
+	require.Len(h.mounted(), 1)
+	swapped := h.mounted()[0]
+	assert.True(swapped.inMemory)
+	assert.True(swapped.noSymlinks)
+	assert.Equal([]byte("secret"), swapped.password)
+}
+
+func TestRemountFs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "game.zip")
+	writeTestZip(t, zipPath, "index.html", "v1")
+
+	h := EmptyFileServer("api/", "", false, []string{"html"}, dir, "", nil, nil, t.TempDir()).(*fileHandler)
+	mountTestZip(t, h, `{"filePath": "game.zip", "urlPrefix": "/game/"}`)
+	assert.Equal("v1", serveTest(h, "GET", "/game/", "").buf.String())
+
+	// Replace the zip on disk the way an updater would
+	writeTestZip(t, filepath.Join(dir, "game.zip.new"), "index.html", "v2")
+	require.NoError(os.Rename(filepath.Join(dir, "game.zip.new"), zipPath))
+
+	// A request in flight keeps reading from the old archive
+	mounts, release := h.acquireMounts()
+	w := serveTest(h, "POST", "/api/mountzip", `{"filePath": "game.zip"}`)
+	require.Equal(200, w.status)
+	assert.Contains(w.buf.String(), "Zip file remounted!")
+	assert.Equal("v2", serveTest(h, "GET", "/game/", "").buf.String())
+
+	assert.Equal("v1", readTestFile(t, mounts[0], "index.html"))
+	release()
+
+	require.Len(h.mounted(), 1)
+	assert.True(mounts[0] != h.mounted()[0])
+}
@@ -0,0 +1,131 @@
+package zipfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxSymlinks is the number of symbolic links that are followed to
+// resolve a name, like MAXSYMLINKS on Linux.
+const maxSymlinks = 40
+
+// maxSymlinkTarget is the longest target of a symbolic link that is read.
+const maxSymlinkTarget = 4096
+
+var (
+	errTooManySymlinks = errors.New("zip: too many levels of symbolic links")
+	errSymlinkOutside  = errors.New("zip: symbolic link points outside of the archive")
+)
+
+// SetSymlinkResolution sets whether the symbolic links stored in the
+// archive of fs are followed, which they are by default. Zip files made
+// on Unix can hold symbolic links as entries whose contents are the path
+// they point to. Links are followed within the archive only, and links
+// to absolute paths or outside of the archive cannot be opened. Without
+// resolution, links are served as files holding the path. It must be
+// called before fs is used.
+func (fs *FileSystem) SetSymlinkResolution(enabled bool) {
+	fs = fs.current()
+	fs.noSymlinks = !enabled
+}
+
+// WithSymlinkResolution sets whether the symbolic links in archives
+// mounted through the API, and in the archives given to the constructor,
+// are followed, see FileSystem.SetSymlinkResolution.
+func WithSymlinkResolution(enabled bool) Option {
+	return func(h *fileHandler) {
+		h.noSymlinks = !enabled
+	}
+}
+
+// isSymlink reports whether the entry of fi is a symbolic link.
+func (fi *fileInfo) isSymlink() bool {
+	return fi.zipFile != nil && fi.zipFile.Mode()&os.ModeSymlink != 0
+}
+
+// readSymlink returns the path that the symbolic link fi points to.
+func (fi *fileInfo) readSymlink() (string, error) {
+	reader, err := fi.open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	target, err := io.ReadAll(io.LimitReader(reader, maxSymlinkTarget))
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
+
+// resolveSymlinks looks up name, following the symbolic links in any of
+// its elements. name must be clean and lower case, and relative to the
+// root of fs. If allowed is not nil, the target of every link followed,
+// and the entry that name resolves to, must be allowed by it.
+func (fs *FileSystem) resolveSymlinks(name string, allowed func(target string) bool) (*fileInfo, error) {
+	followed := 0
+	resolved, rest := "", name
+	for rest != "" {
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		if elem == "" || elem == "." {
+			continue
+		}
+		current := path.Join(resolved, elem)
+		fi := fs.fileInfos[current]
+		if fi == nil {
+			return nil, os.ErrNotExist
+		}
+		if !fi.isSymlink() {
+			resolved = current
+			continue
+		}
+
+		followed++
+		if followed > maxSymlinks {
+			return nil, errTooManySymlinks
+		}
+		target, err := fi.readSymlink()
+		if err != nil {
+			return nil, err
+		}
+		if path.IsAbs(target) {
+			return nil, errSymlinkOutside
+		}
+		// The target is relative to the directory holding the link
+		target = strings.ToLower(path.Join(resolved, target))
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return nil, errSymlinkOutside
+		}
+		if allowed != nil && !allowed(target) {
+			return nil, os.ErrNotExist
+		}
+		resolved, rest = "", path.Join(target, rest)
+	}
+
+	fi := fs.fileInfos[resolved]
+	if fi == nil || followed > 0 && allowed != nil && !allowed(resolved) {
+		return nil, os.ErrNotExist
+	}
+	return fi, nil
+}
+
+// openServable opens name in the archive fs like openFileInfo, except
+// that symbolic links are only followed to entries that would be served
+// if they were requested directly, so that links cannot reach hidden,
+// blocked or configuration files.
+func (h *fileHandler) openServable(fs *FileSystem, name string) (*fileInfo, error) {
+	prefix := h.mountPrefix(fs)
+	return fs.openAllowedFileInfo(name, func(target string) bool {
+		urlPath := path.Join("/", prefix, target)
+		if h.isHidden(urlPath) || !h.pathAllowed(urlPath) {
+			return false
+		}
+		if h.dirConfig && isDirConfigFile(target) {
+			return false
+		}
+		return !(h.headersFile && isHeadersFile(target) || h.redirectsFile && isRedirectsFile(target))
+	})
+}
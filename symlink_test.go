@@ -0,0 +1,120 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLinkedFileSystem returns a FileSystem of files, from alternating
+// name and content arguments, and of the symbolic links in links, from
+// their names to their targets.
+func newTestLinkedFileSystem(t *testing.T, links map[string]string, files ...string) *FileSystem {
+	t.Helper()
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, contents string, mode os.FileMode) {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(mode)
+		w, err := zw.CreateHeader(header)
+		require.NoError(err)
+		w.Write([]byte(contents))
+	}
+	for i := 0; i+1 < len(files); i += 2 {
+		add(files[i], files[i+1], 0644)
+	}
+	for name, target := range links {
+		add(name, target, os.ModeSymlink|0777)
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "links.zip")
+	require.NoError(err)
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+func TestSymlinks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := newTestLinkedFileSystem(t, map[string]string{
+		"site/latest": "v2",
+		"home.html":   "site/latest/index.html",
+		"Upper.html":  "SITE/V2/INDEX.HTML",
+		"escape.html": "../outside.html",
+		"absolute":    "/etc/passwd",
+		"loop":        "loop",
+	}, "site/v2/index.html", "index")
+
+	for _, name := range []string{"site/v2/index.html", "site/latest/index.html", "home.html", "upper.html"} {
+		assert.Equal("index", readTestFile(t, fs, name), name)
+	}
+	info, err := fs.Stat("site/latest")
+	require.NoError(err)
+	assert.True(info.IsDir())
+
+	_, err = iofs.ReadFile(fs, "escape.html")
+	assert.True(errors.Is(err, errSymlinkOutside), err)
+	_, err = iofs.ReadFile(fs, "absolute")
+	assert.True(errors.Is(err, errSymlinkOutside), err)
+	_, err = iofs.ReadFile(fs, "loop")
+	assert.True(errors.Is(err, errTooManySymlinks), err)
+	_, err = iofs.ReadFile(fs, "site/latest/missing.html")
+	assert.True(errors.Is(err, os.ErrNotExist), err)
+
+	assert.Equal("index", serveTest(FileServer(fs, "api/", "", false, nil, nil), "GET", "/home.html", "").buf.String())
+
+	// Without resolution, links are files holding their target
+	handler := FileServer(fs, "api/", "", false, nil, nil, WithSymlinkResolution(false))
+	assert.Equal("site/latest/index.html", serveTest(handler, "GET", "/home.html", "").buf.String())
+	_, err = iofs.ReadFile(fs, "site/latest/index.html")
+	assert.True(errors.Is(err, os.ErrNotExist), err)
+}
+
+func TestSymlinkRules(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := newTestLinkedFileSystem(t, map[string]string{
+		"public/a.txt":     "../admin/secret.txt",
+		"public/b.txt":     "../.git/config",
+		"public/admin":     "../admin",
+		"public/hop.txt":   "../admin/next.txt",
+		"admin/next.txt":   "../public/ok.txt",
+		"public/rc":        "../.zipfsrc",
+		"public/headers":   "../_headers",
+		"public/redirects": "../_redirects",
+		"public/link.txt":  "ok.txt",
+	},
+		"admin/secret.txt", "SECRET",
+		".git/config", "GITCFG",
+		"public/ok.txt", "ok",
+		".zipfsrc", "{}",
+		"_headers", "/*\n  X-Test: 1\n",
+		"_redirects", "/old /new\n",
+	)
+
+	// Links cannot reach what would be refused if requested directly
+	handler := FileServer(fs, "api/", "", false, nil, nil,
+		WithPathRules(nil, []string{"/admin/**"}),
+		WithHiddenPatterns(".*"),
+	)
+	for _, target := range []string{"/admin/secret.txt", "/.git/config", "/public/a.txt", "/public/b.txt", "/public/admin/secret.txt", "/public/hop.txt"} {
+		assert.Equal(404, serveTest(handler, "GET", target, "").status, target)
+	}
+	assert.Equal("ok", serveTest(handler, "GET", "/public/link.txt", "").buf.String())
+
+	// Nor can they reach configuration files
+	handler = FileServer(fs, "api/", "", false, nil, nil, WithDirConfig(), WithHeadersFile(), WithRedirectsFile())
+	for _, target := range []string{"/public/rc", "/public/headers", "/public/redirects"} {
+		assert.Equal(404, serveTest(handler, "GET", target, "").status, target)
+	}
+	assert.Equal("SECRET", serveTest(handler, "GET", "/public/a.txt", "").buf.String())
+}
@@ -0,0 +1,176 @@
+package zipfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var errNotArchive = errors.New("zip: not a valid zip, tar or tar.gz file")
+
+// isTar reports whether the file in r is a tar file, which has the
+// "ustar" magic of POSIX and GNU tar files in its first header.
+func isTar(r io.ReaderAt) bool {
+	magic := make([]byte, 5)
+	_, err := r.ReadAt(magic, 257)
+	return err == nil && string(magic) == "ustar"
+}
+
+// isGzip reports whether the file in r is gzip compressed.
+func isGzip(r io.ReaderAt) bool {
+	magic := make([]byte, 2)
+	_, err := r.ReadAt(magic, 0)
+	return err == nil && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// newFromTar returns a file system of the tar file, or gzip compressed
+// tar file, in readerAt. Tar files have no index, so they are read once
+// to find the offsets and CRCs of their files. A Zip file storing the
+// files without compression is then made up from them, which reads their
+// data from the tar file in place. Gzip compressed tar files cannot be
+// read in place, so they are decompressed to a temporary file first.
+func newFromTar(readerAt io.ReaderAt, size int64, closer io.Closer, filePath string) (*FileSystem, error) {
+	tarReaderAt, tarSize := readerAt, size
+	var tempCloser io.Closer
+	if isGzip(readerAt) {
+		file, err := decompressGzipFile(io.NewSectionReader(readerAt, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			tempFileCloser{file}.Close()
+			return nil, err
+		}
+		tarReaderAt, tarSize = file, info.Size()
+		tempCloser = tempFileCloser{file}
+	}
+	if !isTar(tarReaderAt) {
+		if tempCloser != nil {
+			tempCloser.Close()
+		}
+		return nil, errNotArchive
+	}
+
+	zipReaderAt, zipSize, err := tarAsZip(tarReaderAt, tarSize)
+	if err == nil {
+		var fs *FileSystem
+		fs, err = NewFromReaderAt(zipReaderAt, zipSize, multiCloser{tempCloser, closer}, filePath)
+		if err == nil {
+			fs.size = size
+			fs.madeUp = true
+			return fs, nil
+		}
+	}
+	if tempCloser != nil {
+		tempCloser.Close()
+	}
+	return nil, err
+}
+
+// decompressGzipFile decompresses r to a temporary file.
+func decompressGzipFile(r io.Reader) (*os.File, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	file, err := os.CreateTemp("", "zipfs")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(file, gr); err != nil {
+		tempFileCloser{file}.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// tarAsZip returns a Zip file of the regular files and directories of
+// the tar file in r. Hard links share the data of the file they link to.
+// Symbolic links, sparse files and other special files are left out.
+func tarAsZip(r io.ReaderAt, size int64) (io.ReaderAt, int64, error) {
+	counter := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(counter)
+
+	type tarFile struct {
+		offset int64 // Of the data in the tar file
+		size   int64
+		crc    uint32
+	}
+	files := map[string]tarFile{}
+	z := &madeUpZip{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		name := strings.TrimLeft(path.Clean("/"+hdr.Name), "/")
+		if name == "" || isSparse(hdr) {
+			continue
+		}
+
+		var file tarFile
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			file.offset = counter.n
+			hash := crc32.NewIEEE()
+			n, err := io.Copy(hash, tr)
+			if err != nil {
+				return nil, 0, err
+			}
+			file.size, file.crc = n, hash.Sum32()
+			files[name] = file
+		case tar.TypeLink:
+			target, ok := files[strings.TrimLeft(path.Clean("/"+hdr.Linkname), "/")]
+			if !ok {
+				continue
+			}
+			file = target
+			files[name] = file
+		case tar.TypeDir:
+			name += "/"
+		default:
+			continue
+		}
+
+		z.add(name, hdr.ModTime, zip.Store, file.crc, file.size)
+		z.data.append(nil, r, file.offset, file.size)
+	}
+	return z.finish()
+}
+
+// isSparse reports whether hdr is of a sparse file, whose data is not
+// stored in one piece.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,80 @@
+package zipfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTar(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	long := strings.Repeat("tarball ", 1000)
+	var tarData bytes.Buffer
+	tw := tar.NewWriter(&tarData)
+	for _, hdr := range []*tar.Header{
+		{Name: "./assets/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime},
+		{Name: "./assets/long.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(long)), ModTime: modTime},
+		{Name: "index.html", Typeflag: tar.TypeReg, Mode: 0644, Size: 5, ModTime: modTime},
+		{Name: "copy.txt", Typeflag: tar.TypeLink, Linkname: "assets/long.txt", ModTime: modTime},
+		{Name: "link.txt", Typeflag: tar.TypeSymlink, Linkname: "index.html", ModTime: modTime},
+	} {
+		require.NoError(tw.WriteHeader(hdr))
+		switch hdr.Name {
+		case "./assets/long.txt":
+			io.WriteString(tw, long)
+		case "index.html":
+			io.WriteString(tw, "index")
+		}
+	}
+	require.NoError(tw.Close())
+	var tgzData bytes.Buffer
+	gw := gzip.NewWriter(&tgzData)
+	gw.Write(tarData.Bytes())
+	require.NoError(gw.Close())
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "bundle.tar.gz"), tgzData.Bytes(), 0644))
+	tgz, err := New(filepath.Join(dir, "bundle.tar.gz"))
+	require.NoError(err)
+	defer tgz.Close()
+	plain, err := NewFromBytes(tarData.Bytes(), "bundle.tar")
+	require.NoError(err)
+	defer plain.Close()
+
+	for _, fs := range []*FileSystem{plain, tgz} {
+		assert.Equal(long, readTestFile(t, fs, "assets/long.txt"), fs.givenPath)
+		assert.Equal(long, readTestFile(t, fs, "copy.txt"), fs.givenPath)
+		assert.Equal("index", readTestFile(t, fs, "index.html"), fs.givenPath)
+		_, err := fs.Open("link.txt")
+		assert.Error(err, fs.givenPath)
+
+		info, err := fs.Stat("assets")
+		require.NoError(err)
+		assert.True(info.IsDir())
+		info, err = fs.Stat("assets/long.txt")
+		require.NoError(err)
+		assert.Equal(int64(len(long)), info.Size())
+		assert.True(modTime.Equal(info.ModTime()), info.ModTime())
+		assert.NoError(fs.Verify(0))
+	}
+
+	handler := FileServer(tgz, "api/", "", false, []string{"html"}, nil, WithArchiveDownload())
+	assert.Equal("index", serveTest(handler, "GET", "/", "").buf.String())
+	assert.Equal("tarball", serveTest(handler, "GET", "/assets/long.txt", "", "Range", "bytes=8-14").buf.String())
+	assert.Equal(404, serveTest(handler, "GET", "/api/downloadzip", "").status)
+
+	_, err = NewFromBytes(bytes.Repeat([]byte{0}, 1024), "empty")
+	assert.Error(err)
+}
@@ -0,0 +1,206 @@
+package zipfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithTranscodeCache keeps the variants of entries that are made while
+// serving them in files in dir, using up to limit bytes: the gzip and
+// zstd compressed variants made for WithGzip and WithZstd, and the
+// decompressed contents of compressed entries that are needed to serve
+// range requests. The files are named after the CRC and size of the
+// entry and the encoding, so they are shared by every archive holding
+// the same entry, and are still used after a restart. The least
+// recently used files are removed when the limit is reached. Entries
+// without a CRC, and encrypted entries, are not cached.
+func WithTranscodeCache(dir string, limit int64) Option {
+	return func(h *fileHandler) {
+		h.transcodeCache = &transcodeCache{dir: dir, limit: limit}
+	}
+}
+
+// transcodeCache is a cache of the variants of entries in a directory,
+// see WithTranscodeCache.
+type transcodeCache struct {
+	dir   string
+	limit int64
+
+	loadOnce sync.Once
+	mutex    sync.Mutex
+	files    map[string]*transcodeFile // By file name
+	size     int64
+
+	hits   atomic.Int64 // Lookups that found a file, see Stats
+	misses atomic.Int64
+}
+
+type transcodeFile struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// transcodeFileName returns the name of the file holding the variant of
+// fi in encoding, and reports whether it can be cached.
+func transcodeFileName(fi *fileInfo, encoding string) (string, bool) {
+	zf := fi.zipFile
+	if zf.CRC32 == 0 || fi.encrypted() {
+		return "", false
+	}
+	return fmt.Sprintf("%08x-%x.%s", zf.CRC32, zf.UncompressedSize64, encoding), true
+}
+
+// load indexes the files left in the directory by earlier runs.
+func (c *transcodeCache) load() {
+	c.files = map[string]*transcodeFile{}
+	entries, _ := os.ReadDir(c.dir)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), "tmp-") {
+			// Left behind by a run that was interrupted
+			os.Remove(filepath.Join(c.dir, entry.Name()))
+			continue
+		}
+		c.files[entry.Name()] = &transcodeFile{size: info.Size(), lastUsed: info.ModTime()}
+		c.size += info.Size()
+	}
+	c.mutex.Lock()
+	c.evictLocked("")
+	c.mutex.Unlock()
+}
+
+// openFile opens the cached file name, calling fill to create it if it
+// is not in the cache. If the new file could not be added to the cache,
+// temporary is true and the caller must remove the file after closing
+// it.
+func (c *transcodeCache) openFile(name string, now time.Time, fill func(io.Writer) error) (file *os.File, temporary bool, err error) {
+	c.loadOnce.Do(c.load)
+	path := filepath.Join(c.dir, name)
+
+	c.mutex.Lock()
+	f := c.files[name]
+	if f != nil {
+		f.lastUsed = now
+	}
+	c.mutex.Unlock()
+	if f != nil {
+		file, err := os.Open(path)
+		if err == nil {
+			// The modification time is the last use for the next run
+			os.Chtimes(path, now, now)
+			c.hits.Add(1)
+			return file, false, nil
+		}
+		// The file was evicted, or removed by something else
+		c.mutex.Lock()
+		c.removeLocked(name)
+		c.mutex.Unlock()
+	}
+	c.misses.Add(1)
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, false, err
+	}
+	file, err = os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return nil, false, err
+	}
+	size, err := countedFill(file, fill)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, false, err
+	}
+	if size > c.limit || os.Rename(file.Name(), path) != nil {
+		return file, true, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.removeLocked(name) // Filled by another request at the same time
+	c.files[name] = &transcodeFile{size: size, lastUsed: now}
+	c.size += size
+	c.evictLocked(name)
+	return file, false, nil
+}
+
+// evictLocked removes the least recently used files, other than keep,
+// until the cache is within its limit.
+func (c *transcodeCache) evictLocked(keep string) {
+	for c.size > c.limit {
+		victim := ""
+		for name, f := range c.files {
+			if name != keep && (victim == "" || f.lastUsed.Before(c.files[victim].lastUsed)) {
+				victim = name
+			}
+		}
+		if victim == "" {
+			return
+		}
+		c.removeLocked(victim)
+		os.Remove(filepath.Join(c.dir, victim))
+	}
+}
+
+func (c *transcodeCache) removeLocked(name string) {
+	if f := c.files[name]; f != nil {
+		c.size -= f.size
+		delete(c.files, name)
+	}
+}
+
+// serveTranscoded serves the variant of fi in encoding from the
+// transcode cache, calling fill to make it first if necessary, and
+// reports whether it did. The variant "identity" is the decompressed
+// contents, which are served with serveRanges. Other variants are
+// served with their content-encoding, which must already be set.
+func (h *fileHandler) serveTranscoded(w http.ResponseWriter, r *http.Request, fi *fileInfo, encoding string, modtime time.Time, fill func(io.Writer) error) bool {
+	if h.transcodeCache == nil {
+		return false
+	}
+	name, ok := transcodeFileName(fi, encoding)
+	if !ok {
+		return false
+	}
+	file, temporary, err := h.transcodeCache.openFile(name, h.now(), fill)
+	if err != nil {
+		w.Header().Del("Content-Encoding")
+		recordError(r, fi.name, err)
+		msg, code := toHTTPError(err)
+		httpError(w, r, msg, code)
+		return true
+	}
+	defer func() {
+		file.Close()
+		if temporary {
+			os.Remove(file.Name())
+		}
+	}()
+
+	if encoding == "identity" {
+		serveRanges(w, r, fi.Name(), modtime, fi.Size(), file)
+		return true
+	}
+	// http.ServeContent leaves out the Content-Length of encoded
+	// responses, so the file is copied instead.
+	if stat, err := file.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", stat.Size()))
+	}
+	if r.Method != "HEAD" {
+		io.Copy(w, file)
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscodeCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("transcode me ", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "page.html", Method: zip.Store})
+	require.NoError(err)
+	fw.Write([]byte(content))
+	fw, err = zw.Create("deflated.txt")
+	require.NoError(err)
+	fw.Write([]byte(content + "deflated"))
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newHandler := func(limit int64) http.Handler {
+		return FileServer(fs, "api/", "", false, nil, nil,
+			WithClock(clock),
+			WithGzip(gzip.BestSpeed),
+			WithZstd(false),
+			WithTranscodeCache(dir, limit),
+		)
+	}
+	handler := newHandler(1 << 20)
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+		require.NoError(err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	gunzip := func(data []byte) string {
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(err)
+		data, err = io.ReadAll(gr)
+		require.NoError(err)
+		return string(data)
+	}
+
+	for i := 0; i < 2; i++ {
+		w := serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "gzip")
+		require.Equal(200, w.status)
+		assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+		assert.NotEmpty(w.Header().Get("Content-Length"))
+		assert.Equal(content, gunzip(w.buf.Bytes()))
+	}
+	gzipName, _ := transcodeFileName(fs.fileInfos["page.html"], "gz1")
+	assert.Equal([]string{gzipName}, files())
+
+	w := serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "zstd")
+	assert.Equal("zstd", w.Header().Get("Content-Encoding"))
+	w = serveTest(handler, "GET", "/deflated.txt", "", "Range", "bytes=13-21")
+	assert.Equal(http.StatusPartialContent, w.status)
+	assert.Equal("transcode", w.buf.String())
+	assert.Len(files(), 3)
+
+	// A new handler finds the files of the earlier one, and evicts the
+	// least recently used ones to stay within its limit
+	clock.now = clock.now.Add(time.Minute)
+	handler = newHandler(1 << 20)
+	require.NoError(os.WriteFile(filepath.Join(dir, gzipName), []byte("not gzip"), 0644))
+	assert.Equal("not gzip", serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "gzip").buf.String())
+	handler = newHandler(500)
+	serveTest(handler, "GET", "/page.html", "", "Accept-Encoding", "gzip")
+	assert.Equal([]string{gzipName}, files())
+}
@@ -0,0 +1,204 @@
+package zipfs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	_ iofs.FS          = (*Union)(nil)
+	_ iofs.StatFS      = (*Union)(nil)
+	_ iofs.ReadDirFS   = (*Union)(nil)
+	_ iofs.ReadDirFile = (*unionDir)(nil)
+)
+
+// Union is a read-only file system made of several archives layered on
+// top of each other, such as base content and a patch for it. Lookups
+// fall through the layers in order, so a file in an earlier layer hides
+// the file with the same name in the later ones. Directories are merged:
+// listing one returns the entries of every layer that has it.
+//
+// A Union does not own its layers, which must be closed by the caller.
+// To serve a union over HTTP, pass its layers to FileServers, which
+// looks files up in the same order.
+type Union struct {
+	layers []*FileSystem
+}
+
+// NewUnion returns the union of layers, with the first layer on top.
+func NewUnion(layers []*FileSystem) *Union {
+	return &Union{layers: append([]*FileSystem(nil), layers...)}
+}
+
+// Layers returns the layers of the union, with the first layer on top.
+func (u *Union) Layers() []*FileSystem {
+	return append([]*FileSystem(nil), u.layers...)
+}
+
+// Open implements the io/fs.FS interface. Names are matched
+// case-insensitively. Files are opened from the first layer that has
+// them. Directories also implement io/fs.ReadDirFile, listing the
+// merged entries of all layers.
+func (u *Union) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+	fi, err := u.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return fi.openReader(name), nil
+	}
+	infos, err := u.readdir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &unionDir{name: name, fileInfo: fi, readdir: infos}, nil
+}
+
+// Stat returns the FileInfo of the named entry in the first layer that
+// has it. The concrete type of the result is *FileInfo.
+func (u *Union) Stat(name string) (os.FileInfo, error) {
+	fi, err := u.lookup(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "Stat", Path: name, Err: err}
+	}
+	return fi.info(), nil
+}
+
+// ReadDir implements the io/fs.ReadDirFS interface. The entries of all
+// layers that have the directory are merged and sorted by name. An
+// entry in an earlier layer hides the entries with the same name in
+// later ones, even if one is a file and the other a directory.
+func (u *Union) ReadDir(name string) ([]iofs.DirEntry, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: iofs.ErrInvalid}
+	}
+	fi, err := u.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: errNotDirectory}
+	}
+	infos, err := u.readdir(name)
+	if err != nil {
+		return nil, err
+	}
+	return dirEntries(infos), nil
+}
+
+// lookup returns the entry name of the first layer that has it, or the
+// error of the first layer if none does. A file in a layer hides
+// everything below the same path in later layers.
+func (u *Union) lookup(name string) (*fileInfo, error) {
+	var firstErr error
+	for _, layer := range u.layers {
+		fi, err := layer.openFileInfo(name)
+		if err == nil {
+			return fi, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if hasFileAbove(layer, name) {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = os.ErrNotExist
+	}
+	return nil, firstErr
+}
+
+// readdir returns the merged entries of the directory name.
+func (u *Union) readdir(name string) ([]os.FileInfo, error) {
+	seen := map[string]bool{}
+	var infos []os.FileInfo
+	for _, layer := range u.layers {
+		fi, err := layer.openFileInfo(name)
+		if err != nil {
+			if hasFileAbove(layer, name) {
+				break
+			}
+			continue
+		}
+		if !fi.IsDir() {
+			break
+		}
+		layerInfos, err := fi.readdir()
+		if err != nil {
+			return nil, &iofs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, info := range layerInfos {
+			key := strings.ToLower(info.Name())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+// hasFileAbove reports whether fs has a file at one of the parent
+// directories of name.
+func hasFileAbove(fs *FileSystem, name string) bool {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if fi, err := fs.openFileInfo(dir); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// unionDir is a directory opened from a Union.
+type unionDir struct {
+	name     string
+	fileInfo *fileInfo
+	readdir  []os.FileInfo // Entries that have not been returned yet
+	closed   bool
+}
+
+func (d *unionDir) Stat() (os.FileInfo, error) {
+	return d.fileInfo.info(), nil
+}
+
+func (d *unionDir) Read(p []byte) (int, error) {
+	return 0, &iofs.PathError{Op: "Read", Path: d.name, Err: errDirectory}
+}
+
+func (d *unionDir) Close() error {
+	d.closed = true
+	return nil
+}
+
+// ReadDir implements the io/fs.ReadDirFile interface.
+func (d *unionDir) ReadDir(count int) ([]iofs.DirEntry, error) {
+	if d.closed {
+		return nil, &iofs.PathError{Op: "ReadDir", Path: d.name, Err: errFileClosed}
+	}
+	if count <= 0 {
+		infos := d.readdir
+		d.readdir = nil
+		return dirEntries(infos), nil
+	}
+	if len(d.readdir) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.readdir) {
+		count = len(d.readdir)
+	}
+	infos := d.readdir[:count]
+	d.readdir = d.readdir[count:]
+	return dirEntries(infos), nil
+}
@@ -0,0 +1,74 @@
+package zipfs
+
+import (
+	"io"
+	iofs "io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	patch := newTestFileSystem(t,
+		"index.html", "patched",
+		"levels/", "",
+		"levels/2.dat", "level 2",
+		"extra", "patch file",
+	)
+	defer patch.Close()
+	base := newTestFileSystem(t,
+		"index.html", "base",
+		"levels/", "",
+		"levels/1.dat", "level 1",
+		"levels/2.dat", "old level 2",
+		"extra/", "",
+		"extra/readme.txt", "base dir",
+	)
+	defer base.Close()
+
+	u := NewUnion([]*FileSystem{patch, base})
+	require.NoError(fstest.TestFS(u, "index.html", "levels/1.dat", "levels/2.dat", "extra"))
+
+	read := func(name string) string {
+		data, err := iofs.ReadFile(u, name)
+		require.NoError(err, name)
+		return string(data)
+	}
+	assert.Equal("patched", read("index.html"))
+	assert.Equal("level 1", read("levels/1.dat"))
+	assert.Equal("level 2", read("LEVELS/2.dat"))
+	assert.Equal("patch file", read("extra"))
+
+	entries, err := u.ReadDir("levels")
+	require.NoError(err)
+	require.Len(entries, 2)
+	assert.Equal("1.dat", entries[0].Name())
+	assert.Equal("2.dat", entries[1].Name())
+
+	_, err = u.Open("extra/readme.txt")
+	assert.Error(err)
+	_, err = u.Stat("missing")
+	assert.Error(err)
+
+	f, err := u.Open("levels")
+	require.NoError(err)
+	dir := f.(iofs.ReadDirFile)
+	first, err := dir.ReadDir(1)
+	require.NoError(err)
+	assert.Len(first, 1)
+	_, err = dir.ReadDir(1)
+	require.NoError(err)
+	_, err = dir.ReadDir(1)
+	assert.Equal(io.EOF, err)
+	f.Close()
+
+	// The file server looks files up in the same order
+	handler := FileServers(u.Layers(), "api/", "", false, nil, nil)
+	w := serveTest(handler, "GET", "/levels/1.dat", "")
+	assert.Equal("level 1", w.buf.String())
+}
@@ -0,0 +1,106 @@
+package zipfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// Verify decompresses every entry of fs and checks it against the CRC-32
+// recorded in the archive, so that damaged archives can be rejected
+// before they are served. Entries are checked by up to workers
+// goroutines at once, or by one per CPU if workers is not positive. The
+// first error found is returned, and stops the check. Encrypted entries
+// are skipped unless a password is set.
+func (fs *FileSystem) Verify(workers int) error {
+	fs = fs.current()
+	if fs.readerAt == nil {
+		return errFileSystemClosed
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var entries []*fileInfo
+	seen := map[*fileInfo]bool{}
+	for _, fi := range fs.fileInfos {
+		if fi.zipFile != nil && !fi.IsDir() && !seen[fi] && fi.checkPassword() == nil {
+			seen[fi] = true
+			entries = append(entries, fi)
+		}
+	}
+
+	jobs := make(chan *fileInfo)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fi := range jobs {
+				if err := verifyEntry(fi); err != nil {
+					stopOnce.Do(func() {
+						firstErr = fmt.Errorf("%s: %w", fi.zipFile.Name, err)
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, fi := range entries {
+		select {
+		case jobs <- fi:
+		case <-stop:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// verifyEntry reads all of the contents of fi, which checks their CRC.
+func verifyEntry(fi *fileInfo) error {
+	reader, err := fi.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// WithMountVerification checks every entry of the archives mounted
+// through the mountZIP API endpoint with FileSystem.Verify, using up to
+// workers goroutines, before they are served. Damaged archives are not
+// mounted. Without this option, a mount request can ask for the check
+// with "verify": true.
+func WithMountVerification(workers int) Option {
+	return func(h *fileHandler) {
+		h.mountVerify = true
+		h.verifyWorkers = workers
+	}
+}
+
+// verifyMount checks newFS before it is mounted for the mount request m
+// if the check is enabled or requested, and reports whether it may be
+// mounted. Otherwise it closes newFS and responds with the error.
+func (h *fileHandler) verifyMount(w http.ResponseWriter, r *http.Request, m Mount, newFS *FileSystem) bool {
+	if !h.mountVerify && !m.Verify {
+		return true
+	}
+	err := newFS.Verify(h.verifyWorkers)
+	if err == nil {
+		return true
+	}
+	newFS.Close()
+	h.logError("MountFs", err)
+	recordError(r, newFS.givenPath, err)
+	http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	return false
+}
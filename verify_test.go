@@ -0,0 +1,59 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "good.zip"), "index.html", "good", "a/b.txt", strings.Repeat("b", 10000))
+
+	// A stored entry whose contents no longer match its CRC
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"index.html", "damaged.txt"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		require.NoError(err)
+		w.Write([]byte("original " + name))
+	}
+	require.NoError(zw.Close())
+	damaged := bytes.Replace(buf.Bytes(), []byte("original damaged"), []byte("ORIGINAL damaged"), 1)
+	require.NoError(os.WriteFile(filepath.Join(dir, "bad.zip"), damaged, 0644))
+
+	fs, err := New(filepath.Join(dir, "good.zip"))
+	require.NoError(err)
+	assert.NoError(fs.Verify(0))
+	fs.Close()
+	assert.Equal(errFileSystemClosed, fs.Verify(0))
+
+	fs, err = New(filepath.Join(dir, "bad.zip"))
+	require.NoError(err)
+	err = fs.Verify(2)
+	assert.True(errors.Is(err, zip.ErrChecksum), err)
+	assert.Contains(err.Error(), "damaged.txt")
+	fs.Close()
+
+	// Damaged archives are only rejected when asked
+	h := EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir())
+	assert.Equal(422, serveTest(h, "POST", "/api/mountzip", `{"filePath": "bad.zip", "verify": true}`).status)
+	assert.Equal(200, serveTest(h, "POST", "/api/mountzip", `{"filePath": "bad.zip"}`).status)
+
+	h = EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), WithMountVerification(4))
+	assert.Equal(422, serveTest(h, "POST", "/api/mountzip", `{"filePath": "bad.zip"}`).status)
+	assert.Equal(200, serveTest(h, "POST", "/api/mountzip", `{"filePath": "good.zip"}`).status)
+	mounts, release := h.(*fileHandler).acquireMounts()
+	assert.Len(mounts, 1)
+	release()
+}
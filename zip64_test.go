@@ -0,0 +1,137 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sparseZip is a zip file with a single stored entry whose content is
+// generated rather than kept in memory, which allows testing entries
+// larger than 4 GB.
+type sparseZip struct {
+	head     []byte // Everything before the entry content
+	dataSize int64
+	tail     []byte // Everything after the entry content
+	mode     int
+}
+
+func (z *sparseZip) Write(b []byte) (int, error) {
+	switch z.mode {
+	case 0:
+		z.head = append(z.head, b...)
+	case 1:
+		z.dataSize += int64(len(b))
+	default:
+		z.tail = append(z.tail, b...)
+	}
+	return len(b), nil
+}
+
+func (z *sparseZip) size() int64 {
+	return int64(len(z.head)) + z.dataSize + int64(len(z.tail))
+}
+
+// sparseByte is the content of the entry at offset.
+func sparseByte(offset int64) byte {
+	return byte(offset % 251)
+}
+
+func (z *sparseZip) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		switch {
+		case pos < int64(len(z.head)):
+			n += copy(p[n:], z.head[pos:])
+		case pos < int64(len(z.head))+z.dataSize:
+			p[n] = sparseByte(pos - int64(len(z.head)))
+			n++
+		case pos < z.size():
+			n += copy(p[n:], z.tail[pos-int64(len(z.head))-z.dataSize:])
+		default:
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func newSparseZip(t *testing.T, name string, size int64) *sparseZip {
+	require := require.New(t)
+
+	z := &sparseZip{}
+	zw := zip.NewWriter(z)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              0x12345678,
+		CompressedSize64:   uint64(size),
+		UncompressedSize64: uint64(size),
+	})
+	require.NoError(err)
+	require.NoError(zw.Flush())
+
+	// Only the number of bytes written matters, as the content itself
+	// is generated by ReadAt.
+	z.mode = 1
+	chunk := make([]byte, 1<<20)
+	for remaining := size; remaining > 0; {
+		n := int64(len(chunk))
+		if remaining < n {
+			n = remaining
+		}
+		_, err := w.Write(chunk[:n])
+		require.NoError(err)
+		remaining -= n
+	}
+	require.NoError(zw.Flush())
+	z.mode = 2
+	require.NoError(zw.Close())
+	return z
+}
+
+func TestZip64(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const size = 5<<30 + 123
+	z := newSparseZip(t, "huge.bin", size)
+	fs, err := NewFromReaderAt(z, z.size(), nil, "huge.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	stat, err := fs.Stat("/huge.bin")
+	require.NoError(err)
+	assert.Equal(int64(size), stat.Size())
+
+	// Entries that only differ in the high bits of their size have
+	// different ETags
+	small := *fs.reader.File[0]
+	small.UncompressedSize64 = size & 0xffffffff
+	etag := calcEtag(fs.reader.File[0])
+	assert.NotEqual(calcEtag(&small), etag)
+	assert.Equal(stat.(*FileInfo).ETag, etag)
+
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	w := serveTest(handler, "HEAD", "/huge.bin", "")
+	assert.Equal(200, w.status)
+	assert.Equal(fmt.Sprint(size), w.Header().Get("Content-Length"))
+	assert.Equal(etag, w.Header().Get("Etag"))
+
+	// Ranges beyond 4 GB are served straight from the archive
+	start := int64(size - 1000)
+	w = serveTest(handler, "GET", "/huge.bin", "", "Range", fmt.Sprintf("bytes=%d-", start))
+	require.Equal(206, w.status)
+	assert.Equal("1000", w.Header().Get("Content-Length"))
+	assert.Equal(fmt.Sprintf("bytes %d-%d/%d", start, size-1, size), w.Header().Get("Content-Range"))
+	want := make([]byte, 1000)
+	for i := range want {
+		want[i] = sparseByte(start + int64(i))
+	}
+	assert.True(bytes.Equal(want, w.buf.Bytes()))
+}
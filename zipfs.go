@@ -1,4 +1,4 @@
-// Package zipfs provides an implementation of the net/http.FileSystem
+// Package zipfs provides an implementation of the io/fs.FS
 // interface based on the contents of a ZIP file. It also provides
 // the FileServer function, which returns a net/http.Handler that
 // serves static files from a ZIP file. This HTTP handler exploits
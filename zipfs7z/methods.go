@@ -0,0 +1,59 @@
+package zipfs7z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Compression methods of Zip entries that are registered by this
+// package.
+const (
+	methodLZMA = 14
+	methodXZ   = 95
+)
+
+func init() {
+	zipfs.RegisterSizedDecompressor(methodLZMA, decompressLZMA)
+	zipfs.RegisterSizedDecompressor(methodXZ, decompressXZ)
+}
+
+var errLZMAHeader = errors.New("zip: invalid LZMA header")
+
+// decompressLZMA decompresses LZMA entries, whose data starts with a
+// version and the LZMA properties. Unlike in .lzma files, the size of
+// the contents is not stored with them, but in the Zip headers, so it
+// is added to make up a .lzma header.
+func decompressLZMA(r io.Reader, size uint64) (io.ReadCloser, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errLZMAHeader
+	}
+	props := make([]byte, binary.LittleEndian.Uint16(header[2:]))
+	if len(props) != 5 {
+		return nil, errLZMAHeader
+	}
+	if _, err := io.ReadFull(r, props); err != nil {
+		return nil, errLZMAHeader
+	}
+	lzmaHeader := binary.LittleEndian.AppendUint64(props, size)
+	lr, err := lzma.NewReader(io.MultiReader(bytes.NewReader(lzmaHeader), r))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(lr), nil
+}
+
+// decompressXZ decompresses XZ entries, whose data is an .xz file.
+func decompressXZ(r io.Reader, size uint64) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(xr), nil
+}
@@ -0,0 +1,78 @@
+package zipfs7z_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/FlashpointProject/zipfs"
+	_ "github.com/FlashpointProject/zipfs/zipfs7z"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// lzmaEntry returns content compressed as a Zip LZMA entry, with or
+// without an end of stream marker.
+func lzmaEntry(t *testing.T, content string, eos bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg := lzma.WriterConfig{SizeInHeader: !eos, Size: int64(len(content)), EOSMarker: eos}
+	if eos {
+		cfg.Size = 0
+	}
+	w, err := cfg.NewWriter(&buf)
+	require.NoError(t, err)
+	io.WriteString(w, content)
+	require.NoError(t, w.Close())
+	// A .lzma header is the properties and the size of the contents
+	data := buf.Bytes()
+	return append([]byte{9, 20, 5, 0}, append(data[:5:5], data[lzma.HeaderLen:]...)...)
+}
+
+func TestMethods(t *testing.T) {
+	require := require.New(t)
+
+	long := strings.Repeat("compressed entry ", 1000)
+	var xzData bytes.Buffer
+	xw, err := xz.NewWriter(&xzData)
+	require.NoError(err)
+	io.WriteString(xw, long)
+	require.NoError(xw.Close())
+
+	entries := []struct {
+		name   string
+		method uint16
+		data   []byte
+	}{
+		{"lzma.txt", 14, lzmaEntry(t, long, false)},
+		{"lzma-eos.txt", 14, lzmaEntry(t, long, true)},
+		{"xz.txt", 95, xzData.Bytes()},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               e.name,
+			Method:             e.method,
+			CRC32:              crc32.ChecksumIEEE([]byte(long)),
+			CompressedSize64:   uint64(len(e.data)),
+			UncompressedSize64: uint64(len(long)),
+		})
+		require.NoError(err)
+		w.Write(e.data)
+	}
+	require.NoError(zw.Close())
+
+	fs, err := zipfs.NewFromBytes(buf.Bytes(), "methods.zip")
+	require.NoError(err)
+	defer fs.Close()
+	handler := zipfstest.Handler(t, fs)
+	for _, e := range entries {
+		zipfstest.Get(t, handler, "/"+e.name).AssertStatus(200).AssertBody(long)
+	}
+}
@@ -0,0 +1,44 @@
+// Package zipfs7z adds support for 7z archives, and for Zip entries
+// compressed with LZMA and XZ, the methods of 7-Zip, to package zipfs.
+// It is a package of its own so that programs that do not serve them do
+// not depend on the 7z reader and the decoders it uses. It is imported
+// for its side effect of registering the format and the methods:
+//
+//	import _ "github.com/FlashpointProject/zipfs/zipfs7z"
+package zipfs7z
+
+import (
+	"io"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/bodgit/sevenzip"
+)
+
+func init() {
+	zipfs.RegisterFormat("7z\xbc\xaf\x27\x1c", read)
+}
+
+// read lists the files of the 7z archive in r. The files of a 7z archive
+// are compressed together in blocks, so their contents are read with the
+// 7z reader, which keeps its place in a block so that reading the files
+// of a block in order does not decompress it again for each of them.
+// Encrypted 7z archives are not supported.
+func read(r io.ReaderAt, size int64) ([]zipfs.Entry, error) {
+	szReader, err := sevenzip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []zipfs.Entry
+	for _, f := range szReader.File {
+		entries = append(entries, zipfs.Entry{
+			Name:     f.Name,
+			Dir:      f.FileInfo().IsDir(),
+			Modified: f.Modified,
+			CRC32:    f.CRC32,
+			Size:     int64(f.UncompressedSize),
+			Open:     f.Open,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,110 @@
+package zipfs7z_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	iofs "io/fs"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/FlashpointProject/zipfs"
+	_ "github.com/FlashpointProject/zipfs/zipfs7z"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// write7z returns a 7z archive from alternating name and content
+// arguments, with the files stored without compression in one block.
+func write7z(modTime time.Time, files ...string) []byte {
+	le := binary.LittleEndian
+	number := func(b []byte, n int) []byte {
+		return le.AppendUint64(append(b, 0xff), uint64(n))
+	}
+
+	var packed []byte
+	var names []byte
+	var sizes []int
+	for i := 0; i+1 < len(files); i += 2 {
+		packed = append(packed, files[i+1]...)
+		sizes = append(sizes, len(files[i+1]))
+		for _, c := range utf16.Encode([]rune(files[i])) {
+			names = le.AppendUint16(names, c)
+		}
+		names = append(names, 0, 0)
+	}
+
+	h := []byte{0x01, 0x04}
+	h = number(append(h, 0x06), 0) // Pack info at the start
+	h = number(append(number(h, 1), 0x09), len(packed))
+	h = append(h, 0x00, 0x07, 0x0b, 0x01, 0x00) // One folder
+	h = append(h, 0x01, 0x01, 0x00)             // With the copy coder
+	h = append(number(append(h, 0x0c), len(packed)), 0x00)
+	h = number(append(h, 0x08, 0x0d), len(sizes)) // Substreams
+	h = append(h, 0x09)
+	for _, size := range sizes[:len(sizes)-1] {
+		h = number(h, size)
+	}
+	h = append(h, 0x0a, 0x01)
+	for i := 0; i+1 < len(files); i += 2 {
+		h = le.AppendUint32(h, crc32.ChecksumIEEE([]byte(files[i+1])))
+	}
+	h = append(h, 0x00, 0x00)
+	h = number(append(h, 0x05), len(sizes)) // Files
+	h = append(number(append(h, 0x11), len(names)+1), 0x00)
+	h = append(h, names...)
+	h = append(number(append(h, 0x14), 2+8*len(sizes)), 0x01, 0x00)
+	for range sizes {
+		h = le.AppendUint64(h, uint64(modTime.Unix()+11644473600)*10000000)
+	}
+	h = append(h, 0x00, 0x00)
+
+	start := le.AppendUint64(nil, uint64(len(packed)))
+	start = le.AppendUint64(start, uint64(len(h)))
+	start = le.AppendUint32(start, crc32.ChecksumIEEE(h))
+	archive := append([]byte("7z\xbc\xaf\x27\x1c\x00\x04"), le.AppendUint32(nil, crc32.ChecksumIEEE(start))...)
+	archive = append(archive, start...)
+	archive = append(archive, packed...)
+	return append(archive, h...)
+}
+
+func Test7z(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	modTime := time.Date(2010, 1, 2, 3, 4, 6, 0, time.UTC)
+	long := strings.Repeat("seven zip ", 1000)
+	data := write7z(modTime, "index.html", "index", "assets/long.txt", long, "assets/other.txt", "other")
+	fs, err := zipfs.NewFromBytes(data, "bundle.7z")
+	require.NoError(err)
+	defer fs.Close()
+
+	for name, content := range map[string]string{"index.html": "index", "assets/other.txt": "other", "assets/long.txt": long} {
+		data, err := iofs.ReadFile(fs, name)
+		require.NoError(err)
+		assert.Equal(content, string(data))
+	}
+	info, err := fs.Stat("assets/long.txt")
+	require.NoError(err)
+	assert.Equal(int64(len(long)), info.Size())
+	assert.True(modTime.Equal(info.ModTime()), info.ModTime())
+	assert.NoError(fs.Verify(2))
+
+	handler := zipfstest.Handler(t, fs)
+	zipfstest.Get(t, handler, "/").AssertBody("index")
+	zipfstest.Get(t, handler, "/assets/long.txt").AssertBody(long)
+	zipfstest.Get(t, handler, "/assets/long.txt", "Range", "bytes=6-8").AssertStatus(206).AssertBody("zip")
+
+	// The CRCs of the files are checked
+	damaged, err := zipfs.NewFromBytes(bytes.Replace(data, []byte("other"), []byte("OTHER"), 1), "damaged.7z")
+	require.NoError(err)
+	defer damaged.Close()
+	f, err := damaged.Open("assets/other.txt")
+	require.NoError(err)
+	_, err = io.ReadAll(f)
+	assert.EqualError(err, "checksum error")
+}
@@ -0,0 +1,70 @@
+// Package zipfsmd renders the markdown entries served by package zipfs
+// to HTML. It is a package of its own so that programs that do not
+// render markdown do not depend on the markdown parser.
+//
+//	h := zipfs.FileServerWithOptions(fs, zipfsmd.WithMarkdown(nil))
+package zipfsmd
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"path"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// DefaultTemplate is the template used to wrap rendered markdown
+// documents when WithMarkdown is given a nil template.
+var DefaultTemplate = template.Must(template.New("markdown").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{.Content}}
+</body>
+</html>
+`))
+
+// Page is the data passed to the markdown template.
+type Page struct {
+	Title   string        // Name of the markdown file
+	Path    string        // Path of the markdown file inside the archive
+	Content template.HTML // Rendered document
+}
+
+// WithMarkdown enables rendering of .md entries to HTML. The rendered
+// document is passed to tmpl as a Page, or to DefaultTemplate if tmpl is
+// nil. Raw HTML inside documents is not passed through. Rendered pages
+// are cached like those of any zipfs.Renderer.
+func WithMarkdown(tmpl *template.Template) zipfs.Option {
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	return zipfs.WithRenderer(".md", renderer{
+		tmpl:     tmpl,
+		markdown: goldmark.New(goldmark.WithExtensions(extension.GFM)),
+	})
+}
+
+// renderer is the zipfs.Renderer of WithMarkdown.
+type renderer struct {
+	tmpl     *template.Template
+	markdown goldmark.Markdown
+}
+
+func (m renderer) Render(w io.Writer, name string, source []byte) error {
+	var content bytes.Buffer
+	if err := m.markdown.Convert(source, &content); err != nil {
+		return err
+	}
+	return m.tmpl.Execute(w, Page{
+		Title:   path.Base(name),
+		Path:    name,
+		Content: template.HTML(content.String()),
+	})
+}
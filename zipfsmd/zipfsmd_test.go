@@ -0,0 +1,42 @@
+package zipfsmd_test
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/FlashpointProject/zipfs/zipfsmd"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := zipfstest.NewArchive().
+		File("readme.md", "# Hello\n\nSome *text* and <script>alert(1)</script>\n").
+		File("notes.txt", "plain").
+		FileSystem(t)
+
+	handler := zipfstest.Handler(t, fs, zipfsmd.WithMarkdown(nil))
+	w := zipfstest.Get(t, handler, "/readme.md").
+		AssertStatus(200).
+		AssertHeader("Content-Type", "text/html; charset=utf-8")
+	assert.Contains(w.Body.String(), "<title>readme.md</title>")
+	assert.Contains(w.Body.String(), "<h1>Hello</h1>")
+	assert.Contains(w.Body.String(), "<em>text</em>")
+	assert.NotContains(w.Body.String(), "<script>")
+
+	etag := w.Header().Get("Etag")
+	assert.Contains(etag, "-md")
+	zipfstest.Get(t, handler, "/readme.md", "If-None-Match", etag).AssertStatus(304)
+
+	zipfstest.Get(t, handler, "/notes.txt").AssertBody("plain")
+
+	tmpl := template.Must(template.New("custom").Parse(`<main data-path="{{.Path}}">{{.Content}}</main>`))
+	handler = zipfstest.Handler(t, fs, zipfsmd.WithMarkdown(tmpl))
+	w = zipfstest.Get(t, handler, "/readme.md").AssertStatus(200)
+	assert.Contains(w.Body.String(), `<main data-path="readme.md"><h1>Hello</h1>`)
+
+	handler = zipfstest.Handler(t, fs)
+	assert.Contains(zipfstest.Get(t, handler, "/readme.md").Body.String(), "# Hello")
+}
@@ -0,0 +1,70 @@
+// Package zipfsotel traces the requests served by the file servers of
+// package zipfs with OpenTelemetry. It is a package of its own so that
+// programs that do not export traces do not depend on OpenTelemetry.
+//
+//	h := zipfs.FileServerWithOptions(fs, zipfsotel.WithTracing(otel.GetTracerProvider()))
+package zipfsotel
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/FlashpointProject/zipfs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation scope of the spans.
+const tracerName = "github.com/FlashpointProject/zipfs"
+
+// WithTracing emits an OpenTelemetry span for every request with the
+// tracers of tp, continuing the trace of the client if the request
+// carries one in the format of the global propagator. Requests for
+// entries get child spans for the lookup, decompress and write phases,
+// as timed by zipfs.WithSlowRequestLog. Decompressing and writing take
+// turns while an entry is served, so their spans are laid end to end,
+// each as long as the total time spent in that phase.
+func WithTracing(tp trace.TracerProvider) zipfs.Option {
+	return zipfs.WithObserver(tracer{tp.Tracer(tracerName)})
+}
+
+// tracer is the zipfs.Observer of WithTracing.
+type tracer struct {
+	trace.Tracer
+}
+
+// StartRequest starts the span of the request.
+func (t tracer) StartRequest(r *http.Request, start time.Time) *http.Request {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, _ = t.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+	return r.WithContext(ctx)
+}
+
+// EndRequest adds the spans of the phases and ends the span of the
+// request.
+func (t tracer) EndRequest(r *http.Request, info zipfs.RequestInfo) {
+	ctx := r.Context()
+	if info.Entry {
+		start := info.Start
+		phase := func(name string, duration time.Duration) {
+			_, child := t.Start(ctx, name, trace.WithTimestamp(start))
+			start = start.Add(duration)
+			child.End(trace.WithTimestamp(start))
+		}
+		phase("lookup", info.Lookup)
+		phase("decompress", info.Decompress)
+		phase("write", info.Write)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(info.Status), semconv.HTTPResponseBodySize(int(info.Bytes)))
+	if info.Status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(info.Status))
+	}
+	span.End(trace.WithTimestamp(info.End))
+}
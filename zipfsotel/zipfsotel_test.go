@@ -0,0 +1,45 @@
+package zipfsotel_test
+
+import (
+	"testing"
+
+	"github.com/FlashpointProject/zipfs/zipfsotel"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestTracing(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	fs := zipfstest.NewArchive().File("test.txt", "hello").FileSystem(t)
+	h := zipfstest.Handler(t, fs, zipfsotel.WithTracing(tp))
+
+	zipfstest.Get(t, h, "/test.txt").AssertBody("hello")
+
+	spans := recorder.Ended()
+	require.Len(spans, 4)
+	request := spans[3]
+	assert.Equal("GET", request.Name())
+	assert.Contains(request.Attributes(), semconv.URLPath("/test.txt"))
+	assert.Contains(request.Attributes(), semconv.HTTPResponseStatusCode(200))
+	assert.Contains(request.Attributes(), semconv.HTTPResponseBodySize(len("hello")))
+	for i, name := range []string{"lookup", "decompress", "write"} {
+		assert.Equal(name, spans[i].Name())
+		assert.Equal(request.SpanContext().SpanID(), spans[i].Parent().SpanID())
+		assert.False(spans[i].StartTime().Before(request.StartTime()))
+		assert.False(spans[i].EndTime().After(request.EndTime()))
+	}
+
+	// Requests that are not for entries have no phases
+	zipfstest.Get(t, h, "/missing.txt").AssertStatus(404)
+	spans = recorder.Ended()
+	require.Len(spans, 5)
+	assert.Contains(spans[4].Attributes(), semconv.HTTPResponseStatusCode(404))
+}
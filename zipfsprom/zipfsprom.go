@@ -0,0 +1,129 @@
+// Package zipfsprom collects metrics of the file servers of package zipfs
+// for Prometheus. It is a package of its own so that programs that do
+// not export metrics do not depend on the Prometheus client.
+//
+//	h := zipfs.FileServerWithOptions(fs, zipfsprom.WithMetrics(prometheus.DefaultRegisterer))
+package zipfsprom
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// WithMetrics collects metrics of the file server for Prometheus: the
+// number of responses by status code, the number of body bytes served,
+// the lookups in each cache that were hits and misses, the time spent
+// decompressing entries and the number of mounted archives. The metrics
+// are registered with reg, which is then served as usual, for example
+// with promhttp.Handler for prometheus.DefaultRegisterer. If reg is nil,
+// they are registered with a registry of their own that is served by the
+// metrics API endpoint instead.
+//
+// The time spent decompressing is the time it took to serve an entry
+// after it was found, less the time spent writing to the client.
+func WithMetrics(reg prometheus.Registerer) zipfs.Option {
+	c := newCollector(reg)
+	if reg != nil {
+		return zipfs.WithObserver(c)
+	}
+	registry := prometheus.NewRegistry()
+	c.registerer = registry
+	return zipfs.WithObserver(servedCollector{
+		collector: c,
+		handler:   promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	})
+}
+
+// servedCollector is a collector whose registry is served by the metrics
+// API endpoint.
+type servedCollector struct {
+	*collector
+	handler http.Handler
+}
+
+func (c servedCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.handler.ServeHTTP(w, r)
+}
+
+// collector is the zipfs.Observer of WithMetrics.
+type collector struct {
+	registerer prometheus.Registerer
+	stats      func() zipfs.Stats
+
+	requests      *prometheus.CounterVec
+	bytesServed   prometheus.Counter
+	decompression prometheus.Histogram
+	cacheLookups  *prometheus.Desc
+	mounts        *prometheus.Desc
+}
+
+func newCollector(reg prometheus.Registerer) *collector {
+	return &collector{
+		registerer: reg,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zipfs_requests_total",
+			Help: "Number of responses by status code.",
+		}, []string{"code"}),
+		bytesServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "zipfs_response_bytes_total",
+			Help: "Number of body bytes served.",
+		}),
+		decompression: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "zipfs_decompression_seconds",
+			Help:    "Time spent decompressing entries to serve them.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+		cacheLookups: prometheus.NewDesc("zipfs_cache_lookups_total",
+			"Number of cache lookups by cache and result.",
+			[]string{"cache", "result"}, nil),
+		mounts: prometheus.NewDesc("zipfs_mounted_archives",
+			"Number of mounted archives.", nil, nil),
+	}
+}
+
+// Attach registers the metrics once the file server is configured, as
+// the caches are not known before.
+func (c *collector) Attach(stats func() zipfs.Stats) error {
+	c.stats = stats
+	return c.registerer.Register(c)
+}
+
+func (c *collector) StartRequest(r *http.Request, start time.Time) *http.Request {
+	return r
+}
+
+func (c *collector) EndRequest(r *http.Request, info zipfs.RequestInfo) {
+	c.requests.WithLabelValues(strconv.Itoa(info.Status)).Inc()
+	c.bytesServed.Add(float64(info.Bytes))
+	if info.Entry {
+		c.decompression.Observe(info.Decompress.Seconds())
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.bytesServed.Describe(ch)
+	c.decompression.Describe(ch)
+	ch <- c.mounts
+	ch <- c.cacheLookups
+}
+
+// Collect collects the metrics, reading the statistics of the file
+// server when they are collected.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.bytesServed.Collect(ch)
+	c.decompression.Collect(ch)
+
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.mounts, prometheus.GaugeValue, float64(stats.Mounts))
+	for _, cache := range stats.Caches {
+		ch <- prometheus.MustNewConstMetric(c.cacheLookups, prometheus.CounterValue, float64(cache.Hits), cache.Name, "hit")
+		ch <- prometheus.MustNewConstMetric(c.cacheLookups, prometheus.CounterValue, float64(cache.Misses), cache.Name, "miss")
+	}
+}
@@ -0,0 +1,61 @@
+package zipfsprom_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/FlashpointProject/zipfs/zipfsprom"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := filepath.Dir(zipfstest.NewArchive().File("a.txt", "hello").WriteFile(t, "a.zip"))
+	mount := func(h http.Handler) {
+		zipfstest.Do(t, h, "POST", "/api/mountzip", strings.NewReader(`{"filePath": "a.zip"}`)).AssertStatus(200)
+	}
+
+	reg := prometheus.NewRegistry()
+	h := zipfs.EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(),
+		zipfs.WithCache(zipfs.CacheConfig{MemoryLimit: 1 << 20, DecompressedLimit: 1 << 20}), zipfsprom.WithMetrics(reg))
+	mount(h)
+	zipfstest.Get(t, h, "/a.txt").AssertStatus(200)
+	zipfstest.Get(t, h, "/a.txt").AssertStatus(200)
+	zipfstest.Get(t, h, "/missing.txt").AssertStatus(404)
+	// Served by a registry of their own only
+	zipfstest.Get(t, h, "/api/metrics").AssertStatus(404)
+
+	expected := `
+# HELP zipfs_cache_lookups_total Number of cache lookups by cache and result.
+# TYPE zipfs_cache_lookups_total counter
+zipfs_cache_lookups_total{cache="memory",result="hit"} 1
+zipfs_cache_lookups_total{cache="memory",result="miss"} 1
+# HELP zipfs_mounted_archives Number of mounted archives.
+# TYPE zipfs_mounted_archives gauge
+zipfs_mounted_archives 1
+# HELP zipfs_requests_total Number of responses by status code.
+# TYPE zipfs_requests_total counter
+zipfs_requests_total{code="200"} 3
+zipfs_requests_total{code="404"} 2
+`
+	assert.NoError(testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"zipfs_cache_lookups_total", "zipfs_mounted_archives", "zipfs_requests_total"))
+	count, err := testutil.GatherAndCount(reg, "zipfs_decompression_seconds")
+	assert.NoError(err)
+	assert.Equal(1, count)
+
+	h = zipfs.EmptyFileServer("api/", "", false, nil, dir, "", nil, nil, t.TempDir(), zipfsprom.WithMetrics(nil))
+	mount(h)
+	zipfstest.Get(t, h, "/a.txt").AssertStatus(200)
+	w := zipfstest.Get(t, h, "/api/metrics").AssertStatus(200)
+	assert.Contains(w.Body.String(), `zipfs_requests_total{code="200"} 2`)
+	assert.Contains(w.Body.String(), "zipfs_response_bytes_total")
+	assert.Contains(w.Body.String(), "zipfs_mounted_archives 1")
+}
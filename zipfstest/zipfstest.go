@@ -0,0 +1,185 @@
+// Package zipfstest provides utilities for testing code that serves
+// content with package zipfs, without having to commit binary ZIP
+// fixtures to the repository.
+//
+// Archives are described in code and built in memory:
+//
+//	fs := zipfstest.NewArchive().
+//		File("index.html", "<h1>Hello</h1>").
+//		StoredFile("img/logo.png", logo).
+//		FileSystem(t)
+//
+//	h := zipfstest.Handler(t, fs)
+//	zipfstest.Get(t, h, "/").
+//		AssertStatus(http.StatusOK).
+//		AssertBody("<h1>Hello</h1>")
+package zipfstest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlashpointProject/zipfs"
+)
+
+// ModTime is the modification time given to every entry of an Archive.
+var ModTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type entry struct {
+	name    string
+	content []byte
+	method  uint16
+}
+
+// Archive describes a ZIP file to be built in memory.
+// The zero value is an empty archive.
+type Archive struct {
+	entries []entry
+}
+
+// NewArchive returns an empty archive.
+func NewArchive() *Archive {
+	return &Archive{}
+}
+
+// File adds a deflate-compressed file to the archive.
+func (a *Archive) File(name string, content string) *Archive {
+	a.entries = append(a.entries, entry{name: name, content: []byte(content), method: zip.Deflate})
+	return a
+}
+
+// StoredFile adds an uncompressed file to the archive.
+func (a *Archive) StoredFile(name string, content string) *Archive {
+	a.entries = append(a.entries, entry{name: name, content: []byte(content), method: zip.Store})
+	return a
+}
+
+// Dir adds an explicit directory entry to the archive.
+func (a *Archive) Dir(name string) *Archive {
+	if !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+	a.entries = append(a.entries, entry{name: name, method: zip.Store})
+	return a
+}
+
+// Bytes returns the contents of the ZIP file.
+func (a *Archive) Bytes(t testing.TB) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range a.entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     e.name,
+			Method:   e.method,
+			Modified: ModTime,
+		})
+		if err != nil {
+			t.Fatalf("zipfstest: cannot add %s: %v", e.name, err)
+		}
+		if _, err := w.Write(e.content); err != nil {
+			t.Fatalf("zipfstest: cannot write %s: %v", e.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zipfstest: cannot build archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// FileSystem opens the archive as a zipfs.FileSystem, which is closed
+// when the test finishes.
+func (a *Archive) FileSystem(t testing.TB) *zipfs.FileSystem {
+	t.Helper()
+
+	data := a.Bytes(t)
+	fs, err := zipfs.NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil, "zipfstest.zip")
+	if err != nil {
+		t.Fatalf("zipfstest: cannot open archive: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+// WriteFile writes the archive to a file called name in a temporary
+// directory and returns the path of the file. This is useful for
+// testing the mount API.
+func (a *Archive) WriteFile(t testing.TB, name string) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, a.Bytes(t), 0644); err != nil {
+		t.Fatalf("zipfstest: cannot write archive: %v", err)
+	}
+	return p
+}
+
+// Handler returns a zipfs.FileServer for fs with the API under /api/
+// and html and htm as index extensions.
+func Handler(t testing.TB, fs *zipfs.FileSystem, opts ...zipfs.Option) http.Handler {
+	t.Helper()
+	return zipfs.FileServer(fs, "api/", "", false, []string{"html", "htm"}, nil, opts...)
+}
+
+// Response is the recorded response to a request.
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// Do sends a request to handler and records the response. The headers
+// are given as alternating names and values.
+func Do(t testing.TB, handler http.Handler, method string, target string, body io.Reader, headers ...string) *Response {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Add(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return &Response{ResponseRecorder: w, t: t}
+}
+
+// Get sends a GET request to handler and records the response.
+func Get(t testing.TB, handler http.Handler, target string, headers ...string) *Response {
+	t.Helper()
+	return Do(t, handler, "GET", target, nil, headers...)
+}
+
+// AssertStatus reports an error if the response status is not status.
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Errorf("zipfstest: status: got %d, want %d", r.Code, status)
+	}
+	return r
+}
+
+// AssertBody reports an error if the response body is not body.
+func (r *Response) AssertBody(body string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); got != body {
+		r.t.Errorf("zipfstest: body: got %q, want %q", got, body)
+	}
+	return r
+}
+
+// AssertHeader reports an error if the response header key does not
+// have the given value. An empty value asserts that the header is absent.
+func (r *Response) AssertHeader(key string, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("zipfstest: header %s: got %q, want %q", key, got, value)
+	}
+	return r
+}
@@ -0,0 +1,75 @@
+package zipfstest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FlashpointProject/zipfs"
+	"github.com/FlashpointProject/zipfs/zipfstest"
+)
+
+func TestHandler(t *testing.T) {
+	fs := zipfstest.NewArchive().
+		File("index.html", "<h1>Hello</h1>").
+		StoredFile("data.bin", "0123456789").
+		Dir("empty").
+		FileSystem(t)
+
+	h := zipfstest.Handler(t, fs)
+
+	zipfstest.Get(t, h, "/").
+		AssertStatus(http.StatusOK).
+		AssertHeader("Content-Type", "text/html; charset=utf-8").
+		AssertBody("<h1>Hello</h1>")
+
+	zipfstest.Get(t, h, "/data.bin", "Range", "bytes=2-4").
+		AssertStatus(http.StatusPartialContent).
+		AssertBody("234")
+
+	zipfstest.Get(t, h, "/empty/").
+		AssertStatus(http.StatusForbidden)
+
+	zipfstest.Get(t, h, "/missing").
+		AssertStatus(http.StatusNotFound)
+}
+
+func TestMount(t *testing.T) {
+	zipPath := zipfstest.NewArchive().
+		File("hello.txt", "hello").
+		WriteFile(t, "pack.zip")
+
+	body, _ := json.Marshal(zipfs.Mount{FilePath: zipPath})
+
+	h := zipfs.EmptyFileServer("api/", "", false, nil, "/", "", nil, nil, t.TempDir())
+	zipfstest.Do(t, h, "POST", "/api/mountzip", bytes.NewReader(body)).
+		AssertStatus(http.StatusOK)
+
+	zipfstest.Get(t, h, "/hello.txt").
+		AssertStatus(http.StatusOK).
+		AssertBody("hello")
+}
+
+func TestClock(t *testing.T) {
+	fs := zipfstest.NewArchive().
+		File("hello.txt", "hello").
+		FileSystem(t)
+
+	// The archive entries are newer than the clock, so Last-Modified
+	// follows the clock.
+	clock := zipfstest.NewClock(zipfstest.ModTime.Add(-time.Hour))
+	h := zipfstest.Handler(t, fs, zipfs.WithClock(clock))
+
+	lastModified := clock.Now().UTC().Format(http.TimeFormat)
+	zipfstest.Get(t, h, "/hello.txt").
+		AssertStatus(http.StatusOK).
+		AssertHeader("Last-Modified", lastModified)
+
+	clock.Advance(2 * time.Hour)
+	zipfstest.Get(t, h, "/hello.txt").
+		AssertHeader("Last-Modified", zipfstest.ModTime.Format(http.TimeFormat))
+	zipfstest.Get(t, h, "/hello.txt", "If-Modified-Since", lastModified).
+		AssertStatus(http.StatusOK)
+}
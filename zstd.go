@@ -0,0 +1,186 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// WithZstd compresses entries with zstd for clients that accept the
+// zstd content-encoding, when the entry would otherwise be sent without
+// compression. Deflated entries that can be sent as they are, see
+// WithCompression, are still sent deflated unless the client gives zstd
+// a higher q-value. Range requests are served without compression.
+//
+// If cache is true and a disk cache is configured with WithCache, each
+// entry is compressed once and kept in the disk cache. Otherwise entries
+// are compressed while they are served. WithTranscodeCache keeps them
+// whatever cache is.
+func WithZstd(cache bool) Option {
+	return func(h *fileHandler) {
+		h.zstd = &zstdConfig{cache: cache}
+	}
+}
+
+type zstdConfig struct {
+	cache    bool
+	encoders sync.Pool
+}
+
+// encoder returns a zstd encoder writing to w. It must be returned with
+// putEncoder once it has been closed.
+func (c *zstdConfig) encoder(w io.Writer) *zstd.Encoder {
+	if enc, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return enc
+	}
+	// The options are valid, so NewWriter cannot fail
+	enc, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	return enc
+}
+
+func (c *zstdConfig) putEncoder(enc *zstd.Encoder) {
+	enc.Reset(nil)
+	c.encoders.Put(enc)
+}
+
+// compress writes the zstd compressed contents of fi to w.
+func (c *zstdConfig) compress(w io.Writer, fi *fileInfo) error {
+	reader, err := fi.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	enc := c.encoder(w)
+	defer c.putEncoder(enc)
+	if _, err := io.Copy(enc, reader); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
+
+// useZstd reports whether fi should be served compressed with zstd.
+func (h *fileHandler) useZstd(r *http.Request, fi *fileInfo) bool {
+	if h.zstd == nil || r.Header.Get("Range") != "" || (h.phpPath != "" && checkForPhp(fi.name)) {
+		return false
+	}
+	accept := parseAcceptEncoding(r)
+	if !accept.accepts("zstd") {
+		return false
+	}
+	// Deflated entries are sent as they are unless zstd is preferred
+	sendsDeflate := max(accept.quality("deflate"), accept.quality("gzip")) >= accept.quality("zstd")
+	return !(h.compression && fi.zipFile.Method == zip.Deflate && !fi.encrypted() && sendsDeflate)
+}
+
+// zstdEtag returns the ETag of the zstd compressed variant of an entry
+// with the given ETag.
+func zstdEtag(etag string) string {
+	return etag[:len(etag)-1] + `-zst"`
+}
+
+// serveZstd serves the zstd compressed contents of fi. The Etag and
+// Content-Type headers must already have been set.
+func (h *fileHandler) serveZstd(w http.ResponseWriter, r *http.Request, fs *FileSystem, fi *fileInfo) {
+	w.Header().Set("Content-Encoding", "zstd")
+
+	if h.serveTranscoded(w, r, fi, "zst", time.Time{}, func(dst io.Writer) error {
+		return h.zstd.compress(dst, fi)
+	}) {
+		return
+	}
+	if h.zstd.cache && h.diskCache != nil {
+		key := cacheKey{fs: fs, name: fi.fullName(), variant: w.Header().Get("Etag")}
+		file, temporary, err := h.diskCache.openFile(key, h.now(), func(dst io.Writer) error {
+			return h.zstd.compress(dst, fi)
+		})
+		if err != nil {
+			w.Header().Del("Content-Encoding")
+			recordError(r, fi.name, err)
+			msg, code := toHTTPError(err)
+			httpError(w, r, msg, code)
+			return
+		}
+		defer func() {
+			file.Close()
+			if temporary {
+				os.Remove(file.Name())
+			}
+		}()
+		// http.ServeContent leaves out the Content-Length of encoded
+		// responses, so the file is copied instead.
+		if stat, err := file.Stat(); err == nil {
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", stat.Size()))
+		}
+		if r.Method != "HEAD" {
+			io.Copy(w, file)
+		}
+		return
+	}
+
+	// The compressed size is not known until the entry has been
+	// compressed, so the response is sent chunked.
+	w.Header().Del("Content-Length")
+	if r.Method == "HEAD" {
+		return
+	}
+	sw, finish := h.streamWriter(w)
+	defer finish()
+	if err := h.zstd.compress(sw, fi); err != nil {
+		// Part of the response may have been sent, so all that can be
+		// done is to record the error, or abort the response so that
+		// it is not mistaken for the whole entry.
+		if h.verifyCRC && isCorrupt(err) {
+			h.abortCorrupt(r, fi, err)
+		}
+		recordError(r, fi.name, err)
+		return
+	}
+	h.logf("[Zipfs] Serving Zstd Compressed File: %s\n", fi.zipFile.Name)
+}
+
+// zstdDecoders holds idle decoders of zstd compressed entries.
+var zstdDecoders sync.Pool
+
+// decompressZstd is the zip.Decompressor of zstd compressed entries.
+func decompressZstd(r io.Reader) io.ReadCloser {
+	dec, ok := zstdDecoders.Get().(*zstd.Decoder)
+	var err error
+	if ok {
+		err = dec.Reset(r)
+	} else {
+		dec, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	}
+	return &zstdReader{dec: dec, err: err}
+}
+
+// zstdReader reads a zstd compressed entry, and returns its decoder to
+// zstdDecoders when closed.
+type zstdReader struct {
+	dec *zstd.Decoder
+	err error
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	return z.dec.Read(p)
+}
+
+func (z *zstdReader) Close() error {
+	if z.dec != nil && z.err == nil {
+		zstdDecoders.Put(z.dec)
+	}
+	z.dec = nil
+	z.err = os.ErrClosed
+	return nil
+}
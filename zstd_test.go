@@ -0,0 +1,125 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZstd(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := strings.Repeat("squeeze me ", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("page%d.txt", method), Method: method})
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+	}
+	require.NoError(zw.Close())
+	fs, err := NewFromBytes(buf.Bytes(), "test.zip")
+	require.NoError(err)
+	defer fs.Close()
+
+	decode := func(b []byte) string {
+		dec, err := zstd.NewReader(bytes.NewReader(b))
+		require.NoError(err)
+		defer dec.Close()
+		data, err := io.ReadAll(dec)
+		require.NoError(err)
+		return string(data)
+	}
+
+	cacheDir := t.TempDir()
+	for _, cache := range []bool{false, true} {
+		handler := FileServer(fs, "api/", "", false, nil, nil,
+			WithCompression(true),
+			WithCache(CacheConfig{DiskLimit: 1 << 20, DiskDir: cacheDir}),
+			WithZstd(cache),
+		)
+
+		w := serveTest(handler, "GET", "/page0.txt", "", "Accept-Encoding", "zstd")
+		require.Equal(200, w.status)
+		assert.Equal("zstd", w.Header().Get("Content-Encoding"))
+		assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+		assert.True(strings.HasSuffix(w.Header().Get("Etag"), `-zst"`))
+		assert.True(w.buf.Len() < len(content))
+		assert.Equal(content, decode(w.buf.Bytes()))
+		if cache {
+			assert.Equal(fmt.Sprint(w.buf.Len()), w.Header().Get("Content-Length"))
+		} else {
+			assert.Equal("", w.Header().Get("Content-Length"))
+		}
+		etag := w.Header().Get("Etag")
+
+		w = serveTest(handler, "GET", "/page0.txt", "", "Accept-Encoding", "zstd", "If-None-Match", etag)
+		assert.Equal(304, w.status)
+
+		// Deflated entries are still sent as they are
+		w = serveTest(handler, "GET", "/page8.txt", "", "Accept-Encoding", "deflate, zstd")
+		assert.Equal("deflate", w.Header().Get("Content-Encoding"))
+		w = serveTest(handler, "GET", "/page8.txt", "", "Accept-Encoding", "deflate;q=0.5, zstd")
+		assert.Equal("zstd", w.Header().Get("Content-Encoding"))
+		w = serveTest(handler, "GET", "/page0.txt", "", "Accept-Encoding", "gzip, zstd;q=0")
+		assert.NotEqual("zstd", w.Header().Get("Content-Encoding"))
+		w = serveTest(handler, "GET", "/page8.txt", "", "Accept-Encoding", "zstd")
+		assert.Equal("zstd", w.Header().Get("Content-Encoding"))
+		assert.Equal(content, decode(w.buf.Bytes()))
+
+		// Other clients and range requests are served without compression
+		w = serveTest(handler, "GET", "/page0.txt", "")
+		assert.Equal("", w.Header().Get("Content-Encoding"))
+		assert.Equal(content, w.buf.String())
+		assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+		w = serveTest(handler, "GET", "/page0.txt", "", "Accept-Encoding", "zstd", "Range", "bytes=0-5")
+		assert.Equal(206, w.status)
+		assert.Equal("", w.Header().Get("Content-Encoding"))
+		assert.Equal(content[:6], w.buf.String())
+	}
+}
+
+func TestZstdEntries(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	long := strings.Repeat("zstd compressed entry ", 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.RegisterCompressor(methodZstd, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	for _, name := range []string{"a.txt", "b.txt"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: methodZstd})
+		require.NoError(err)
+		w.Write([]byte(long))
+	}
+	require.NoError(zw.Close())
+
+	fs, err := NewFromBytes(buf.Bytes(), "zstd.zip")
+	require.NoError(err)
+	defer fs.Close()
+	assert.NoError(fs.Verify(2))
+	for i := 0; i < 3; i++ {
+		f, err := fs.Open("b.txt")
+		require.NoError(err)
+		data, err := io.ReadAll(f)
+		assert.NoError(err)
+		assert.Equal(long, string(data))
+		f.Close()
+	}
+
+	handler := FileServer(fs, "api/", "", false, nil, nil)
+	w := serveTest(handler, "GET", "/a.txt", "", "Range", "bytes=5-14")
+	assert.Equal(206, w.status)
+	assert.Equal(long[5:15], w.buf.String())
+}